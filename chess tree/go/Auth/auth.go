@@ -0,0 +1,81 @@
+// Package auth checks the JWTs the socket service issues and tells which of
+// its accounts a token is for.
+package auth
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrNoAccount = errors.New("auth: the token names no account")
+
+// Config says how tokens are signed, with Secret (HMAC) or the keys served
+// at JWKSURL, and the claim holding the account id, "id" in the tokens of
+// the socket service. Issuer and Audience are checked when set.
+type Config struct {
+	Secret    string
+	JWKSURL   string
+	Issuer    string
+	Audience  string
+	UserClaim string
+}
+
+// Verifier validates tokens against a Config.
+type Verifier struct {
+	parser  *jwt.Parser
+	keyfunc jwt.Keyfunc
+	claim   string
+}
+
+func New(cfg Config) (*Verifier, error) {
+	v := &Verifier{claim: cfg.UserClaim}
+	if v.claim == "" {
+		v.claim = "id"
+	}
+
+	var methods []string
+	switch {
+	case cfg.Secret != "" && cfg.JWKSURL != "":
+		return nil, errors.New("auth: set a secret or a JWKS url, not both")
+	case cfg.Secret != "":
+		methods = []string{"HS256", "HS384", "HS512"}
+		secret := []byte(cfg.Secret)
+		v.keyfunc = func(*jwt.Token) (any, error) {
+			return secret, nil
+		}
+	case cfg.JWKSURL != "":
+		methods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+		keys := newJWKS(cfg.JWKSURL)
+		v.keyfunc = func(token *jwt.Token) (any, error) {
+			kid, _ := token.Header["kid"].(string)
+			return keys.key(kid)
+		}
+	default:
+		return nil, errors.New("auth: no secret or JWKS url")
+	}
+
+	options := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(options...)
+	return v, nil
+}
+
+// Account validates token and returns the id of the account it is for.
+func (v *Verifier) Account(token string) (string, error) {
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.keyfunc); err != nil {
+		return "", fmt.Errorf("auth: %w", err)
+	}
+	account, _ := claims[v.claim].(string)
+	if account == "" {
+		return "", ErrNoAccount
+	}
+	return account, nil
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksMaxAge is how long the keys are used before fetching them again.
+	jwksMaxAge = time.Hour
+	// jwksMinRefresh bounds how often an unknown kid refetches the keys, a
+	// flood of forged tokens would otherwise hammer the issuer.
+	jwksMinRefresh = time.Minute
+)
+
+var errUnknownKey = errors.New("auth: unknown signing key")
+
+// jwks caches the public keys of a JSON Web Key Set by kid.
+type jwks struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]any
+	fetched time.Time
+}
+
+func newJWKS(url string) *jwks {
+	return &jwks{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (k *jwks) key(kid string) (any, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	key, known := k.keys[kid]
+	age := time.Since(k.fetched)
+	if (known && age < jwksMaxAge) || (!known && age < jwksMinRefresh) {
+		if !known {
+			return nil, errUnknownKey
+		}
+		return key, nil
+	}
+
+	keys, err := k.fetch()
+	if err != nil {
+		if known {
+			// the issuer being down does not lock everyone out
+			return key, nil
+		}
+		return nil, err
+	}
+	k.keys, k.fetched = keys, time.Now()
+	if key, known = keys[kid]; !known {
+		return nil, errUnknownKey
+	}
+	return key, nil
+}
+
+func (k *jwks) fetch() (map[string]any, error) {
+	response, err := k.client.Get(k.url)
+	if err != nil {
+		return nil, fmt.Errorf("auth: fetching the JWKS: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth: fetching the JWKS: status %d", response.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("auth: decoding the JWKS: %w", err)
+	}
+	keys := make(map[string]any, len(set.Keys))
+	for _, j := range set.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		key, err := j.publicKey()
+		if err != nil {
+			// a key of a kind we do not use is no reason to drop the others
+			continue
+		}
+		keys[j.Kid] = key
+	}
+	return keys, nil
+}
+
+// jwk is a public key of a JWKS, RSA or elliptic curve.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (j jwk) publicKey() (any, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := decodeInt(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("auth: unsupported curve %q", j.Crv)
+		}
+		x, err := decodeInt(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("auth: unsupported key type %q", j.Kty)
+}
+
+func decodeInt(raw string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("auth: invalid key: %w", err)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS game_positions;
+DROP TABLE IF EXISTS move_tree;
+DROP TABLE IF EXISTS position_stats;
+DROP TABLE IF EXISTS games;
+DROP TABLE IF EXISTS users;
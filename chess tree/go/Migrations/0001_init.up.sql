@@ -16,7 +16,7 @@ CREATE TABLE IF NOT EXISTS games (
     white_elo INT,
     black_elo INT,
     result TEXT NOT NULL,
-    time_class TEXT NOT NULL CHECK (time_class IN ('blitz', 'rapid', 'bullet')),
+    time_class TEXT NOT NULL CHECK (time_class IN ('blitz', 'rapid', 'bullet', 'daily')),
     time_control TEXT,
     pgn TEXT NOT NULL,
     played_at TIMESTAMPTZ NOT NULL,
@@ -36,7 +36,7 @@ CREATE TABLE IF NOT EXISTS position_stats (
     fen TEXT NOT NULL,
     user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     color TEXT NOT NULL CHECK (color IN ('white', 'black')),
-    time_class TEXT NOT NULL CHECK (time_class IN ('blitz', 'rapid', 'bullet')),
+    time_class TEXT NOT NULL CHECK (time_class IN ('blitz', 'rapid', 'bullet', 'daily')),
     win_count INT DEFAULT 0,
     loss_count INT DEFAULT 0,
     draw_count INT DEFAULT 0,
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS move_edges;
+
+CREATE TABLE IF NOT EXISTS move_tree (
+    fen TEXT PRIMARY KEY,
+    parent_fen TEXT,
+    move_number INT NOT NULL,
+    last_move_san TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_parent_lookup ON move_tree(parent_fen);
+CREATE INDEX IF NOT EXISTS idx_move_number ON move_tree(move_number);
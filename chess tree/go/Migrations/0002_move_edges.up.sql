@@ -0,0 +1,24 @@
+-- move_tree kept a single parent per position and no user, so a position
+-- reached by two move orders lost one of them. Edges are keyed by the
+-- position key (the first four FEN fields) so transpositions meet on the
+-- same node, and carry the counts of the games that played them.
+DROP TABLE IF EXISTS move_tree;
+
+CREATE TABLE IF NOT EXISTS move_edges (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    color TEXT NOT NULL CHECK (color IN ('white', 'black')),
+    time_class TEXT NOT NULL CHECK (time_class IN ('blitz', 'rapid', 'bullet', 'daily')),
+    parent_key TEXT NOT NULL,
+    move_san TEXT NOT NULL,
+    child_key TEXT NOT NULL,
+    child_fen TEXT NOT NULL,
+    win_count INT NOT NULL DEFAULT 0,
+    loss_count INT NOT NULL DEFAULT 0,
+    draw_count INT NOT NULL DEFAULT 0,
+    game_count INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, color, time_class, parent_key, move_san)
+);
+
+CREATE INDEX IF NOT EXISTS idx_edge_children ON move_edges(user_id, parent_key);
+CREATE INDEX IF NOT EXISTS idx_edge_parents ON move_edges(user_id, child_key);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS rebuild_game_positions;
+DROP TABLE IF EXISTS rebuild_move_edges;
+DROP TABLE IF EXISTS rebuild_position_stats;
@@ -0,0 +1,38 @@
+-- Shadow tables the rebuild fills from games.pgn before swapping the rows
+-- into position_stats, move_edges and game_positions, see Store/rebuild.go.
+-- They hold nothing between rebuilds.
+CREATE UNLOGGED TABLE IF NOT EXISTS rebuild_position_stats (
+    fen TEXT NOT NULL,
+    user_id UUID NOT NULL,
+    color TEXT NOT NULL,
+    time_class TEXT NOT NULL,
+    win_count INT NOT NULL DEFAULT 0,
+    loss_count INT NOT NULL DEFAULT 0,
+    draw_count INT NOT NULL DEFAULT 0,
+    game_count INT NOT NULL DEFAULT 0,
+    latest_game_id UUID,
+    latest_played_at TIMESTAMPTZ,
+    UNIQUE(fen, user_id, color, time_class)
+);
+
+CREATE UNLOGGED TABLE IF NOT EXISTS rebuild_move_edges (
+    user_id UUID NOT NULL,
+    color TEXT NOT NULL,
+    time_class TEXT NOT NULL,
+    parent_key TEXT NOT NULL,
+    move_san TEXT NOT NULL,
+    child_key TEXT NOT NULL,
+    child_fen TEXT NOT NULL,
+    win_count INT NOT NULL DEFAULT 0,
+    loss_count INT NOT NULL DEFAULT 0,
+    draw_count INT NOT NULL DEFAULT 0,
+    game_count INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, color, time_class, parent_key, move_san)
+);
+
+CREATE UNLOGGED TABLE IF NOT EXISTS rebuild_game_positions (
+    game_id UUID NOT NULL,
+    fen TEXT NOT NULL,
+    move_number INT NOT NULL,
+    PRIMARY KEY (game_id, move_number)
+);
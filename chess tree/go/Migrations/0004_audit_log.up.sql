@@ -0,0 +1,12 @@
+-- Audit trail of the destructive operations on a user's data. It does not
+-- reference users so the records outlive the user they are about.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    action TEXT NOT NULL,
+    username TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    game_count INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_username ON audit_log(username, created_at DESC);
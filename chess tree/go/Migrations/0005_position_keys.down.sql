@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_position_key_games;
+DROP INDEX IF EXISTS idx_position_key;
+ALTER TABLE game_positions DROP COLUMN IF EXISTS position_key;
+ALTER TABLE position_stats DROP COLUMN IF EXISTS position_key;
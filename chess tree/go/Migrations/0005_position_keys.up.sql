@@ -0,0 +1,13 @@
+-- The explorer looks positions up by their key, the FEN without the halfmove
+-- clock and move number, the same key move_edges uses. The key is derived
+-- from fen so the writers do not have to set it.
+ALTER TABLE position_stats
+    ADD COLUMN IF NOT EXISTS position_key TEXT
+    GENERATED ALWAYS AS (regexp_replace(fen, ' \S+ \S+$', '')) STORED;
+
+ALTER TABLE game_positions
+    ADD COLUMN IF NOT EXISTS position_key TEXT
+    GENERATED ALWAYS AS (regexp_replace(fen, ' \S+ \S+$', '')) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_position_key ON position_stats(user_id, position_key);
+CREATE INDEX IF NOT EXISTS idx_position_key_games ON game_positions(position_key);
@@ -0,0 +1,19 @@
+-- One row per run of the pipeline over a user's games, the Node backend's
+-- ProcessingLog.
+CREATE TABLE IF NOT EXISTS processing_log (
+    id BIGSERIAL PRIMARY KEY,
+    username TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('processing', 'completed', 'failed')),
+    source_file TEXT,
+    api_endpoint TEXT,
+    games_from_date TIMESTAMPTZ,
+    games_to_date TIMESTAMPTZ,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    completed_at TIMESTAMPTZ,
+    total_games INT NOT NULL DEFAULT 0,
+    success_count INT NOT NULL DEFAULT 0,
+    error_count INT NOT NULL DEFAULT 0,
+    error_message TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_processing_username ON processing_log(username, started_at DESC);
@@ -0,0 +1,18 @@
+-- Background syncs of a user's chess.com games, kept once done as their
+-- history. errors holds the failures of single games as a JSON array.
+CREATE TABLE IF NOT EXISTS sync_jobs (
+    id UUID PRIMARY KEY,
+    username TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('queued', 'running', 'completed', 'failed')),
+    archives INT NOT NULL DEFAULT 0,
+    fetched_games INT NOT NULL DEFAULT 0,
+    processed_games INT NOT NULL DEFAULT 0,
+    failed_games INT NOT NULL DEFAULT 0,
+    errors JSONB NOT NULL DEFAULT '[]',
+    error_message TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_jobs_username ON sync_jobs(username, created_at DESC);
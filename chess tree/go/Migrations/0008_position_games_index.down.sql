@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_position_key_games ON game_positions(position_key);
+DROP INDEX IF EXISTS idx_position_key_game;
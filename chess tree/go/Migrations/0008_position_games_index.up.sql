@@ -0,0 +1,4 @@
+-- Lists the games behind a position from the index alone, grouping them by
+-- game and taking their first ply. It covers idx_position_key_games.
+CREATE INDEX IF NOT EXISTS idx_position_key_game ON game_positions(position_key, game_id, move_number);
+DROP INDEX IF EXISTS idx_position_key_games;
@@ -0,0 +1,3 @@
+-- Whether the game was rated, for the explorer filters. It is NULL for the
+-- games stored before, which neither the rated nor the casual filter keeps.
+ALTER TABLE games ADD COLUMN IF NOT EXISTS rated BOOLEAN;
@@ -0,0 +1,2 @@
+-- Whether a user lets everyone read their tree, only they can otherwise.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS shared BOOLEAN NOT NULL DEFAULT false;
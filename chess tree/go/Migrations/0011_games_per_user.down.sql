@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN IF EXISTS last_archive;
+DROP INDEX IF EXISTS idx_games_user_link;
+ALTER TABLE games ADD CONSTRAINT games_link_key UNIQUE (link);
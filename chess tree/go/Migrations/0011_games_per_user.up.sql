@@ -0,0 +1,6 @@
+-- A game is kept once per user rather than once, two synced users who played
+-- each other both keep it under an id of their own. The last archive synced
+-- lets a sync skip the months it already fetched.
+ALTER TABLE games DROP CONSTRAINT IF EXISTS games_link_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_games_user_link ON games(user_id, link);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_archive TEXT;
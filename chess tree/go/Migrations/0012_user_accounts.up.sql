@@ -0,0 +1,4 @@
+-- The account of the socket service a user signs in with, the id its tokens
+-- carry, linked to the chess.com user they explore.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS account_id TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_account ON users(account_id);
@@ -0,0 +1,79 @@
+-- SQLite version of the Postgres schema for the single user local mode. UUIDs
+-- are stored as text and timestamps as RFC 3339 text.
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY,
+    chess_com_username TEXT UNIQUE NOT NULL,
+    created_at TEXT DEFAULT CURRENT_TIMESTAMP,
+    updated_at TEXT DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS games (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    link TEXT NOT NULL UNIQUE,
+    white_username TEXT NOT NULL,
+    black_username TEXT NOT NULL,
+    white_elo INTEGER,
+    black_elo INTEGER,
+    result TEXT NOT NULL,
+    time_class TEXT NOT NULL CHECK (time_class IN ('blitz', 'rapid', 'bullet', 'daily')),
+    time_control TEXT,
+    pgn TEXT NOT NULL,
+    played_at TEXT NOT NULL,
+    eco TEXT,
+    termination TEXT,
+    white_accuracy REAL,
+    black_accuracy REAL,
+    created_at TEXT DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_games ON games(user_id, played_at DESC);
+CREATE INDEX IF NOT EXISTS idx_time_class ON games(user_id, time_class);
+
+CREATE TABLE IF NOT EXISTS position_stats (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    fen TEXT NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    color TEXT NOT NULL CHECK (color IN ('white', 'black')),
+    time_class TEXT NOT NULL CHECK (time_class IN ('blitz', 'rapid', 'bullet', 'daily')),
+    win_count INTEGER NOT NULL DEFAULT 0,
+    loss_count INTEGER NOT NULL DEFAULT 0,
+    draw_count INTEGER NOT NULL DEFAULT 0,
+    game_count INTEGER NOT NULL DEFAULT 0,
+    latest_game_id TEXT REFERENCES games(id),
+    latest_played_at TEXT,
+    created_at TEXT DEFAULT CURRENT_TIMESTAMP,
+    updated_at TEXT DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(fen, user_id, color, time_class)
+);
+
+CREATE INDEX IF NOT EXISTS idx_position_lookup ON position_stats(user_id, fen, color, time_class);
+CREATE INDEX IF NOT EXISTS idx_latest_game ON position_stats(user_id, latest_played_at DESC);
+
+CREATE TABLE IF NOT EXISTS move_edges (
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    color TEXT NOT NULL CHECK (color IN ('white', 'black')),
+    time_class TEXT NOT NULL CHECK (time_class IN ('blitz', 'rapid', 'bullet', 'daily')),
+    parent_key TEXT NOT NULL,
+    move_san TEXT NOT NULL,
+    child_key TEXT NOT NULL,
+    child_fen TEXT NOT NULL,
+    win_count INTEGER NOT NULL DEFAULT 0,
+    loss_count INTEGER NOT NULL DEFAULT 0,
+    draw_count INTEGER NOT NULL DEFAULT 0,
+    game_count INTEGER NOT NULL DEFAULT 0,
+    updated_at TEXT DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, color, time_class, parent_key, move_san)
+);
+
+CREATE INDEX IF NOT EXISTS idx_edge_children ON move_edges(user_id, parent_key);
+CREATE INDEX IF NOT EXISTS idx_edge_parents ON move_edges(user_id, child_key);
+
+CREATE TABLE IF NOT EXISTS game_positions (
+    game_id TEXT NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+    fen TEXT NOT NULL,
+    move_number INTEGER NOT NULL,
+    PRIMARY KEY (game_id, move_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_position_games ON game_positions(fen);
@@ -0,0 +1,10 @@
+-- See 0005_position_keys of the Postgres schema. SQLite can only add virtual
+-- generated columns, the trims drop the move number and the halfmove clock.
+ALTER TABLE position_stats ADD COLUMN position_key TEXT
+    GENERATED ALWAYS AS (rtrim(rtrim(rtrim(rtrim(fen, '0123456789'), ' '), '0123456789'), ' ')) VIRTUAL;
+
+ALTER TABLE game_positions ADD COLUMN position_key TEXT
+    GENERATED ALWAYS AS (rtrim(rtrim(rtrim(rtrim(fen, '0123456789'), ' '), '0123456789'), ' ')) VIRTUAL;
+
+CREATE INDEX IF NOT EXISTS idx_position_key ON position_stats(user_id, position_key);
+CREATE INDEX IF NOT EXISTS idx_position_key_games ON game_positions(position_key);
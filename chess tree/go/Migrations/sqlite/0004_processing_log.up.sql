@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS processing_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('processing', 'completed', 'failed')),
+    source_file TEXT,
+    api_endpoint TEXT,
+    games_from_date TEXT,
+    games_to_date TEXT,
+    started_at TEXT NOT NULL,
+    completed_at TEXT,
+    total_games INTEGER NOT NULL DEFAULT 0,
+    success_count INTEGER NOT NULL DEFAULT 0,
+    error_count INTEGER NOT NULL DEFAULT 0,
+    error_message TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_processing_username ON processing_log(username, started_at DESC);
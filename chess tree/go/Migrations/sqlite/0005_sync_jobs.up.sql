@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS sync_jobs (
+    id TEXT PRIMARY KEY,
+    username TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('queued', 'running', 'completed', 'failed')),
+    archives INTEGER NOT NULL DEFAULT 0,
+    fetched_games INTEGER NOT NULL DEFAULT 0,
+    processed_games INTEGER NOT NULL DEFAULT 0,
+    failed_games INTEGER NOT NULL DEFAULT 0,
+    errors TEXT NOT NULL DEFAULT '[]',
+    error_message TEXT,
+    created_at TEXT NOT NULL,
+    started_at TEXT,
+    finished_at TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_jobs_username ON sync_jobs(username, created_at DESC);
@@ -0,0 +1,2 @@
+-- Whether a user lets everyone read their tree, only they can otherwise.
+ALTER TABLE users ADD COLUMN shared INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,35 @@
+ALTER TABLE users DROP COLUMN last_archive;
+
+CREATE TABLE games_per_link (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    link TEXT NOT NULL UNIQUE,
+    white_username TEXT NOT NULL,
+    black_username TEXT NOT NULL,
+    white_elo INTEGER,
+    black_elo INTEGER,
+    result TEXT NOT NULL,
+    time_class TEXT NOT NULL CHECK (time_class IN ('blitz', 'rapid', 'bullet', 'daily')),
+    time_control TEXT,
+    pgn TEXT NOT NULL,
+    played_at TEXT NOT NULL,
+    eco TEXT,
+    termination TEXT,
+    white_accuracy REAL,
+    black_accuracy REAL,
+    created_at TEXT DEFAULT CURRENT_TIMESTAMP,
+    rated INTEGER
+);
+INSERT OR IGNORE INTO games_per_link (
+    id, user_id, link, white_username, black_username, white_elo, black_elo,
+    result, time_class, time_control, pgn, played_at, eco, termination,
+    white_accuracy, black_accuracy, created_at, rated
+)
+SELECT id, user_id, link, white_username, black_username, white_elo, black_elo,
+       result, time_class, time_control, pgn, played_at, eco, termination,
+       white_accuracy, black_accuracy, created_at, rated
+FROM games;
+DROP TABLE games;
+ALTER TABLE games_per_link RENAME TO games;
+CREATE INDEX IF NOT EXISTS idx_user_games ON games(user_id, played_at DESC);
+CREATE INDEX IF NOT EXISTS idx_time_class ON games(user_id, time_class);
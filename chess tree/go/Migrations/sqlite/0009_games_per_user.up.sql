@@ -0,0 +1,40 @@
+-- A game is kept once per user rather than once, two synced users who played
+-- each other both keep it under an id of their own. SQLite cannot drop the
+-- UNIQUE of a column, games is copied into a table without it. The last
+-- archive synced lets a sync skip the months it already fetched.
+CREATE TABLE games_per_user (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    link TEXT NOT NULL,
+    white_username TEXT NOT NULL,
+    black_username TEXT NOT NULL,
+    white_elo INTEGER,
+    black_elo INTEGER,
+    result TEXT NOT NULL,
+    time_class TEXT NOT NULL CHECK (time_class IN ('blitz', 'rapid', 'bullet', 'daily')),
+    time_control TEXT,
+    pgn TEXT NOT NULL,
+    played_at TEXT NOT NULL,
+    eco TEXT,
+    termination TEXT,
+    white_accuracy REAL,
+    black_accuracy REAL,
+    created_at TEXT DEFAULT CURRENT_TIMESTAMP,
+    rated INTEGER,
+    UNIQUE (user_id, link)
+);
+INSERT INTO games_per_user (
+    id, user_id, link, white_username, black_username, white_elo, black_elo,
+    result, time_class, time_control, pgn, played_at, eco, termination,
+    white_accuracy, black_accuracy, created_at, rated
+)
+SELECT id, user_id, link, white_username, black_username, white_elo, black_elo,
+       result, time_class, time_control, pgn, played_at, eco, termination,
+       white_accuracy, black_accuracy, created_at, rated
+FROM games;
+DROP TABLE games;
+ALTER TABLE games_per_user RENAME TO games;
+CREATE INDEX IF NOT EXISTS idx_user_games ON games(user_id, played_at DESC);
+CREATE INDEX IF NOT EXISTS idx_time_class ON games(user_id, time_class);
+
+ALTER TABLE users ADD COLUMN last_archive TEXT;
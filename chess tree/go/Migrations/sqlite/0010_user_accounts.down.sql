@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_users_account;
+ALTER TABLE users DROP COLUMN account_id;
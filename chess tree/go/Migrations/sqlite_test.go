@@ -0,0 +1,91 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// TestSQLiteGamesPerUser upgrades a database holding a game to the games kept
+// per user, the positions of the game surviving the copy of its table.
+func TestSQLiteGamesPerUser(t *testing.T) {
+	ctx := context.Background()
+	dsn := "file:" + filepath.Join(t.TempDir(), "test.db") + "?_pragma=foreign_keys(1)"
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+
+	all, err := load("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range all {
+		if m.Version >= 9 {
+			break
+		}
+		if err := applySQLite(ctx, conn, m); err != nil {
+			t.Fatalf("%04d_%s: %v", m.Version, m.Name, err)
+		}
+	}
+
+	for _, stmt := range []string{
+		`INSERT INTO users (id, chess_com_username) VALUES ('u1', 'alice'), ('u2', 'bob')`,
+		`INSERT INTO games (id, user_id, link, white_username, black_username, result, time_class, pgn, played_at)
+		 VALUES ('g1', 'u1', 'https://www.chess.com/game/live/1', 'alice', 'bob', '1-0', 'blitz', '*', '2026-01-05T00:00:00Z')`,
+		`INSERT INTO game_positions (game_id, fen, move_number) VALUES ('g1', 'start', 0)`,
+	} {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done, err := UpSQLite(ctx, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) == 0 || done[0].Version != 9 {
+		t.Fatalf("applied %+v, want 0009 first", done)
+	}
+
+	var positions int
+	if err := conn.QueryRowContext(ctx, `SELECT count(*) FROM game_positions`).Scan(&positions); err != nil {
+		t.Fatal(err)
+	}
+	if positions != 1 {
+		t.Errorf("%d game positions left, want 1", positions)
+	}
+	// the other player keeps the same game
+	_, err = conn.ExecContext(ctx, `INSERT INTO games (id, user_id, link, white_username, black_username, result, time_class, pgn, played_at)
+		VALUES ('g2', 'u2', 'https://www.chess.com/game/live/1', 'alice', 'bob', '1-0', 'blitz', '*', '2026-01-05T00:00:00Z')`)
+	if err != nil {
+		t.Errorf("the opponent could not keep the game: %v", err)
+	}
+	_, err = conn.ExecContext(ctx, `INSERT INTO games (id, user_id, link, white_username, black_username, result, time_class, pgn, played_at)
+		VALUES ('g3', 'u1', 'https://www.chess.com/game/live/1', 'alice', 'bob', '1-0', 'blitz', '*', '2026-01-05T00:00:00Z')`)
+	if err == nil {
+		t.Error("a user kept the same game twice")
+	}
+	// the games still cascade to their positions
+	if _, err := conn.ExecContext(ctx, `DELETE FROM users WHERE id = 'u1'`); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.QueryRowContext(ctx, `SELECT count(*) FROM game_positions`).Scan(&positions); err != nil {
+		t.Fatal(err)
+	}
+	if positions != 0 {
+		t.Errorf("deleting the user left %d game positions", positions)
+	}
+}
@@ -0,0 +1,154 @@
+package polyglot
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"chess/ProcessPipline"
+	"chess/Types"
+	lib "github.com/notnil/chess"
+)
+
+var (
+	ErrBookSize = errors.New("polyglot: book size is not a multiple of 16 bytes")
+	ErrColor    = errors.New("polyglot: color must be white or black")
+)
+
+// Book holds the entries of a Polyglot file grouped by position key.
+type Book map[uint64][]Entry
+
+func ReadBook(r io.Reader) (Book, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%16 != 0 {
+		return nil, ErrBookSize
+	}
+
+	book := make(Book)
+	for off := 0; off < len(data); off += 16 {
+		e := Entry{
+			Key:    binary.BigEndian.Uint64(data[off:]),
+			Move:   binary.BigEndian.Uint16(data[off+8:]),
+			Weight: binary.BigEndian.Uint16(data[off+10:]),
+			Learn:  binary.BigEndian.Uint32(data[off+12:]),
+		}
+		book[e.Key] = append(book[e.Key], e)
+	}
+	return book, nil
+}
+
+func ReadBookFile(path string) (Book, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadBook(f)
+}
+
+// DecodeMove finds the legal move of pos matching a Polyglot encoded move.
+func DecodeMove(pos *lib.Position, move uint16) (*lib.Move, error) {
+	for _, m := range pos.ValidMoves() {
+		if EncodeMove(m) == move {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("polyglot: move %#04x is not legal in %s", move, pos)
+}
+
+// Annotate checks the moves the user chose against the book. tree must only
+// hold the games the user played with color, only the moves of that side are
+// then looked at, the others being the opponents' answers.
+func Annotate(tree map[string]*types.PositonInfo, book Book, color string) ([]types.BookAnnotation, error) {
+	if color != "white" && color != "black" {
+		return nil, ErrColor
+	}
+	annotations := []types.BookAnnotation{}
+	for fen, info := range tree {
+		if len(info.Moves) == 0 {
+			continue
+		}
+		pos, err := position(fen)
+		if err != nil {
+			return nil, err
+		}
+		if color != colorName(pos.Turn()) {
+			continue
+		}
+
+		entries := book[Key(pos)]
+		bookMoves := make([]types.BookMove, 0, len(entries))
+		bookCodes := make([]uint16, 0, len(entries))
+		for _, e := range entries {
+			m, err := DecodeMove(pos, e.Move)
+			if err != nil {
+				// books built for other variants or with hash collisions
+				// carry moves that are illegal here, they are not ours
+				continue
+			}
+			bookMoves = append(bookMoves, types.BookMove{
+				Move:   lib.AlgebraicNotation{}.Encode(pos, m),
+				Weight: e.Weight,
+			})
+			bookCodes = append(bookCodes, e.Move)
+		}
+
+		for san, move := range info.Moves {
+			m, err := lib.AlgebraicNotation{}.Decode(pos, san)
+			if err != nil {
+				return nil, fmt.Errorf("polyglot: move %s in %s: %w", san, fen, err)
+			}
+			annotation := types.BookAnnotation{
+				Fen:       fen,
+				Move:      san,
+				Games:     move.Count,
+				Score:     Processpipline.MoveScore(move),
+				BookMoves: bookMoves,
+			}
+			code := EncodeMove(m)
+			for i, bookCode := range bookCodes {
+				if bookCode == code {
+					annotation.InBook = true
+					annotation.BookWeight = bookMoves[i].Weight
+					break
+				}
+			}
+			annotations = append(annotations, annotation)
+		}
+	}
+
+	sort.Slice(annotations, func(i, j int) bool {
+		if annotations[i].Games != annotations[j].Games {
+			return annotations[i].Games > annotations[j].Games
+		}
+		if annotations[i].Fen != annotations[j].Fen {
+			return annotations[i].Fen < annotations[j].Fen
+		}
+		return annotations[i].Move < annotations[j].Move
+	})
+	return annotations, nil
+}
+
+// OutOfBook keeps the moves that leave the book from a position the book
+// still knows, those are the home made lines. Positions the book has no entry
+// for are skipped since the deviation happened earlier. Annotations come
+// sorted by games from Annotate so the result is the most played first.
+func OutOfBook(annotations []types.BookAnnotation, minGames, limit int) []types.BookAnnotation {
+	report := []types.BookAnnotation{}
+	for _, a := range annotations {
+		if a.InBook || len(a.BookMoves) == 0 || a.Games < minGames {
+			continue
+		}
+		report = append(report, a)
+		if limit > 0 && len(report) == limit {
+			break
+		}
+	}
+	return report
+}
@@ -0,0 +1,122 @@
+package polyglot
+
+import (
+	"fmt"
+
+	"chess/ProcessPipline"
+	"chess/Types"
+	lib "github.com/notnil/chess"
+)
+
+const (
+	WeightGames = "games"
+	WeightScore = "score"
+)
+
+type ExportOptions struct {
+	// Color keeps only the moves played by that side, "white" or "black",
+	// the opponents' answers are walked but not exported. The tree must only
+	// hold the games the user played with Color, as for Annotate. Empty
+	// exports both sides.
+	Color string
+	// MinGames drops moves played fewer times.
+	MinGames int
+	// MaxDepth is the number of plies from the start to export, 0 for all.
+	MaxDepth int
+	// Weight is WeightGames to weigh moves by how often they were played or
+	// WeightScore for 2 points per win and 1 per draw.
+	Weight string
+}
+
+// Export walks the tree from the start position along its moves and returns
+// one book entry per move that passes the filters. Positions are visited once
+// even when transpositions reach them through several move orders.
+func Export(tree map[string]*types.PositonInfo, opts ExportOptions) ([]Entry, error) {
+	switch opts.Color {
+	case "", "white", "black":
+	default:
+		return nil, fmt.Errorf("polyglot: invalid color %q", opts.Color)
+	}
+	switch opts.Weight {
+	case "":
+		opts.Weight = WeightGames
+	case WeightGames, WeightScore:
+	default:
+		return nil, fmt.Errorf("polyglot: invalid weight %q", opts.Weight)
+	}
+
+	type node struct {
+		fen   string
+		depth int
+	}
+	var entries []Entry
+	visited := map[string]bool{Processpipline.StartFEN: true}
+	queue := []node{{Processpipline.StartFEN, 0}}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		info, exists := tree[current.fen]
+		if !exists || len(info.Moves) == 0 {
+			continue
+		}
+		if opts.MaxDepth > 0 && current.depth >= opts.MaxDepth {
+			continue
+		}
+
+		pos, err := position(current.fen)
+		if err != nil {
+			return nil, err
+		}
+		key := Key(pos)
+		export := opts.Color == "" || opts.Color == colorName(pos.Turn())
+
+		for san, move := range info.Moves {
+			if move.Count < opts.MinGames {
+				continue
+			}
+			if !visited[move.Fen] {
+				visited[move.Fen] = true
+				queue = append(queue, node{move.Fen, current.depth + 1})
+			}
+			if !export {
+				continue
+			}
+
+			m, err := lib.AlgebraicNotation{}.Decode(pos, san)
+			if err != nil {
+				return nil, fmt.Errorf("polyglot: move %s in %s: %w", san, current.fen, err)
+			}
+			weight := weigh(move, opts.Weight)
+			if weight == 0 {
+				continue
+			}
+			entries = append(entries, Entry{Key: key, Move: EncodeMove(m), Weight: weight})
+		}
+	}
+	return entries, nil
+}
+
+func weigh(move *types.MoveInfo, weight string) uint16 {
+	w := move.Count
+	if weight == WeightScore {
+		w = 2*move.WinCount + move.DrawCount
+	}
+	return uint16(min(w, 0xFFFF))
+}
+
+func position(fen string) (*lib.Position, error) {
+	opt, err := lib.FEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	return lib.NewGame(opt).Position(), nil
+}
+
+func colorName(c lib.Color) string {
+	if c == lib.Black {
+		return "black"
+	}
+	return "white"
+}
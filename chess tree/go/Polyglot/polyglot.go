@@ -0,0 +1,161 @@
+package polyglot
+
+import (
+	"encoding/binary"
+	"io"
+	"sort"
+
+	lib "github.com/notnil/chess"
+)
+
+// Entry is one 16 byte record of a Polyglot book.
+type Entry struct {
+	Key    uint64
+	Move   uint16
+	Weight uint16
+	Learn  uint32
+}
+
+const (
+	castleOffset    = 768
+	enPassantOffset = 772
+	turnOffset      = 780
+)
+
+// Key computes the Polyglot hash of a position.
+func Key(pos *lib.Position) uint64 {
+	var key uint64
+	for sq, piece := range pos.Board().SquareMap() {
+		key ^= Random64[64*pieceKind(piece)+8*int(sq.Rank())+int(sq.File())]
+	}
+
+	rights := pos.CastleRights()
+	for i, right := range []struct {
+		color lib.Color
+		side  lib.Side
+	}{
+		{lib.White, lib.KingSide},
+		{lib.White, lib.QueenSide},
+		{lib.Black, lib.KingSide},
+		{lib.Black, lib.QueenSide},
+	} {
+		if rights.CanCastle(right.color, right.side) {
+			key ^= Random64[castleOffset+i]
+		}
+	}
+
+	if file, ok := enPassantFile(pos); ok {
+		key ^= Random64[enPassantOffset+file]
+	}
+	if pos.Turn() == lib.White {
+		key ^= Random64[turnOffset]
+	}
+	return key
+}
+
+// pieceKind maps a piece to its Polyglot index, black pawn being 0 and white
+// king 11.
+func pieceKind(p lib.Piece) int {
+	kind := 0
+	switch p.Type() {
+	case lib.Pawn:
+		kind = 0
+	case lib.Knight:
+		kind = 2
+	case lib.Bishop:
+		kind = 4
+	case lib.Rook:
+		kind = 6
+	case lib.Queen:
+		kind = 8
+	case lib.King:
+		kind = 10
+	}
+	if p.Color() == lib.White {
+		kind++
+	}
+	return kind
+}
+
+// enPassantFile only reports the en passant file when a pawn of the side to
+// move could actually capture, FEN sets the square after every double push
+// but Polyglot does not hash it in that case.
+func enPassantFile(pos *lib.Position) (int, bool) {
+	ep := pos.EnPassantSquare()
+	if ep == lib.NoSquare {
+		return 0, false
+	}
+
+	pawnRank := lib.Rank5
+	if pos.Turn() == lib.Black {
+		pawnRank = lib.Rank4
+	}
+	board := pos.Board()
+	pawn := lib.NewPiece(lib.Pawn, pos.Turn())
+	file := int(ep.File())
+	for _, f := range []int{file - 1, file + 1} {
+		if f < 0 || f > 7 {
+			continue
+		}
+		if board.Piece(lib.NewSquare(lib.File(f), pawnRank)) == pawn {
+			return file, true
+		}
+	}
+	return 0, false
+}
+
+// EncodeMove packs a move the way Polyglot stores it. Castling is written as
+// the king capturing its own rook, e1h1 rather than e1g1.
+func EncodeMove(m *lib.Move) uint16 {
+	from, to := m.S1(), m.S2()
+	if m.HasTag(lib.KingSideCastle) {
+		to = lib.NewSquare(lib.FileH, from.Rank())
+	} else if m.HasTag(lib.QueenSideCastle) {
+		to = lib.NewSquare(lib.FileA, from.Rank())
+	}
+
+	promo := 0
+	switch m.Promo() {
+	case lib.Knight:
+		promo = 1
+	case lib.Bishop:
+		promo = 2
+	case lib.Rook:
+		promo = 3
+	case lib.Queen:
+		promo = 4
+	}
+
+	return uint16(int(to.File()) |
+		int(to.Rank())<<3 |
+		int(from.File())<<6 |
+		int(from.Rank())<<9 |
+		promo<<12)
+}
+
+// WriteBook writes the entries sorted by key, and by descending weight for the
+// same key, which is the order Polyglot readers binary search on.
+func WriteBook(w io.Writer, entries []Entry) error {
+	sorted := append([]Entry(nil), entries...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Key != sorted[j].Key {
+			return sorted[i].Key < sorted[j].Key
+		}
+		if sorted[i].Weight != sorted[j].Weight {
+			return sorted[i].Weight > sorted[j].Weight
+		}
+		return sorted[i].Move < sorted[j].Move
+	})
+
+	buf := make([]byte, 16)
+	for _, e := range sorted {
+		binary.BigEndian.PutUint64(buf[0:], e.Key)
+		binary.BigEndian.PutUint16(buf[8:], e.Move)
+		binary.BigEndian.PutUint16(buf[10:], e.Weight)
+		binary.BigEndian.PutUint32(buf[12:], e.Learn)
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package polyglot
+
+import (
+	"bytes"
+	"testing"
+
+	"chess/Types"
+	lib "github.com/notnil/chess"
+)
+
+// The key vectors published with the Polyglot book format specification.
+var keyTests = []struct {
+	moves string
+	fen   string
+	key   uint64
+}{
+	{"", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 0x463b96181691fc9c},
+	{"e2e4", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", 0x823c9b50fd114196},
+	{"e2e4 d7d5", "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 2", 0x0756b94461c50fb0},
+	{"e2e4 d7d5 e4e5", "rnbqkbnr/ppp1pppp/8/3pP3/8/8/PPPP1PPP/RNBQKBNR b KQkq - 0 2", 0x662fafb965db29d4},
+	{"e2e4 d7d5 e4e5 f7f5", "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", 0x22a48b5a8e47ff78},
+	{"e2e4 d7d5 e4e5 f7f5 e1e2", "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPPKPPP/RNBQ1BNR b kq - 0 3", 0x652a607ca3f242c1},
+	{"e2e4 d7d5 e4e5 f7f5 e1e2 e8f7", "rnbq1bnr/ppp1pkpp/8/3pPp2/8/8/PPPPKPPP/RNBQ1BNR w - - 0 4", 0x00fdd303c946bdd9},
+	{"a2a4 b7b5 h2h4 b5b4 c2c4", "rnbqkbnr/p1pppppp/8/8/PpP4P/8/1P1PPPP1/RNBQKBNR b KQkq c3 0 3", 0x3c8123ea7b067637},
+	{"a2a4 b7b5 h2h4 b5b4 c2c4 b4c3 a1a3", "rnbqkbnr/p1pppppp/8/8/P6P/R1p5/1P1PPPP1/1NBQKBNR b Kkq - 0 4", 0x5c3f9b829b279560},
+}
+
+func mustPosition(t *testing.T, fen string) *lib.Position {
+	t.Helper()
+	pos, err := position(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pos
+}
+
+// findMove returns the legal move of pos written uci, like "e1g1".
+func findMove(t *testing.T, pos *lib.Position, uci string) *lib.Move {
+	t.Helper()
+	for _, m := range pos.ValidMoves() {
+		if m.String() == uci {
+			return m
+		}
+	}
+	t.Fatalf("%s is not legal in %s", uci, pos)
+	return nil
+}
+
+func TestKey(t *testing.T) {
+	for _, tt := range keyTests {
+		if got := Key(mustPosition(t, tt.fen)); got != tt.key {
+			t.Errorf("Key after %q = %#016x, want %#016x", tt.moves, got, tt.key)
+		}
+	}
+}
+
+// TestKeyAfterMoves reaches the positions by playing the moves, the en
+// passant square a FEN leaves out has to come from the board.
+func TestKeyAfterMoves(t *testing.T) {
+	for _, tt := range keyTests {
+		game := lib.NewGame(lib.UseNotation(lib.UCINotation{}))
+		for _, m := range bytes.Fields([]byte(tt.moves)) {
+			if err := game.MoveStr(string(m)); err != nil {
+				t.Fatalf("%q: %v", tt.moves, err)
+			}
+		}
+		if got := Key(game.Position()); got != tt.key {
+			t.Errorf("Key after %q = %#016x, want %#016x", tt.moves, got, tt.key)
+		}
+	}
+}
+
+func TestEncodeMove(t *testing.T) {
+	tests := []struct {
+		fen  string
+		move string
+		want uint16
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e2e4", 0x031c},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "g1f3", 0x0195},
+		// castling is the king taking its own rook
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1g1", 0x0107}, // e1h1
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1c1", 0x0100}, // e1a1
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8g8", 0x0f3f}, // e8h8
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8c8", 0x0f38}, // e8a8
+		{"8/4P3/8/8/8/8/8/k6K w - - 0 1", "e7e8q", 0x4d3c},
+		{"8/4P3/8/8/8/8/8/k6K w - - 0 1", "e7e8n", 0x1d3c},
+	}
+	for _, tt := range tests {
+		pos := mustPosition(t, tt.fen)
+		m := findMove(t, pos, tt.move)
+		if got := EncodeMove(m); got != tt.want {
+			t.Errorf("EncodeMove(%s) = %#04x, want %#04x", tt.move, got, tt.want)
+		}
+		decoded, err := DecodeMove(pos, tt.want)
+		if err != nil {
+			t.Errorf("DecodeMove(%#04x): %v", tt.want, err)
+			continue
+		}
+		if decoded.String() != tt.move {
+			t.Errorf("DecodeMove(%#04x) = %s, want %s", tt.want, decoded, tt.move)
+		}
+	}
+}
+
+func TestDecodeMoveIllegal(t *testing.T) {
+	pos := mustPosition(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	// e1h1 with the f and g squares taken
+	if _, err := DecodeMove(pos, 0x0107); err == nil {
+		t.Error("castling through pieces decoded as a legal move")
+	}
+}
+
+func TestBookRoundTrip(t *testing.T) {
+	entries := []Entry{
+		{Key: 0x823c9b50fd114196, Move: 0x0e5b, Weight: 1},
+		{Key: 0x463b96181691fc9c, Move: 0x0195, Weight: 3},
+		{Key: 0x463b96181691fc9c, Move: 0x031c, Weight: 7, Learn: 42},
+	}
+	var buf bytes.Buffer
+	if err := WriteBook(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 16*len(entries) {
+		t.Fatalf("book is %d bytes, want %d", buf.Len(), 16*len(entries))
+	}
+	// sorted by key, the heaviest move first
+	if got := buf.Bytes()[:8]; !bytes.Equal(got, []byte{0x46, 0x3b, 0x96, 0x18, 0x16, 0x91, 0xfc, 0x9c}) {
+		t.Errorf("first key is % x, want the start position", got)
+	}
+
+	book, err := ReadBook(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := book[0x463b96181691fc9c]
+	if len(start) != 2 || start[0] != entries[2] || start[1] != entries[1] {
+		t.Errorf("start position entries = %+v", start)
+	}
+	if len(book[0x823c9b50fd114196]) != 1 {
+		t.Errorf("entries after e4 = %+v", book[0x823c9b50fd114196])
+	}
+
+	if _, err := ReadBook(bytes.NewReader(make([]byte, 17))); err != ErrBookSize {
+		t.Errorf("got %v, want %v", err, ErrBookSize)
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	const (
+		start   = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+		afterE4 = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1"
+	)
+	tree := map[string]*types.PositonInfo{
+		start: {Count: 3, Moves: map[string]*types.MoveInfo{
+			"e4": {Fen: afterE4, Count: 2, WinCount: 2},
+			"a4": {Count: 1, LossCount: 1},
+		}},
+		afterE4: {Count: 2, Moves: map[string]*types.MoveInfo{
+			"e5": {Count: 2, WinCount: 2},
+		}},
+	}
+	book := Book{0x463b96181691fc9c: {{Key: 0x463b96181691fc9c, Move: 0x031c, Weight: 5}}}
+
+	if _, err := Annotate(tree, book, ""); err != ErrColor {
+		t.Errorf("no color: got %v, want %v", err, ErrColor)
+	}
+
+	white, err := Annotate(tree, book, "white")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(white) != 2 || white[0].Move != "e4" || !white[0].InBook || white[0].BookWeight != 5 ||
+		white[1].Move != "a4" || white[1].InBook {
+		t.Errorf("white annotations = %+v", white)
+	}
+	if out := OutOfBook(white, 1, 0); len(out) != 1 || out[0].Move != "a4" {
+		t.Errorf("out of book = %+v", out)
+	}
+
+	black, err := Annotate(tree, book, "black")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(black) != 1 || black[0].Move != "e5" || black[0].InBook || len(black[0].BookMoves) != 0 {
+		t.Errorf("black annotations = %+v", black)
+	}
+}
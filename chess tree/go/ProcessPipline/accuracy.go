@@ -0,0 +1,90 @@
+package Processpipline
+
+import (
+	"path"
+	"sort"
+
+	"chess/Types"
+)
+
+// UserAccuracy returns the chess.com accuracy of the user in the game, which
+// is only there when the game was analysed.
+func UserAccuracy(rec *types.GameRecord) (float64, bool) {
+	accuracy := rec.WhiteAccuracy
+	if rec.Color == "black" {
+		accuracy = rec.BlackAccuracy
+	}
+	if accuracy == nil || *accuracy <= 0 {
+		return 0, false
+	}
+	return *accuracy, true
+}
+
+// UpdateOpening counts the game under its ECO code, named after the last
+// segment of the chess.com opening url.
+func UpdateOpening(rec *types.GameRecord, accuracy float64, hasAccuracy bool) {
+	updateOpening(Openings, rec, accuracy, hasAccuracy)
+}
+
+// OpeningsOf aggregates recs per ECO code like Openings does for every game.
+func OpeningsOf(recs []*types.GameRecord) map[string]*types.OpeningStat {
+	openings := make(map[string]*types.OpeningStat)
+	for _, rec := range recs {
+		accuracy, hasAccuracy := UserAccuracy(rec)
+		updateOpening(openings, rec, accuracy, hasAccuracy)
+	}
+	return openings
+}
+
+func updateOpening(openings map[string]*types.OpeningStat, rec *types.GameRecord, accuracy float64, hasAccuracy bool) {
+	if rec.ECO == "" {
+		return
+	}
+	stat, exists := openings[rec.ECO]
+	if !exists {
+		stat = &types.OpeningStat{}
+		openings[rec.ECO] = stat
+	}
+	if rec.ECOUrl != "" {
+		stat.Name = path.Base(rec.ECOUrl)
+	}
+	stat.Games++
+	if hasAccuracy {
+		stat.Accuracy.Add(rec.Color, accuracy)
+	}
+}
+
+// AccuracyReport lists the openings with their average accuracy for color,
+// most played first.
+func AccuracyReport(openings map[string]*types.OpeningStat, color string, minGames int) []types.OpeningAccuracy {
+	report := []types.OpeningAccuracy{}
+	for eco, stat := range openings {
+		if stat.Games < minGames {
+			continue
+		}
+		report = append(report, types.OpeningAccuracy{
+			ECO:      eco,
+			Name:     stat.Name,
+			Games:    stat.Games,
+			Analysed: analysedGames(stat.Accuracy, color),
+			Average:  stat.Accuracy.Average(color),
+		})
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Games != report[j].Games {
+			return report[i].Games > report[j].Games
+		}
+		return report[i].ECO < report[j].ECO
+	})
+	return report
+}
+
+func analysedGames(a types.AccuracyStat, color string) int {
+	switch color {
+	case "white":
+		return a.WhiteGames
+	case "black":
+		return a.BlackGames
+	}
+	return a.WhiteGames + a.BlackGames
+}
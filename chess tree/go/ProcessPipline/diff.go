@@ -0,0 +1,140 @@
+package Processpipline
+
+import (
+	"sort"
+
+	"chess/Types"
+)
+
+// Diff compares two position trees, typically two snapshots of different
+// periods or of different users. Positions and moves seen fewer than
+// minGames times on their side are ignored to keep the noise down. Scores
+// are from the user's point of view, a win counting 1 and a draw 0.5, and
+// ScoreDelta is B minus A. A side without a decided game has no score.
+func Diff(a, b map[string]*types.PositonInfo, minGames int) *types.TreeDiff {
+	diff := &types.TreeDiff{
+		OnlyA:      []types.DiffNode{},
+		OnlyB:      []types.DiffNode{},
+		MovesOnlyA: []types.DiffMove{},
+		MovesOnlyB: []types.DiffMove{},
+		Shared:     []types.SharedNode{},
+	}
+
+	for fen, infoA := range a {
+		infoB, shared := b[fen]
+		if !shared {
+			if infoA.Count >= minGames {
+				diff.OnlyA = append(diff.OnlyA, diffNode(fen, infoA))
+			}
+			continue
+		}
+		if infoA.Count >= minGames || infoB.Count >= minGames {
+			node := types.SharedNode{
+				Fen:    fen,
+				GamesA: infoA.Count,
+				GamesB: infoB.Count,
+				ScoreA: decidedScore(infoA),
+				ScoreB: decidedScore(infoB),
+			}
+			if node.ScoreA != nil && node.ScoreB != nil {
+				delta := *node.ScoreB - *node.ScoreA
+				node.ScoreDelta = &delta
+			}
+			diff.Shared = append(diff.Shared, node)
+		}
+		diff.MovesOnlyA = append(diff.MovesOnlyA, missingMoves(fen, infoA, infoB, minGames)...)
+		diff.MovesOnlyB = append(diff.MovesOnlyB, missingMoves(fen, infoB, infoA, minGames)...)
+	}
+	for fen, infoB := range b {
+		if _, shared := a[fen]; !shared && infoB.Count >= minGames {
+			diff.OnlyB = append(diff.OnlyB, diffNode(fen, infoB))
+		}
+	}
+
+	sortNodes(diff.OnlyA)
+	sortNodes(diff.OnlyB)
+	sortMoves(diff.MovesOnlyA)
+	sortMoves(diff.MovesOnlyB)
+	sort.Slice(diff.Shared, func(i, j int) bool {
+		si, sj := diff.Shared[i], diff.Shared[j]
+		if si.GamesA+si.GamesB != sj.GamesA+sj.GamesB {
+			return si.GamesA+si.GamesB > sj.GamesA+sj.GamesB
+		}
+		return si.Fen < sj.Fen
+	})
+	return diff
+}
+
+// Score is the share of points the user took from a position, 0 when no game
+// through it was decided.
+func Score(info *types.PositonInfo) float64 {
+	decided := info.WinCount + info.LossCount + info.DrawCount
+	if decided == 0 {
+		return 0
+	}
+	return (float64(info.WinCount) + float64(info.DrawCount)/2) / float64(decided)
+}
+
+// MoveScore is Score for a single move out of a position.
+func MoveScore(move *types.MoveInfo) float64 {
+	return Score(&types.PositonInfo{
+		WinCount:  move.WinCount,
+		LossCount: move.LossCount,
+		DrawCount: move.DrawCount,
+	})
+}
+
+// decidedScore is Score, nil when no game through the position was decided.
+func decidedScore(info *types.PositonInfo) *float64 {
+	if info.WinCount+info.LossCount+info.DrawCount == 0 {
+		return nil
+	}
+	score := Score(info)
+	return &score
+}
+
+func diffNode(fen string, info *types.PositonInfo) types.DiffNode {
+	return types.DiffNode{Fen: fen, Games: info.Count, Score: decidedScore(info)}
+}
+
+// missingMoves lists the moves played from fen in from that other never played.
+func missingMoves(fen string, from, other *types.PositonInfo, minGames int) []types.DiffMove {
+	var moves []types.DiffMove
+	for san, move := range from.Moves {
+		if _, exists := other.Moves[san]; exists || move.Count < minGames {
+			continue
+		}
+		moves = append(moves, types.DiffMove{
+			Fen:   fen,
+			Move:  san,
+			Games: move.Count,
+			Score: decidedScore(&types.PositonInfo{
+				WinCount:  move.WinCount,
+				LossCount: move.LossCount,
+				DrawCount: move.DrawCount,
+			}),
+		})
+	}
+	return moves
+}
+
+func sortNodes(nodes []types.DiffNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Games != nodes[j].Games {
+			return nodes[i].Games > nodes[j].Games
+		}
+		return nodes[i].Fen < nodes[j].Fen
+	})
+}
+
+func sortMoves(moves []types.DiffMove) {
+	sort.Slice(moves, func(i, j int) bool {
+		if moves[i].Games != moves[j].Games {
+			return moves[i].Games > moves[j].Games
+		}
+		if moves[i].Fen != moves[j].Fen {
+			return moves[i].Fen < moves[j].Fen
+		}
+		return moves[i].Move < moves[j].Move
+	})
+}
@@ -0,0 +1,112 @@
+package Processpipline
+
+import (
+	"slices"
+	"strings"
+
+	"chess/Types"
+)
+
+// PositionKey drops the halfmove clock and move number from a FEN, two move
+// orders reaching the same position then share the key.
+func PositionKey(fen string) string {
+	fields := strings.Fields(fen)
+	if len(fields) > 4 {
+		fields = fields[:4]
+	}
+	return strings.Join(fields, " ")
+}
+
+// Keys indexes the HashMap by PositionKey, listing the FENs of the entries of
+// each position so one is looked up without a scan. Guarded by Mu.
+var Keys = make(map[string][]string)
+
+// Variants returns the FENs of the HashMap entries of the position of fen,
+// whatever their clocks. The caller holds Mu.
+func Variants(fen string) []string {
+	var fens []string
+	for _, full := range Keys[PositionKey(fen)] {
+		if _, exists := HashMap[full]; exists {
+			fens = append(fens, full)
+		}
+	}
+	return fens
+}
+
+func indexKey(fen string) {
+	key := PositionKey(fen)
+	if !slices.Contains(Keys[key], fen) {
+		Keys[key] = append(Keys[key], fen)
+	}
+}
+
+// unindexKey drops fen from Keys once the HashMap has no entry for it.
+func unindexKey(fen string) {
+	if _, exists := HashMap[fen]; exists {
+		return
+	}
+	key := PositionKey(fen)
+	fens := slices.DeleteFunc(Keys[key], func(full string) bool { return full == fen })
+	if len(fens) == 0 {
+		delete(Keys, key)
+		return
+	}
+	Keys[key] = fens
+}
+
+// indexKeys rebuilds Keys after the HashMap is replaced.
+func indexKeys() {
+	Keys = make(map[string][]string, len(HashMap))
+	for fen := range HashMap {
+		indexKey(fen)
+	}
+}
+
+// KeyInfo sums the entries of tree for the position of fen, whatever their
+// clocks, like Variants does for the HashMap. It returns false when tree has
+// none, the entries themselves are left untouched.
+func KeyInfo(tree map[string]*types.PositonInfo, fen string) (*types.PositonInfo, bool) {
+	key := PositionKey(fen)
+	merged := &types.PositonInfo{Moves: make(map[string]*types.MoveInfo)}
+	found := false
+	for full, info := range tree {
+		if PositionKey(full) != key {
+			continue
+		}
+		found = true
+		merged.Count += info.Count
+		merged.WinCount += info.WinCount
+		merged.LossCount += info.LossCount
+		merged.DrawCount += info.DrawCount
+		for _, id := range info.GamesId {
+			merged.GamesId = AddGameID(merged.GamesId, id)
+		}
+		merged.Accuracy.Merge(info.Accuracy)
+		for termination, count := range info.Terminations {
+			if merged.Terminations == nil {
+				merged.Terminations = make(map[types.Termination]*types.TerminationCount)
+			}
+			sum, exists := merged.Terminations[termination]
+			if !exists {
+				sum = &types.TerminationCount{}
+				merged.Terminations[termination] = sum
+			}
+			sum.Win += count.Win
+			sum.Loss += count.Loss
+			sum.Draw += count.Draw
+		}
+		for san, move := range info.Moves {
+			sum, exists := merged.Moves[san]
+			if !exists {
+				sum = &types.MoveInfo{Fen: move.Fen}
+				merged.Moves[san] = sum
+			}
+			sum.Count += move.Count
+			sum.WinCount += move.WinCount
+			sum.LossCount += move.LossCount
+			sum.DrawCount += move.DrawCount
+			sum.Accuracy.Merge(move.Accuracy)
+		}
+	}
+	return merged, found
+}
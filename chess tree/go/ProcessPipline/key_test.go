@@ -0,0 +1,103 @@
+package Processpipline
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"chess/Types"
+)
+
+func TestAddGameID(t *testing.T) {
+	var ids []string
+	for i := range MaxGameIDs + 10 {
+		ids = AddGameID(ids, fmt.Sprint(i))
+	}
+	if len(ids) != MaxGameIDs || ids[0] != "10" || ids[len(ids)-1] != fmt.Sprint(MaxGameIDs+9) {
+		t.Errorf("kept %d ids from %s to %s, want the latest %d", len(ids), ids[0], ids[len(ids)-1], MaxGameIDs)
+	}
+}
+
+// TestKeys follows the entries of a position reached with two move orders,
+// the clocks of the FENs differing, through Apply and Remove.
+func TestKeys(t *testing.T) {
+	useEmptyTree(t)
+	direct := playedRecord(t, "direct", "win", 0, "Nf3", "Nf6", "Nc3", "Nc6")
+	around := playedRecord(t, "around", "loss", 0, "Nc3", "Nc6", "Nb1", "Nb8", "Nf3", "Nf6", "Nc3", "Nc6")
+	Apply(direct)
+	Apply(around)
+
+	fen := direct.Plies[len(direct.Plies)-1].Fen
+	other := around.Plies[len(around.Plies)-1].Fen
+	if fen == other {
+		t.Fatal("the two move orders reach the same FEN, clocks included")
+	}
+	if got := Variants(fen); len(got) != 2 || !slices.Contains(got, fen) || !slices.Contains(got, other) {
+		t.Errorf("Variants = %v, want %q and %q", got, fen, other)
+	}
+
+	Remove(around)
+	if got := Variants(PositionKey(other)); !slices.Equal(got, []string{fen}) {
+		t.Errorf("after the removal Variants = %v, want %q", got, fen)
+	}
+	for key := range Keys {
+		if len(Variants(key)) == 0 {
+			t.Errorf("key %q is left without entries", key)
+		}
+	}
+}
+
+// TestApplyManyGames checks a position keeps MaxGameIDs ids however many
+// games reach it, and still removes a game whose id it dropped.
+func TestApplyManyGames(t *testing.T) {
+	useEmptyTree(t)
+	first := playedRecord(t, "g0", "win", 0, "e4")
+	Apply(first)
+	for i := 1; i <= MaxGameIDs; i++ {
+		Apply(playedRecord(t, fmt.Sprint("g", i), "win", 0, "e4"))
+	}
+	after := HashMap[first.Plies[0].Fen]
+	if after.Count != MaxGameIDs+1 || len(after.GamesId) != MaxGameIDs || slices.Contains(after.GamesId, "g0") {
+		t.Fatalf("count %d with %d ids, want %d games and the latest %d ids", after.Count, len(after.GamesId), MaxGameIDs+1, MaxGameIDs)
+	}
+	if !Remove(first) || after.Count != MaxGameIDs {
+		t.Errorf("removing the first game left %d games, want %d", after.Count, MaxGameIDs)
+	}
+}
+
+// TestKeyInfo sums the entries of a position reached with two move orders.
+func TestKeyInfo(t *testing.T) {
+	tree := make(map[string]*types.PositonInfo)
+	direct := playedRecord(t, "direct", "win", 80, "Nf3", "Nf6", "Nc3", "Nc6")
+	around := playedRecord(t, "around", "loss", 60, "Nc3", "Nc6", "Nb1", "Nb8", "Nf3", "Nf6", "Nc3", "Nc6", "e4")
+	ApplyTo(tree, direct)
+	ApplyTo(tree, around)
+
+	fen := direct.Plies[3].Fen
+	info, exists := KeyInfo(tree, PositionKey(fen))
+	if !exists {
+		t.Fatal("the position is not found by its key")
+	}
+	if info.Count != 2 || info.WinCount != 1 || info.LossCount != 1 {
+		t.Errorf("counts %d (%d/%d), want a win and a loss", info.Count, info.WinCount, info.LossCount)
+	}
+	if got := info.Terminations[types.TerminationResignation]; got == nil || got.Win != 1 || got.Loss != 1 {
+		t.Errorf("terminations %v, want both games by resignation", info.Terminations)
+	}
+	if got := info.Accuracy.Average("white"); got != 70 {
+		t.Errorf("accuracy %v, want 70", got)
+	}
+	if move := info.Moves["e4"]; len(info.Moves) != 1 || move == nil || move.Count != 1 {
+		t.Errorf("moves %v, want e4 once", info.Moves)
+	}
+	if tree[fen].Count != 1 {
+		t.Errorf("the entry of %s was changed to %d games", fen, tree[fen].Count)
+	}
+
+	if _, exists := KeyInfo(tree, PositionKey(StartFEN)+" 5 9"); !exists {
+		t.Error("the start position with another clock is not found")
+	}
+	if _, exists := KeyInfo(tree, "8/8/8/8/8/8/8/8 w - - 0 1"); exists {
+		t.Error("a position of no game is found")
+	}
+}
@@ -2,79 +2,260 @@ package Processpipline
 
 import (
 	"fmt"
+	"strings"
+	"sync"
+	"time"
+
 	// "errors"
 	"chess/Types"
+	"github.com/google/uuid"
 	lib "github.com/notnil/chess"
 )
 
+// StartFEN is the root of every tree, games are replayed from it.
+const StartFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// MaxPlies is how many plies of a game the pipeline replays, the tree stops
+// at that depth.
+var MaxPlies = 31
+
+// MaxGameIDs is how many game ids a position keeps, the latest ones. They are
+// a sample, the stores list the games behind a position from an index of
+// their own.
+const MaxGameIDs = 100
+
 var HashMap = make(map[string]*types.PositonInfo)
 
-func ProcessPipeline(root *types.Game, moves []types.Move, obj *types.Pgn, color string) error {
+// Openings aggregates the games per ECO code, guarded by Mu as well.
+var Openings = make(map[string]*types.OpeningStat)
+
+// Games holds the ids of the games in HashMap, a game synced again is not
+// applied twice. Guarded by Mu as well.
+var Games = make(map[string]bool)
+
+// Mu guards HashMap, it is written by the pipeline and read by the http
+// handlers and the snapshot saver at the same time.
+var Mu sync.RWMutex
+
+// ProcessPipeline replays the moves of a game and returns the record the
+// stores persist. It does not touch the HashMap, the memory store does that
+// through Apply.
+func ProcessPipeline(root *types.Game, moves []types.Move, obj *types.Pgn, color string) (*types.GameRecord, error) {
 	game := lib.NewGame()
-	UpdateUnitialPositon(root)
 	result := obj.Result
 	conclusion := CheckIfUsrWon(result, color)
-	fmt.Println("conclusion:", conclusion)
-
-	IsWin := false
-	IsLoss := false
-	IsDraw := false
 
-	switch conclusion {
-	case "win":
-		IsWin = true
-	case "loss":
-		IsLoss = true
-	case "draw":
-		IsDraw = true
+	rec := &types.GameRecord{
+		Username:    root.White.Username,
+		Color:       color,
+		Outcome:     conclusion,
+		Result:      result,
+		Termination: NormalizeTermination(obj.Termination),
+		TimeClass:   root.TimeClass,
+		TimeControl: root.TimeControl,
+		Rated:       root.Rated,
+		White:       root.White.Username,
+		Black:       root.Black.Username,
+		WhiteElo:    root.White.Rating,
+		BlackElo:    root.Black.Rating,
+		ECO:         obj.ECO,
+		ECOUrl:      obj.ECOUrl,
+		Link:        root.URL,
+		PGN:         root.PGN,
+		PlayedAt:    time.Unix(root.EndTime, 0).UTC(),
+	}
+	if color == "black" {
+		rec.Username = root.Black.Username
+	}
+	rec.ID = GameID(root.UUID, rec.Username)
+	if rec.Link == "" {
+		rec.Link = obj.Link
+	}
+	if root.Accuracies != nil {
+		white, black := root.Accuracies.White, root.Accuracies.Black
+		rec.WhiteAccuracy, rec.BlackAccuracy = &white, &black
 	}
 
 	for i, m := range moves {
-		if i > 30 {
-			fmt.Println("we reached move 30, stopping early")
+		if i >= MaxPlies {
+			break
+		}
+
+		parent := game.FEN()
+		if err := game.MoveStr(m.San); err != nil {
+			fmt.Println("illegal move in game", root.UUID, m.San, err)
 			break
 		}
+		rec.Plies = append(rec.Plies, types.Ply{
+			Number: i + 1,
+			San:    m.San,
+			Parent: parent,
+			Fen:    game.FEN(),
+		})
+	}
+
+	return rec, nil
+}
+
+// GameID is the id a chess.com game is kept under for username. Two synced
+// users who played each other both keep the game, from their side, so the id
+// is derived from the chess.com one and the user.
+func GameID(chessComID, username string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(chessComID+"/"+strings.ToLower(username))).String()
+}
+
+// Apply adds a processed game to the HashMap and Openings, and reports false
+// when they have it already.
+func Apply(rec *types.GameRecord) bool {
+	Mu.Lock()
+	defer Mu.Unlock()
+
+	if Games[rec.ID] {
+		return false
+	}
+	Games[rec.ID] = true
+	accuracy, hasAccuracy := UserAccuracy(rec)
+	UpdateOpening(rec, accuracy, hasAccuracy)
+	ApplyTo(HashMap, rec)
+	indexKey(StartFEN)
+	for _, ply := range rec.Plies {
+		indexKey(ply.Fen)
+	}
+	return true
+}
 
-		game.MoveStr(m.San)
-		position := game.FEN()
+// ApplyTo adds a processed game to tree, a diff builds the tree of a period
+// with it without touching the HashMap.
+func ApplyTo(tree map[string]*types.PositonInfo, rec *types.GameRecord) {
+	IsWin := rec.Outcome == "win"
+	IsLoss := rec.Outcome == "loss"
+	IsDraw := rec.Outcome == "draw"
 
-		if info, exists := HashMap[position]; exists {
+	// the start position counts results like every other position so its
+	// stats match the ones the Postgres store keeps
+	addRoot(tree, rec.ID)
+	root := tree[StartFEN]
+	root.WinCount += btoi(IsWin)
+	root.LossCount += btoi(IsLoss)
+	root.DrawCount += btoi(IsDraw)
+	UpdateTermination(root, rec.Termination, IsWin, IsLoss, IsDraw)
+
+	accuracy, hasAccuracy := UserAccuracy(rec)
+	if hasAccuracy {
+		root.Accuracy.Add(rec.Color, accuracy)
+	}
+
+	for _, ply := range rec.Plies {
+		position := ply.Fen
+		addMove(tree, ply.Parent, ply.San, position, IsWin, IsLoss, IsDraw)
+
+		if info, exists := tree[position]; exists {
 			info.Count++
-			info.GamesId = append(info.GamesId, root.UUID)
+			info.GamesId = AddGameID(info.GamesId, rec.ID)
 			info.WinCount += btoi(IsWin)
 			info.LossCount += btoi(IsLoss)
 			info.DrawCount += btoi(IsDraw)
 		} else {
-			HashMap[position] = &types.PositonInfo{
+			tree[position] = &types.PositonInfo{
 				Count:     1,
-				GamesId:   []string{root.UUID},
+				GamesId:   []string{rec.ID},
 				WinCount:  btoi(IsWin),
 				LossCount: btoi(IsLoss),
 				DrawCount: btoi(IsDraw),
 			}
 		}
+		UpdateTermination(tree[position], rec.Termination, IsWin, IsLoss, IsDraw)
+		if hasAccuracy {
+			tree[position].Accuracy.Add(rec.Color, accuracy)
+			tree[ply.Parent].Moves[ply.San].Accuracy.Add(rec.Color, accuracy)
+		}
 	}
+}
 
-	data := game.MoveHistory()
-	fmt.Println("History of moves:")
-	for _, move := range data {
-		fmt.Println(move)
+// AddGameID appends id to the game ids of a position, dropping the oldest
+// past MaxGameIDs.
+func AddGameID(ids []string, id string) []string {
+	if len(ids) >= MaxGameIDs {
+		ids = ids[len(ids)-MaxGameIDs+1:]
 	}
+	return append(ids, id)
+}
+
+// CopyHashMap returns a deep copy of the HashMap so it can be read without
+// holding Mu.
+func CopyHashMap() map[string]*types.PositonInfo {
+	Mu.RLock()
+	defer Mu.RUnlock()
 
-	return nil
+	tree := make(map[string]*types.PositonInfo, len(HashMap))
+	for fen, info := range HashMap {
+		copied := *info
+		copied.GamesId = append([]string(nil), info.GamesId...)
+		copied.Moves = make(map[string]*types.MoveInfo, len(info.Moves))
+		for san, move := range info.Moves {
+			m := *move
+			copied.Moves[san] = &m
+		}
+		copied.Terminations = make(map[types.Termination]*types.TerminationCount, len(info.Terminations))
+		for termination, count := range info.Terminations {
+			c := *count
+			copied.Terminations[termination] = &c
+		}
+		tree[fen] = &copied
+	}
+	return tree
 }
 
-func UpdateUnitialPositon(root *types.Game) {
-	if info, exists := HashMap["rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"]; exists {
+// CopyOpenings returns a copy of Openings so it can be read without holding Mu.
+func CopyOpenings() map[string]*types.OpeningStat {
+	Mu.RLock()
+	defer Mu.RUnlock()
+
+	openings := make(map[string]*types.OpeningStat, len(Openings))
+	for eco, stat := range Openings {
+		copied := *stat
+		openings[eco] = &copied
+	}
+	return openings
+}
+
+func UpdateUnitialPositon(gameID string) {
+	addRoot(HashMap, gameID)
+}
+
+func addRoot(tree map[string]*types.PositonInfo, gameID string) {
+	if info, exists := tree[StartFEN]; exists {
 		info.Count++
-		info.GamesId = append(info.GamesId, root.UUID)
+		info.GamesId = AddGameID(info.GamesId, gameID)
 	} else {
 		data := types.PositonInfo{
 			Count:   1,
-			GamesId: []string{root.UUID},
+			GamesId: []string{gameID},
 		}
-		HashMap["rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"] = &data
+		tree[StartFEN] = &data
+	}
+}
+
+// UpdateMove records that san was played from parent and led to child. The
+// parent is always in the HashMap already since it was the previous ply.
+func UpdateMove(parent, san, child string, isWin, isLoss, isDraw bool) {
+	addMove(HashMap, parent, san, child, isWin, isLoss, isDraw)
+}
+
+func addMove(tree map[string]*types.PositonInfo, parent, san, child string, isWin, isLoss, isDraw bool) {
+	info := tree[parent]
+	if info.Moves == nil {
+		info.Moves = make(map[string]*types.MoveInfo)
+	}
+	move, exists := info.Moves[san]
+	if !exists {
+		move = &types.MoveInfo{Fen: child}
+		info.Moves[san] = move
 	}
+	move.Count++
+	move.WinCount += btoi(isWin)
+	move.LossCount += btoi(isLoss)
+	move.DrawCount += btoi(isDraw)
 }
 
 func btoi(b bool) int {
@@ -0,0 +1,129 @@
+package Processpipline
+
+import (
+	"slices"
+
+	"chess/Types"
+)
+
+// Remove takes a game Apply added back out of the HashMap and Openings, when
+// the user it was played by is deleted. It reports false when the tree does
+// not have the game.
+func Remove(rec *types.GameRecord) bool {
+	Mu.Lock()
+	defer Mu.Unlock()
+
+	if !Games[rec.ID] {
+		return false
+	}
+	delete(Games, rec.ID)
+	accuracy, hasAccuracy := UserAccuracy(rec)
+	if stat, exists := Openings[rec.ECO]; exists && rec.ECO != "" {
+		stat.Games--
+		if hasAccuracy {
+			stat.Accuracy.Remove(rec.Color, accuracy)
+		}
+		if stat.Games <= 0 {
+			delete(Openings, rec.ECO)
+		}
+	}
+	RemoveFrom(HashMap, rec)
+	unindexKey(StartFEN)
+	for _, ply := range rec.Plies {
+		unindexKey(ply.Fen)
+	}
+	return true
+}
+
+// RemoveFrom undoes ApplyTo, dropping the positions and moves no game goes
+// through anymore. Only the plies the tree has the game at are taken back, a
+// game replayed deeper than it was applied leaves the rest alone. A position
+// with MaxGameIDs ids may have dropped the game's, it is taken to have it.
+func RemoveFrom(tree map[string]*types.PositonInfo, rec *types.GameRecord) {
+	IsWin := rec.Outcome == "win"
+	IsLoss := rec.Outcome == "loss"
+	IsDraw := rec.Outcome == "draw"
+	accuracy, hasAccuracy := UserAccuracy(rec)
+
+	plies := rec.Plies
+	for i, ply := range rec.Plies {
+		info, exists := tree[ply.Fen]
+		if !exists || (len(info.GamesId) < MaxGameIDs && !slices.Contains(info.GamesId, rec.ID)) {
+			plies = rec.Plies[:i]
+			break
+		}
+	}
+
+	// the deepest ply first, a position is only dropped once no move of the
+	// game leaves it
+	for i := len(plies) - 1; i >= 0; i-- {
+		ply := plies[i]
+		info, exists := tree[ply.Fen]
+		if !exists {
+			continue
+		}
+		info.Count--
+		info.GamesId = removeID(info.GamesId, rec.ID)
+		info.WinCount -= btoi(IsWin)
+		info.LossCount -= btoi(IsLoss)
+		info.DrawCount -= btoi(IsDraw)
+		removeTermination(info, rec.Termination, IsWin, IsLoss, IsDraw)
+		if hasAccuracy {
+			info.Accuracy.Remove(rec.Color, accuracy)
+		}
+		if info.Count <= 0 {
+			delete(tree, ply.Fen)
+		}
+
+		if parent, exists := tree[ply.Parent]; exists {
+			if move, exists := parent.Moves[ply.San]; exists {
+				move.Count--
+				move.WinCount -= btoi(IsWin)
+				move.LossCount -= btoi(IsLoss)
+				move.DrawCount -= btoi(IsDraw)
+				if hasAccuracy {
+					move.Accuracy.Remove(rec.Color, accuracy)
+				}
+				if move.Count <= 0 {
+					delete(parent.Moves, ply.San)
+				}
+			}
+		}
+	}
+
+	root, exists := tree[StartFEN]
+	if !exists {
+		return
+	}
+	root.Count--
+	root.GamesId = removeID(root.GamesId, rec.ID)
+	root.WinCount -= btoi(IsWin)
+	root.LossCount -= btoi(IsLoss)
+	root.DrawCount -= btoi(IsDraw)
+	removeTermination(root, rec.Termination, IsWin, IsLoss, IsDraw)
+	if hasAccuracy {
+		root.Accuracy.Remove(rec.Color, accuracy)
+	}
+	if root.Count <= 0 {
+		delete(tree, StartFEN)
+	}
+}
+
+// removeID drops one occurrence of id, a game through a position twice is
+// listed twice.
+func removeID(ids []string, id string) []string {
+	if i := slices.Index(ids, id); i >= 0 {
+		return slices.Delete(ids, i, i+1)
+	}
+	return ids
+}
+
+func removeTermination(info *types.PositonInfo, termination types.Termination, isWin, isLoss, isDraw bool) {
+	count, exists := info.Terminations[termination]
+	if !exists {
+		return
+	}
+	count.Win -= btoi(isWin)
+	count.Loss -= btoi(isLoss)
+	count.Draw -= btoi(isDraw)
+}
@@ -0,0 +1,112 @@
+package Processpipline
+
+import (
+	"reflect"
+	"testing"
+
+	"chess/Types"
+	lib "github.com/notnil/chess"
+)
+
+// playedRecord replays sans from the start into a game of the white side.
+func playedRecord(t *testing.T, id, outcome string, accuracy float64, sans ...string) *types.GameRecord {
+	t.Helper()
+	game := lib.NewGame()
+	rec := &types.GameRecord{
+		ID:          id,
+		Color:       "white",
+		Outcome:     outcome,
+		Termination: types.TerminationResignation,
+		ECO:         "C20",
+	}
+	if accuracy > 0 {
+		rec.WhiteAccuracy = &accuracy
+	}
+	for i, san := range sans {
+		parent := game.FEN()
+		if err := game.MoveStr(san); err != nil {
+			t.Fatal(err)
+		}
+		rec.Plies = append(rec.Plies, types.Ply{Number: i + 1, San: san, Parent: parent, Fen: game.FEN()})
+	}
+	return rec
+}
+
+// useEmptyTree gives the test an empty HashMap, restored when it ends.
+func useEmptyTree(t *testing.T) {
+	t.Helper()
+	saved, savedOpenings, savedGames, savedKeys := HashMap, Openings, Games, Keys
+	HashMap = make(map[string]*types.PositonInfo)
+	Openings = make(map[string]*types.OpeningStat)
+	Games = make(map[string]bool)
+	Keys = make(map[string][]string)
+	t.Cleanup(func() { HashMap, Openings, Games, Keys = saved, savedOpenings, savedGames, savedKeys })
+}
+
+// withoutEmptyTerminations drops the termination counts a removal left at
+// zero, ApplyTo never makes them for a game that was decided.
+func withoutEmptyTerminations(tree map[string]*types.PositonInfo) map[string]*types.PositonInfo {
+	for _, info := range tree {
+		for termination, count := range info.Terminations {
+			if *count == (types.TerminationCount{}) {
+				delete(info.Terminations, termination)
+			}
+		}
+	}
+	return tree
+}
+
+func TestRemoveFrom(t *testing.T) {
+	kept := playedRecord(t, "kept", "loss", 0, "e4", "e5", "Nf3")
+	removed := playedRecord(t, "removed", "win", 81.5, "e4", "c5", "Nf3", "d6", "d4")
+
+	want := make(map[string]*types.PositonInfo)
+	ApplyTo(want, kept)
+
+	tree := make(map[string]*types.PositonInfo)
+	ApplyTo(tree, removed)
+	ApplyTo(tree, kept)
+	RemoveFrom(tree, removed)
+
+	if got := withoutEmptyTerminations(tree); !reflect.DeepEqual(got, want) {
+		t.Errorf("tree after the removal:\n%+v\nwant the tree of the game kept:\n%+v", got, want)
+	}
+
+	RemoveFrom(tree, kept)
+	if len(tree) != 0 {
+		t.Errorf("removing every game left %d positions", len(tree))
+	}
+}
+
+// TestRemoveFromShallower removes a game applied with fewer plies than it is
+// replayed with, like after MaxPlies was raised.
+func TestRemoveFromShallower(t *testing.T) {
+	rec := playedRecord(t, "g1", "draw", 0, "d4", "d5", "c4")
+	tree := make(map[string]*types.PositonInfo)
+	applied := *rec
+	applied.Plies = rec.Plies[:2]
+	ApplyTo(tree, &applied)
+
+	RemoveFrom(tree, rec)
+	if len(tree) != 0 {
+		t.Errorf("the tree kept %d positions", len(tree))
+	}
+}
+
+func TestRemove(t *testing.T) {
+	useEmptyTree(t)
+
+	rec := playedRecord(t, "g1", "win", 90, "e4")
+	if !Apply(rec) || Apply(rec) {
+		t.Fatal("Apply did not apply the game exactly once")
+	}
+	if !Remove(rec) {
+		t.Fatal("Remove did not find the game applied")
+	}
+	if len(HashMap) != 0 || len(Openings) != 0 || len(Games) != 0 || len(Keys) != 0 {
+		t.Errorf("left %d positions, %d openings, %d games and %d keys", len(HashMap), len(Openings), len(Games), len(Keys))
+	}
+	if Remove(rec) {
+		t.Error("a game removed twice was found again")
+	}
+}
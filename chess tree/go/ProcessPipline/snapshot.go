@@ -0,0 +1,362 @@
+package Processpipline
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"chess/Types"
+)
+
+// Snapshot file layout, all integers are unsigned varints unless noted:
+//
+//	magic "OETS" | version (uint16, little endian) | entry count | entries... | crc32 (uint32, little endian)
+//
+// Each entry is the FEN followed by the PositonInfo counters and its game ids,
+// strings being written as a length followed by the raw bytes. Since version 2
+// an entry ends with its moves: a count, then san, resulting FEN and the move
+// counters for each. Version 3 appends the terminations: a count, then the
+// termination enum with its win, loss and draw counters. Version 4 adds the
+// accuracy sums, as little endian float64 bits, after each position and each
+// move, and a section with the opening stats after the last entry. Version 5
+// ends with the ids of the games in the tree, read from the game ids of the
+// start position before. The trailing checksum covers every byte before it.
+const (
+	snapshotMagic   = "OETS"
+	SnapshotVersion = 5
+)
+
+var (
+	ErrSnapshotMagic    = errors.New("snapshot: not a tree snapshot file")
+	ErrSnapshotVersion  = errors.New("snapshot: unsupported version")
+	ErrSnapshotChecksum = errors.New("snapshot: checksum mismatch")
+)
+
+// SaveSnapshot writes the current HashMap to path. The file is written next
+// to the destination first and renamed into place so a crash never leaves a
+// half written snapshot behind.
+func SaveSnapshot(path string) error {
+	Mu.RLock()
+	defer Mu.RUnlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := WriteSnapshot(tmp, HashMap, Openings, Games); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshot replaces the HashMap, Openings and Games with the contents of
+// the snapshot at path. A missing file is not an error, the tree simply starts
+// empty.
+func LoadSnapshot(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	positions, openings, games, err := readSnapshot(f)
+	if err != nil {
+		return err
+	}
+
+	Mu.Lock()
+	HashMap = positions
+	Openings = openings
+	Games = games
+	indexKeys()
+	Mu.Unlock()
+	return nil
+}
+
+// ReadSnapshotFile decodes the snapshot at path without touching the HashMap.
+func ReadSnapshotFile(path string) (map[string]*types.PositonInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadSnapshot(f)
+}
+
+func WriteSnapshot(w io.Writer, positions map[string]*types.PositonInfo, openings map[string]*types.OpeningStat, games map[string]bool) error {
+	crc := crc32.NewIEEE()
+	bw := bufio.NewWriter(io.MultiWriter(w, crc))
+	sw := snapshotWriter{w: bw}
+
+	sw.bytes([]byte(snapshotMagic))
+	sw.bytes(binary.LittleEndian.AppendUint16(nil, SnapshotVersion))
+	sw.uvarint(uint64(len(positions)))
+	for fen, info := range positions {
+		sw.string(fen)
+		sw.uvarint(uint64(info.Count))
+		sw.uvarint(uint64(info.DrawCount))
+		sw.uvarint(uint64(info.WinCount))
+		sw.uvarint(uint64(info.LossCount))
+		sw.uvarint(uint64(len(info.GamesId)))
+		for _, id := range info.GamesId {
+			sw.string(id)
+		}
+		sw.uvarint(uint64(len(info.Moves)))
+		for san, move := range info.Moves {
+			sw.string(san)
+			sw.string(move.Fen)
+			sw.uvarint(uint64(move.Count))
+			sw.uvarint(uint64(move.DrawCount))
+			sw.uvarint(uint64(move.WinCount))
+			sw.uvarint(uint64(move.LossCount))
+			sw.accuracy(move.Accuracy)
+		}
+		sw.uvarint(uint64(len(info.Terminations)))
+		for termination, count := range info.Terminations {
+			sw.uvarint(uint64(termination))
+			sw.uvarint(uint64(count.Win))
+			sw.uvarint(uint64(count.Loss))
+			sw.uvarint(uint64(count.Draw))
+		}
+		sw.accuracy(info.Accuracy)
+	}
+	sw.uvarint(uint64(len(openings)))
+	for eco, stat := range openings {
+		sw.string(eco)
+		sw.string(stat.Name)
+		sw.uvarint(uint64(stat.Games))
+		sw.accuracy(stat.Accuracy)
+	}
+	sw.uvarint(uint64(len(games)))
+	for id := range games {
+		sw.string(id)
+	}
+	if sw.err != nil {
+		return sw.err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := w.Write(binary.LittleEndian.AppendUint32(nil, crc.Sum32()))
+	return err
+}
+
+func ReadSnapshot(r io.Reader) (map[string]*types.PositonInfo, error) {
+	positions, _, _, err := readSnapshot(r)
+	return positions, err
+}
+
+func readSnapshot(r io.Reader) (map[string]*types.PositonInfo, map[string]*types.OpeningStat, map[string]bool, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(data) < len(snapshotMagic)+2+4 {
+		return nil, nil, nil, ErrSnapshotMagic
+	}
+	if string(data[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, nil, nil, ErrSnapshotMagic
+	}
+
+	body, sum := data[:len(data)-4], binary.LittleEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return nil, nil, nil, ErrSnapshotChecksum
+	}
+
+	version := binary.LittleEndian.Uint16(body[len(snapshotMagic):])
+	if version < 1 || version > SnapshotVersion {
+		return nil, nil, nil, fmt.Errorf("%w: %d", ErrSnapshotVersion, version)
+	}
+
+	sr := snapshotReader{r: bytes.NewReader(body[len(snapshotMagic)+2:])}
+	count := sr.uvarint()
+	positions := make(map[string]*types.PositonInfo, min(count, 1<<20))
+	for i := uint64(0); i < count && sr.err == nil; i++ {
+		fen := sr.string()
+		info := &types.PositonInfo{
+			Count:     int(sr.uvarint()),
+			DrawCount: int(sr.uvarint()),
+			WinCount:  int(sr.uvarint()),
+			LossCount: int(sr.uvarint()),
+		}
+		ids := sr.uvarint()
+		for j := uint64(0); j < ids && sr.err == nil; j++ {
+			info.GamesId = append(info.GamesId, sr.string())
+		}
+		if version >= 2 {
+			moves := sr.uvarint()
+			for j := uint64(0); j < moves && sr.err == nil; j++ {
+				if info.Moves == nil {
+					info.Moves = make(map[string]*types.MoveInfo)
+				}
+				san := sr.string()
+				info.Moves[san] = &types.MoveInfo{
+					Fen:       sr.string(),
+					Count:     int(sr.uvarint()),
+					DrawCount: int(sr.uvarint()),
+					WinCount:  int(sr.uvarint()),
+					LossCount: int(sr.uvarint()),
+				}
+				if version >= 4 {
+					info.Moves[san].Accuracy = sr.accuracy()
+				}
+			}
+		}
+		if version >= 3 {
+			terminations := sr.uvarint()
+			for j := uint64(0); j < terminations && sr.err == nil; j++ {
+				if info.Terminations == nil {
+					info.Terminations = make(map[types.Termination]*types.TerminationCount)
+				}
+				termination := types.Termination(sr.uvarint())
+				info.Terminations[termination] = &types.TerminationCount{
+					Win:  int(sr.uvarint()),
+					Loss: int(sr.uvarint()),
+					Draw: int(sr.uvarint()),
+				}
+			}
+		}
+		if version >= 4 {
+			info.Accuracy = sr.accuracy()
+		}
+		positions[fen] = info
+	}
+
+	openings := make(map[string]*types.OpeningStat)
+	if version >= 4 {
+		count := sr.uvarint()
+		for i := uint64(0); i < count && sr.err == nil; i++ {
+			eco := sr.string()
+			openings[eco] = &types.OpeningStat{
+				Name:     sr.string(),
+				Games:    int(sr.uvarint()),
+				Accuracy: sr.accuracy(),
+			}
+		}
+	}
+	games := make(map[string]bool)
+	if version >= 5 {
+		count := sr.uvarint()
+		for i := uint64(0); i < count && sr.err == nil; i++ {
+			games[sr.string()] = true
+		}
+	} else if root, exists := positions[StartFEN]; exists {
+		for _, id := range root.GamesId {
+			games[id] = true
+		}
+	}
+	// the snapshots written before MaxGameIDs keep every id
+	for _, info := range positions {
+		if len(info.GamesId) > MaxGameIDs {
+			info.GamesId = slices.Clone(info.GamesId[len(info.GamesId)-MaxGameIDs:])
+		}
+	}
+	if sr.err == nil && sr.r.Len() != 0 {
+		sr.err = errors.New("trailing data")
+	}
+	if sr.err != nil {
+		return nil, nil, nil, fmt.Errorf("snapshot: corrupt entry: %w", sr.err)
+	}
+	return positions, openings, games, nil
+}
+
+// snapshotWriter and snapshotReader keep the first error so the encoding
+// loops above stay readable.
+type snapshotWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (s *snapshotWriter) bytes(b []byte) {
+	if s.err == nil {
+		_, s.err = s.w.Write(b)
+	}
+}
+
+func (s *snapshotWriter) uvarint(v uint64) {
+	s.bytes(binary.AppendUvarint(nil, v))
+}
+
+func (s *snapshotWriter) string(v string) {
+	s.uvarint(uint64(len(v)))
+	s.bytes([]byte(v))
+}
+
+func (s *snapshotWriter) float(v float64) {
+	s.bytes(binary.LittleEndian.AppendUint64(nil, math.Float64bits(v)))
+}
+
+func (s *snapshotWriter) accuracy(a types.AccuracyStat) {
+	s.float(a.WhiteSum)
+	s.uvarint(uint64(a.WhiteGames))
+	s.float(a.BlackSum)
+	s.uvarint(uint64(a.BlackGames))
+}
+
+type snapshotReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (s *snapshotReader) uvarint() uint64 {
+	if s.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(s.r)
+	s.err = err
+	return v
+}
+
+func (s *snapshotReader) string() string {
+	n := s.uvarint()
+	if s.err != nil {
+		return ""
+	}
+	if n > uint64(s.r.Len()) {
+		s.err = io.ErrUnexpectedEOF
+		return ""
+	}
+	b := make([]byte, n)
+	_, s.err = io.ReadFull(s.r, b)
+	return string(b)
+}
+
+func (s *snapshotReader) float() float64 {
+	if s.err != nil {
+		return 0
+	}
+	var b [8]byte
+	_, s.err = io.ReadFull(s.r, b[:])
+	return math.Float64frombits(binary.LittleEndian.Uint64(b[:]))
+}
+
+func (s *snapshotReader) accuracy() types.AccuracyStat {
+	return types.AccuracyStat{
+		WhiteSum:   s.float(),
+		WhiteGames: int(s.uvarint()),
+		BlackSum:   s.float(),
+		BlackGames: int(s.uvarint()),
+	}
+}
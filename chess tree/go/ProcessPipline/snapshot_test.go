@@ -0,0 +1,146 @@
+package Processpipline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"chess/Types"
+)
+
+const afterE4 = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1"
+
+func testTree() (map[string]*types.PositonInfo, map[string]*types.OpeningStat, map[string]bool) {
+	positions := map[string]*types.PositonInfo{
+		StartFEN: {
+			Count:     3,
+			WinCount:  2,
+			LossCount: 1,
+			GamesId:   []string{"g1", "g2", "g3"},
+			Moves: map[string]*types.MoveInfo{
+				"e4": {Fen: afterE4, Count: 3, WinCount: 2, LossCount: 1,
+					Accuracy: types.AccuracyStat{WhiteSum: 171.25, WhiteGames: 2}},
+			},
+			Terminations: map[types.Termination]*types.TerminationCount{
+				types.Termination(1): {Win: 2},
+				types.Termination(2): {Loss: 1},
+			},
+			Accuracy: types.AccuracyStat{WhiteSum: 171.25, WhiteGames: 2},
+		},
+		afterE4: {
+			Count:     1,
+			DrawCount: 1,
+			GamesId:   []string{"g3"},
+		},
+	}
+	openings := map[string]*types.OpeningStat{
+		"B00": {Name: "King's Pawn Opening", Games: 3,
+			Accuracy: types.AccuracyStat{BlackSum: 80.5, BlackGames: 1}},
+	}
+	games := map[string]bool{"g1": true, "g2": true, "g3": true}
+	return positions, openings, games
+}
+
+func encode(t *testing.T) []byte {
+	t.Helper()
+	positions, openings, games := testTree()
+	var buf bytes.Buffer
+	if err := WriteSnapshot(&buf, positions, openings, games); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// resum replaces the trailing checksum of a snapshot changed by a test.
+func resum(data []byte) []byte {
+	body := data[:len(data)-4]
+	return binary.LittleEndian.AppendUint32(body, crc32.ChecksumIEEE(body))
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	positions, openings, games, err := readSnapshot(bytes.NewReader(encode(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPositions, wantOpenings, wantGames := testTree()
+	if !reflect.DeepEqual(positions, wantPositions) {
+		t.Errorf("positions differ after a round trip:\ngot  %+v\nwant %+v", positions, wantPositions)
+	}
+	if !reflect.DeepEqual(openings, wantOpenings) {
+		t.Errorf("openings differ after a round trip:\ngot  %+v\nwant %+v", openings, wantOpenings)
+	}
+	if !reflect.DeepEqual(games, wantGames) {
+		t.Errorf("games differ after a round trip: got %v, want %v", games, wantGames)
+	}
+}
+
+func TestSnapshotFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.snapshot")
+	saved, savedOpenings, savedGames := HashMap, Openings, Games
+	t.Cleanup(func() { HashMap, Openings, Games = saved, savedOpenings, savedGames })
+
+	HashMap, Openings, Games = testTree()
+	if err := SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	HashMap, Openings, Games = nil, nil, nil
+	if err := LoadSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	wantPositions, wantOpenings, wantGames := testTree()
+	if !reflect.DeepEqual(HashMap, wantPositions) || !reflect.DeepEqual(Openings, wantOpenings) || !reflect.DeepEqual(Games, wantGames) {
+		t.Errorf("the loaded tree differs from the saved one")
+	}
+}
+
+func TestLoadSnapshotMissingFile(t *testing.T) {
+	if err := LoadSnapshot(filepath.Join(t.TempDir(), "none.snapshot")); err != nil {
+		t.Errorf("a missing snapshot should load as an empty tree, got %v", err)
+	}
+}
+
+func TestSnapshotVersionMismatch(t *testing.T) {
+	for _, version := range []uint16{0, SnapshotVersion + 1} {
+		data := encode(t)
+		binary.LittleEndian.PutUint16(data[len(snapshotMagic):], version)
+		_, err := ReadSnapshot(bytes.NewReader(resum(data)))
+		if !errors.Is(err, ErrSnapshotVersion) {
+			t.Errorf("version %d: got %v, want %v", version, err, ErrSnapshotVersion)
+		}
+	}
+}
+
+func TestSnapshotChecksum(t *testing.T) {
+	data := encode(t)
+	for _, at := range []int{len(snapshotMagic) + 2, len(data) / 2, len(data) - 5, len(data) - 1} {
+		corrupt := bytes.Clone(data)
+		corrupt[at] ^= 0x40
+		_, err := ReadSnapshot(bytes.NewReader(corrupt))
+		if !errors.Is(err, ErrSnapshotChecksum) {
+			t.Errorf("byte %d flipped: got %v, want %v", at, err, ErrSnapshotChecksum)
+		}
+	}
+}
+
+func TestSnapshotMagic(t *testing.T) {
+	data := encode(t)
+	data[0] = 'X'
+	if _, err := ReadSnapshot(bytes.NewReader(data)); !errors.Is(err, ErrSnapshotMagic) {
+		t.Errorf("got %v, want %v", err, ErrSnapshotMagic)
+	}
+	if _, err := ReadSnapshot(bytes.NewReader(nil)); !errors.Is(err, ErrSnapshotMagic) {
+		t.Errorf("empty file: got %v, want %v", err, ErrSnapshotMagic)
+	}
+}
+
+func TestSnapshotTruncatedEntry(t *testing.T) {
+	data := encode(t)
+	truncated := resum(append(bytes.Clone(data[:len(data)/2]), 0, 0, 0, 0))
+	if _, err := ReadSnapshot(bytes.NewReader(truncated)); err == nil {
+		t.Error("a truncated snapshot with a valid checksum should not decode")
+	}
+}
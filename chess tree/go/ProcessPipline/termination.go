@@ -0,0 +1,99 @@
+package Processpipline
+
+import (
+	"sort"
+	"strings"
+
+	"chess/Types"
+)
+
+// NormalizeTermination maps the chess.com Termination tag, such as
+// "I_use_NVIM_Btw won on time" or "Game drawn by repetition", to its enum.
+func NormalizeTermination(tag string) types.Termination {
+	tag = strings.ToLower(tag)
+	switch {
+	case strings.Contains(tag, "timeout vs insufficient material"):
+		return types.TerminationTimeoutVsInsufficient
+	case strings.Contains(tag, "on time"):
+		return types.TerminationTime
+	case strings.Contains(tag, "checkmate"):
+		return types.TerminationCheckmate
+	case strings.Contains(tag, "resignation"):
+		return types.TerminationResignation
+	case strings.Contains(tag, "abandoned"):
+		return types.TerminationAbandoned
+	case strings.Contains(tag, "agreement"):
+		return types.TerminationAgreement
+	case strings.Contains(tag, "repetition"):
+		return types.TerminationRepetition
+	case strings.Contains(tag, "stalemate"):
+		return types.TerminationStalemate
+	case strings.Contains(tag, "insufficient material"):
+		return types.TerminationInsufficientMaterial
+	case strings.Contains(tag, "50-move"), strings.Contains(tag, "50 move"):
+		return types.TerminationFiftyMove
+	default:
+		return types.TerminationUnknown
+	}
+}
+
+// UpdateTermination counts how a game through info ended.
+func UpdateTermination(info *types.PositonInfo, termination types.Termination, isWin, isLoss, isDraw bool) {
+	if info.Terminations == nil {
+		info.Terminations = make(map[types.Termination]*types.TerminationCount)
+	}
+	count, exists := info.Terminations[termination]
+	if !exists {
+		count = &types.TerminationCount{}
+		info.Terminations[termination] = count
+	}
+	count.Win += btoi(isWin)
+	count.Loss += btoi(isLoss)
+	count.Draw += btoi(isDraw)
+}
+
+// TerminationReport ranks the positions by how often the games through them
+// ended with termination and result ("win", "loss" or "draw"), for instance
+// the lines where the user most often loses on time.
+func TerminationReport(tree map[string]*types.PositonInfo, termination types.Termination, result string, minGames, limit int) []types.TerminationLine {
+	lines := []types.TerminationLine{}
+	for fen, info := range tree {
+		if info.Count < minGames || info.Count == 0 {
+			continue
+		}
+		count, exists := info.Terminations[termination]
+		if !exists {
+			continue
+		}
+		n := count.Win
+		switch result {
+		case "loss":
+			n = count.Loss
+		case "draw":
+			n = count.Draw
+		}
+		if n == 0 {
+			continue
+		}
+		lines = append(lines, types.TerminationLine{
+			Fen:   fen,
+			Games: info.Count,
+			Count: n,
+			Rate:  float64(n) / float64(info.Count),
+		})
+	}
+
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].Rate != lines[j].Rate {
+			return lines[i].Rate > lines[j].Rate
+		}
+		if lines[i].Games != lines[j].Games {
+			return lines[i].Games > lines[j].Games
+		}
+		return lines[i].Fen < lines[j].Fen
+	})
+	if limit > 0 && len(lines) > limit {
+		lines = lines[:limit]
+	}
+	return lines
+}
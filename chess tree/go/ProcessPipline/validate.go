@@ -0,0 +1,309 @@
+package Processpipline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FENProblem is one reason a FEN is refused, Field being the FEN field at
+// fault: placement, turn, castling, enPassant, halfmove or fullmove.
+type FENProblem struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// FENError lists everything wrong with a FEN.
+type FENError struct {
+	Problems []FENProblem
+}
+
+func (e *FENError) Error() string {
+	messages := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		messages[i] = p.Field + ": " + p.Message
+	}
+	return "invalid FEN: " + strings.Join(messages, "; ")
+}
+
+func (e *FENError) add(field, format string, args ...any) {
+	e.Problems = append(e.Problems, FENProblem{field, fmt.Sprintf(format, args...)})
+}
+
+// NormalizeFEN checks that a FEN, or an EPD whose operations are ignored,
+// describes a position a game can reach and returns its PositionKey: the
+// placement with the empty squares merged, the castling rights in KQkq
+// order. The en passant square is kept as given, the pipeline writing it
+// after every double push whether a capture is possible or not. A FEN
+// refused comes with a *FENError.
+func NormalizeFEN(fen string) (string, error) {
+	fields := strings.Fields(fen)
+	problems := &FENError{}
+	if len(fields) < 4 {
+		problems.add("placement", "expected at least the placement, side to move, castling and en passant fields, got %d fields", len(fields))
+		return "", problems
+	}
+	if len(fields) >= 6 && isCounter(fields[4]) {
+		// a FEN, an EPD has operations like "bm Nf3;" instead of counters
+		if n, err := strconv.Atoi(fields[4]); err != nil || n < 0 {
+			problems.add("halfmove", "the halfmove clock must be a non-negative number, got %q", fields[4])
+		}
+		if n, err := strconv.Atoi(fields[5]); err != nil || n < 1 {
+			problems.add("fullmove", "the move number must be a positive number, got %q", fields[5])
+		}
+	}
+
+	b, ok := parsePlacement(fields[0], problems)
+	white := fields[1] == "w"
+	if fields[1] != "w" && fields[1] != "b" {
+		problems.add("turn", "the side to move must be w or b, got %q", fields[1])
+		ok = false
+	}
+	castling := ""
+	if fields[2] != "-" && strings.Trim(fields[2], "KQkq") != "" {
+		problems.add("castling", "the castling rights must be - or letters of KQkq, got %q", fields[2])
+	} else if fields[2] != "-" {
+		for _, right := range "KQkq" {
+			n := strings.Count(fields[2], string(right))
+			if n > 0 {
+				castling += string(right)
+			}
+			if n > 1 {
+				problems.add("castling", "the right %c is given %d times", right, n)
+			}
+		}
+	}
+	if castling == "" {
+		castling = "-"
+	}
+	if fields[3] != "-" {
+		if _, valid := square(fields[3]); !valid {
+			problems.add("enPassant", "the en passant square must be - or a square, got %q", fields[3])
+			fields[3] = "-"
+		}
+	}
+
+	if ok {
+		b.check(white, castling, fields[3], problems)
+	}
+	if len(problems.Problems) > 0 {
+		return "", problems
+	}
+	return strings.Join([]string{b.placement(), fields[1], castling, fields[3]}, " "), nil
+}
+
+func isCounter(field string) bool {
+	_, err := strconv.Atoi(field)
+	return err == nil
+}
+
+// fenBoard holds the FEN letter of the piece on each square, a1 = 0 to
+// h8 = 63, or 0.
+type fenBoard [64]byte
+
+func square(name string) (int, bool) {
+	if len(name) != 2 || name[0] < 'a' || name[0] > 'h' || name[1] < '1' || name[1] > '8' {
+		return 0, false
+	}
+	return int(name[1]-'1')*8 + int(name[0]-'a'), true
+}
+
+func squareName(sq int) string {
+	return string([]byte{byte('a' + sq%8), byte('1' + sq/8)})
+}
+
+func parsePlacement(placement string, problems *FENError) (*fenBoard, bool) {
+	ranks := strings.Split(placement, "/")
+	if len(ranks) != 8 {
+		problems.add("placement", "expected 8 ranks, got %d", len(ranks))
+		return nil, false
+	}
+	var b fenBoard
+	ok := true
+	for i, rank := range ranks {
+		file := 0
+		for _, r := range rank {
+			switch {
+			case r >= '1' && r <= '8':
+				file += int(r - '0')
+			case strings.ContainsRune("PNBRQKpnbrqk", r):
+				if file < 8 {
+					b[(7-i)*8+file] = byte(r)
+				}
+				file++
+			default:
+				problems.add("placement", "unknown piece %q on rank %d", r, 8-i)
+				ok = false
+			}
+		}
+		if file != 8 {
+			problems.add("placement", "rank %d has %d squares", 8-i, file)
+			ok = false
+		}
+	}
+	return &b, ok
+}
+
+// placement writes the board back as a FEN placement.
+func (b *fenBoard) placement() string {
+	var sb strings.Builder
+	for rank := 7; rank >= 0; rank-- {
+		empty := 0
+		for file := 0; file < 8; file++ {
+			p := b[rank*8+file]
+			if p == 0 {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteByte(byte('0' + empty))
+				empty = 0
+			}
+			sb.WriteByte(p)
+		}
+		if empty > 0 {
+			sb.WriteByte(byte('0' + empty))
+		}
+		if rank > 0 {
+			sb.WriteByte('/')
+		}
+	}
+	return sb.String()
+}
+
+// check looks for what no game can reach: a side without exactly one king,
+// pawns on the back ranks, castling rights without their king and rook, an
+// en passant square no double push left, or the side that just moved in
+// check.
+func (b *fenBoard) check(white bool, castling, enPassant string, problems *FENError) {
+	kings := map[byte]int{}
+	for sq, p := range b {
+		switch p {
+		case 'K', 'k':
+			kings[p] = sq
+		case 'P', 'p':
+			if sq < 8 || sq >= 56 {
+				problems.add("placement", "a pawn stands on %s, a back rank", squareName(sq))
+			}
+		}
+	}
+	for _, side := range []struct {
+		king  byte
+		pawn  byte
+		color string
+	}{{'K', 'P', "white"}, {'k', 'p', "black"}} {
+		if n := strings.Count(string(b[:]), string(side.king)); n != 1 {
+			problems.add("placement", "%s has %d kings", side.color, n)
+		}
+		if n := strings.Count(string(b[:]), string(side.pawn)); n > 8 {
+			problems.add("placement", "%s has %d pawns", side.color, n)
+		}
+	}
+
+	rights := []struct {
+		right      byte
+		king, rook int
+		pieces     string
+	}{
+		{'K', 4, 7, "KR"}, {'Q', 4, 0, "KR"},
+		{'k', 60, 63, "kr"}, {'q', 60, 56, "kr"},
+	}
+	for _, r := range rights {
+		if strings.IndexByte(castling, r.right) < 0 {
+			continue
+		}
+		if b[r.king] != r.pieces[0] || b[r.rook] != r.pieces[1] {
+			problems.add("castling", "%c needs the king on %s and the rook on %s", r.right, squareName(r.king), squareName(r.rook))
+		}
+	}
+
+	if enPassant != "-" {
+		sq, _ := square(enPassant)
+		// the pawn that moved two squares stands in front of the square,
+		// which it went through from an empty start square
+		pushed, from, pawn, rank := sq-8, sq+8, byte('p'), 5
+		if !white {
+			pushed, from, pawn, rank = sq+8, sq-8, 'P', 2
+		}
+		if sq/8 != rank {
+			problems.add("enPassant", "with %s to move the en passant square must be on rank %d", turnName(white), rank+1)
+		} else if b[pushed] != pawn || b[sq] != 0 || b[from] != 0 {
+			problems.add("enPassant", "no pawn just moved two squares through %s", enPassant)
+		}
+	}
+
+	waiting := byte('K')
+	if white {
+		waiting = 'k'
+	}
+	if king, found := kings[waiting]; found && b.attacked(king, white) {
+		problems.add("turn", "%s is in check with %s to move", turnName(!white), turnName(white))
+	}
+}
+
+func turnName(white bool) string {
+	if white {
+		return "white"
+	}
+	return "black"
+}
+
+// attacked tells whether the pieces of white, or black, attack sq.
+func (b *fenBoard) attacked(sq int, white bool) bool {
+	own := func(kind byte) byte {
+		if white {
+			return kind - 'a' + 'A'
+		}
+		return kind
+	}
+	file, rank := sq%8, sq/8
+	at := func(df, dr int) (byte, bool) {
+		f, r := file+df, rank+dr
+		if f < 0 || f > 7 || r < 0 || r > 7 {
+			return 0, false
+		}
+		return b[r*8+f], true
+	}
+
+	// a white pawn attacks upwards, so it stands below the square
+	pawnRank := -1
+	if !white {
+		pawnRank = 1
+	}
+	for _, df := range []int{-1, 1} {
+		if p, _ := at(df, pawnRank); p == own('p') {
+			return true
+		}
+	}
+	for _, d := range [][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}} {
+		if p, _ := at(d[0], d[1]); p == own('n') {
+			return true
+		}
+	}
+	for df := -1; df <= 1; df++ {
+		for dr := -1; dr <= 1; dr++ {
+			if p, _ := at(df, dr); (df != 0 || dr != 0) && p == own('k') {
+				return true
+			}
+		}
+	}
+	for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}} {
+		slider := own('r')
+		if d[0] != 0 && d[1] != 0 {
+			slider = own('b')
+		}
+		for step := 1; ; step++ {
+			p, inside := at(d[0]*step, d[1]*step)
+			if !inside {
+				break
+			}
+			if p == slider || p == own('q') {
+				return true
+			}
+			if p != 0 {
+				break
+			}
+		}
+	}
+	return false
+}
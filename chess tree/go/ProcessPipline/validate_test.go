@@ -0,0 +1,115 @@
+package Processpipline
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	lib "github.com/notnil/chess"
+)
+
+func TestNormalizeFEN(t *testing.T) {
+	tests := []struct {
+		name, fen, want string
+	}{
+		{"start", StartFEN, PositionKey(StartFEN)},
+		{"epd", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 bm e5; id \"open\";",
+			"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3"},
+		{"epd without operations", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq -", PositionKey(StartFEN)},
+		{"castling order", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w kqQK - 0 1", PositionKey(StartFEN)},
+		{"split empty squares", "rnbqkbnr/pppppppp/44/8/251/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", PositionKey(StartFEN)},
+		{"spaces", "  rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR   w KQkq -  0 1 ", PositionKey(StartFEN)},
+		{"no castling", "4k3/8/8/8/8/8/8/4K3 w - - 12 40", "4k3/8/8/8/8/8/8/4K3 w - -"},
+		{"castling king moved", "r3k2r/8/8/8/8/8/8/R4K1R w q - 0 1", "r3k2r/8/8/8/8/8/8/R4K1R w q -"},
+		{"check blocked", "4k3/3p4/8/1B6/8/8/8/4K3 w - - 0 1", "4k3/3p4/8/1B6/8/8/8/4K3 w - -"},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeFEN(tt.fen)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: key %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestNormalizeFENProblems checks the fields blamed for each kind of FEN
+// refused, a problem being reported once.
+func TestNormalizeFENProblems(t *testing.T) {
+	tests := []struct {
+		name, fen string
+		fields    []string
+	}{
+		{"too few fields", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w", []string{"placement"}},
+		{"seven ranks", "rnbqkbnr/pppppppp/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", []string{"placement"}},
+		{"unknown piece", "rnbqkbnr/pppppppp/8/8/3X4/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", []string{"placement", "placement"}},
+		{"long rank", "rnbqkbnr/pppppppp/9/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", []string{"placement", "placement"}},
+		{"two kings", "4k3/8/8/8/8/8/8/3KK3 w - - 0 1", []string{"placement"}},
+		{"no king", "8/8/8/8/8/8/8/4K3 w - - 0 1", []string{"placement"}},
+		{"back rank pawn", "4k2P/8/8/8/8/8/8/4K3 w - - 0 1", []string{"placement"}},
+		{"nine pawns", "4k3/8/8/8/8/P7/PPPPPPPP/4K3 w - - 0 1", []string{"placement"}},
+
+		{"turn", "4k3/8/8/8/8/8/8/4K3 x - - 0 1", []string{"turn"}},
+		{"rook check", "4k3/8/8/8/8/8/8/4RK2 w - - 0 1", []string{"turn"}},
+		{"pawn check", "4k3/3P4/8/8/8/8/8/4K3 w - - 0 1", []string{"turn"}},
+		{"knight check", "4k3/8/5N2/8/8/8/8/4K3 w - - 0 1", []string{"turn"}},
+		{"bishop check", "4k3/8/8/1B6/8/8/8/4K3 w - - 0 1", []string{"turn"}},
+		{"queen check", "4K3/8/8/8/q7/8/8/4k3 b - - 0 1", []string{"turn"}},
+		{"king check", "8/8/8/8/8/8/3kK3/8 w - - 0 1", []string{"turn"}},
+
+		{"castling letter", "4k3/8/8/8/8/8/8/R3K2R w KX - 0 1", []string{"castling"}},
+		{"castling twice", "4k3/8/8/8/8/8/8/R3K2R w KK - 0 1", []string{"castling"}},
+		{"castling twice without rook", "4k3/8/8/8/8/8/8/4K3 w KK - 0 1", []string{"castling", "castling"}},
+		{"castling without rook", "4k3/8/8/8/8/8/8/4K3 w K - 0 1", []string{"castling"}},
+		{"castling black king moved", "r4k1r/8/8/8/8/8/8/R3K2R w Kq - 0 1", []string{"castling"}},
+
+		{"en passant square", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq z9 0 1", []string{"enPassant"}},
+		{"en passant rank", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e3 0 1", []string{"enPassant"}},
+		{"en passant no push", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq e3 0 1", []string{"enPassant"}},
+
+		{"halfmove", "4k3/8/8/8/8/8/8/4K3 w - - -1 1", []string{"halfmove"}},
+		{"fullmove", "4k3/8/8/8/8/8/8/4K3 w - - 0 0", []string{"fullmove"}},
+	}
+	for _, tt := range tests {
+		_, err := NormalizeFEN(tt.fen)
+		var invalid *FENError
+		if !errors.As(err, &invalid) {
+			t.Errorf("%s: err = %v, want a FENError", tt.name, err)
+			continue
+		}
+		fields := make([]string, len(invalid.Problems))
+		for i, p := range invalid.Problems {
+			fields[i] = p.Field
+		}
+		if !slices.Equal(fields, tt.fields) {
+			t.Errorf("%s: problems %+v, want fields %v", tt.name, invalid.Problems, tt.fields)
+		}
+	}
+}
+
+// TestNormalizeFENIsPositionKey checks a FEN the pipeline writes normalizes
+// to the key it stores the position under, through castling, en passant and
+// a promotion.
+func TestNormalizeFENIsPositionKey(t *testing.T) {
+	game := lib.NewGame()
+	sans := []string{
+		"e4", "d5", "e5", "f5", "exf6", "Nc6", "fxg7", "Bg4", "gxh8=Q", "Qd6",
+		"Nf3", "O-O-O", "Bc4", "e5", "O-O", "e4", "d4", "exd3",
+	}
+	for _, san := range sans {
+		if err := game.MoveStr(san); err != nil {
+			t.Fatalf("%s: %v", san, err)
+		}
+		fen := game.FEN()
+		got, err := NormalizeFEN(fen)
+		if err != nil {
+			t.Errorf("after %s: %v", san, err)
+			continue
+		}
+		if want := PositionKey(fen); got != want {
+			t.Errorf("after %s: key %q, want %q", san, got, want)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	lib "github.com/notnil/chess"
+)
+
+const (
+	squareSize  = 45
+	boardSize   = 8 * squareSize
+	DefaultSize = boardSize
+	MinSize     = 64
+	MaxSize     = 2048
+
+	lightSquare = "#f0d9b5"
+	darkSquare  = "#b58863"
+	highlight   = "#cdd26a"
+)
+
+// ArrowColors are the colors an arrow can be drawn in, green by default.
+var ArrowColors = map[string]string{
+	"green":  "#15781b",
+	"red":    "#882020",
+	"blue":   "#003088",
+	"yellow": "#e68f00",
+}
+
+var (
+	ErrInvalidFEN         = errors.New("render: invalid FEN")
+	ErrInvalidOrientation = errors.New("render: orientation must be white or black")
+	ErrInvalidMove        = errors.New("render: a move must be two squares like e2e4")
+	ErrInvalidArrow       = errors.New("render: an arrow must be two squares like e2e4, or a square to circle, with an optional :green, :red, :blue or :yellow")
+	ErrInvalidSize        = fmt.Errorf("render: size must be between %d and %d", MinSize, MaxSize)
+)
+
+// Arrow goes from From to To, a circle around From when To is empty.
+type Arrow struct {
+	From, To string
+	Color    string
+}
+
+type Options struct {
+	// FEN is the position, only its piece placement is read.
+	FEN string
+	// Orientation is the side at the bottom, "white" or "black".
+	Orientation string
+	// LastMove highlights its squares, in UCI like "e2e4" or "e7e8q".
+	LastMove    string
+	Arrows      []Arrow
+	Coordinates bool
+	// Size is the width and height of the image, DefaultSize when 0.
+	Size int
+}
+
+// ParseArrows reads a comma separated list of arrows like
+// "e2e4,g1f3:red,d5:blue".
+func ParseArrows(list string) ([]Arrow, error) {
+	var arrows []Arrow
+	for _, raw := range strings.Split(list, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		squares, color, _ := strings.Cut(raw, ":")
+		if color == "" {
+			color = "green"
+		}
+		if _, known := ArrowColors[color]; !known {
+			return nil, ErrInvalidArrow
+		}
+		arrow := Arrow{Color: color}
+		switch len(squares) {
+		case 2:
+			arrow.From = squares
+		case 4:
+			arrow.From, arrow.To = squares[:2], squares[2:]
+		default:
+			return nil, ErrInvalidArrow
+		}
+		if !validSquare(arrow.From) || (arrow.To != "" && !validSquare(arrow.To)) {
+			return nil, ErrInvalidArrow
+		}
+		arrows = append(arrows, arrow)
+	}
+	return arrows, nil
+}
+
+func validSquare(s string) bool {
+	return len(s) == 2 && s[0] >= 'a' && s[0] <= 'h' && s[1] >= '1' && s[1] <= '8'
+}
+
+// Board draws the position of o as an SVG image: the squares, the last move
+// highlighted, the coordinates along the bottom and left edges, the pieces
+// and the arrows on top. The same options always give the same bytes.
+func Board(o Options) ([]byte, error) {
+	placement, _, _ := strings.Cut(strings.TrimSpace(o.FEN), " ")
+	board := &lib.Board{}
+	if err := board.UnmarshalText([]byte(placement)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFEN, err)
+	}
+	if o.Orientation == "" {
+		o.Orientation = "white"
+	}
+	if o.Orientation != "white" && o.Orientation != "black" {
+		return nil, ErrInvalidOrientation
+	}
+	var lastMove []string
+	if o.LastMove != "" {
+		move := o.LastMove
+		if len(move) == 5 && strings.IndexByte("qrbn", move[4]) >= 0 {
+			move = move[:4]
+		}
+		if len(move) != 4 || !validSquare(move[:2]) || !validSquare(move[2:]) {
+			return nil, ErrInvalidMove
+		}
+		lastMove = []string{move[:2], move[2:]}
+	}
+	if o.Size == 0 {
+		o.Size = DefaultSize
+	}
+	if o.Size < MinSize || o.Size > MaxSize {
+		return nil, ErrInvalidSize
+	}
+
+	d := &drawing{flipped: o.Orientation == "black"}
+	fmt.Fprintf(&d.sb, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%d" height="%d" viewBox="0 0 %d %d">`, o.Size, o.Size, boardSize, boardSize)
+	d.pieceSymbols(board)
+	d.squares()
+	for _, square := range lastMove {
+		x, y := d.corner(square)
+		fmt.Fprintf(&d.sb, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s" fill-opacity="0.8"/>`, x, y, squareSize, squareSize, highlight)
+	}
+	if o.Coordinates {
+		d.coordinates()
+	}
+	d.pieces(board)
+	for _, arrow := range o.Arrows {
+		d.arrow(arrow)
+	}
+	d.sb.WriteString(`</svg>`)
+	return []byte(d.sb.String()), nil
+}
+
+type drawing struct {
+	sb      strings.Builder
+	flipped bool
+}
+
+// corner is the top left corner of a square in the board's coordinates.
+func (d *drawing) corner(square string) (int, int) {
+	file, rank := int(square[0]-'a'), int(square[1]-'1')
+	if d.flipped {
+		return (7 - file) * squareSize, rank * squareSize
+	}
+	return file * squareSize, (7 - rank) * squareSize
+}
+
+func (d *drawing) center(square string) (float64, float64) {
+	x, y := d.corner(square)
+	return float64(x) + squareSize/2.0, float64(y) + squareSize/2.0
+}
+
+func squareName(file, rank int) string {
+	return string([]byte{byte('a' + file), byte('1' + rank)})
+}
+
+// pieceID is the id of the symbol of a piece, "wK" for the white king.
+func pieceID(p lib.Piece) string {
+	letter := strings.ToUpper(p.Type().String())
+	if p.Color() == lib.White {
+		return "w" + letter
+	}
+	return "b" + letter
+}
+
+// pieceSymbols defines the pieces on the board, in a fixed order.
+func (d *drawing) pieceSymbols(board *lib.Board) {
+	used := make(map[string]bool)
+	for _, p := range board.SquareMap() {
+		used[pieceID(p)] = true
+	}
+	d.sb.WriteString(`<defs>`)
+	for _, side := range []string{"w", "b"} {
+		colors := whiteColors
+		if side == "b" {
+			colors = blackColors
+		}
+		for _, letter := range "KQRBNP" {
+			id := side + string(letter)
+			if !used[id] {
+				continue
+			}
+			shape := strings.ReplaceAll(pieceShapes[byte(letter-'A'+'a')], "DETAIL", colors.detail)
+			fmt.Fprintf(&d.sb, `<symbol id="%s" viewBox="0 0 45 45"><g fill="%s" stroke="%s" stroke-width="1.5" stroke-linejoin="round" stroke-linecap="round">%s</g></symbol>`,
+				id, colors.fill, colors.stroke, shape)
+		}
+	}
+	d.sb.WriteString(`</defs>`)
+}
+
+func (d *drawing) squares() {
+	fmt.Fprintf(&d.sb, `<rect width="%d" height="%d" fill="%s"/>`, boardSize, boardSize, lightSquare)
+	for rank := 0; rank < 8; rank++ {
+		for file := 0; file < 8; file++ {
+			if (file+rank)%2 != 0 {
+				continue
+			}
+			// a1 is dark
+			x, y := d.corner(squareName(file, rank))
+			fmt.Fprintf(&d.sb, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`, x, y, squareSize, squareSize, darkSquare)
+		}
+	}
+}
+
+// coordinates writes the files along the bottom edge and the ranks along the
+// left one, inside the squares in the color of the other squares.
+func (d *drawing) coordinates() {
+	d.sb.WriteString(`<g font-family="sans-serif" font-size="10" font-weight="bold">`)
+	for i := 0; i < 8; i++ {
+		file, rank := i, i
+		bottom, left := 0, 0
+		if d.flipped {
+			file, rank = 7-i, 7-i
+			bottom, left = 7, 7
+		}
+		x, y := d.corner(squareName(file, bottom))
+		fmt.Fprintf(&d.sb, `<text x="%d" y="%d" text-anchor="end" fill="%s">%c</text>`,
+			x+squareSize-3, y+squareSize-3, coordinateColor(file, bottom), 'a'+file)
+		x, y = d.corner(squareName(left, rank))
+		fmt.Fprintf(&d.sb, `<text x="%d" y="%d" fill="%s">%c</text>`,
+			x+3, y+11, coordinateColor(left, rank), '1'+rank)
+	}
+	d.sb.WriteString(`</g>`)
+}
+
+func coordinateColor(file, rank int) string {
+	if (file+rank)%2 == 0 {
+		return lightSquare
+	}
+	return darkSquare
+}
+
+func (d *drawing) pieces(board *lib.Board) {
+	for rank := 7; rank >= 0; rank-- {
+		for file := 0; file < 8; file++ {
+			p := board.Piece(lib.NewSquare(lib.File(file), lib.Rank(rank)))
+			if p == lib.NoPiece {
+				continue
+			}
+			x, y := d.corner(squareName(file, rank))
+			fmt.Fprintf(&d.sb, `<use xlink:href="#%s" x="%d" y="%d" width="%d" height="%d"/>`, pieceID(p), x, y, squareSize, squareSize)
+		}
+	}
+}
+
+// arrow draws an arrow as one polygon, its shaft starting at the center of
+// From and its head ending short of the center of To.
+func (d *drawing) arrow(a Arrow) {
+	color := ArrowColors[a.Color]
+	x1, y1 := d.center(a.From)
+	if a.To == "" || a.To == a.From {
+		fmt.Fprintf(&d.sb, `<circle cx="%s" cy="%s" r="%s" fill="none" stroke="%s" stroke-width="4" stroke-opacity="0.8"/>`,
+			number(x1), number(y1), number(squareSize/2.0-3), color)
+		return
+	}
+	x2, y2 := d.center(a.To)
+	const (
+		shaft    = 4.5  // half width
+		head     = 11.5 // half width
+		headLong = 17.0
+		short    = 7.0
+	)
+	length := math.Hypot(x2-x1, y2-y1)
+	ux, uy := (x2-x1)/length, (y2-y1)/length
+	nx, ny := -uy, ux
+	tipX, tipY := x2-ux*short, y2-uy*short
+	baseX, baseY := tipX-ux*headLong, tipY-uy*headLong
+	points := [][2]float64{
+		{x1 + nx*shaft, y1 + ny*shaft},
+		{baseX + nx*shaft, baseY + ny*shaft},
+		{baseX + nx*head, baseY + ny*head},
+		{tipX, tipY},
+		{baseX - nx*head, baseY - ny*head},
+		{baseX - nx*shaft, baseY - ny*shaft},
+		{x1 - nx*shaft, y1 - ny*shaft},
+	}
+	formatted := make([]string, len(points))
+	for i, p := range points {
+		formatted[i] = number(p[0]) + "," + number(p[1])
+	}
+	fmt.Fprintf(&d.sb, `<polygon points="%s" fill="%s" fill-opacity="0.8"/>`, strings.Join(formatted, " "), color)
+}
+
+// number writes a coordinate with at most two decimals, -0 being 0.
+func number(v float64) string {
+	v = math.Round(v*100) / 100
+	if v == 0 {
+		v = 0
+	}
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+}
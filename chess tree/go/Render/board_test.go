@@ -0,0 +1,105 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+const (
+	startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+	e4FEN    = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"
+)
+
+func TestBoardGolden(t *testing.T) {
+	arrows, err := ParseArrows("e2e4,g1f3:red,d5:blue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"start_white", Options{FEN: startFEN, Orientation: "white", Coordinates: true}},
+		{"start_black", Options{FEN: startFEN, Orientation: "black", Coordinates: true}},
+		{"last_move", Options{FEN: e4FEN, LastMove: "e2e4", Coordinates: true}},
+		{"last_move_black", Options{FEN: e4FEN, Orientation: "black", LastMove: "e2e4", Coordinates: true}},
+		{"arrows", Options{FEN: startFEN, Arrows: arrows, Coordinates: true}},
+		{"arrows_black", Options{FEN: startFEN, Orientation: "black", Arrows: arrows, Coordinates: true}},
+		{"no_coordinates", Options{FEN: startFEN}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Board(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", tt.name+".svg")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("board differs from %s, rerun with -update if the change is wanted", golden)
+			}
+		})
+	}
+}
+
+func TestBoardErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want error
+	}{
+		{"fen", Options{FEN: "not a fen"}, ErrInvalidFEN},
+		{"orientation", Options{FEN: startFEN, Orientation: "left"}, ErrInvalidOrientation},
+		{"last move", Options{FEN: startFEN, LastMove: "e2"}, ErrInvalidMove},
+		{"small", Options{FEN: startFEN, Size: MinSize - 1}, ErrInvalidSize},
+		{"large", Options{FEN: startFEN, Size: MaxSize + 1}, ErrInvalidSize},
+	}
+	for _, tt := range tests {
+		if _, err := Board(tt.opts); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestParseArrows(t *testing.T) {
+	got, err := ParseArrows("e2e4,g1f3:red,d5:blue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Arrow{
+		{From: "e2", To: "e4", Color: "green"},
+		{From: "g1", To: "f3", Color: "red"},
+		{From: "d5", Color: "blue"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("arrows = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("arrow %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if arrows, err := ParseArrows(""); err != nil || len(arrows) != 0 {
+		t.Errorf("empty list: %+v, %v", arrows, err)
+	}
+	for _, list := range []string{"e2e9", "e2e4:purple", "e2e4e5", "z1", "e2-e4"} {
+		if _, err := ParseArrows(list); !errors.Is(err, ErrInvalidArrow) {
+			t.Errorf("%q: err = %v, want ErrInvalidArrow", list, err)
+		}
+	}
+}
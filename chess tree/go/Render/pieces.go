@@ -0,0 +1,45 @@
+package render
+
+// The pieces are drawn in a 45x45 square, from shapes rather than font glyphs
+// so that an SVG renderer without a chess font draws them too. Each is a
+// symbol with the id of its FEN letter prefixed by w or b, "wK" for the white
+// king. The fill, stroke and details colors come from the side.
+
+type pieceColors struct {
+	fill, stroke, detail string
+}
+
+var (
+	whiteColors = pieceColors{"#ffffff", "#000000", "#000000"}
+	blackColors = pieceColors{"#000000", "#000000", "#ffffff"}
+)
+
+const base = `<rect x="11.5" y="33" width="22" height="4.5" rx="1.5"/>`
+
+// pieceShapes holds the elements of each piece by lowercase FEN letter, DETAIL
+// standing for the color of the lines drawn over the fill.
+var pieceShapes = map[byte]string{
+	'p': `<circle cx="22.5" cy="15" r="5.5"/>` +
+		`<path d="M16 33.5C16 26 19.5 21.5 22.5 21.5C25.5 21.5 29 26 29 33.5Z"/>` +
+		base,
+	'r': `<path d="M12.5 17V10H16.5V13H20.5V10H24.5V13H28.5V10H32.5V17Z"/>` +
+		`<path d="M14.5 33L16 17H29L30.5 33Z"/>` +
+		base,
+	'b': `<circle cx="22.5" cy="10.5" r="2.5"/>` +
+		`<path d="M15 33C15 26 17 21.5 22.5 14C28 21.5 30 26 30 33Z"/>` +
+		`<path d="M25.5 20.5L20 26" stroke="DETAIL" fill="none"/>` +
+		base,
+	'n': `<path d="M14 33C15 27 19 24 21 19.5C20 20.5 18.5 21 17 21.5L13.5 24.5C12 25.5 10 24 10.5 22C11.5 17 14 12.5 17 10.5L19 7L20.5 10C26 11.5 29.5 16 30.5 22.5C31 26 31 30 31 33Z"/>` +
+		`<circle cx="18.5" cy="14.5" r="1.2" fill="DETAIL" stroke="none"/>` +
+		base,
+	'q': `<path d="M12 33L9.5 14L15 24L16 11L20 23L22.5 9.5L25 23L29 11L30 24L35.5 14L33 33Z"/>` +
+		`<circle cx="9.5" cy="13" r="2.2"/><circle cx="16" cy="10" r="2.2"/>` +
+		`<circle cx="22.5" cy="8.5" r="2.2"/><circle cx="29" cy="10" r="2.2"/>` +
+		`<circle cx="35.5" cy="13" r="2.2"/>` +
+		base,
+	'k': `<path d="M22.5 5.5V14M18.5 9H26.5" fill="none" stroke-width="2.2"/>` +
+		`<path d="M20 21.5L20.5 15H24.5L25 21.5Z"/>` +
+		`<path d="M12 33C10 26 12 20 17 20C19.5 20 21.5 22 22.5 24C23.5 22 25.5 20 28 20C33 20 35 26 33 33Z"/>` +
+		`<path d="M13.5 29H31.5" stroke="DETAIL" fill="none"/>` +
+		base,
+}
@@ -0,0 +1,105 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+)
+
+// board is the piece placement of a FEN, squares indexed a1 = 0 to h8 = 63
+// and holding the FEN letter of their piece or 0.
+type board [64]byte
+
+func parseBoard(fen string) (*board, error) {
+	placement, _, _ := strings.Cut(fen, " ")
+	ranks := strings.Split(placement, "/")
+	if len(ranks) != 8 {
+		return nil, fmt.Errorf("search: invalid FEN %q", fen)
+	}
+	var b board
+	for i, rank := range ranks {
+		file := 0
+		for _, r := range rank {
+			switch {
+			case r >= '1' && r <= '8':
+				file += int(r - '0')
+			case strings.ContainsRune("PNBRQKpnbrqk", r):
+				if file > 7 {
+					return nil, fmt.Errorf("search: invalid FEN %q", fen)
+				}
+				b[(7-i)*8+file] = byte(r)
+				file++
+			default:
+				return nil, fmt.Errorf("search: invalid FEN %q", fen)
+			}
+		}
+		if file != 8 {
+			return nil, fmt.Errorf("search: invalid FEN %q", fen)
+		}
+	}
+	return &b, nil
+}
+
+// parseSquare reads a square like "e4".
+func parseSquare(s string) (int, bool) {
+	if len(s) != 2 || s[0] < 'a' || s[0] > 'h' || s[1] < '1' || s[1] > '8' {
+		return 0, false
+	}
+	return int(s[1]-'1')*8 + int(s[0]-'a'), true
+}
+
+// piece is the FEN letter of kind ("k", "q", ...) for white or black.
+func piece(kind byte, white bool) byte {
+	if white {
+		return kind - 'a' + 'A'
+	}
+	return kind
+}
+
+func (b *board) has(p byte, file int) bool {
+	for rank := 0; rank < 8; rank++ {
+		if b[rank*8+file] == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *board) count(p byte) int {
+	n := 0
+	for _, sq := range b {
+		if sq == p {
+			n++
+		}
+	}
+	return n
+}
+
+// isolatedQueenPawn is a d-pawn of the side without pawns on the c and e
+// files.
+func (b *board) isolatedQueenPawn(white bool) bool {
+	pawn := piece('p', white)
+	return b.has(pawn, 3) && !b.has(pawn, 2) && !b.has(pawn, 4)
+}
+
+// castled tells the wing the king of the side stands on along its back rank,
+// g or h file for kingside and a to c for queenside, empty when it is
+// anywhere else. The board does not know how the king got there.
+func (b *board) castled(white bool) string {
+	back := 0
+	if !white {
+		back = 7
+	}
+	king := piece('k', white)
+	for file := 0; file < 8; file++ {
+		if b[back*8+file] != king {
+			continue
+		}
+		switch {
+		case file >= 6:
+			return kingside
+		case file <= 2:
+			return queenside
+		}
+	}
+	return ""
+}
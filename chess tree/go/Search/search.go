@@ -0,0 +1,457 @@
+// Package search matches positions by their structure rather than their
+// exact FEN, with queries like
+//
+//	iqp me and not queens-off
+//	opposite-castling and move <= 20
+//	queens-off and move < 15
+//	on Nf3 pe5 and empty d4
+//
+// A query is predicates joined by and, or and not, with parentheses. The
+// predicates are:
+//
+//	iqp [side]                    an isolated d-pawn, no pawn on c or e
+//	castled [side] [wing]         a king on g/h or a/b/c of its back rank
+//	opposite-castling             the kings castled on different wings
+//	queens-off                    no queen left on the board
+//	on <piece><square>...         pieces placed, FEN letters: Nf3 white, pe5 black
+//	empty <square>...             squares without a piece
+//	move <op> N, ply <op> N       the move or ply the position came after
+//
+// A side is me (the default), opponent, white or black, a wing kingside or
+// queenside, an op one of = != < <= > >=. Keywords are case insensitive,
+// piece letters are not.
+//
+// The stores only keep the positions of the first plies of a game, MAX_PLIES
+// of the pipeline. Reachable tells the queries no position within them can
+// match, like "move > 20" with the default 31 plies, which are rejected
+// rather than searched for nothing.
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxLength bounds the length of a query.
+const MaxLength = 1000
+
+const (
+	kingside  = "kingside"
+	queenside = "queenside"
+)
+
+// SyntaxError is a query that does not parse, Offset being the byte of the
+// query where the problem is.
+type SyntaxError struct {
+	Offset  int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("search: %s (at offset %d)", e.Message, e.Offset)
+}
+
+// Query is a parsed search.
+type Query struct {
+	source string
+	root   node
+}
+
+// position is what a node looks at, white telling the color the user played.
+type position struct {
+	board *board
+	ply   int
+	white bool
+}
+
+// node is a predicate or a combination of them. match looks at a position,
+// plies tells what the node is at a ply whatever the board.
+type node struct {
+	match func(p *position) bool
+	plies func(ply int) outcome
+}
+
+// outcome is whether a node holds at a ply, always, never or depending on
+// the board.
+type outcome int
+
+const (
+	depends outcome = iota
+	always
+	never
+)
+
+// onBoard is the node of a predicate looking at the board only.
+func onBoard(match func(p *position) bool) node {
+	return node{match, func(int) outcome { return depends }}
+}
+
+func Parse(source string) (*Query, error) {
+	if len(source) > MaxLength {
+		return nil, &SyntaxError{MaxLength, fmt.Sprintf("the query is longer than %d bytes", MaxLength)}
+	}
+	p := &parser{source: source, tokens: tokenize(source)}
+	if len(p.tokens) == 0 {
+		return nil, &SyntaxError{0, "the query is empty"}
+	}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.peek(); ok {
+		return nil, p.errorf(t, "expected and, or or the end of the query, got %q", t.text)
+	}
+	return &Query{source: source, root: root}, nil
+}
+
+func (q *Query) String() string {
+	return q.source
+}
+
+// Match tells whether the position of fen, reached after ply half moves in a
+// game where the user played color, matches the query. A FEN that does not
+// parse matches nothing.
+func (q *Query) Match(fen string, ply int, color string) bool {
+	b, err := parseBoard(fen)
+	if err != nil {
+		return false
+	}
+	return q.root.match(&position{board: b, ply: ply, white: color == "white"})
+}
+
+// Reachable tells whether a position reached within maxPly half moves can
+// match the query, as far as its move and ply bounds tell.
+func (q *Query) Reachable(maxPly int) bool {
+	for ply := 0; ply <= maxPly; ply++ {
+		if q.root.plies(ply) != never {
+			return true
+		}
+	}
+	return false
+}
+
+type token struct {
+	text   string
+	offset int
+}
+
+// tokenize splits a query into words, parentheses and comparison operators.
+func tokenize(source string) []token {
+	var tokens []token
+	for i := 0; i < len(source); {
+		c := source[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '(' || c == ')':
+			i++
+		case strings.IndexByte("<>=!", c) >= 0:
+			for i < len(source) && strings.IndexByte("<>=!", source[i]) >= 0 {
+				i++
+			}
+		default:
+			for i < len(source) && strings.IndexByte(" \t\n\r()<>=!", source[i]) < 0 {
+				i++
+			}
+		}
+		tokens = append(tokens, token{source[start:i], start})
+	}
+	return tokens
+}
+
+type parser struct {
+	source string
+	tokens []token
+	next   int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.next == len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.next], true
+}
+
+// keyword consumes the next token when it is word, whatever its case.
+func (p *parser) keyword(word string) bool {
+	t, ok := p.peek()
+	if !ok || !strings.EqualFold(t.text, word) {
+		return false
+	}
+	p.next++
+	return true
+}
+
+func (p *parser) take(what string) (token, error) {
+	t, ok := p.peek()
+	if !ok {
+		return t, &SyntaxError{len(p.source), "expected " + what + ", got the end of the query"}
+	}
+	p.next++
+	return t, nil
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return &SyntaxError{t.offset, fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return node{}, err
+	}
+	for p.keyword("or") {
+		right, err := p.and()
+		if err != nil {
+			return node{}, err
+		}
+		l := left
+		left = node{
+			match: func(pos *position) bool { return l.match(pos) || right.match(pos) },
+			plies: func(ply int) outcome {
+				a, b := l.plies(ply), right.plies(ply)
+				switch {
+				case a == always || b == always:
+					return always
+				case a == never && b == never:
+					return never
+				}
+				return depends
+			},
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return node{}, err
+	}
+	for p.keyword("and") {
+		right, err := p.unary()
+		if err != nil {
+			return node{}, err
+		}
+		l := left
+		left = node{
+			match: func(pos *position) bool { return l.match(pos) && right.match(pos) },
+			plies: func(ply int) outcome {
+				a, b := l.plies(ply), right.plies(ply)
+				switch {
+				case a == never || b == never:
+					return never
+				case a == always && b == always:
+					return always
+				}
+				return depends
+			},
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (node, error) {
+	if p.keyword("not") {
+		n, err := p.unary()
+		if err != nil {
+			return node{}, err
+		}
+		return node{
+			match: func(pos *position) bool { return !n.match(pos) },
+			plies: func(ply int) outcome {
+				switch n.plies(ply) {
+				case always:
+					return never
+				case never:
+					return always
+				}
+				return depends
+			},
+		}, nil
+	}
+	if p.keyword("(") {
+		n, err := p.or()
+		if err != nil {
+			return node{}, err
+		}
+		t, err := p.take(")")
+		if err != nil {
+			return node{}, err
+		}
+		if t.text != ")" {
+			return node{}, p.errorf(t, "expected ), got %q", t.text)
+		}
+		return n, nil
+	}
+	return p.predicate()
+}
+
+func (p *parser) predicate() (node, error) {
+	t, err := p.take("a predicate")
+	if err != nil {
+		return node{}, err
+	}
+	switch strings.ToLower(t.text) {
+	case "iqp":
+		side := p.side()
+		return onBoard(func(pos *position) bool {
+			return pos.board.isolatedQueenPawn(side(pos))
+		}), nil
+	case "castled":
+		side := p.side()
+		wing := ""
+		if p.keyword(kingside) {
+			wing = kingside
+		} else if p.keyword(queenside) {
+			wing = queenside
+		}
+		return onBoard(func(pos *position) bool {
+			castled := pos.board.castled(side(pos))
+			return castled != "" && (wing == "" || castled == wing)
+		}), nil
+	case "opposite-castling":
+		return onBoard(func(pos *position) bool {
+			white, black := pos.board.castled(true), pos.board.castled(false)
+			return white != "" && black != "" && white != black
+		}), nil
+	case "queens-off":
+		return onBoard(func(pos *position) bool {
+			return pos.board.count('Q') == 0 && pos.board.count('q') == 0
+		}), nil
+	case "on":
+		return p.placement()
+	case "empty":
+		return p.empty()
+	case "move":
+		return p.comparison(func(ply int) int { return (ply + 1) / 2 })
+	case "ply":
+		return p.comparison(func(ply int) int { return ply })
+	}
+	return node{}, p.errorf(t, "unknown predicate %q", t.text)
+}
+
+// side reads an optional side, the user's one when there is none, and
+// returns whether it is white in a position.
+func (p *parser) side() func(pos *position) bool {
+	switch {
+	case p.keyword("opponent"):
+		return func(pos *position) bool { return !pos.white }
+	case p.keyword("white"):
+		return func(*position) bool { return true }
+	case p.keyword("black"):
+		return func(*position) bool { return false }
+	}
+	p.keyword("me")
+	return func(pos *position) bool { return pos.white }
+}
+
+// placement reads the pieces of "on", as many as follow.
+func (p *parser) placement() (node, error) {
+	type placed struct {
+		square int
+		piece  byte
+	}
+	var pieces []placed
+	for t, ok := p.peek(); ok && len(t.text) == 3 && strings.IndexByte("PNBRQKpnbrqk", t.text[0]) >= 0; t, ok = p.peek() {
+		square, valid := parseSquare(t.text[1:])
+		if !valid {
+			// a keyword like not
+			break
+		}
+		pieces = append(pieces, placed{square, t.text[0]})
+		p.next++
+	}
+	if len(pieces) == 0 {
+		t, _ := p.peek()
+		return node{}, p.placeholder(t, "a piece on a square like Nf3 or pe5")
+	}
+	return onBoard(func(pos *position) bool {
+		for _, pc := range pieces {
+			if pos.board[pc.square] != pc.piece {
+				return false
+			}
+		}
+		return true
+	}), nil
+}
+
+// empty reads the squares of "empty", as many as follow.
+func (p *parser) empty() (node, error) {
+	var squares []int
+	for t, ok := p.peek(); ok; t, ok = p.peek() {
+		square, valid := parseSquare(strings.ToLower(t.text))
+		if !valid {
+			break
+		}
+		squares = append(squares, square)
+		p.next++
+	}
+	if len(squares) == 0 {
+		t, _ := p.peek()
+		return node{}, p.placeholder(t, "a square like d4")
+	}
+	return onBoard(func(pos *position) bool {
+		for _, square := range squares {
+			if pos.board[square] != 0 {
+				return false
+			}
+		}
+		return true
+	}), nil
+}
+
+// comparison reads the bound of "move" or "ply", value being the move or ply
+// of a position at a ply.
+func (p *parser) comparison(value func(ply int) int) (node, error) {
+	op, err := p.take("a comparison like < 15")
+	if err != nil {
+		return node{}, err
+	}
+	var compare func(a, b int) bool
+	switch op.text {
+	case "=", "==":
+		compare = func(a, b int) bool { return a == b }
+	case "!=":
+		compare = func(a, b int) bool { return a != b }
+	case "<":
+		compare = func(a, b int) bool { return a < b }
+	case "<=":
+		compare = func(a, b int) bool { return a <= b }
+	case ">":
+		compare = func(a, b int) bool { return a > b }
+	case ">=":
+		compare = func(a, b int) bool { return a >= b }
+	default:
+		return node{}, p.errorf(op, "expected one of = != < <= > >=, got %q", op.text)
+	}
+	t, err := p.take("a number")
+	if err != nil {
+		return node{}, err
+	}
+	n, err := strconv.Atoi(t.text)
+	if err != nil || n < 0 {
+		return node{}, p.errorf(t, "expected a number, got %q", t.text)
+	}
+	return node{
+		match: func(pos *position) bool { return compare(value(pos.ply), n) },
+		plies: func(ply int) outcome {
+			if compare(value(ply), n) {
+				return always
+			}
+			return never
+		},
+	}, nil
+}
+
+// placeholder is the error of a predicate missing its arguments, t being the
+// token found instead if any.
+func (p *parser) placeholder(t token, what string) error {
+	if t.text == "" {
+		return &SyntaxError{len(p.source), "expected " + what + ", got the end of the query"}
+	}
+	return p.errorf(t, "expected %s, got %q", what, t.text)
+}
@@ -0,0 +1,160 @@
+package search
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+	// white has an isolated d-pawn, black an e-pawn instead, both castled
+	// kingside
+	iqpFEN = "r1bq1rk1/pp2bppp/2n1pn2/8/3P4/2N2N2/PP2BPPP/R1BQ1RK1 w - - 0 10"
+	// white castled kingside, black queenside, the queens traded
+	oppositeFEN = "2kr3r/ppp2ppp/8/8/8/8/PPP2PPP/R4RK1 w - - 0 15"
+	// 1.e4 e5 2.Nf3
+	knightFEN = "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2"
+)
+
+// TestPredicates matches each predicate, and the ways to combine them,
+// against positions where the user played color.
+func TestPredicates(t *testing.T) {
+	tests := []struct {
+		query string
+		fen   string
+		ply   int
+		color string
+		want  bool
+	}{
+		{"iqp", iqpFEN, 19, "white", true},
+		{"iqp me", iqpFEN, 19, "black", false},
+		{"iqp opponent", iqpFEN, 19, "black", true},
+		{"iqp white", iqpFEN, 19, "black", true},
+		{"iqp black", iqpFEN, 19, "white", false},
+		{"iqp", startFEN, 0, "white", false},
+
+		{"castled", iqpFEN, 19, "white", true},
+		{"castled kingside", iqpFEN, 19, "white", true},
+		{"castled queenside", iqpFEN, 19, "white", false},
+		{"castled opponent queenside", oppositeFEN, 29, "white", true},
+		{"castled black kingside", oppositeFEN, 29, "white", false},
+		{"castled", startFEN, 0, "white", false},
+
+		{"opposite-castling", oppositeFEN, 29, "white", true},
+		{"opposite-castling", iqpFEN, 19, "white", false},
+		{"opposite-castling", startFEN, 0, "white", false},
+
+		{"queens-off", oppositeFEN, 29, "white", true},
+		{"queens-off", iqpFEN, 19, "white", false},
+
+		{"on Nf3 pe5", knightFEN, 3, "white", true},
+		{"on Nf3 Pe5", knightFEN, 3, "white", false},
+		{"on nf3", knightFEN, 3, "white", false},
+		{"on Nf3 and not on Ng1", knightFEN, 3, "white", true},
+
+		{"empty d4 d5", knightFEN, 3, "white", true},
+		{"empty e4", knightFEN, 3, "white", false},
+		{"empty D4", knightFEN, 3, "white", true},
+
+		{"move = 2", knightFEN, 3, "white", true},
+		{"move < 2", knightFEN, 3, "white", false},
+		{"move <= 15", iqpFEN, 29, "white", true},
+		{"move <= 15", iqpFEN, 31, "white", false},
+		{"move != 16", iqpFEN, 31, "white", false},
+		{"move >= 16", iqpFEN, 31, "white", true},
+		{"ply > 30", iqpFEN, 31, "white", true},
+		{"ply == 3", knightFEN, 3, "white", true},
+
+		{"not queens-off", iqpFEN, 19, "white", true},
+		{"not not queens-off", iqpFEN, 19, "white", false},
+		{"queens-off or iqp and castled", iqpFEN, 19, "white", true},
+		{"queens-off or iqp and castled queenside", iqpFEN, 19, "white", false},
+		{"(queens-off or iqp) and move < 5", iqpFEN, 19, "white", false},
+		{"(queens-off or iqp) and move < 15", iqpFEN, 19, "white", true},
+		{"IQP ME AND NOT Queens-Off", iqpFEN, 19, "white", true},
+		{"queens-off and move<=15", oppositeFEN, 29, "white", true},
+
+		{"queens-off", "not a fen", 0, "white", false},
+		{"queens-off", "8/8/8/8/8/8/8 w - - 0 1", 0, "white", false},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("%q: %v", tt.query, err)
+			continue
+		}
+		if got := q.Match(tt.fen, tt.ply, tt.color); got != tt.want {
+			t.Errorf("%q on %s at ply %d as %s = %v, want %v", tt.query, tt.fen, tt.ply, tt.color, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query   string
+		offset  int
+		message string
+	}{
+		{"", 0, "empty"},
+		{"   ", 0, "empty"},
+		{strings.Repeat("iqp or ", MaxLength/7+1), MaxLength, "longer"},
+		{"foo", 0, "unknown predicate"},
+		{"iqp and", 7, "expected a predicate"},
+		{"iqp iqp", 4, "expected and, or"},
+		{"(iqp", 4, "expected )"},
+		{"(iqp iqp", 5, "expected ), got"},
+		{"not", 3, "expected a predicate"},
+		{"move 15", 5, "expected one of"},
+		{"move =! 3", 5, "expected one of"},
+		{"move <", 6, "expected a number"},
+		{"move < x", 7, "expected a number"},
+		{"ply >= -1", 7, "expected a number"},
+		{"on", 2, "expected a piece"},
+		{"on xz9", 3, "expected a piece"},
+		{"on Nz9", 3, "expected a piece"},
+		{"empty", 5, "expected a square"},
+		{"empty and", 6, "expected a square"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.query)
+		var syntax *SyntaxError
+		if !errors.As(err, &syntax) {
+			t.Errorf("%q: err = %v, want a SyntaxError", tt.query, err)
+			continue
+		}
+		if syntax.Offset != tt.offset || !strings.Contains(syntax.Message, tt.message) {
+			t.Errorf("%q: %q at %d, want %q at %d", tt.query, syntax.Message, syntax.Offset, tt.message, tt.offset)
+		}
+	}
+}
+
+// TestReachable checks the queries whose move and ply bounds are beyond the
+// stored plies are told apart, whatever their other predicates.
+func TestReachable(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"queens-off", true},
+		{"move > 15", true},
+		{"move > 16", false},
+		{"ply = 31", true},
+		{"ply = 32", false},
+		{"not move <= 40", false},
+		{"queens-off and move > 20", false},
+		{"queens-off or move > 20", true},
+		{"not (queens-off and move > 20)", true},
+		{"(move > 20 or ply < 3) and not ply < 3", false},
+		{"move < 10 and move > 12", false},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		if got := q.Reachable(31); got != tt.want {
+			t.Errorf("%q reachable within 31 plies = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
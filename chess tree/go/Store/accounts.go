@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"chess/internal/db"
+	"chess/internal/sqlitedb"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrUserTaken     = errors.New("store: the chess.com user is linked to another account")
+	ErrAccountLinked = errors.New("store: the account is linked to another chess.com user")
+)
+
+// Accounts is implemented by the stores that link the accounts of the socket
+// service, the id its tokens carry, to the chess.com user each one explores.
+type Accounts interface {
+	// AccountUser returns ErrNotFound for an account not linked yet.
+	AccountUser(ctx context.Context, account string) (string, error)
+	// LinkAccount links account to username, once: ErrAccountLinked when
+	// the account explores another user, ErrUserTaken when another account
+	// explores username.
+	LinkAccount(ctx context.Context, account, username string) error
+}
+
+func (c Chain) accounts() Accounts {
+	for _, s := range c {
+		if a, ok := s.(Accounts); ok {
+			return a
+		}
+	}
+	return nil
+}
+
+func (c Chain) AccountUser(ctx context.Context, account string) (string, error) {
+	if a := c.accounts(); a != nil {
+		return a.AccountUser(ctx, account)
+	}
+	return "", ErrNoUserData
+}
+
+func (c Chain) LinkAccount(ctx context.Context, account, username string) error {
+	if a := c.accounts(); a != nil {
+		return a.LinkAccount(ctx, account, username)
+	}
+	return ErrNoUserData
+}
+
+// linkable checks account is not linked to a user other than username.
+func linkable(ctx context.Context, a Accounts, account, username string) error {
+	linked, err := a.AccountUser(ctx, account)
+	if errors.Is(err, ErrNotFound) || (err == nil && linked == username) {
+		return nil
+	}
+	if err == nil {
+		return ErrAccountLinked
+	}
+	return err
+}
+
+func (p *Postgres) AccountUser(ctx context.Context, account string) (string, error) {
+	username, err := db.New(p.pool).GetAccountUser(ctx, pgtype.Text{String: account, Valid: true})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return username, err
+}
+
+func (p *Postgres) LinkAccount(ctx context.Context, account, username string) error {
+	username = strings.ToLower(username)
+	if err := linkable(ctx, p, account, username); err != nil {
+		return err
+	}
+	linked, err := db.New(p.pool).LinkAccount(ctx, db.LinkAccountParams{
+		ChessComUsername: username,
+		AccountID:        pgtype.Text{String: account, Valid: true},
+	})
+	if err == nil && linked == 0 {
+		return ErrUserTaken
+	}
+	return err
+}
+
+func (s *SQLite) AccountUser(ctx context.Context, account string) (string, error) {
+	username, err := sqlitedb.New(s.db).GetAccountUser(ctx, sql.NullString{String: account, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return username, err
+}
+
+func (s *SQLite) LinkAccount(ctx context.Context, account, username string) error {
+	username = strings.ToLower(username)
+	if err := linkable(ctx, s, account, username); err != nil {
+		return err
+	}
+	linked, err := sqlitedb.New(s.db).LinkAccount(ctx, sqlitedb.LinkAccountParams{
+		ID:               uuid.NewString(),
+		ChessComUsername: username,
+		AccountID:        sql.NullString{String: account, Valid: true},
+	})
+	if err == nil && linked == 0 {
+		return ErrUserTaken
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"chess/internal/db"
+	"chess/internal/sqlitedb"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Archives is implemented by the stores that remember the last chess.com
+// archive synced for a user, so a sync only fetches that month and the newer
+// ones.
+type Archives interface {
+	// LastArchive is empty for a user never synced.
+	LastArchive(ctx context.Context, username string) (string, error)
+	SetLastArchive(ctx context.Context, username, archive string) error
+}
+
+func (c Chain) archives() Archives {
+	for _, s := range c {
+		if a, ok := s.(Archives); ok {
+			return a
+		}
+	}
+	return nil
+}
+
+func (c Chain) LastArchive(ctx context.Context, username string) (string, error) {
+	if a := c.archives(); a != nil {
+		return a.LastArchive(ctx, username)
+	}
+	return "", nil
+}
+
+func (c Chain) SetLastArchive(ctx context.Context, username, archive string) error {
+	if a := c.archives(); a != nil {
+		return a.SetLastArchive(ctx, username, archive)
+	}
+	return nil
+}
+
+func (p *Postgres) LastArchive(ctx context.Context, username string) (string, error) {
+	last, err := db.New(p.pool).GetLastArchive(ctx, strings.ToLower(username))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return last.String, err
+}
+
+func (p *Postgres) SetLastArchive(ctx context.Context, username, archive string) error {
+	return db.New(p.pool).SetLastArchive(ctx, db.SetLastArchiveParams{
+		ChessComUsername: strings.ToLower(username),
+		LastArchive:      pgtype.Text{String: archive, Valid: archive != ""},
+	})
+}
+
+func (s *SQLite) LastArchive(ctx context.Context, username string) (string, error) {
+	last, err := sqlitedb.New(s.db).GetLastArchive(ctx, strings.ToLower(username))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return last.String, err
+}
+
+func (s *SQLite) SetLastArchive(ctx context.Context, username, archive string) error {
+	return sqlitedb.New(s.db).SetLastArchive(ctx, sqlitedb.SetLastArchiveParams{
+		ID:               uuid.NewString(),
+		ChessComUsername: strings.ToLower(username),
+		LastArchive:      sql.NullString{String: archive, Valid: archive != ""},
+	})
+}
+
+// LastArchive is remembered until the server stops, the first sync after a
+// restart fetches every archive again and Apply skips the games the snapshot
+// has.
+func (m *Memory) LastArchive(ctx context.Context, username string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.archives[strings.ToLower(username)], nil
+}
+
+func (m *Memory) SetLastArchive(ctx context.Context, username, archive string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.archives[strings.ToLower(username)] = archive
+	return nil
+}
@@ -0,0 +1,283 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"chess/ProcessPipline"
+	"chess/Types"
+	"chess/internal/db"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Flusher is implemented by stores that buffer games, callers flush once the
+// last game of a run has been saved.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Batcher is implemented by stores that buffer games. Batch returns a store
+// with a buffer of its own, so a run flushing it only commits, or fails, the
+// games it saved and not those of a run going on at the same time.
+type Batcher interface {
+	Batch() PositionStore
+}
+
+// Batch is the store a run saves its games through, see Batcher.
+func Batch(s PositionStore) PositionStore {
+	if b, ok := s.(Batcher); ok {
+		return b.Batch()
+	}
+	return s
+}
+
+// BulkWriter is the Postgres store for large imports. Games are buffered and
+// written batchSize at a time in one transaction: the games with a single
+// multi row insert, game_positions through COPY, and position_stats as one
+// upsert of the per batch deltas instead of one upsert per ply per game, and
+// move_edges the same way. A run should save through a Batch of its own,
+// the writer is safe to share but its buffer is common to every caller.
+type BulkWriter struct {
+	*Postgres
+	batchSize int
+
+	mu      sync.Mutex
+	pending []*types.GameRecord
+}
+
+func (p *Postgres) Bulk(batchSize int) *BulkWriter {
+	return &BulkWriter{Postgres: p, batchSize: max(batchSize, 1)}
+}
+
+// Batch returns a writer over the same database with an empty buffer.
+func (w *BulkWriter) Batch() PositionStore {
+	return w.Bulk(w.batchSize)
+}
+
+func (w *BulkWriter) SaveGame(ctx context.Context, rec *types.GameRecord) error {
+	if err := validate(rec); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, rec)
+	if len(w.pending) < w.batchSize {
+		return nil
+	}
+	return w.flush(ctx)
+}
+
+type statsKey struct {
+	fen       string
+	userID    pgtype.UUID
+	color     string
+	timeClass string
+}
+
+type edgeKey struct {
+	userID    pgtype.UUID
+	color     string
+	timeClass string
+	parent    string
+	move      string
+	child     string
+}
+
+type statsDelta struct {
+	win, loss, draw, games int32
+	latestID               pgtype.UUID
+	latestAt               pgtype.Timestamptz
+}
+
+func (w *BulkWriter) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.flush(ctx)
+}
+
+// flush writes the pending games, the caller holds mu.
+func (w *BulkWriter) flush(ctx context.Context) error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+
+	tx, err := w.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	q := db.New(tx)
+
+	users := make(map[string]pgtype.UUID)
+	games := db.InsertGamesParams{}
+	byID := make(map[pgtype.UUID]*types.GameRecord, len(w.pending))
+	for _, rec := range w.pending {
+		gameID, err := pgUUID(rec.ID)
+		if err != nil {
+			return err
+		}
+		if _, seen := byID[gameID]; seen {
+			continue
+		}
+		byID[gameID] = rec
+
+		username := strings.ToLower(rec.Username)
+		userID, exists := users[username]
+		if !exists {
+			userID, err = q.UpsertUser(ctx, username)
+			if err != nil {
+				return err
+			}
+			users[username] = userID
+		}
+
+		games.Ids = append(games.Ids, gameID)
+		games.UserIds = append(games.UserIds, userID)
+		games.Links = append(games.Links, rec.Link)
+		games.WhiteUsernames = append(games.WhiteUsernames, rec.White)
+		games.BlackUsernames = append(games.BlackUsernames, rec.Black)
+		games.WhiteElos = append(games.WhiteElos, int32(rec.WhiteElo))
+		games.BlackElos = append(games.BlackElos, int32(rec.BlackElo))
+		games.Results = append(games.Results, rec.Result)
+		games.TimeClasses = append(games.TimeClasses, rec.TimeClass)
+		games.TimeControls = append(games.TimeControls, rec.TimeControl)
+		games.Pgns = append(games.Pgns, rec.PGN)
+		games.PlayedAts = append(games.PlayedAts, pgtype.Timestamptz{Time: rec.PlayedAt, Valid: true})
+		games.Ecos = append(games.Ecos, rec.ECO)
+		games.Terminations = append(games.Terminations, rec.Termination.String())
+		games.WhiteAccuracies = append(games.WhiteAccuracies, pgFloat(rec.WhiteAccuracy).Float64)
+		games.BlackAccuracies = append(games.BlackAccuracies, pgFloat(rec.BlackAccuracy).Float64)
+		games.Rated = append(games.Rated, rec.Rated)
+	}
+
+	// games already stored come back missing from inserted, their positions
+	// were counted when they were first written
+	inserted, err := q.InsertGames(ctx, games)
+	if err != nil {
+		return err
+	}
+
+	batch := newDeltaBatch()
+	for _, gameID := range inserted {
+		rec := byID[gameID]
+		batch.add(rec, gameID, users[strings.ToLower(rec.Username)])
+	}
+	if len(batch.positions) > 0 {
+		if _, err := q.CopyGamePositions(ctx, batch.positions); err != nil {
+			return err
+		}
+		if err := q.ApplyPositionStatsDeltas(ctx, batch.stats()); err != nil {
+			return err
+		}
+		if err := q.ApplyMoveEdgeDeltas(ctx, batch.edges()); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	w.pending = w.pending[:0]
+	return nil
+}
+
+// deltaBatch sums what a set of new games adds to position_stats and
+// move_edges, so each row is upserted once per batch.
+type deltaBatch struct {
+	positions  []db.CopyGamePositionsParams
+	deltas     map[statsKey]*statsDelta
+	order      []statsKey
+	edgeDeltas map[edgeKey]*statsDelta
+	edgeOrder  []edgeKey
+	childFens  map[edgeKey]string
+}
+
+func newDeltaBatch() *deltaBatch {
+	return &deltaBatch{
+		deltas:     make(map[statsKey]*statsDelta),
+		edgeDeltas: make(map[edgeKey]*statsDelta),
+		childFens:  make(map[edgeKey]string),
+	}
+}
+
+func (b *deltaBatch) add(rec *types.GameRecord, gameID, userID pgtype.UUID) {
+	playedAt := pgtype.Timestamptz{Time: rec.PlayedAt, Valid: true}
+	win, loss, draw := outcomeCounts(rec.Outcome)
+
+	for _, ply := range append([]types.Ply{{Fen: Processpipline.StartFEN}}, rec.Plies...) {
+		b.positions = append(b.positions, db.CopyGamePositionsParams{
+			GameID:     gameID,
+			Fen:        ply.Fen,
+			MoveNumber: int32(ply.Number),
+		})
+
+		key := statsKey{ply.Fen, userID, rec.Color, rec.TimeClass}
+		delta, exists := b.deltas[key]
+		if !exists {
+			delta = &statsDelta{}
+			b.deltas[key] = delta
+			b.order = append(b.order, key)
+		}
+		delta.add(win, loss, draw)
+		if !delta.latestAt.Valid || !playedAt.Time.Before(delta.latestAt.Time) {
+			delta.latestID, delta.latestAt = gameID, playedAt
+		}
+	}
+
+	for _, e := range gameEdges(rec) {
+		key := edgeKey{userID, rec.Color, rec.TimeClass, e.parent, e.move, e.child}
+		delta, exists := b.edgeDeltas[key]
+		if !exists {
+			delta = &statsDelta{}
+			b.edgeDeltas[key] = delta
+			b.edgeOrder = append(b.edgeOrder, key)
+		}
+		delta.add(win, loss, draw)
+		b.childFens[key] = e.childFen
+	}
+}
+
+func (d *statsDelta) add(win, loss, draw int) {
+	d.win += int32(win)
+	d.loss += int32(loss)
+	d.draw += int32(draw)
+	d.games++
+}
+
+func (b *deltaBatch) stats() db.ApplyPositionStatsDeltasParams {
+	stats := db.ApplyPositionStatsDeltasParams{}
+	for _, key := range b.order {
+		delta := b.deltas[key]
+		stats.Fens = append(stats.Fens, key.fen)
+		stats.UserIds = append(stats.UserIds, key.userID)
+		stats.Colors = append(stats.Colors, key.color)
+		stats.TimeClasses = append(stats.TimeClasses, key.timeClass)
+		stats.WinCounts = append(stats.WinCounts, delta.win)
+		stats.LossCounts = append(stats.LossCounts, delta.loss)
+		stats.DrawCounts = append(stats.DrawCounts, delta.draw)
+		stats.GameCounts = append(stats.GameCounts, delta.games)
+		stats.LatestGameIds = append(stats.LatestGameIds, delta.latestID)
+		stats.LatestPlayedAts = append(stats.LatestPlayedAts, delta.latestAt)
+	}
+	return stats
+}
+
+func (b *deltaBatch) edges() db.ApplyMoveEdgeDeltasParams {
+	edges := db.ApplyMoveEdgeDeltasParams{}
+	for _, key := range b.edgeOrder {
+		delta := b.edgeDeltas[key]
+		edges.UserIds = append(edges.UserIds, key.userID)
+		edges.Colors = append(edges.Colors, key.color)
+		edges.TimeClasses = append(edges.TimeClasses, key.timeClass)
+		edges.ParentKeys = append(edges.ParentKeys, key.parent)
+		edges.MoveSans = append(edges.MoveSans, key.move)
+		edges.ChildKeys = append(edges.ChildKeys, key.child)
+		edges.ChildFens = append(edges.ChildFens, b.childFens[key])
+		edges.WinCounts = append(edges.WinCounts, delta.win)
+		edges.LossCounts = append(edges.LossCounts, delta.loss)
+		edges.DrawCounts = append(edges.DrawCounts, delta.draw)
+		edges.GameCounts = append(edges.GameCounts, delta.games)
+	}
+	return edges
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"chess/Migrations"
+	"chess/Types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	lib "github.com/notnil/chess"
+)
+
+func TestBulkWriterBatch(t *testing.T) {
+	shared := (&Postgres{}).Bulk(1000)
+	memory := NewMemory()
+	batched, ok := Chain{shared, memory}.Batch().(Chain)
+	if !ok || len(batched) != 2 {
+		t.Fatalf("Batch of a chain = %#v, want a chain of two stores", batched)
+	}
+	writer, ok := batched[0].(*BulkWriter)
+	if !ok || writer == shared || writer.batchSize != shared.batchSize {
+		t.Errorf("the chain's bulk writer was not given a buffer of its own")
+	}
+	if batched[1] != memory {
+		t.Errorf("the memory store was replaced, it does not buffer games")
+	}
+
+	// a writer shared by several runs keeps every game they save
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if err := shared.SaveGame(context.Background(), &types.GameRecord{Color: "white", TimeClass: "blitz"}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if len(shared.pending) != 400 {
+		t.Errorf("the shared writer has %d games pending, want 400", len(shared.pending))
+	}
+	if len(writer.pending) != 0 {
+		t.Errorf("the batch got %d games saved to the shared writer", len(writer.pending))
+	}
+}
+
+// benchmarkGame is the line every benchmark game plays, 30 plies deep like
+// the default MaxPlies.
+var benchmarkGame = []string{
+	"e4", "c5", "Nf3", "d6", "d4", "cxd4", "Nxd4", "Nf6", "Nc3", "a6",
+	"Be3", "e5", "Nb3", "Be6", "f3", "Be7", "Qd2", "O-O", "O-O-O", "Nbd7",
+	"g4", "b5", "g5", "b4", "Ne2", "Ne8", "f4", "a5", "f5", "a4",
+}
+
+// benchmarkRecords returns n games of a fresh user with the plies of
+// benchmarkGame, the time class and result changing from game to game.
+func benchmarkRecords(b *testing.B, n int) []*types.GameRecord {
+	b.Helper()
+	game := lib.NewGame()
+	var plies []types.Ply
+	for i, san := range benchmarkGame {
+		parent := game.FEN()
+		if err := game.MoveStr(san); err != nil {
+			b.Fatal(err)
+		}
+		plies = append(plies, types.Ply{Number: i + 1, San: san, Parent: parent, Fen: game.FEN()})
+	}
+
+	username := fmt.Sprintf("bench-%d", time.Now().UnixNano())
+	timeClasses := []string{"bullet", "blitz", "rapid"}
+	outcomes := []string{"win", "loss", "draw"}
+	records := make([]*types.GameRecord, n)
+	for i := range records {
+		id := uuid.NewString()
+		records[i] = &types.GameRecord{
+			ID:        id,
+			Username:  username,
+			Color:     "white",
+			Outcome:   outcomes[i%len(outcomes)],
+			Result:    "1-0",
+			TimeClass: timeClasses[i%len(timeClasses)],
+			White:     username,
+			Black:     "opponent",
+			Link:      "https://www.chess.com/game/live/" + id,
+			PGN:       "1. e4 c5 *",
+			PlayedAt:  time.Unix(int64(1_700_000_000+i), 0).UTC(),
+			Plies:     plies,
+		}
+	}
+	return records
+}
+
+// BenchmarkSaveGame compares writing games one row at a time with the bulk
+// writer. It needs a scratch Postgres database in DATABASE_URL, every run
+// adds games under new users.
+func BenchmarkSaveGame(b *testing.B) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		b.Skip("DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, err = migrations.Up(ctx, conn)
+	conn.Close(ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+	pg, err := NewPostgres(ctx, dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer pg.Close()
+
+	run := func(b *testing.B, st PositionStore) {
+		records := benchmarkRecords(b, b.N)
+		b.ResetTimer()
+		for _, rec := range records {
+			if err := st.SaveGame(ctx, rec); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if f, ok := st.(Flusher); ok {
+			if err := f.Flush(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "games/s")
+	}
+	b.Run("rows", func(b *testing.B) { run(b, pg) })
+	for _, size := range []int{100, 500} {
+		b.Run(fmt.Sprintf("bulk-%d", size), func(b *testing.B) { run(b, pg.Bulk(size)) })
+	}
+}
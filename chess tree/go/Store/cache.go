@@ -0,0 +1,169 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"chess/ProcessPipline"
+	"chess/Types"
+)
+
+// Cache is a read-through LRU of the Position and NextMoves answers of a
+// store, the root and first plies being asked for on every page load. It
+// keeps at most size answers for ttl each, the queries with filters are not
+// cached. The stores answer a FEN like its position key, the queries reach
+// them with the key the answer is cached under. The games of a user being
+// committed must be followed by Invalidate, a nil Cache does nothing.
+type Cache struct {
+	PositionStore
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	order   *list.List // of *cacheEntry, most recently used first
+	entries map[cacheKey]*list.Element
+	// generation counts the invalidations, an answer read from the store
+	// across one is not cached since it may predate the commit
+	generation uint64
+}
+
+type cacheKey struct {
+	moves                           bool
+	username, fen, color, timeClass string
+}
+
+type cacheEntry struct {
+	key      cacheKey
+	expires  time.Time
+	position *types.PositionStats
+	moves    []types.MoveStats
+}
+
+func NewCache(s PositionStore, size int, ttl time.Duration) *Cache {
+	return &Cache{
+		PositionStore: s,
+		size:          size,
+		ttl:           ttl,
+		order:         list.New(),
+		entries:       make(map[cacheKey]*list.Element),
+	}
+}
+
+func newCacheKey(q types.PositionQuery, moves bool) cacheKey {
+	return cacheKey{
+		moves:     moves,
+		username:  strings.ToLower(q.Username),
+		fen:       Processpipline.PositionKey(q.Fen),
+		color:     q.Color,
+		timeClass: q.TimeClass,
+	}
+}
+
+func (c *Cache) Position(ctx context.Context, q types.PositionQuery) (*types.PositionStats, error) {
+	if !q.Filters.IsZero() {
+		return c.PositionStore.Position(ctx, q)
+	}
+	key := newCacheKey(q, false)
+	if entry := c.get(key); entry != nil {
+		position := *entry.position
+		position.Fen = q.Fen
+		return &position, nil
+	}
+	generation := c.currentGeneration()
+	fen := q.Fen
+	q.Fen = key.fen
+	position, err := c.PositionStore.Position(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	cached := *position
+	c.put(generation, &cacheEntry{key: key, position: &cached})
+	position.Fen = fen
+	return position, nil
+}
+
+func (c *Cache) NextMoves(ctx context.Context, q types.PositionQuery) ([]types.MoveStats, error) {
+	if !q.Filters.IsZero() {
+		return c.PositionStore.NextMoves(ctx, q)
+	}
+	key := newCacheKey(q, true)
+	if entry := c.get(key); entry != nil {
+		return append([]types.MoveStats(nil), entry.moves...), nil
+	}
+	generation := c.currentGeneration()
+	q.Fen = key.fen
+	moves, err := c.PositionStore.NextMoves(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	c.put(generation, &cacheEntry{key: key, moves: append([]types.MoveStats(nil), moves...)})
+	return moves, nil
+}
+
+// Invalidate drops the answers about username, every answer when username is
+// empty.
+func (c *Cache) Invalidate(username string) {
+	if c == nil {
+		return
+	}
+	username = strings.ToLower(username)
+	if _, perUser := c.PositionStore.(UserData); !perUser {
+		// the in-memory tree mixes everyone's games
+		username = ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for key, element := range c.entries {
+		if username == "" || key.username == username {
+			c.order.Remove(element)
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (c *Cache) get(key cacheKey) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, found := c.entries[key]
+	if !found {
+		return nil
+	}
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil
+	}
+	c.order.MoveToFront(element)
+	return entry
+}
+
+func (c *Cache) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+func (c *Cache) put(generation uint64, entry *cacheEntry) {
+	entry.expires = time.Now().Add(c.ttl)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	if element, found := c.entries[entry.key]; found {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chess/ProcessPipline"
+	"chess/Types"
+	lib "github.com/notnil/chess"
+)
+
+// TestCacheFENAndKey asks for a position reached with two move orders by
+// each of its FENs and by its key, in and out of the cache: the answers are
+// the same whichever is asked first.
+func TestCacheFENAndKey(t *testing.T) {
+	useEmptyTree(t)
+	m := NewMemory()
+	var fens []string
+	for i, sans := range [][]string{
+		{"Nf3", "Nf6", "Nc3", "Nc6"},
+		{"Nc3", "Nc6", "Nb1", "Nb8", "Nf3", "Nf6", "Nc3", "Nc6", "e4"},
+	} {
+		// the ply reaching the four knights
+		reached := []int{3, 7}[i]
+		game := lib.NewGame()
+		rec := &types.GameRecord{ID: string(rune('a' + i)), Username: "alice", Color: "white", Outcome: "win"}
+		for n, san := range sans {
+			parent := game.FEN()
+			if err := game.MoveStr(san); err != nil {
+				t.Fatal(err)
+			}
+			rec.Plies = append(rec.Plies, types.Ply{Number: n + 1, San: san, Parent: parent, Fen: game.FEN()})
+			if n == reached {
+				fens = append(fens, game.FEN())
+			}
+		}
+		if err := m.SaveGame(context.Background(), rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fens = append(fens, Processpipline.PositionKey(fens[0]))
+	if fens[0] == fens[1] {
+		t.Fatal("the two move orders reach the same FEN, clocks included")
+	}
+
+	for first := range fens {
+		cache := NewCache(m, 16, time.Minute)
+		for i := range fens {
+			fen := fens[(first+i)%len(fens)]
+			for name, s := range map[string]PositionStore{"memory": m, "cache": cache} {
+				q := types.PositionQuery{Username: "alice", Fen: fen}
+				stats, err := s.Position(context.Background(), q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stats.Games != 2 || stats.Fen != fen {
+					t.Errorf("%s, %q: %d games for %q, want 2", name, fen, stats.Games, stats.Fen)
+				}
+				moves, err := s.NextMoves(context.Background(), q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(moves) != 1 || moves[0].Move != "e4" || moves[0].Games != 1 {
+					t.Errorf("%s, %q: moves %+v, want e4 once", name, fen, moves)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package store
+
+import (
+	"context"
+	"sort"
+
+	"chess/ProcessPipline"
+	"chess/Types"
+	"chess/internal/db"
+)
+
+// Edges is implemented by the stores that keep the moves between positions,
+// it walks the tree of a user up and down from a position.
+type Edges interface {
+	// Parents returns every move leading into the position of q, one per
+	// position and move it was reached from.
+	Parents(ctx context.Context, q types.PositionQuery) ([]types.Edge, error)
+	// Subtree returns the edges below the position of q down to depth plies.
+	Subtree(ctx context.Context, q types.PositionQuery, depth int) ([]types.Edge, error)
+}
+
+func (c Chain) edges() (Edges, error) {
+	for _, s := range c {
+		if e, ok := s.(Edges); ok {
+			return e, nil
+		}
+	}
+	return nil, ErrNoUserData
+}
+
+func (c Chain) Parents(ctx context.Context, q types.PositionQuery) ([]types.Edge, error) {
+	e, err := c.edges()
+	if err != nil {
+		return nil, err
+	}
+	return e.Parents(ctx, q)
+}
+
+func (c Chain) Subtree(ctx context.Context, q types.PositionQuery, depth int) ([]types.Edge, error) {
+	e, err := c.edges()
+	if err != nil {
+		return nil, err
+	}
+	return e.Subtree(ctx, q, depth)
+}
+
+// edge is one move of a game between two position keys.
+type edge struct {
+	parent, move, child, childFen string
+}
+
+// gameEdges lists the moves of a game, a move repeated within the game being
+// listed once so it counts the game once.
+func gameEdges(rec *types.GameRecord) []edge {
+	var edges []edge
+	seen := make(map[edge]bool)
+	for _, ply := range rec.Plies {
+		if ply.Parent == "" || ply.San == "" {
+			continue
+		}
+		e := edge{
+			parent: Processpipline.PositionKey(ply.Parent),
+			move:   ply.San,
+			child:  Processpipline.PositionKey(ply.Fen),
+		}
+		if seen[e] {
+			continue
+		}
+		seen[e] = true
+		e.childFen = ply.Fen
+		edges = append(edges, e)
+	}
+	return edges
+}
+
+// edgeRow is what the edge queries have in common.
+type edgeRow struct {
+	color, timeClass, parent, move, child, childFen string
+	win, loss, draw, games                          int32
+	depth                                           int32
+}
+
+// mergeEdges sums the rows of the same move across the colors and time
+// classes the query keeps, a subtree edge keeping its shallowest depth.
+func mergeEdges(q types.PositionQuery, rows []edgeRow) []types.Edge {
+	type moveKey struct{ parent, move string }
+	byMove := make(map[moveKey]*types.Edge)
+	var order []moveKey
+	for _, row := range rows {
+		if !matches(q, row.color, row.timeClass) {
+			continue
+		}
+		key := moveKey{row.parent, row.move}
+		e, exists := byMove[key]
+		if !exists {
+			e = &types.Edge{
+				Parent:      row.parent,
+				Move:        row.move,
+				Child:       row.child,
+				ChildFen:    row.childFen,
+				TimeClasses: make(map[string]int),
+				Depth:       int(row.depth),
+			}
+			byMove[key] = e
+			order = append(order, key)
+		}
+		e.Games += int(row.games)
+		e.Wins += int(row.win)
+		e.Losses += int(row.loss)
+		e.Draws += int(row.draw)
+		e.TimeClasses[row.timeClass] += int(row.games)
+		if row.depth > 0 && int(row.depth) < e.Depth {
+			e.Depth = int(row.depth)
+		}
+	}
+
+	edges := make([]types.Edge, 0, len(order))
+	for _, key := range order {
+		edges = append(edges, *byMove[key])
+	}
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].Depth != edges[j].Depth {
+			return edges[i].Depth < edges[j].Depth
+		}
+		if edges[i].Games != edges[j].Games {
+			return edges[i].Games > edges[j].Games
+		}
+		if edges[i].Parent != edges[j].Parent {
+			return edges[i].Parent < edges[j].Parent
+		}
+		return edges[i].Move < edges[j].Move
+	})
+	return edges
+}
+
+// Children returns the moves played from the position of q.
+func (p *Postgres) Children(ctx context.Context, q types.PositionQuery) ([]types.Edge, error) {
+	userID, err := p.userID(ctx, q.Username)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.New(p.pool).GetChildEdges(ctx, db.GetChildEdgesParams{
+		UserID:    userID,
+		ParentKey: Processpipline.PositionKey(q.Fen),
+	})
+	if err != nil {
+		return nil, err
+	}
+	edges := make([]edgeRow, len(rows))
+	for i, r := range rows {
+		edges[i] = edgeRow{r.Color, r.TimeClass, r.ParentKey, r.MoveSan, r.ChildKey, r.ChildFen,
+			r.WinCount, r.LossCount, r.DrawCount, r.GameCount, 0}
+	}
+	return mergeEdges(q, edges), nil
+}
+
+// Parents returns every move leading into the position of q, one per
+// position and move it was reached from.
+func (p *Postgres) Parents(ctx context.Context, q types.PositionQuery) ([]types.Edge, error) {
+	userID, err := p.userID(ctx, q.Username)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.New(p.pool).GetParentEdges(ctx, db.GetParentEdgesParams{
+		UserID:   userID,
+		ChildKey: Processpipline.PositionKey(q.Fen),
+	})
+	if err != nil {
+		return nil, err
+	}
+	edges := make([]edgeRow, len(rows))
+	for i, r := range rows {
+		edges[i] = edgeRow{r.Color, r.TimeClass, r.ParentKey, r.MoveSan, r.ChildKey, r.ChildFen,
+			r.WinCount, r.LossCount, r.DrawCount, r.GameCount, 0}
+	}
+	return mergeEdges(q, edges), nil
+}
+
+// Subtree returns the edges below the position of q down to depth plies.
+func (p *Postgres) Subtree(ctx context.Context, q types.PositionQuery, depth int) ([]types.Edge, error) {
+	userID, err := p.userID(ctx, q.Username)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.New(p.pool).GetSubtreeEdges(ctx, db.GetSubtreeEdgesParams{
+		UserID:   userID,
+		RootKey:  Processpipline.PositionKey(q.Fen),
+		MaxDepth: int32(max(depth, 1)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	edges := make([]edgeRow, len(rows))
+	for i, r := range rows {
+		edges[i] = edgeRow{r.Color, r.TimeClass, r.ParentKey, r.MoveSan, r.ChildKey, r.ChildFen,
+			r.WinCount, r.LossCount, r.DrawCount, r.GameCount, r.Depth}
+	}
+	return mergeEdges(q, edges), nil
+}
@@ -0,0 +1,564 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"chess/ProcessPipline"
+	"chess/Types"
+	"chess/internal/db"
+	"chess/internal/sqlitedb"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var ErrInvalidCursor = errors.New("store: invalid cursor")
+
+// Explorer is implemented by the stores that keep the games themselves, it
+// answers the explorer endpoints beyond position stats.
+type Explorer interface {
+	// PositionGames returns a page of the games of the user that reached the
+	// position of q, ErrInvalidCursor when q.Cursor was not made for q.Sort.
+	PositionGames(ctx context.Context, q types.GamesQuery) (*types.GamesPage, error)
+	Opponents(ctx context.Context, username, timeClass string) ([]types.OpponentStats, error)
+	TimeClasses(ctx context.Context, username string) ([]types.TimeClassCount, error)
+	ProcessingHistory(ctx context.Context, username string, limit int) ([]types.ProcessingLog, error)
+}
+
+// ProcessingLogger records the runs of the pipeline, see ProcessingHistory.
+type ProcessingLogger interface {
+	StartProcessing(ctx context.Context, run types.ProcessingLog) (int64, error)
+	FinishProcessing(ctx context.Context, run types.ProcessingLog) error
+}
+
+func (c Chain) explorer() (Explorer, error) {
+	for _, s := range c {
+		if e, ok := s.(Explorer); ok {
+			return e, nil
+		}
+	}
+	return nil, ErrNoUserData
+}
+
+func (c Chain) PositionGames(ctx context.Context, q types.GamesQuery) (*types.GamesPage, error) {
+	e, err := c.explorer()
+	if err != nil {
+		return nil, err
+	}
+	return e.PositionGames(ctx, q)
+}
+
+func (c Chain) Opponents(ctx context.Context, username, timeClass string) ([]types.OpponentStats, error) {
+	e, err := c.explorer()
+	if err != nil {
+		return nil, err
+	}
+	return e.Opponents(ctx, username, timeClass)
+}
+
+func (c Chain) TimeClasses(ctx context.Context, username string) ([]types.TimeClassCount, error) {
+	e, err := c.explorer()
+	if err != nil {
+		return nil, err
+	}
+	return e.TimeClasses(ctx, username)
+}
+
+func (c Chain) ProcessingHistory(ctx context.Context, username string, limit int) ([]types.ProcessingLog, error) {
+	e, err := c.explorer()
+	if err != nil {
+		return nil, err
+	}
+	return e.ProcessingHistory(ctx, username, limit)
+}
+
+// StartProcessing logs the run in the first store that keeps a log, the
+// returned id is 0 when none does.
+func (c Chain) StartProcessing(ctx context.Context, run types.ProcessingLog) (int64, error) {
+	for _, s := range c {
+		if l, ok := s.(ProcessingLogger); ok {
+			return l.StartProcessing(ctx, run)
+		}
+	}
+	return 0, nil
+}
+
+func (c Chain) FinishProcessing(ctx context.Context, run types.ProcessingLog) error {
+	for _, s := range c {
+		if l, ok := s.(ProcessingLogger); ok {
+			return l.FinishProcessing(ctx, run)
+		}
+	}
+	return nil
+}
+
+// gameResult is what the explorer needs from a stored game, whatever the store.
+type gameResult struct {
+	white, black       string
+	whiteElo, blackElo int
+	result, timeClass  string
+	playedAt           time.Time
+	userIsWhite        bool
+}
+
+func (g gameResult) color() string {
+	if g.userIsWhite {
+		return "white"
+	}
+	return "black"
+}
+
+func (g gameResult) opponent() (string, int) {
+	if g.userIsWhite {
+		return g.black, g.blackElo
+	}
+	return g.white, g.whiteElo
+}
+
+func (g gameResult) outcome() string {
+	return Processpipline.CheckIfUsrWon(g.result, g.color())
+}
+
+func positionGame(id, link, pgn string, ply int, g gameResult) types.PositionGame {
+	opponent, rating := g.opponent()
+	player := g.whiteElo
+	if !g.userIsWhite {
+		player = g.blackElo
+	}
+	return types.PositionGame{
+		ID:             id,
+		OpponentName:   opponent,
+		OpponentRating: rating,
+		PlayerRating:   player,
+		Result:         g.outcome(),
+		PlayerColor:    g.color(),
+		TimeClass:      g.timeClass,
+		PlayedAt:       g.playedAt,
+		ChessComUrl:    link,
+		Ply:            ply,
+		PGN:            pgn,
+	}
+}
+
+// gamesCursor is where a page of PositionGames ends, the sort key, played_at
+// and id of its last game. It goes to the client as an opaque string.
+type gamesCursor struct {
+	sort     string
+	key      int64
+	playedAt time.Time
+	id       string
+}
+
+func (c gamesCursor) String() string {
+	raw := fmt.Sprintf("%s|%d|%d|%s", c.sort, c.key, c.playedAt.UnixMicro(), c.id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// parseCursor reads the cursor of q, nil when q asks for the first page.
+func parseCursor(q types.GamesQuery) (*gamesCursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), "|", 4)
+	if len(parts) != 4 || parts[0] != q.Sort {
+		return nil, ErrInvalidCursor
+	}
+	key, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	micros, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &gamesCursor{sort: parts[0], key: key, playedAt: time.UnixMicro(micros).UTC(), id: parts[3]}, nil
+}
+
+// gamesPage trims the limit+1 games fetched to a page, the extra game telling
+// there is a next one.
+func gamesPage(q types.GamesQuery, games []types.PositionGame, keys []int64) *types.GamesPage {
+	page := &types.GamesPage{Games: games}
+	if len(games) > q.Limit {
+		page.Games = games[:q.Limit]
+		last := page.Games[q.Limit-1]
+		page.NextCursor = gamesCursor{q.Sort, keys[q.Limit-1], last.PlayedAt, last.ID}.String()
+	}
+	return page
+}
+
+// opponentStats groups the games by opponent, most played first.
+func opponentStats(games []gameResult) []types.OpponentStats {
+	byName := make(map[string]*types.OpponentStats)
+	ratings := make(map[string]int)
+	for _, g := range games {
+		name, rating := g.opponent()
+		stats, exists := byName[name]
+		if !exists {
+			stats = &types.OpponentStats{Username: name}
+			byName[name] = stats
+		}
+		stats.GamesPlayed++
+		switch g.outcome() {
+		case "win":
+			stats.Wins++
+		case "loss":
+			stats.Losses++
+		case "draw":
+			stats.Draws++
+		}
+		ratings[name] += rating
+		if g.playedAt.After(stats.LastPlayedDate) {
+			stats.LastPlayedDate = g.playedAt
+		}
+	}
+
+	opponents := make([]types.OpponentStats, 0, len(byName))
+	for name, stats := range byName {
+		stats.AvgRating = (ratings[name] + stats.GamesPlayed/2) / stats.GamesPlayed
+		opponents = append(opponents, *stats)
+	}
+	sort.Slice(opponents, func(i, j int) bool {
+		if opponents[i].GamesPlayed != opponents[j].GamesPlayed {
+			return opponents[i].GamesPlayed > opponents[j].GamesPlayed
+		}
+		return opponents[i].Username < opponents[j].Username
+	})
+	return opponents
+}
+
+func (p *Postgres) PositionGames(ctx context.Context, q types.GamesQuery) (*types.GamesPage, error) {
+	cursor, err := parseCursor(q)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := p.userID(ctx, q.Username)
+	if err != nil {
+		return nil, err
+	}
+	f := newPgFilters(q.PositionQuery)
+	params := db.ListPositionGamesParams{
+		UserID:      userID,
+		PositionKey: pgtype.Text{String: Processpipline.PositionKey(q.Fen), Valid: true},
+		TimeClass:   f.timeClass,
+		Color:       f.color,
+		PlayedFrom:  f.from,
+		PlayedTo:    f.to,
+		Rated:       f.rated,
+		TimeControl: f.timeControl,
+		Opponent:    f.opponent,
+		OpponentMin: f.opponentMin,
+		OpponentMax: f.opponentMax,
+		RatingMin:   f.ratingMin,
+		RatingMax:   f.ratingMax,
+		Sort:        q.Sort,
+		MaxGames:    int32(q.Limit + 1),
+	}
+	if cursor != nil {
+		afterID, err := uuid.Parse(cursor.id)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		params.AfterKey = pgtype.Int8{Int64: cursor.key, Valid: true}
+		params.AfterPlayedAt = pgtype.Timestamptz{Time: cursor.playedAt, Valid: true}
+		params.AfterID = pgtype.UUID{Bytes: afterID, Valid: true}
+	}
+	rows, err := db.New(p.pool).ListPositionGames(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	games := make([]types.PositionGame, 0, len(rows))
+	keys := make([]int64, 0, len(rows))
+	for _, r := range rows {
+		games = append(games, positionGame(uuid.UUID(r.ID.Bytes).String(), r.Link, r.Pgn, int(r.Ply), gameResult{
+			r.WhiteUsername, r.BlackUsername, int(r.WhiteElo.Int32), int(r.BlackElo.Int32),
+			r.Result, r.TimeClass, r.PlayedAt.Time.UTC(), r.UserIsWhite,
+		}))
+		keys = append(keys, r.SortKey)
+	}
+	return gamesPage(q, games, keys), nil
+}
+
+func (p *Postgres) Opponents(ctx context.Context, username, timeClass string) ([]types.OpponentStats, error) {
+	userID, err := p.userID(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.New(p.pool).ListUserGameResults(ctx, db.ListUserGameResultsParams{
+		UserID:    userID,
+		TimeClass: pgtype.Text{String: timeClass, Valid: timeClass != ""},
+	})
+	if err != nil {
+		return nil, err
+	}
+	games := make([]gameResult, len(rows))
+	for i, r := range rows {
+		games[i] = gameResult{
+			r.WhiteUsername, r.BlackUsername, int(r.WhiteElo.Int32), int(r.BlackElo.Int32),
+			r.Result, r.TimeClass, r.PlayedAt.Time.UTC(), r.UserIsWhite,
+		}
+	}
+	return opponentStats(games), nil
+}
+
+func (p *Postgres) TimeClasses(ctx context.Context, username string) ([]types.TimeClassCount, error) {
+	userID, err := p.userID(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.New(p.pool).CountTimeClasses(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]types.TimeClassCount, 0, len(rows))
+	for _, r := range rows {
+		counts = append(counts, types.TimeClassCount{TimeClass: r.TimeClass, Count: int(r.Games)})
+	}
+	return counts, nil
+}
+
+func (p *Postgres) ProcessingHistory(ctx context.Context, username string, limit int) ([]types.ProcessingLog, error) {
+	rows, err := db.New(p.pool).ListProcessingLogs(ctx, db.ListProcessingLogsParams{
+		Username: pgtype.Text{String: strings.ToLower(username), Valid: username != ""},
+		MaxRows:  int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	logs := make([]types.ProcessingLog, 0, len(rows))
+	for _, r := range rows {
+		logs = append(logs, types.ProcessingLog{
+			ID:            r.ID,
+			Username:      r.Username,
+			Status:        r.Status,
+			SourceFile:    r.SourceFile.String,
+			ApiEndpoint:   r.ApiEndpoint.String,
+			GamesFromDate: pgTimePtr(r.GamesFromDate),
+			GamesToDate:   pgTimePtr(r.GamesToDate),
+			StartedAt:     r.StartedAt.Time.UTC(),
+			CompletedAt:   pgTimePtr(r.CompletedAt),
+			TotalGames:    int(r.TotalGames),
+			SuccessCount:  int(r.SuccessCount),
+			ErrorCount:    int(r.ErrorCount),
+			ErrorMessage:  r.ErrorMessage.String,
+		})
+	}
+	return logs, nil
+}
+
+func (p *Postgres) StartProcessing(ctx context.Context, run types.ProcessingLog) (int64, error) {
+	return db.New(p.pool).StartProcessing(ctx, db.StartProcessingParams{
+		Username:      strings.ToLower(run.Username),
+		SourceFile:    pgtype.Text{String: run.SourceFile, Valid: run.SourceFile != ""},
+		ApiEndpoint:   pgtype.Text{String: run.ApiEndpoint, Valid: run.ApiEndpoint != ""},
+		GamesFromDate: pgTime(run.GamesFromDate),
+		GamesToDate:   pgTime(run.GamesToDate),
+	})
+}
+
+func (p *Postgres) FinishProcessing(ctx context.Context, run types.ProcessingLog) error {
+	return db.New(p.pool).FinishProcessing(ctx, db.FinishProcessingParams{
+		ID:            run.ID,
+		Status:        run.Status,
+		TotalGames:    int32(run.TotalGames),
+		SuccessCount:  int32(run.SuccessCount),
+		ErrorCount:    int32(run.ErrorCount),
+		ErrorMessage:  pgtype.Text{String: run.ErrorMessage, Valid: run.ErrorMessage != ""},
+		GamesFromDate: pgTime(run.GamesFromDate),
+		GamesToDate:   pgTime(run.GamesToDate),
+	})
+}
+
+func (s *SQLite) PositionGames(ctx context.Context, q types.GamesQuery) (*types.GamesPage, error) {
+	cursor, err := parseCursor(q)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := s.userID(ctx, q.Username)
+	if err != nil {
+		return nil, err
+	}
+	f := newSQLiteFilters(q.PositionQuery)
+	params := sqlitedb.ListPositionGamesParams{
+		UserID:      userID,
+		PositionKey: sql.NullString{String: Processpipline.PositionKey(q.Fen), Valid: true},
+		TimeClass:   f.timeClass,
+		Color:       f.color,
+		PlayedFrom:  f.from,
+		PlayedTo:    f.to,
+		Rated:       f.rated,
+		TimeControl: f.timeControl,
+		Opponent:    f.opponent,
+		OpponentMin: f.opponentMin,
+		OpponentMax: f.opponentMax,
+		RatingMin:   f.ratingMin,
+		RatingMax:   f.ratingMax,
+		Sort:        q.Sort,
+		MaxGames:    int64(q.Limit + 1),
+	}
+	if cursor != nil {
+		params.AfterKey = cursor.key
+		params.AfterPlayedAt = sqliteTime(cursor.playedAt)
+		params.AfterID = cursor.id
+	}
+	rows, err := sqlitedb.New(s.db).ListPositionGames(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	games := make([]types.PositionGame, 0, len(rows))
+	keys := make([]int64, 0, len(rows))
+	for _, r := range rows {
+		games = append(games, positionGame(r.ID, r.Link, r.Pgn, int(r.Ply), gameResult{
+			r.WhiteUsername, r.BlackUsername, int(r.WhiteElo.Int64), int(r.BlackElo.Int64),
+			r.Result, r.TimeClass, parseSQLiteTime(r.PlayedAt), r.UserIsWhite == 1,
+		}))
+		keys = append(keys, r.SortKey)
+	}
+	return gamesPage(q, games, keys), nil
+}
+
+func (s *SQLite) Opponents(ctx context.Context, username, timeClass string) ([]types.OpponentStats, error) {
+	userID, err := s.userID(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := sqlitedb.New(s.db).ListUserGameResults(ctx, sqlitedb.ListUserGameResultsParams{
+		UserID:    userID,
+		TimeClass: nullable(timeClass),
+	})
+	if err != nil {
+		return nil, err
+	}
+	games := make([]gameResult, len(rows))
+	for i, r := range rows {
+		games[i] = gameResult{
+			r.WhiteUsername, r.BlackUsername, int(r.WhiteElo.Int64), int(r.BlackElo.Int64),
+			r.Result, r.TimeClass, parseSQLiteTime(r.PlayedAt), r.UserIsWhite == 1,
+		}
+	}
+	return opponentStats(games), nil
+}
+
+func (s *SQLite) TimeClasses(ctx context.Context, username string) ([]types.TimeClassCount, error) {
+	userID, err := s.userID(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := sqlitedb.New(s.db).CountTimeClasses(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]types.TimeClassCount, 0, len(rows))
+	for _, r := range rows {
+		counts = append(counts, types.TimeClassCount{TimeClass: r.TimeClass, Count: int(r.Games)})
+	}
+	return counts, nil
+}
+
+func (s *SQLite) ProcessingHistory(ctx context.Context, username string, limit int) ([]types.ProcessingLog, error) {
+	rows, err := sqlitedb.New(s.db).ListProcessingLogs(ctx, sqlitedb.ListProcessingLogsParams{
+		Username: nullable(strings.ToLower(username)),
+		MaxRows:  int64(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	logs := make([]types.ProcessingLog, 0, len(rows))
+	for _, r := range rows {
+		logs = append(logs, types.ProcessingLog{
+			ID:            r.ID,
+			Username:      r.Username,
+			Status:        r.Status,
+			SourceFile:    r.SourceFile.String,
+			ApiEndpoint:   r.ApiEndpoint.String,
+			GamesFromDate: sqliteTimePtr(r.GamesFromDate),
+			GamesToDate:   sqliteTimePtr(r.GamesToDate),
+			StartedAt:     parseSQLiteTime(r.StartedAt),
+			CompletedAt:   sqliteTimePtr(r.CompletedAt),
+			TotalGames:    int(r.TotalGames),
+			SuccessCount:  int(r.SuccessCount),
+			ErrorCount:    int(r.ErrorCount),
+			ErrorMessage:  r.ErrorMessage.String,
+		})
+	}
+	return logs, nil
+}
+
+func (s *SQLite) StartProcessing(ctx context.Context, run types.ProcessingLog) (int64, error) {
+	return sqlitedb.New(s.db).StartProcessing(ctx, sqlitedb.StartProcessingParams{
+		Username:      strings.ToLower(run.Username),
+		SourceFile:    sql.NullString{String: run.SourceFile, Valid: run.SourceFile != ""},
+		ApiEndpoint:   sql.NullString{String: run.ApiEndpoint, Valid: run.ApiEndpoint != ""},
+		GamesFromDate: sqliteNullTime(run.GamesFromDate),
+		GamesToDate:   sqliteNullTime(run.GamesToDate),
+		StartedAt:     sqliteTime(time.Now()),
+	})
+}
+
+func (s *SQLite) FinishProcessing(ctx context.Context, run types.ProcessingLog) error {
+	now := time.Now()
+	return sqlitedb.New(s.db).FinishProcessing(ctx, sqlitedb.FinishProcessingParams{
+		ID:            run.ID,
+		Status:        run.Status,
+		CompletedAt:   sqliteNullTime(&now),
+		TotalGames:    int64(run.TotalGames),
+		SuccessCount:  int64(run.SuccessCount),
+		ErrorCount:    int64(run.ErrorCount),
+		ErrorMessage:  sql.NullString{String: run.ErrorMessage, Valid: run.ErrorMessage != ""},
+		GamesFromDate: sqliteNullTime(run.GamesFromDate),
+		GamesToDate:   sqliteNullTime(run.GamesToDate),
+	})
+}
+
+// nullable is the value of an optional SQLite filter, NULL matching all.
+func nullable(v string) any {
+	if v == "" {
+		return nil
+	}
+	return v
+}
+
+func pgTime(t *time.Time) pgtype.Timestamptz {
+	if t == nil {
+		return pgtype.Timestamptz{}
+	}
+	return pgtype.Timestamptz{Time: *t, Valid: true}
+}
+
+func pgTimePtr(t pgtype.Timestamptz) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	utc := t.Time.UTC()
+	return &utc
+}
+
+func parseSQLiteTime(v string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, v)
+	return t
+}
+
+func sqliteNullTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: sqliteTime(*t), Valid: true}
+}
+
+func sqliteTimePtr(v sql.NullString) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := parseSQLiteTime(v.String)
+	return &t
+}
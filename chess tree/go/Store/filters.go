@@ -0,0 +1,263 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"chess/ProcessPipline"
+	"chess/Types"
+	"chess/internal/db"
+	"chess/internal/sqlitedb"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// The SQL stores precompute the stats per color and time class only. A query
+// with filters is answered from the games themselves: ListFilteredPositionGames
+// returns the kept games through the position with their next position, and
+// the stats are counted here. The filters mean the same as in
+// types.PositionQuery.Matches, which the in-memory store applies.
+
+// filteredGame is a row of ListFilteredPositionGames, whatever the store.
+type filteredGame struct {
+	id, result, timeClass string
+	userIsWhite           bool
+	nextFen               string
+}
+
+func (g filteredGame) outcome() string {
+	return gameResult{result: g.result, userIsWhite: g.userIsWhite}.outcome()
+}
+
+func filteredPosition(q types.PositionQuery, games []filteredGame) (*types.PositionStats, error) {
+	stats := &types.PositionStats{Fen: q.Fen, TimeClasses: make(map[string]int)}
+	seen := make(map[string]bool)
+	for _, g := range games {
+		if seen[g.id] {
+			continue
+		}
+		seen[g.id] = true
+		win, loss, draw := outcomeCounts(g.outcome())
+		stats.Games++
+		stats.Wins += win
+		stats.Losses += loss
+		stats.Draws += draw
+		stats.TimeClasses[g.timeClass]++
+	}
+	if stats.Games == 0 {
+		return nil, ErrNotFound
+	}
+	return stats, nil
+}
+
+// filteredMoves counts the kept games per move out of the position, naming
+// the moves after the edges to their child.
+func filteredMoves(games []filteredGame, edges []types.Edge) []types.MoveStats {
+	byChild := make(map[string]types.Edge, len(edges))
+	for _, e := range edges {
+		byChild[e.Child] = e
+	}
+
+	bySan := make(map[string]*types.MoveStats)
+	counted := make(map[string]bool)
+	for _, g := range games {
+		edge, known := byChild[Processpipline.PositionKey(g.nextFen)]
+		if g.nextFen == "" || !known || counted[g.id+" "+edge.Move] {
+			continue
+		}
+		counted[g.id+" "+edge.Move] = true
+		stats, exists := bySan[edge.Move]
+		if !exists {
+			stats = &types.MoveStats{Move: edge.Move, Fen: edge.ChildFen, TimeClasses: make(map[string]int)}
+			bySan[edge.Move] = stats
+		}
+		win, loss, draw := outcomeCounts(g.outcome())
+		stats.Games++
+		stats.Wins += win
+		stats.Losses += loss
+		stats.Draws += draw
+		stats.TimeClasses[g.timeClass]++
+	}
+	moves := make([]types.MoveStats, 0, len(bySan))
+	for _, move := range bySan {
+		moves = append(moves, *move)
+	}
+	sortMoves(moves)
+	return moves
+}
+
+// allMoves is q without anything narrowing the moves down, for naming them.
+func allMoves(q types.PositionQuery) types.PositionQuery {
+	return types.PositionQuery{Username: q.Username, Fen: q.Fen}
+}
+
+// pgFilters are the filter parameters of the Postgres queries.
+type pgFilters struct {
+	timeClass, color, timeControl, opponent pgtype.Text
+	from, to                                pgtype.Timestamptz
+	rated                                   pgtype.Bool
+	opponentMin, opponentMax                pgtype.Int4
+	ratingMin, ratingMax                    pgtype.Int4
+}
+
+func newPgFilters(q types.PositionQuery) pgFilters {
+	f := q.Filters
+	filters := pgFilters{
+		timeClass:   pgtype.Text{String: q.TimeClass, Valid: q.TimeClass != ""},
+		color:       pgtype.Text{String: q.Color, Valid: q.Color != ""},
+		timeControl: pgtype.Text{String: f.TimeControl, Valid: f.TimeControl != ""},
+		opponent:    pgtype.Text{String: f.Opponent, Valid: f.Opponent != ""},
+		from:        pgTime(f.From),
+		to:          pgTime(f.To),
+		opponentMin: pgtype.Int4{Int32: int32(f.OpponentMin), Valid: f.OpponentMin != 0},
+		opponentMax: pgtype.Int4{Int32: int32(f.OpponentMax), Valid: f.OpponentMax != 0},
+		ratingMin:   pgtype.Int4{Int32: int32(f.RatingMin), Valid: f.RatingMin != 0},
+		ratingMax:   pgtype.Int4{Int32: int32(f.RatingMax), Valid: f.RatingMax != 0},
+	}
+	if f.Rated != nil {
+		filters.rated = pgtype.Bool{Bool: *f.Rated, Valid: true}
+	}
+	return filters
+}
+
+func (p *Postgres) filteredGames(ctx context.Context, q types.PositionQuery) ([]filteredGame, error) {
+	userID, err := p.userID(ctx, q.Username)
+	if err != nil {
+		return nil, err
+	}
+	f := newPgFilters(q)
+	rows, err := db.New(p.pool).ListFilteredPositionGames(ctx, db.ListFilteredPositionGamesParams{
+		UserID:      userID,
+		PositionKey: pgtype.Text{String: Processpipline.PositionKey(q.Fen), Valid: true},
+		TimeClass:   f.timeClass,
+		Color:       f.color,
+		PlayedFrom:  f.from,
+		PlayedTo:    f.to,
+		Rated:       f.rated,
+		TimeControl: f.timeControl,
+		Opponent:    f.opponent,
+		OpponentMin: f.opponentMin,
+		OpponentMax: f.opponentMax,
+		RatingMin:   f.ratingMin,
+		RatingMax:   f.ratingMax,
+	})
+	if err != nil {
+		return nil, err
+	}
+	games := make([]filteredGame, len(rows))
+	for i, r := range rows {
+		games[i] = filteredGame{uuid.UUID(r.ID.Bytes).String(), r.Result, r.TimeClass, r.UserIsWhite, r.NextFen.String}
+	}
+	return games, nil
+}
+
+func (p *Postgres) filteredPosition(ctx context.Context, q types.PositionQuery) (*types.PositionStats, error) {
+	games, err := p.filteredGames(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return filteredPosition(q, games)
+}
+
+func (p *Postgres) filteredMoves(ctx context.Context, q types.PositionQuery) ([]types.MoveStats, error) {
+	games, err := p.filteredGames(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	edges, err := p.Children(ctx, allMoves(q))
+	if err != nil {
+		return nil, err
+	}
+	return filteredMoves(games, edges), nil
+}
+
+// sqliteFilters are the filter parameters of the SQLite queries, nil for the
+// filters not set.
+type sqliteFilters struct {
+	timeClass, color, timeControl, opponent any
+	from, to, rated                         any
+	opponentMin, opponentMax                any
+	ratingMin, ratingMax                    any
+}
+
+func newSQLiteFilters(q types.PositionQuery) sqliteFilters {
+	f := q.Filters
+	filters := sqliteFilters{
+		timeClass:   nullable(q.TimeClass),
+		color:       nullable(q.Color),
+		timeControl: nullable(f.TimeControl),
+		opponent:    nullable(f.Opponent),
+		opponentMin: nullableInt(f.OpponentMin),
+		opponentMax: nullableInt(f.OpponentMax),
+		ratingMin:   nullableInt(f.RatingMin),
+		ratingMax:   nullableInt(f.RatingMax),
+	}
+	if f.From != nil {
+		filters.from = sqliteTime(*f.From)
+	}
+	if f.To != nil {
+		filters.to = sqliteTime(*f.To)
+	}
+	if f.Rated != nil {
+		filters.rated = sqliteBool(*f.Rated).Int64
+	}
+	return filters
+}
+
+func nullableInt(v int) any {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+func (s *SQLite) filteredGames(ctx context.Context, q types.PositionQuery) ([]filteredGame, error) {
+	userID, err := s.userID(ctx, q.Username)
+	if err != nil {
+		return nil, err
+	}
+	f := newSQLiteFilters(q)
+	rows, err := sqlitedb.New(s.db).ListFilteredPositionGames(ctx, sqlitedb.ListFilteredPositionGamesParams{
+		UserID:      userID,
+		PositionKey: sql.NullString{String: Processpipline.PositionKey(q.Fen), Valid: true},
+		TimeClass:   f.timeClass,
+		Color:       f.color,
+		PlayedFrom:  f.from,
+		PlayedTo:    f.to,
+		Rated:       f.rated,
+		TimeControl: f.timeControl,
+		Opponent:    f.opponent,
+		OpponentMin: f.opponentMin,
+		OpponentMax: f.opponentMax,
+		RatingMin:   f.ratingMin,
+		RatingMax:   f.ratingMax,
+	})
+	if err != nil {
+		return nil, err
+	}
+	games := make([]filteredGame, len(rows))
+	for i, r := range rows {
+		games[i] = filteredGame{r.ID, r.Result, r.TimeClass, r.UserIsWhite == 1, r.NextFen.String}
+	}
+	return games, nil
+}
+
+func (s *SQLite) filteredPosition(ctx context.Context, q types.PositionQuery) (*types.PositionStats, error) {
+	games, err := s.filteredGames(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return filteredPosition(q, games)
+}
+
+func (s *SQLite) filteredMoves(ctx context.Context, q types.PositionQuery) ([]types.MoveStats, error) {
+	games, err := s.filteredGames(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	edges, err := s.Children(ctx, allMoves(q))
+	if err != nil {
+		return nil, err
+	}
+	return filteredMoves(games, edges), nil
+}
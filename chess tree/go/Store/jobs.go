@@ -0,0 +1,235 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"chess/Types"
+	"chess/internal/db"
+	"chess/internal/sqlitedb"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var ErrJobNotFound = errors.New("store: job not found")
+
+// JobStore keeps the sync jobs, past ones included.
+type JobStore interface {
+	CreateJob(ctx context.Context, job *types.SyncJob) error
+	UpdateJob(ctx context.Context, job *types.SyncJob) error
+	Job(ctx context.Context, id string) (*types.SyncJob, error)
+	// AbandonJobs fails the jobs a previous run of the server left
+	// unfinished and returns how many there were.
+	AbandonJobs(ctx context.Context, reason string) (int64, error)
+}
+
+// jobs returns the first store of the chain keeping jobs, the in-memory one
+// always does.
+func (c Chain) jobs() JobStore {
+	for _, s := range c {
+		if j, ok := s.(JobStore); ok {
+			return j
+		}
+	}
+	return nil
+}
+
+func (c Chain) CreateJob(ctx context.Context, job *types.SyncJob) error {
+	return c.jobs().CreateJob(ctx, job)
+}
+
+func (c Chain) UpdateJob(ctx context.Context, job *types.SyncJob) error {
+	return c.jobs().UpdateJob(ctx, job)
+}
+
+func (c Chain) Job(ctx context.Context, id string) (*types.SyncJob, error) {
+	return c.jobs().Job(ctx, id)
+}
+
+func (c Chain) AbandonJobs(ctx context.Context, reason string) (int64, error) {
+	return c.jobs().AbandonJobs(ctx, reason)
+}
+
+// memoryJobs keeps the jobs of the in-memory store, lost on restart like the
+// tree itself.
+type memoryJobs struct {
+	mu   sync.Mutex
+	byID map[string]types.SyncJob
+}
+
+func (m *Memory) CreateJob(ctx context.Context, job *types.SyncJob) error {
+	m.jobs.mu.Lock()
+	defer m.jobs.mu.Unlock()
+	if m.jobs.byID == nil {
+		m.jobs.byID = make(map[string]types.SyncJob)
+	}
+	m.jobs.byID[job.ID] = copyJob(job)
+	return nil
+}
+
+func (m *Memory) UpdateJob(ctx context.Context, job *types.SyncJob) error {
+	m.jobs.mu.Lock()
+	defer m.jobs.mu.Unlock()
+	if _, exists := m.jobs.byID[job.ID]; !exists {
+		return ErrJobNotFound
+	}
+	m.jobs.byID[job.ID] = copyJob(job)
+	return nil
+}
+
+func (m *Memory) Job(ctx context.Context, id string) (*types.SyncJob, error) {
+	m.jobs.mu.Lock()
+	defer m.jobs.mu.Unlock()
+	job, exists := m.jobs.byID[id]
+	if !exists {
+		return nil, ErrJobNotFound
+	}
+	job = copyJob(&job)
+	return &job, nil
+}
+
+func (m *Memory) AbandonJobs(ctx context.Context, reason string) (int64, error) {
+	return 0, nil
+}
+
+func copyJob(job *types.SyncJob) types.SyncJob {
+	c := *job
+	c.Errors = append([]string(nil), job.Errors...)
+	return c
+}
+
+func (p *Postgres) CreateJob(ctx context.Context, job *types.SyncJob) error {
+	id, err := uuid.Parse(job.ID)
+	if err != nil {
+		return err
+	}
+	return db.New(p.pool).CreateSyncJob(ctx, db.CreateSyncJobParams{
+		ID:       pgtype.UUID{Bytes: id, Valid: true},
+		Username: job.Username,
+	})
+}
+
+func (p *Postgres) UpdateJob(ctx context.Context, job *types.SyncJob) error {
+	id, err := uuid.Parse(job.ID)
+	if err != nil {
+		return err
+	}
+	jobErrors, err := json.Marshal(job.Errors)
+	if err != nil {
+		return err
+	}
+	return db.New(p.pool).UpdateSyncJob(ctx, db.UpdateSyncJobParams{
+		ID:             pgtype.UUID{Bytes: id, Valid: true},
+		Status:         job.Status,
+		Archives:       int32(job.Archives),
+		FetchedGames:   int32(job.Fetched),
+		ProcessedGames: int32(job.Processed),
+		FailedGames:    int32(job.Failed),
+		Errors:         jobErrors,
+		ErrorMessage:   pgtype.Text{String: job.Error, Valid: job.Error != ""},
+		StartedAt:      pgTime(job.StartedAt),
+		FinishedAt:     pgTime(job.FinishedAt),
+	})
+}
+
+func (p *Postgres) Job(ctx context.Context, id string) (*types.SyncJob, error) {
+	jobID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrJobNotFound
+	}
+	row, err := db.New(p.pool).GetSyncJob(ctx, pgtype.UUID{Bytes: jobID, Valid: true})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	job := &types.SyncJob{
+		ID:         id,
+		Username:   row.Username,
+		Status:     row.Status,
+		Archives:   int(row.Archives),
+		Fetched:    int(row.FetchedGames),
+		Processed:  int(row.ProcessedGames),
+		Failed:     int(row.FailedGames),
+		Error:      row.ErrorMessage.String,
+		CreatedAt:  row.CreatedAt.Time.UTC(),
+		StartedAt:  pgTimePtr(row.StartedAt),
+		FinishedAt: pgTimePtr(row.FinishedAt),
+	}
+	if err := json.Unmarshal(row.Errors, &job.Errors); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (p *Postgres) AbandonJobs(ctx context.Context, reason string) (int64, error) {
+	return db.New(p.pool).AbandonSyncJobs(ctx, pgtype.Text{String: reason, Valid: true})
+}
+
+func (s *SQLite) CreateJob(ctx context.Context, job *types.SyncJob) error {
+	return sqlitedb.New(s.db).CreateSyncJob(ctx, sqlitedb.CreateSyncJobParams{
+		ID:        job.ID,
+		Username:  job.Username,
+		CreatedAt: sqliteTime(job.CreatedAt),
+	})
+}
+
+func (s *SQLite) UpdateJob(ctx context.Context, job *types.SyncJob) error {
+	jobErrors, err := json.Marshal(job.Errors)
+	if err != nil {
+		return err
+	}
+	return sqlitedb.New(s.db).UpdateSyncJob(ctx, sqlitedb.UpdateSyncJobParams{
+		Status:         job.Status,
+		Archives:       int64(job.Archives),
+		FetchedGames:   int64(job.Fetched),
+		ProcessedGames: int64(job.Processed),
+		FailedGames:    int64(job.Failed),
+		Errors:         string(jobErrors),
+		ErrorMessage:   sql.NullString{String: job.Error, Valid: job.Error != ""},
+		StartedAt:      sqliteNullTime(job.StartedAt),
+		FinishedAt:     sqliteNullTime(job.FinishedAt),
+		ID:             job.ID,
+	})
+}
+
+func (s *SQLite) Job(ctx context.Context, id string) (*types.SyncJob, error) {
+	row, err := sqlitedb.New(s.db).GetSyncJob(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	job := &types.SyncJob{
+		ID:         row.ID,
+		Username:   row.Username,
+		Status:     row.Status,
+		Archives:   int(row.Archives),
+		Fetched:    int(row.FetchedGames),
+		Processed:  int(row.ProcessedGames),
+		Failed:     int(row.FailedGames),
+		Error:      row.ErrorMessage.String,
+		CreatedAt:  parseSQLiteTime(row.CreatedAt),
+		StartedAt:  sqliteTimePtr(row.StartedAt),
+		FinishedAt: sqliteTimePtr(row.FinishedAt),
+	}
+	if err := json.Unmarshal([]byte(row.Errors), &job.Errors); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (s *SQLite) AbandonJobs(ctx context.Context, reason string) (int64, error) {
+	now := time.Now()
+	return sqlitedb.New(s.db).AbandonSyncJobs(ctx, sqlitedb.AbandonSyncJobsParams{
+		ErrorMessage: sql.NullString{String: reason, Valid: true},
+		FinishedAt:   sqliteNullTime(&now),
+	})
+}
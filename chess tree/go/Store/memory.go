@@ -0,0 +1,250 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"chess/ProcessPipline"
+	"chess/Types"
+)
+
+var ErrNotFound = errors.New("store: position not found")
+
+// Memory is the store over Processpipline.HashMap. The tree is a single
+// aggregate of every processed game, so the username, color and time class of
+// a query are not looked at, unless the query has filters. The stats are then
+// counted from the facts of the games saved since the start, the games loaded
+// from a snapshot not having any, and from the positions they reached: the
+// HashMap only keeps a sample of the game ids.
+type Memory struct {
+	jobs memoryJobs
+
+	mu       sync.RWMutex
+	games    map[string]types.GameFacts
+	reached  map[string]map[string]bool
+	archives map[string]string
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		games:    make(map[string]types.GameFacts),
+		reached:  make(map[string]map[string]bool),
+		archives: make(map[string]string),
+	}
+}
+
+// SaveGame adds the game to the tree once, a game synced again, before or
+// after a restart, is skipped by Apply so it is not counted twice. Its facts
+// are recorded either way, the filters then count the games of the snapshot
+// synced again. Apply takes Processpipline.Mu before m.mu is, the readers
+// take the locks the other way round.
+func (m *Memory) SaveGame(ctx context.Context, rec *types.GameRecord) error {
+	Processpipline.Apply(rec)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.games[rec.ID] = gameFacts(rec)
+	for _, fen := range gameFENs(rec) {
+		if m.reached[fen] == nil {
+			m.reached[fen] = make(map[string]bool)
+		}
+		m.reached[fen][rec.ID] = true
+	}
+	return nil
+}
+
+// gameFENs are the positions a game reached, the start one included.
+func gameFENs(rec *types.GameRecord) []string {
+	fens := []string{Processpipline.StartFEN}
+	for _, ply := range rec.Plies {
+		fens = append(fens, ply.Fen)
+	}
+	return fens
+}
+
+// Forget takes the games of a deleted user out of the tree and returns how
+// many it had, see UserData.
+func (m *Memory) Forget(ctx context.Context, recs []*types.GameRecord) int {
+	forgotten := 0
+	for _, rec := range recs {
+		if Processpipline.Remove(rec) {
+			forgotten++
+		}
+		m.mu.Lock()
+		delete(m.games, rec.ID)
+		for _, fen := range gameFENs(rec) {
+			delete(m.reached[fen], rec.ID)
+			if len(m.reached[fen]) == 0 {
+				delete(m.reached, fen)
+			}
+		}
+		m.mu.Unlock()
+	}
+	return forgotten
+}
+
+func gameFacts(rec *types.GameRecord) types.GameFacts {
+	facts := types.GameFacts{
+		Username:       strings.ToLower(rec.Username),
+		Color:          rec.Color,
+		TimeClass:      rec.TimeClass,
+		TimeControl:    rec.TimeControl,
+		Rated:          rec.Rated,
+		Opponent:       rec.Black,
+		OpponentRating: rec.BlackElo,
+		PlayerRating:   rec.WhiteElo,
+		PlayedAt:       rec.PlayedAt,
+		Outcome:        rec.Outcome,
+		Termination:    rec.Termination,
+	}
+	if rec.Color == "black" {
+		facts.Opponent, facts.OpponentRating, facts.PlayerRating = rec.White, rec.WhiteElo, rec.BlackElo
+	}
+	facts.Accuracy, _ = Processpipline.UserAccuracy(rec)
+	return facts
+}
+
+func (m *Memory) Position(ctx context.Context, q types.PositionQuery) (*types.PositionStats, error) {
+	Processpipline.Mu.RLock()
+	defer Processpipline.Mu.RUnlock()
+
+	fens := lookup(q.Fen)
+	if len(fens) == 0 {
+		return nil, ErrNotFound
+	}
+	if !q.Filters.IsZero() {
+		return m.filteredPosition(q, fens)
+	}
+	stats := &types.PositionStats{Fen: q.Fen}
+	for _, fen := range fens {
+		info := Processpipline.HashMap[fen]
+		stats.Games += info.Count
+		stats.Wins += info.WinCount
+		stats.Losses += info.LossCount
+		stats.Draws += info.DrawCount
+	}
+	return stats, nil
+}
+
+func (m *Memory) NextMoves(ctx context.Context, q types.PositionQuery) ([]types.MoveStats, error) {
+	Processpipline.Mu.RLock()
+	defer Processpipline.Mu.RUnlock()
+
+	fens := lookup(q.Fen)
+	if len(fens) == 0 {
+		return nil, ErrNotFound
+	}
+	if !q.Filters.IsZero() {
+		return m.filteredMoves(q, fens), nil
+	}
+	bySan := make(map[string]*types.MoveStats)
+	for _, fen := range fens {
+		for san, move := range Processpipline.HashMap[fen].Moves {
+			stats, exists := bySan[san]
+			if !exists {
+				stats = &types.MoveStats{Move: san, Fen: move.Fen}
+				bySan[san] = stats
+			}
+			stats.Games += move.Count
+			stats.Wins += move.WinCount
+			stats.Losses += move.LossCount
+			stats.Draws += move.DrawCount
+		}
+	}
+	moves := make([]types.MoveStats, 0, len(bySan))
+	for _, move := range bySan {
+		moves = append(moves, *move)
+	}
+	sortMoves(moves)
+	return moves, nil
+}
+
+// matching returns the facts of the games through fens that q keeps.
+func (m *Memory) matching(q types.PositionQuery, fens []string) map[string]types.GameFacts {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	matched := make(map[string]types.GameFacts)
+	for _, fen := range fens {
+		for id := range m.reached[fen] {
+			if facts, known := m.games[id]; known && q.Matches(facts) {
+				matched[id] = facts
+			}
+		}
+	}
+	return matched
+}
+
+func (m *Memory) filteredPosition(q types.PositionQuery, fens []string) (*types.PositionStats, error) {
+	stats := &types.PositionStats{Fen: q.Fen, TimeClasses: make(map[string]int)}
+	for _, facts := range m.matching(q, fens) {
+		win, loss, draw := outcomeCounts(facts.Outcome)
+		stats.Games++
+		stats.Wins += win
+		stats.Losses += loss
+		stats.Draws += draw
+		stats.TimeClasses[facts.TimeClass]++
+	}
+	if stats.Games == 0 {
+		return nil, ErrNotFound
+	}
+	return stats, nil
+}
+
+// filteredMoves counts the kept games through each move, a game being
+// through a move when it reached both its parent and its child.
+func (m *Memory) filteredMoves(q types.PositionQuery, fens []string) []types.MoveStats {
+	matched := m.matching(q, fens)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	bySan := make(map[string]*types.MoveStats)
+	counted := make(map[string]map[string]bool)
+	for _, fen := range fens {
+		for san, move := range Processpipline.HashMap[fen].Moves {
+			for _, child := range Processpipline.Variants(move.Fen) {
+				for id := range m.reached[child] {
+					facts, kept := matched[id]
+					if !kept || counted[san][id] {
+						continue
+					}
+					stats, exists := bySan[san]
+					if !exists {
+						stats = &types.MoveStats{Move: san, Fen: move.Fen, TimeClasses: make(map[string]int)}
+						bySan[san] = stats
+						counted[san] = make(map[string]bool)
+					}
+					counted[san][id] = true
+					win, loss, draw := outcomeCounts(facts.Outcome)
+					stats.Games++
+					stats.Wins += win
+					stats.Losses += loss
+					stats.Draws += draw
+					stats.TimeClasses[facts.TimeClass]++
+				}
+			}
+		}
+	}
+	moves := make([]types.MoveStats, 0, len(bySan))
+	for _, move := range bySan {
+		moves = append(moves, *move)
+	}
+	sortMoves(moves)
+	return moves
+}
+
+// lookup returns the FENs of the entries of the position of fen, whatever
+// their clocks, from the index of the keys. A FEN and its position key get
+// the same answer, like from the SQL stores. The caller holds Mu.
+func lookup(fen string) []string {
+	return Processpipline.Variants(fen)
+}
+
+func sortMoves(moves []types.MoveStats) {
+	sort.Slice(moves, func(i, j int) bool {
+		if moves[i].Games != moves[j].Games {
+			return moves[i].Games > moves[j].Games
+		}
+		return moves[i].Move < moves[j].Move
+	})
+}
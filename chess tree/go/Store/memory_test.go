@@ -0,0 +1,206 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"chess/ProcessPipline"
+	"chess/Types"
+)
+
+// useEmptyTree gives the test an empty HashMap, restored when it ends.
+func useEmptyTree(t *testing.T) {
+	t.Helper()
+	Processpipline.Mu.Lock()
+	saved, savedOpenings, savedGames, savedKeys := Processpipline.HashMap, Processpipline.Openings, Processpipline.Games, Processpipline.Keys
+	Processpipline.HashMap = make(map[string]*types.PositonInfo)
+	Processpipline.Openings = make(map[string]*types.OpeningStat)
+	Processpipline.Games = make(map[string]bool)
+	Processpipline.Keys = make(map[string][]string)
+	Processpipline.Mu.Unlock()
+	t.Cleanup(func() {
+		Processpipline.Mu.Lock()
+		Processpipline.HashMap, Processpipline.Openings = saved, savedOpenings
+		Processpipline.Games, Processpipline.Keys = savedGames, savedKeys
+		Processpipline.Mu.Unlock()
+	})
+}
+
+func TestMemorySaveGameOnce(t *testing.T) {
+	useEmptyTree(t)
+	const afterE4 = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1"
+	rec := &types.GameRecord{
+		ID:        "game-1",
+		Color:     "white",
+		Outcome:   "win",
+		TimeClass: "blitz",
+		Plies:     []types.Ply{{Number: 1, San: "e4", Parent: Processpipline.StartFEN, Fen: afterE4}},
+	}
+	m := NewMemory()
+	for range 2 {
+		if err := m.SaveGame(context.Background(), rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := m.Position(context.Background(), types.PositionQuery{Fen: afterE4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Games != 1 || stats.Wins != 1 {
+		t.Errorf("a game saved twice counts %d games and %d wins, want 1 and 1", stats.Games, stats.Wins)
+	}
+	moves, err := m.NextMoves(context.Background(), types.PositionQuery{Fen: Processpipline.StartFEN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 1 || moves[0].Games != 1 {
+		t.Errorf("moves from the start = %+v, want e4 once", moves)
+	}
+}
+
+// TestMemoryTreePerUser builds the trees of "me vs teammate" from the games
+// of each user only.
+func TestMemoryTreePerUser(t *testing.T) {
+	useEmptyTree(t)
+	const (
+		afterE4 = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1"
+		afterD4 = "rnbqkbnr/pppppppp/8/8/3P4/8/PPP1PPPP/RNBQKBNR b KQkq - 0 1"
+	)
+	m := NewMemory()
+	aliceAccuracy, bobAccuracy := 75.0, 55.0
+	for _, rec := range []*types.GameRecord{
+		{ID: "game-1", Username: "Alice", Color: "white", Outcome: "win", Termination: types.TerminationTime, WhiteAccuracy: &aliceAccuracy,
+			Plies: []types.Ply{{Number: 1, San: "e4", Parent: Processpipline.StartFEN, Fen: afterE4}}},
+		{ID: "game-2", Username: "bob", Color: "white", Outcome: "loss", Termination: types.TerminationCheckmate, WhiteAccuracy: &bobAccuracy,
+			Plies: []types.Ply{{Number: 1, San: "d4", Parent: Processpipline.StartFEN, Fen: afterD4}}},
+	} {
+		if err := m.SaveGame(context.Background(), rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		username, played, other, move string
+		ended                         types.Termination
+		count                         types.TerminationCount
+		accuracy                      float64
+	}{
+		{"alice", afterE4, afterD4, "e4", types.TerminationTime, types.TerminationCount{Win: 1}, aliceAccuracy},
+		{"Bob", afterD4, afterE4, "d4", types.TerminationCheckmate, types.TerminationCount{Loss: 1}, bobAccuracy},
+	} {
+		tree, err := m.Tree(context.Background(), types.PositionQuery{Username: tt.username}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root := tree[Processpipline.StartFEN]
+		if root == nil || root.Count != 1 || len(root.Moves) != 1 {
+			t.Fatalf("%s: root %+v, want their game only", tt.username, root)
+		}
+		if tree[tt.played] == nil || tree[tt.other] != nil {
+			t.Errorf("%s: tree has %v, want %s and not %s", tt.username, tree, tt.played, tt.other)
+		}
+		if ended := root.Terminations; len(ended) != 1 || ended[tt.ended] == nil || *ended[tt.ended] != tt.count {
+			t.Errorf("%s: terminations %v, want their game's %s only", tt.username, ended, tt.ended)
+		}
+		move := root.Moves[tt.move]
+		if root.Accuracy.Average("") != tt.accuracy || move == nil || move.Accuracy.Average("white") != tt.accuracy {
+			t.Errorf("%s: accuracy %+v, move %+v, want their game's %v", tt.username, root.Accuracy, move, tt.accuracy)
+		}
+	}
+
+	tree, err := m.Tree(context.Background(), types.PositionQuery{Username: "carol"}, nil)
+	if err != nil || len(tree) != 0 {
+		t.Errorf("a user without games: %v, %v, want an empty tree", tree, err)
+	}
+}
+
+// TestMemoryResyncAfterRestart syncs a game, restarts from the snapshot and
+// syncs it again, the game is still counted once.
+func TestMemoryResyncAfterRestart(t *testing.T) {
+	useEmptyTree(t)
+	const afterE4 = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1"
+	rec := &types.GameRecord{
+		ID:        "game-1",
+		Username:  "alice",
+		Color:     "white",
+		Outcome:   "win",
+		TimeClass: "blitz",
+		Plies:     []types.Ply{{Number: 1, San: "e4", Parent: Processpipline.StartFEN, Fen: afterE4}},
+	}
+	if err := NewMemory().SaveGame(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "tree.snapshot")
+	if err := Processpipline.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// the restart
+	Processpipline.Mu.Lock()
+	Processpipline.HashMap = make(map[string]*types.PositonInfo)
+	Processpipline.Openings = make(map[string]*types.OpeningStat)
+	Processpipline.Games = make(map[string]bool)
+	Processpipline.Mu.Unlock()
+	if err := Processpipline.LoadSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	m := NewMemory()
+	if err := m.SaveGame(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, q := range []types.PositionQuery{
+		{Fen: afterE4},
+		{Fen: afterE4, Filters: types.Filters{Opponent: rec.Black}},
+	} {
+		stats, err := m.Position(context.Background(), q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Games != 1 || stats.Wins != 1 {
+			t.Errorf("%+v: %d games and %d wins after the resync, want 1 and 1", q.Filters, stats.Games, stats.Wins)
+		}
+	}
+}
+
+// TestMemoryFiltersPastGameIDs filters a position reached by more games than
+// the HashMap keeps the ids of.
+func TestMemoryFiltersPastGameIDs(t *testing.T) {
+	useEmptyTree(t)
+	const afterE4 = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1"
+	m := NewMemory()
+	games := Processpipline.MaxGameIDs + 20
+	for i := range games {
+		rec := &types.GameRecord{
+			ID:        fmt.Sprint("game-", i),
+			Username:  "alice",
+			Color:     "white",
+			Outcome:   "win",
+			TimeClass: "blitz",
+			Plies:     []types.Ply{{Number: 1, San: "e4", Parent: Processpipline.StartFEN, Fen: afterE4}},
+		}
+		if err := m.SaveGame(context.Background(), rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := types.PositionQuery{Fen: Processpipline.PositionKey(afterE4), TimeClass: "blitz", Filters: types.Filters{RatingMax: 3000}}
+	stats, err := m.Position(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Games != games {
+		t.Errorf("filtered position counts %d games, want %d", stats.Games, games)
+	}
+	q.Fen = Processpipline.StartFEN
+	moves, err := m.NextMoves(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 1 || moves[0].Games != games {
+		t.Errorf("filtered moves = %+v, want e4 in %d games", moves, games)
+	}
+}
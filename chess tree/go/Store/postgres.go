@@ -0,0 +1,238 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"chess/ProcessPipline"
+	"chess/Types"
+	"chess/internal/db"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Postgres stores games and their positions in the tables created by the migrations.
+// chess.com usernames are case insensitive so they are stored lower cased.
+type Postgres struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgres(ctx context.Context, dsn string) (*Postgres, error) {
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return &Postgres{pool: pool}, nil
+}
+
+func (p *Postgres) Close() {
+	p.pool.Close()
+}
+
+// SaveGame writes the game, its positions and their stats in one transaction.
+// A game that is already stored is left alone so syncing the same archive
+// twice does not count its positions twice.
+func (p *Postgres) SaveGame(ctx context.Context, rec *types.GameRecord) error {
+	if err := validate(rec); err != nil {
+		return err
+	}
+	gameID, err := pgUUID(rec.ID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	q := db.New(tx)
+
+	userID, err := q.UpsertUser(ctx, strings.ToLower(rec.Username))
+	if err != nil {
+		return err
+	}
+
+	playedAt := pgtype.Timestamptz{Time: rec.PlayedAt, Valid: true}
+	inserted, err := q.InsertGame(ctx, db.InsertGameParams{
+		ID:            gameID,
+		UserID:        userID,
+		Link:          rec.Link,
+		WhiteUsername: rec.White,
+		BlackUsername: rec.Black,
+		WhiteElo:      pgtype.Int4{Int32: int32(rec.WhiteElo), Valid: rec.WhiteElo > 0},
+		BlackElo:      pgtype.Int4{Int32: int32(rec.BlackElo), Valid: rec.BlackElo > 0},
+		Result:        rec.Result,
+		TimeClass:     rec.TimeClass,
+		TimeControl:   pgtype.Text{String: rec.TimeControl, Valid: rec.TimeControl != ""},
+		Pgn:           rec.PGN,
+		PlayedAt:      playedAt,
+		Eco:           pgtype.Text{String: rec.ECO, Valid: rec.ECO != ""},
+		Termination:   pgtype.Text{String: rec.Termination.String(), Valid: true},
+		WhiteAccuracy: pgFloat(rec.WhiteAccuracy),
+		BlackAccuracy: pgFloat(rec.BlackAccuracy),
+		Rated:         pgtype.Bool{Bool: rec.Rated, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	if inserted == 0 {
+		return tx.Commit(ctx)
+	}
+
+	win, loss, draw := outcomeCounts(rec.Outcome)
+	positions := append([]types.Ply{{Fen: Processpipline.StartFEN}}, rec.Plies...)
+	for _, ply := range positions {
+		err := q.UpsertPositionStats(ctx, db.UpsertPositionStatsParams{
+			Fen:            ply.Fen,
+			UserID:         userID,
+			Color:          rec.Color,
+			TimeClass:      rec.TimeClass,
+			WinCount:       pgtype.Int4{Int32: int32(win), Valid: true},
+			LossCount:      pgtype.Int4{Int32: int32(loss), Valid: true},
+			DrawCount:      pgtype.Int4{Int32: int32(draw), Valid: true},
+			LatestGameID:   gameID,
+			LatestPlayedAt: playedAt,
+		})
+		if err != nil {
+			return err
+		}
+		err = q.InsertGamePosition(ctx, db.InsertGamePositionParams{
+			GameID:     gameID,
+			Fen:        ply.Fen,
+			MoveNumber: int32(ply.Number),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, e := range gameEdges(rec) {
+		err := q.UpsertMoveEdge(ctx, db.UpsertMoveEdgeParams{
+			UserID:    userID,
+			Color:     rec.Color,
+			TimeClass: rec.TimeClass,
+			ParentKey: e.parent,
+			MoveSan:   e.move,
+			ChildKey:  e.child,
+			ChildFen:  e.childFen,
+			WinCount:  int32(win),
+			LossCount: int32(loss),
+			DrawCount: int32(draw),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (p *Postgres) Position(ctx context.Context, q types.PositionQuery) (*types.PositionStats, error) {
+	if !q.Filters.IsZero() {
+		return p.filteredPosition(ctx, q)
+	}
+	userID, err := p.userID(ctx, q.Username)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.New(p.pool).GetPositionStats(ctx, db.GetPositionStatsParams{
+		UserID:      userID,
+		PositionKey: pgtype.Text{String: Processpipline.PositionKey(q.Fen), Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &types.PositionStats{Fen: q.Fen, TimeClasses: make(map[string]int)}
+	for _, row := range rows {
+		if !matches(q, row.Color, row.TimeClass) {
+			continue
+		}
+		stats.Games += int(row.GameCount.Int32)
+		stats.Wins += int(row.WinCount.Int32)
+		stats.Losses += int(row.LossCount.Int32)
+		stats.Draws += int(row.DrawCount.Int32)
+		stats.TimeClasses[row.TimeClass] += int(row.GameCount.Int32)
+	}
+	if stats.Games == 0 {
+		return nil, ErrNotFound
+	}
+	return stats, nil
+}
+
+func (p *Postgres) NextMoves(ctx context.Context, q types.PositionQuery) ([]types.MoveStats, error) {
+	if !q.Filters.IsZero() {
+		return p.filteredMoves(ctx, q)
+	}
+	edges, err := p.Children(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	moves := make([]types.MoveStats, 0, len(edges))
+	for _, e := range edges {
+		moves = append(moves, types.MoveStats{
+			Move:        e.Move,
+			Fen:         e.ChildFen,
+			Games:       e.Games,
+			Wins:        e.Wins,
+			Losses:      e.Losses,
+			Draws:       e.Draws,
+			TimeClasses: e.TimeClasses,
+		})
+	}
+	sortMoves(moves)
+	return moves, nil
+}
+
+func (p *Postgres) userID(ctx context.Context, username string) (pgtype.UUID, error) {
+	user, err := db.New(p.pool).GetUserByUsername(ctx, strings.ToLower(username))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return pgtype.UUID{}, ErrNotFound
+	}
+	if err != nil {
+		return pgtype.UUID{}, err
+	}
+	return user.ID, nil
+}
+
+// validate rejects the games the schema constraints would refuse.
+func validate(rec *types.GameRecord) error {
+	switch rec.TimeClass {
+	case "bullet", "blitz", "rapid", "daily":
+	default:
+		return fmt.Errorf("store: unsupported time class %q for game %s", rec.TimeClass, rec.ID)
+	}
+	switch rec.Color {
+	case "white", "black":
+	default:
+		return fmt.Errorf("store: invalid color %q for game %s", rec.Color, rec.ID)
+	}
+	return nil
+}
+
+// matches applies the color and time class of a query, empty meaning all.
+func matches(q types.PositionQuery, color, timeClass string) bool {
+	return (q.Color == "" || q.Color == color) && (q.TimeClass == "" || q.TimeClass == timeClass)
+}
+
+func pgUUID(id string) (pgtype.UUID, error) {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return pgtype.UUID{}, fmt.Errorf("store: invalid game id %q: %w", id, err)
+	}
+	return pgtype.UUID{Bytes: parsed, Valid: true}, nil
+}
+
+func pgFloat(v *float64) pgtype.Float8 {
+	if v == nil || *v <= 0 {
+		return pgtype.Float8{}
+	}
+	return pgtype.Float8{Float64: *v, Valid: true}
+}
@@ -0,0 +1,199 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"chess/Types"
+	"chess/internal/db"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// rebuildLockID keeps two rebuilds from sharing the shadow tables.
+const rebuildLockID int64 = 0x6f65_7265_6275
+
+var ErrRebuildRunning = errors.New("store: a rebuild is already running")
+
+// Replayer turns a stored game back into a record, username being the user
+// the game was stored for. utils.ProcessGame is the one the sync uses, so a
+// rebuild applies the current depth, FEN and time class rules.
+type Replayer func(game *types.Game, username string) (*types.GameRecord, error)
+
+// Rebuild recomputes position_stats, move_edges and game_positions of one
+// user, or of every user when username is empty, from the PGN of their
+// games. The rows are built in the rebuild_ shadow tables while the store
+// keeps serving and writing, then swapped in with a single transaction that
+// first replays the games stored in the meantime. Games that fail to replay
+// are skipped and counted. progress may be nil.
+func (p *Postgres) Rebuild(ctx context.Context, username string, batchSize int, replay Replayer, progress func(types.RebuildProgress)) error {
+	if progress == nil {
+		progress = func(types.RebuildProgress) {}
+	}
+	batchSize = max(batchSize, 1)
+
+	conn, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, rebuildLockID).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return ErrRebuildRunning
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, rebuildLockID)
+
+	var userID pgtype.UUID
+	if username != "" {
+		userID, err = p.userID(ctx, username)
+		if err != nil {
+			return err
+		}
+	}
+
+	q := db.New(conn)
+	if err := q.ClearRebuildTables(ctx); err != nil {
+		return err
+	}
+	defer db.New(conn).ClearRebuildTables(context.Background())
+
+	total, err := q.CountRebuildGames(ctx, userID)
+	if err != nil {
+		return err
+	}
+	state := types.RebuildProgress{Phase: "build", Total: int(total)}
+	progress(state)
+
+	skipped := make(map[pgtype.UUID]bool)
+	if err := rebuildPass(ctx, conn, userID, batchSize, replay, skipped, &state, progress); err != nil {
+		return err
+	}
+
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		// games stored from here on wait for the swap, the ones stored while
+		// building are replayed now so the swap does not drop them
+		if _, err := tx.Exec(ctx, `LOCK TABLE games IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+			return err
+		}
+		state.Phase = "catch-up"
+		progress(state)
+		if err := rebuildPass(ctx, tx, userID, batchSize, replay, skipped, &state, progress); err != nil {
+			return err
+		}
+
+		state.Phase = "swap"
+		progress(state)
+		q := db.New(tx)
+		for _, step := range []func(context.Context, pgtype.UUID) error{
+			q.DeleteGamePositionsForRebuild,
+			q.DeleteMoveEdgesForRebuild,
+			q.DeletePositionStatsForRebuild,
+		} {
+			if err := step(ctx, userID); err != nil {
+				return err
+			}
+		}
+		for _, step := range []func(context.Context) error{
+			q.SwapInGamePositions,
+			q.SwapInMoveEdges,
+			q.SwapInPositionStats,
+		} {
+			if err := step(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// rebuildPass replays the games of the scope missing from the shadow tables,
+// batchSize games per query. The games a previous pass skipped are still
+// missing, they are in skipped and left alone so they are counted once.
+func rebuildPass(ctx context.Context, conn db.DBTX, userID pgtype.UUID, batchSize int, replay Replayer, skipped map[pgtype.UUID]bool, state *types.RebuildProgress, progress func(types.RebuildProgress)) error {
+	q := db.New(conn)
+	after := pgtype.UUID{Valid: true}
+	for {
+		rows, err := q.ListRebuildGames(ctx, db.ListRebuildGamesParams{
+			UserID:    userID,
+			After:     after,
+			BatchSize: int32(batchSize),
+		})
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		batch := newDeltaBatch()
+		replayed := 0
+		for _, row := range rows {
+			if skipped[row.ID] {
+				continue
+			}
+			replayed++
+			rec, err := replay(storedGame(row), row.ChessComUsername)
+			if err == nil {
+				err = validate(rec)
+			}
+			if err != nil {
+				fmt.Println("rebuild: skipping game", row.Link, err)
+				skipped[row.ID] = true
+				state.Skipped++
+				continue
+			}
+			// the stored id wins over the one in the PGN headers
+			batch.add(rec, row.ID, row.UserID)
+		}
+		if err := applyRebuildBatch(ctx, q, batch); err != nil {
+			return err
+		}
+
+		after = rows[len(rows)-1].ID
+		state.Done += replayed
+		progress(*state)
+	}
+}
+
+func applyRebuildBatch(ctx context.Context, q *db.Queries, batch *deltaBatch) error {
+	if len(batch.positions) == 0 {
+		return nil
+	}
+	positions := make([]db.CopyRebuildGamePositionsParams, len(batch.positions))
+	for i, pos := range batch.positions {
+		positions[i] = db.CopyRebuildGamePositionsParams(pos)
+	}
+	if _, err := q.CopyRebuildGamePositions(ctx, positions); err != nil {
+		return err
+	}
+	if err := q.ApplyRebuildPositionStatsDeltas(ctx, db.ApplyRebuildPositionStatsDeltasParams(batch.stats())); err != nil {
+		return err
+	}
+	return q.ApplyRebuildMoveEdgeDeltas(ctx, db.ApplyRebuildMoveEdgeDeltasParams(batch.edges()))
+}
+
+// storedGame rebuilds the chess.com game a row was saved from, as far as
+// the games table keeps it.
+func storedGame(row db.ListRebuildGamesRow) *types.Game {
+	game := &types.Game{
+		UUID:        uuid.UUID(row.ID.Bytes).String(),
+		URL:         row.Link,
+		PGN:         row.Pgn,
+		TimeClass:   row.TimeClass,
+		TimeControl: row.TimeControl.String,
+		EndTime:     row.PlayedAt.Time.Unix(),
+		White:       types.Player{Username: row.WhiteUsername, Rating: int(row.WhiteElo.Int32)},
+		Black:       types.Player{Username: row.BlackUsername, Rating: int(row.BlackElo.Int32)},
+		ECO:         row.Eco.String,
+	}
+	if row.WhiteAccuracy.Valid || row.BlackAccuracy.Valid {
+		game.Accuracies = &types.Accuracies{White: row.WhiteAccuracy.Float64, Black: row.BlackAccuracy.Float64}
+	}
+	return game
+}
@@ -0,0 +1,220 @@
+package store
+
+import (
+	"context"
+
+	"chess/ProcessPipline"
+	"chess/Types"
+	"chess/internal/db"
+	"chess/internal/sqlitedb"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// searchBatch is how many games a search reads the positions of at once.
+	searchBatch = 200
+	// searchMaxScan bounds the games one page of a search looks at, a rare
+	// pattern would otherwise read every position of the user.
+	searchMaxScan = 5000
+	searchSort    = "search"
+)
+
+// MatchFunc tells whether the position fen, reached after ply half moves of a
+// game where the user played color, is one a search looks for.
+type MatchFunc func(fen string, ply int, color string) bool
+
+// Searcher is implemented by the stores that keep the positions of every
+// game, see game_positions.
+type Searcher interface {
+	// SearchPositions returns a page of the games of the user, newest first,
+	// with a position match accepts, ErrInvalidCursor when q.Cursor was not
+	// made by a search.
+	SearchPositions(ctx context.Context, q types.SearchQuery, match MatchFunc) (*types.SearchPage, error)
+}
+
+func (c Chain) SearchPositions(ctx context.Context, q types.SearchQuery, match MatchFunc) (*types.SearchPage, error) {
+	for _, s := range c {
+		if searcher, ok := s.(Searcher); ok {
+			return searcher.SearchPositions(ctx, q, match)
+		}
+	}
+	return nil, ErrNoUserData
+}
+
+// searchGame is a game a search looks at, plies holding its positions in
+// order.
+type searchGame struct {
+	id, link string
+	result   gameResult
+	plies    []searchPly
+}
+
+type searchPly struct {
+	number int
+	fen    string
+}
+
+// searchGames reads up to n games after cursor, with their positions, for a
+// search.
+type searchGames func(ctx context.Context, after *gamesCursor, n int) ([]searchGame, error)
+
+// searchPositions runs match over the positions of the games read by next,
+// a batch at a time, until a page is full or searchMaxScan games are read.
+func searchPositions(ctx context.Context, q types.SearchQuery, match MatchFunc, next searchGames) (*types.SearchPage, error) {
+	cursor, err := parseCursor(types.GamesQuery{Sort: searchSort, Cursor: q.Cursor})
+	if err != nil {
+		return nil, err
+	}
+	page := &types.SearchPage{Games: []types.SearchMatch{}, MaxPly: Processpipline.MaxPlies}
+	for page.Scanned < searchMaxScan {
+		n := min(searchBatch, searchMaxScan-page.Scanned)
+		games, err := next(ctx, cursor, n)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range games {
+			page.Scanned++
+			cursor = &gamesCursor{sort: searchSort, playedAt: g.result.playedAt, id: g.id}
+			var found types.SearchMatch
+			for _, ply := range g.plies {
+				if !match(ply.fen, ply.number, g.result.color()) {
+					continue
+				}
+				if found.Plies == nil {
+					found.PositionGame = positionGame(g.id, g.link, "", ply.number, g.result)
+					found.Fen = ply.fen
+				}
+				found.Plies = append(found.Plies, ply.number)
+			}
+			if found.Plies == nil {
+				continue
+			}
+			page.Games = append(page.Games, found)
+			if len(page.Games) == q.Limit {
+				page.NextCursor = cursor.String()
+				return page, nil
+			}
+		}
+		if len(games) < n {
+			return page, nil
+		}
+	}
+	page.NextCursor = cursor.String()
+	return page, nil
+}
+
+func (p *Postgres) SearchPositions(ctx context.Context, q types.SearchQuery, match MatchFunc) (*types.SearchPage, error) {
+	userID, err := p.userID(ctx, q.Username)
+	if err != nil {
+		return nil, err
+	}
+	f := newPgFilters(q.PositionQuery)
+	queries := db.New(p.pool)
+	return searchPositions(ctx, q, match, func(ctx context.Context, after *gamesCursor, n int) ([]searchGame, error) {
+		params := db.ListSearchGamesParams{
+			UserID:      userID,
+			TimeClass:   f.timeClass,
+			Color:       f.color,
+			PlayedFrom:  f.from,
+			PlayedTo:    f.to,
+			Rated:       f.rated,
+			TimeControl: f.timeControl,
+			Opponent:    f.opponent,
+			OpponentMin: f.opponentMin,
+			OpponentMax: f.opponentMax,
+			RatingMin:   f.ratingMin,
+			RatingMax:   f.ratingMax,
+			MaxGames:    int32(n),
+		}
+		if after != nil {
+			afterID, err := uuid.Parse(after.id)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			params.AfterPlayedAt = pgtype.Timestamptz{Time: after.playedAt, Valid: true}
+			params.AfterID = pgtype.UUID{Bytes: afterID, Valid: true}
+		}
+		rows, err := queries.ListSearchGames(ctx, params)
+		if err != nil || len(rows) == 0 {
+			return nil, err
+		}
+
+		games := make([]searchGame, len(rows))
+		ids := make([]pgtype.UUID, len(rows))
+		index := make(map[string]int, len(rows))
+		for i, r := range rows {
+			id := uuid.UUID(r.ID.Bytes).String()
+			games[i] = searchGame{id: id, link: r.Link, result: gameResult{
+				r.WhiteUsername, r.BlackUsername, int(r.WhiteElo.Int32), int(r.BlackElo.Int32),
+				r.Result, r.TimeClass, r.PlayedAt.Time.UTC(), r.UserIsWhite,
+			}}
+			ids[i] = r.ID
+			index[id] = i
+		}
+		positions, err := queries.ListGamePositions(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range positions {
+			g := &games[index[uuid.UUID(r.GameID.Bytes).String()]]
+			g.plies = append(g.plies, searchPly{int(r.MoveNumber), r.Fen})
+		}
+		return games, nil
+	})
+}
+
+func (s *SQLite) SearchPositions(ctx context.Context, q types.SearchQuery, match MatchFunc) (*types.SearchPage, error) {
+	userID, err := s.userID(ctx, q.Username)
+	if err != nil {
+		return nil, err
+	}
+	f := newSQLiteFilters(q.PositionQuery)
+	queries := sqlitedb.New(s.db)
+	return searchPositions(ctx, q, match, func(ctx context.Context, after *gamesCursor, n int) ([]searchGame, error) {
+		params := sqlitedb.ListSearchGamesParams{
+			UserID:      userID,
+			TimeClass:   f.timeClass,
+			Color:       f.color,
+			PlayedFrom:  f.from,
+			PlayedTo:    f.to,
+			Rated:       f.rated,
+			TimeControl: f.timeControl,
+			Opponent:    f.opponent,
+			OpponentMin: f.opponentMin,
+			OpponentMax: f.opponentMax,
+			RatingMin:   f.ratingMin,
+			RatingMax:   f.ratingMax,
+			MaxGames:    int64(n),
+		}
+		if after != nil {
+			params.AfterPlayedAt = sqliteTime(after.playedAt)
+			params.AfterID = after.id
+		}
+		rows, err := queries.ListSearchGames(ctx, params)
+		if err != nil || len(rows) == 0 {
+			return nil, err
+		}
+
+		games := make([]searchGame, len(rows))
+		ids := make([]string, len(rows))
+		index := make(map[string]int, len(rows))
+		for i, r := range rows {
+			games[i] = searchGame{id: r.ID, link: r.Link, result: gameResult{
+				r.WhiteUsername, r.BlackUsername, int(r.WhiteElo.Int64), int(r.BlackElo.Int64),
+				r.Result, r.TimeClass, parseSQLiteTime(r.PlayedAt), r.UserIsWhite == 1,
+			}}
+			ids[i] = r.ID
+			index[r.ID] = i
+		}
+		positions, err := queries.ListGamePositions(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range positions {
+			g := &games[index[r.GameID]]
+			g.plies = append(g.plies, searchPly{int(r.MoveNumber), r.Fen})
+		}
+		return games, nil
+	})
+}
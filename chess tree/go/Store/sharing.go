@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"chess/internal/db"
+	"chess/internal/sqlitedb"
+	"github.com/jackc/pgx/v5"
+)
+
+// Sharing is implemented by the stores that keep whether a user lets
+// everyone read their tree.
+type Sharing interface {
+	// Shared is false for a user the store does not know.
+	Shared(ctx context.Context, username string) (bool, error)
+	SetShared(ctx context.Context, username string, shared bool) error
+}
+
+func (c Chain) sharing() Sharing {
+	for _, s := range c {
+		if sh, ok := s.(Sharing); ok {
+			return sh
+		}
+	}
+	return nil
+}
+
+func (c Chain) Shared(ctx context.Context, username string) (bool, error) {
+	if sh := c.sharing(); sh != nil {
+		return sh.Shared(ctx, username)
+	}
+	return false, nil
+}
+
+func (c Chain) SetShared(ctx context.Context, username string, shared bool) error {
+	if sh := c.sharing(); sh != nil {
+		return sh.SetShared(ctx, username, shared)
+	}
+	return ErrNoUserData
+}
+
+func (p *Postgres) Shared(ctx context.Context, username string) (bool, error) {
+	user, err := db.New(p.pool).GetUserByUsername(ctx, strings.ToLower(username))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return user.Shared, nil
+}
+
+func (p *Postgres) SetShared(ctx context.Context, username string, shared bool) error {
+	updated, err := db.New(p.pool).SetUserShared(ctx, db.SetUserSharedParams{
+		ChessComUsername: strings.ToLower(username),
+		Shared:           shared,
+	})
+	if err == nil && updated == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *SQLite) Shared(ctx context.Context, username string) (bool, error) {
+	user, err := sqlitedb.New(s.db).GetUserByUsername(ctx, strings.ToLower(username))
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return user.Shared == 1, nil
+}
+
+func (s *SQLite) SetShared(ctx context.Context, username string, shared bool) error {
+	updated, err := sqlitedb.New(s.db).SetUserShared(ctx, sqlitedb.SetUserSharedParams{
+		ChessComUsername: strings.ToLower(username),
+		Shared:           sqliteBool(shared).Int64,
+	})
+	if err == nil && updated == 0 {
+		return ErrNotFound
+	}
+	return err
+}
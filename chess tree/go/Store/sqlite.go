@@ -0,0 +1,330 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"chess/Migrations"
+	"chess/ProcessPipline"
+	"chess/Types"
+	"chess/internal/sqlitedb"
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+)
+
+// SQLite is the Postgres store over a local database file, for running the
+// explorer as a single binary. It has the same tables and answers the same
+// queries, UUIDs and timestamps being stored as text.
+type SQLite struct {
+	db *sql.DB
+}
+
+// NewSQLite opens the database file, creating it if needed, and applies the
+// pending SQLite migrations.
+func NewSQLite(ctx context.Context, path string) (*SQLite, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// a single connection serializes the writers, SQLite would make them
+	// wait on each other anyway
+	conn.SetMaxOpenConns(1)
+
+	if _, err := migrations.UpSQLite(ctx, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &SQLite{db: conn}, nil
+}
+
+func (s *SQLite) Close() {
+	s.db.Close()
+}
+
+// SaveGame writes the game, its positions, their stats and its moves in one
+// transaction, leaving a game already stored alone like Postgres.SaveGame.
+func (s *SQLite) SaveGame(ctx context.Context, rec *types.GameRecord) error {
+	if err := validate(rec); err != nil {
+		return err
+	}
+	gameID, err := uuid.Parse(rec.ID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := sqlitedb.New(tx)
+
+	userID, err := q.UpsertUser(ctx, sqlitedb.UpsertUserParams{
+		ID:               uuid.NewString(),
+		ChessComUsername: strings.ToLower(rec.Username),
+	})
+	if err != nil {
+		return err
+	}
+
+	playedAt := sqliteTime(rec.PlayedAt)
+	inserted, err := q.InsertGame(ctx, sqlitedb.InsertGameParams{
+		ID:            gameID.String(),
+		UserID:        userID,
+		Link:          rec.Link,
+		WhiteUsername: rec.White,
+		BlackUsername: rec.Black,
+		WhiteElo:      sql.NullInt64{Int64: int64(rec.WhiteElo), Valid: rec.WhiteElo > 0},
+		BlackElo:      sql.NullInt64{Int64: int64(rec.BlackElo), Valid: rec.BlackElo > 0},
+		Result:        rec.Result,
+		TimeClass:     rec.TimeClass,
+		TimeControl:   sql.NullString{String: rec.TimeControl, Valid: rec.TimeControl != ""},
+		Pgn:           rec.PGN,
+		PlayedAt:      playedAt,
+		Eco:           sql.NullString{String: rec.ECO, Valid: rec.ECO != ""},
+		Termination:   sql.NullString{String: rec.Termination.String(), Valid: true},
+		WhiteAccuracy: sqliteFloat(rec.WhiteAccuracy),
+		BlackAccuracy: sqliteFloat(rec.BlackAccuracy),
+		Rated:         sqliteBool(rec.Rated),
+	})
+	if err != nil {
+		return err
+	}
+	if inserted == 0 {
+		return tx.Commit()
+	}
+
+	win, loss, draw := outcomeCounts(rec.Outcome)
+	positions := append([]types.Ply{{Fen: Processpipline.StartFEN}}, rec.Plies...)
+	for _, ply := range positions {
+		err := q.UpsertPositionStats(ctx, sqlitedb.UpsertPositionStatsParams{
+			Fen:            ply.Fen,
+			UserID:         userID,
+			Color:          rec.Color,
+			TimeClass:      rec.TimeClass,
+			WinCount:       int64(win),
+			LossCount:      int64(loss),
+			DrawCount:      int64(draw),
+			LatestGameID:   sql.NullString{String: gameID.String(), Valid: true},
+			LatestPlayedAt: sql.NullString{String: playedAt, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		err = q.InsertGamePosition(ctx, sqlitedb.InsertGamePositionParams{
+			GameID:     gameID.String(),
+			Fen:        ply.Fen,
+			MoveNumber: int64(ply.Number),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, e := range gameEdges(rec) {
+		err := q.UpsertMoveEdge(ctx, sqlitedb.UpsertMoveEdgeParams{
+			UserID:    userID,
+			Color:     rec.Color,
+			TimeClass: rec.TimeClass,
+			ParentKey: e.parent,
+			MoveSan:   e.move,
+			ChildKey:  e.child,
+			ChildFen:  e.childFen,
+			WinCount:  int64(win),
+			LossCount: int64(loss),
+			DrawCount: int64(draw),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) Position(ctx context.Context, q types.PositionQuery) (*types.PositionStats, error) {
+	if !q.Filters.IsZero() {
+		return s.filteredPosition(ctx, q)
+	}
+	userID, err := s.userID(ctx, q.Username)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := sqlitedb.New(s.db).GetPositionStats(ctx, sqlitedb.GetPositionStatsParams{
+		UserID:      userID,
+		PositionKey: sql.NullString{String: Processpipline.PositionKey(q.Fen), Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &types.PositionStats{Fen: q.Fen, TimeClasses: make(map[string]int)}
+	for _, row := range rows {
+		if !matches(q, row.Color, row.TimeClass) {
+			continue
+		}
+		stats.Games += int(row.GameCount)
+		stats.Wins += int(row.WinCount)
+		stats.Losses += int(row.LossCount)
+		stats.Draws += int(row.DrawCount)
+		stats.TimeClasses[row.TimeClass] += int(row.GameCount)
+	}
+	if stats.Games == 0 {
+		return nil, ErrNotFound
+	}
+	return stats, nil
+}
+
+func (s *SQLite) NextMoves(ctx context.Context, q types.PositionQuery) ([]types.MoveStats, error) {
+	if !q.Filters.IsZero() {
+		return s.filteredMoves(ctx, q)
+	}
+	edges, err := s.Children(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	moves := make([]types.MoveStats, 0, len(edges))
+	for _, e := range edges {
+		moves = append(moves, types.MoveStats{
+			Move:        e.Move,
+			Fen:         e.ChildFen,
+			Games:       e.Games,
+			Wins:        e.Wins,
+			Losses:      e.Losses,
+			Draws:       e.Draws,
+			TimeClasses: e.TimeClasses,
+		})
+	}
+	sortMoves(moves)
+	return moves, nil
+}
+
+// Children returns the moves played from the position of q.
+func (s *SQLite) Children(ctx context.Context, q types.PositionQuery) ([]types.Edge, error) {
+	userID, err := s.userID(ctx, q.Username)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := sqlitedb.New(s.db).GetChildEdges(ctx, sqlitedb.GetChildEdgesParams{
+		UserID:    userID,
+		ParentKey: Processpipline.PositionKey(q.Fen),
+	})
+	if err != nil {
+		return nil, err
+	}
+	edges := make([]edgeRow, len(rows))
+	for i, r := range rows {
+		edges[i] = edgeRow{r.Color, r.TimeClass, r.ParentKey, r.MoveSan, r.ChildKey, r.ChildFen,
+			int32(r.WinCount), int32(r.LossCount), int32(r.DrawCount), int32(r.GameCount), 0}
+	}
+	return mergeEdges(q, edges), nil
+}
+
+// Parents returns every move leading into the position of q.
+func (s *SQLite) Parents(ctx context.Context, q types.PositionQuery) ([]types.Edge, error) {
+	userID, err := s.userID(ctx, q.Username)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := sqlitedb.New(s.db).GetParentEdges(ctx, sqlitedb.GetParentEdgesParams{
+		UserID:   userID,
+		ChildKey: Processpipline.PositionKey(q.Fen),
+	})
+	if err != nil {
+		return nil, err
+	}
+	edges := make([]edgeRow, len(rows))
+	for i, r := range rows {
+		edges[i] = edgeRow{r.Color, r.TimeClass, r.ParentKey, r.MoveSan, r.ChildKey, r.ChildFen,
+			int32(r.WinCount), int32(r.LossCount), int32(r.DrawCount), int32(r.GameCount), 0}
+	}
+	return mergeEdges(q, edges), nil
+}
+
+// subtreeEdges is GetSubtreeEdges of queries/query.sql, written by hand as
+// sqlc cannot parse it for SQLite.
+const subtreeEdges = `
+WITH RECURSIVE walk AS (
+    SELECT e.color, e.time_class, e.parent_key, e.move_san, e.child_key,
+           e.child_fen, e.win_count, e.loss_count, e.draw_count, e.game_count,
+           1 AS depth
+    FROM move_edges e
+    WHERE e.user_id = ?1 AND e.parent_key = ?2
+    UNION ALL
+    SELECT e.color, e.time_class, e.parent_key, e.move_san, e.child_key,
+           e.child_fen, e.win_count, e.loss_count, e.draw_count, e.game_count,
+           w.depth + 1
+    FROM walk w
+    JOIN move_edges e
+      ON e.user_id = ?1
+     AND e.color = w.color
+     AND e.time_class = w.time_class
+     AND e.parent_key = w.child_key
+    WHERE w.depth < ?3
+)
+SELECT color, time_class, parent_key, move_san, child_key, child_fen,
+       win_count, loss_count, draw_count, game_count, MIN(depth) AS depth
+FROM walk
+GROUP BY color, time_class, parent_key, move_san, child_key, child_fen,
+         win_count, loss_count, draw_count, game_count
+ORDER BY depth, game_count DESC`
+
+// Subtree returns the edges below the position of q down to depth plies.
+func (s *SQLite) Subtree(ctx context.Context, q types.PositionQuery, depth int) ([]types.Edge, error) {
+	userID, err := s.userID(ctx, q.Username)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, subtreeEdges, userID, Processpipline.PositionKey(q.Fen), max(depth, 1))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var edges []edgeRow
+	for rows.Next() {
+		var e edgeRow
+		err := rows.Scan(&e.color, &e.timeClass, &e.parent, &e.move, &e.child, &e.childFen,
+			&e.win, &e.loss, &e.draw, &e.games, &e.depth)
+		if err != nil {
+			return nil, err
+		}
+		edges = append(edges, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return mergeEdges(q, edges), nil
+}
+
+func (s *SQLite) userID(ctx context.Context, username string) (string, error) {
+	user, err := sqlitedb.New(s.db).GetUserByUsername(ctx, strings.ToLower(username))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return user.ID, nil
+}
+
+// sqliteTime formats a timestamp so that comparing the text compares the time.
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000Z")
+}
+
+func sqliteBool(v bool) sql.NullInt64 {
+	if v {
+		return sql.NullInt64{Int64: 1, Valid: true}
+	}
+	return sql.NullInt64{Int64: 0, Valid: true}
+}
+
+func sqliteFloat(v *float64) sql.NullFloat64 {
+	if v == nil || *v <= 0 {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: *v, Valid: true}
+}
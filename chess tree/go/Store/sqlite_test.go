@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"chess/ProcessPipline"
+	"chess/Types"
+)
+
+func newTestSQLite(t *testing.T) *SQLite {
+	t.Helper()
+	lite, err := NewSQLite(context.Background(), filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(lite.Close)
+	return lite
+}
+
+// TestSQLiteGameOfTwoUsers saves a game the two synced players played each
+// other, each keeps it from their side.
+func TestSQLiteGameOfTwoUsers(t *testing.T) {
+	ctx := context.Background()
+	lite := newTestSQLite(t)
+	const (
+		chessComID = "3b4c6a2e-f203-11f0-abbe-32189c01000f"
+		afterE4    = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"
+	)
+	game := func(username, color, outcome string) *types.GameRecord {
+		return &types.GameRecord{
+			ID:        Processpipline.GameID(chessComID, username),
+			Username:  username,
+			Color:     color,
+			Outcome:   outcome,
+			Result:    "1-0",
+			TimeClass: "blitz",
+			White:     "alice",
+			Black:     "bob",
+			Link:      "https://www.chess.com/game/live/1",
+			PGN:       "*",
+			PlayedAt:  time.Unix(1_700_000_000, 0).UTC(),
+			Plies:     []types.Ply{{Number: 1, San: "e4", Parent: Processpipline.StartFEN, Fen: afterE4}},
+		}
+	}
+	for _, rec := range []*types.GameRecord{
+		game("alice", "white", "win"),
+		game("bob", "black", "loss"),
+		game("alice", "white", "win"), // synced again
+	} {
+		if err := lite.SaveGame(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct{ username, color string }{{"alice", "white"}, {"bob", "black"}} {
+		stats, err := lite.Position(ctx, types.PositionQuery{Username: tt.username, Color: tt.color, Fen: afterE4})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.username, err)
+		}
+		if stats.Games != 1 {
+			t.Errorf("%s has the game %d times, want once", tt.username, stats.Games)
+		}
+	}
+}
+
+func TestSQLiteLastArchive(t *testing.T) {
+	ctx := context.Background()
+	lite := newTestSQLite(t)
+	last, err := lite.LastArchive(ctx, "Alice")
+	if err != nil || last != "" {
+		t.Fatalf("a user never synced has %q, %v", last, err)
+	}
+	const archive = "https://api.chess.com/pub/player/alice/games/2026/01"
+	if err := lite.SetLastArchive(ctx, "Alice", archive); err != nil {
+		t.Fatal(err)
+	}
+	if last, err := lite.LastArchive(ctx, "alice"); err != nil || last != archive {
+		t.Errorf("got %q, %v, want %q", last, err, archive)
+	}
+}
+
+// TestSQLiteAccounts links the account id of a socket service token to a
+// chess.com user, once for each.
+func TestSQLiteAccounts(t *testing.T) {
+	ctx := context.Background()
+	lite := newTestSQLite(t)
+	const account = "6f1d2a8e-3c4b-4e5f-8a9b-0c1d2e3f4a5b"
+	if _, err := lite.AccountUser(ctx, account); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unlinked account: err = %v, want ErrNotFound", err)
+	}
+	// a user synced before the account links to them
+	if err := lite.SetLastArchive(ctx, "alice", "2026/01"); err != nil {
+		t.Fatal(err)
+	}
+	if err := lite.LinkAccount(ctx, account, "Alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := lite.LinkAccount(ctx, account, "alice"); err != nil {
+		t.Errorf("linking the same user again: %v", err)
+	}
+	if username, err := lite.AccountUser(ctx, account); err != nil || username != "alice" {
+		t.Errorf("got %q, %v, want alice", username, err)
+	}
+	if err := lite.LinkAccount(ctx, account, "bob"); !errors.Is(err, ErrAccountLinked) {
+		t.Errorf("linking another user: err = %v, want ErrAccountLinked", err)
+	}
+	if err := lite.LinkAccount(ctx, "another-account", "alice"); !errors.Is(err, ErrUserTaken) {
+		t.Errorf("linking alice to another account: err = %v, want ErrUserTaken", err)
+	}
+}
+
+func TestSearchReportsMaxPly(t *testing.T) {
+	ctx := context.Background()
+	lite := newTestSQLite(t)
+	const afterD4 = "rnbqkbnr/pppppppp/8/8/3P4/8/PPP1PPPP/RNBQKBNR b KQkq d3 0 1"
+	err := lite.SaveGame(ctx, &types.GameRecord{
+		ID:        "0b7c3c4e-5d1a-4f0e-9a43-5b8f6f0c2a11",
+		Username:  "alice",
+		Color:     "white",
+		Outcome:   "draw",
+		Result:    "1/2-1/2",
+		TimeClass: "rapid",
+		White:     "alice",
+		Black:     "bob",
+		Link:      "https://www.chess.com/game/live/2",
+		PGN:       "*",
+		PlayedAt:  time.Unix(1_700_000_000, 0).UTC(),
+		Plies:     []types.Ply{{Number: 1, San: "d4", Parent: Processpipline.StartFEN, Fen: afterD4}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	match := func(fen string, ply int, color string) bool { return ply == 1 }
+	page, err := lite.SearchPositions(ctx, types.SearchQuery{
+		PositionQuery: types.PositionQuery{Username: "alice"},
+		Limit:         10,
+	}, match)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Games) != 1 || page.MaxPly != Processpipline.MaxPlies {
+		t.Errorf("page = %+v, want the game and MaxPly %d", page, Processpipline.MaxPlies)
+	}
+}
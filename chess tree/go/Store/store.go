@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+
+	"chess/Types"
+)
+
+// PositionStore persists the games coming out of the pipeline and answers the
+// explorer queries.
+type PositionStore interface {
+	SaveGame(ctx context.Context, rec *types.GameRecord) error
+	Position(ctx context.Context, q types.PositionQuery) (*types.PositionStats, error)
+	NextMoves(ctx context.Context, q types.PositionQuery) ([]types.MoveStats, error)
+}
+
+// Chain saves every game to all of its stores and reads from the first one.
+// The server keeps the in-memory tree filled this way while Postgres is the
+// store it answers from.
+type Chain []PositionStore
+
+func (c Chain) SaveGame(ctx context.Context, rec *types.GameRecord) error {
+	for _, s := range c {
+		if err := s.SaveGame(ctx, rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush flushes the stores of the chain that buffer games.
+func (c Chain) Flush(ctx context.Context) error {
+	for _, s := range c {
+		if f, ok := s.(Flusher); ok {
+			if err := f.Flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Batch returns the chain with a buffer of its own for the stores that
+// buffer games, see Batcher.
+func (c Chain) Batch() PositionStore {
+	batched := make(Chain, len(c))
+	for i, s := range c {
+		batched[i] = Batch(s)
+	}
+	return batched
+}
+
+func (c Chain) Position(ctx context.Context, q types.PositionQuery) (*types.PositionStats, error) {
+	return c[0].Position(ctx, q)
+}
+
+func (c Chain) NextMoves(ctx context.Context, q types.PositionQuery) ([]types.MoveStats, error) {
+	return c[0].NextMoves(ctx, q)
+}
+
+func outcomeCounts(outcome string) (win, loss, draw int) {
+	switch outcome {
+	case "win":
+		return 1, 0, 0
+	case "loss":
+		return 0, 1, 0
+	case "draw":
+		return 0, 0, 1
+	}
+	return 0, 0, 0
+}
@@ -0,0 +1,245 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"chess/ProcessPipline"
+	"chess/Types"
+	"chess/internal/db"
+	"chess/internal/sqlitedb"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// treeBatch is how many games Tree replays at once.
+const treeBatch = 500
+
+// Trees is implemented by the stores that keep the games, the diff of two
+// periods or two users replays them into a tree of their own.
+type Trees interface {
+	// Tree replays the games of q.Username kept by the filters of q, the
+	// position being ignored, into a tree shaped like the HashMap. Games
+	// that fail to replay are skipped.
+	Tree(ctx context.Context, q types.PositionQuery, replay Replayer) (map[string]*types.PositonInfo, error)
+}
+
+func (c Chain) Tree(ctx context.Context, q types.PositionQuery, replay Replayer) (map[string]*types.PositonInfo, error) {
+	for _, s := range c {
+		if trees, ok := s.(Trees); ok {
+			return trees.Tree(ctx, q, replay)
+		}
+	}
+	return nil, ErrNoUserData
+}
+
+// treeGames reads up to treeBatch games after cursor, oldest first, with the
+// user they were stored for and the cursor of the last one.
+type treeGames func(ctx context.Context, after *gamesCursor) ([]*types.Game, string, *gamesCursor, error)
+
+// replayTree folds the games read by next, a batch at a time, into a tree.
+func replayTree(ctx context.Context, replay Replayer, next treeGames) (map[string]*types.PositonInfo, error) {
+	tree := make(map[string]*types.PositonInfo)
+	var after *gamesCursor
+	for {
+		games, username, last, err := next(ctx, after)
+		if err != nil {
+			return nil, err
+		}
+		for _, game := range games {
+			rec, err := replay(game, username)
+			if err != nil {
+				fmt.Println("tree: skipping game", game.URL, err)
+				continue
+			}
+			rec.ID = game.UUID
+			Processpipline.ApplyTo(tree, rec)
+		}
+		if len(games) < treeBatch {
+			return tree, nil
+		}
+		after = last
+	}
+}
+
+func (p *Postgres) Tree(ctx context.Context, q types.PositionQuery, replay Replayer) (map[string]*types.PositonInfo, error) {
+	userID, err := p.userID(ctx, q.Username)
+	if err != nil {
+		return nil, err
+	}
+	f := newPgFilters(q)
+	queries := db.New(p.pool)
+	return replayTree(ctx, replay, func(ctx context.Context, after *gamesCursor) ([]*types.Game, string, *gamesCursor, error) {
+		params := db.ListTreeGamesParams{
+			UserID:      userID,
+			TimeClass:   f.timeClass,
+			Color:       f.color,
+			PlayedFrom:  f.from,
+			PlayedTo:    f.to,
+			Rated:       f.rated,
+			TimeControl: f.timeControl,
+			Opponent:    f.opponent,
+			OpponentMin: f.opponentMin,
+			OpponentMax: f.opponentMax,
+			RatingMin:   f.ratingMin,
+			RatingMax:   f.ratingMax,
+			MaxGames:    treeBatch,
+		}
+		if after != nil {
+			afterID, err := uuid.Parse(after.id)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			params.AfterPlayedAt = pgtype.Timestamptz{Time: after.playedAt, Valid: true}
+			params.AfterID = pgtype.UUID{Bytes: afterID, Valid: true}
+		}
+		rows, err := queries.ListTreeGames(ctx, params)
+		if err != nil || len(rows) == 0 {
+			return nil, "", nil, err
+		}
+		games := make([]*types.Game, len(rows))
+		for i, row := range rows {
+			games[i] = storedGame(db.ListRebuildGamesRow{
+				ID:            row.ID,
+				Link:          row.Link,
+				WhiteUsername: row.WhiteUsername,
+				BlackUsername: row.BlackUsername,
+				WhiteElo:      row.WhiteElo,
+				BlackElo:      row.BlackElo,
+				Result:        row.Result,
+				TimeClass:     row.TimeClass,
+				TimeControl:   row.TimeControl,
+				Pgn:           row.Pgn,
+				PlayedAt:      row.PlayedAt,
+				Eco:           row.Eco,
+				WhiteAccuracy: row.WhiteAccuracy,
+				BlackAccuracy: row.BlackAccuracy,
+			})
+		}
+		last := rows[len(rows)-1]
+		return games, last.ChessComUsername, &gamesCursor{playedAt: last.PlayedAt.Time, id: games[len(games)-1].UUID}, nil
+	})
+}
+
+func (s *SQLite) Tree(ctx context.Context, q types.PositionQuery, replay Replayer) (map[string]*types.PositonInfo, error) {
+	userID, err := s.userID(ctx, q.Username)
+	if err != nil {
+		return nil, err
+	}
+	f := newSQLiteFilters(q)
+	queries := sqlitedb.New(s.db)
+	return replayTree(ctx, replay, func(ctx context.Context, after *gamesCursor) ([]*types.Game, string, *gamesCursor, error) {
+		params := sqlitedb.ListTreeGamesParams{
+			UserID:      userID,
+			TimeClass:   f.timeClass,
+			Color:       f.color,
+			PlayedFrom:  f.from,
+			PlayedTo:    f.to,
+			Rated:       f.rated,
+			TimeControl: f.timeControl,
+			Opponent:    f.opponent,
+			OpponentMin: f.opponentMin,
+			OpponentMax: f.opponentMax,
+			RatingMin:   f.ratingMin,
+			RatingMax:   f.ratingMax,
+			MaxGames:    treeBatch,
+		}
+		if after != nil {
+			params.AfterPlayedAt = sqliteTime(after.playedAt)
+			params.AfterID = after.id
+		}
+		rows, err := queries.ListTreeGames(ctx, params)
+		if err != nil || len(rows) == 0 {
+			return nil, "", nil, err
+		}
+		games := make([]*types.Game, len(rows))
+		for i, row := range rows {
+			game := &types.Game{
+				UUID:        row.ID,
+				URL:         row.Link,
+				PGN:         row.Pgn,
+				TimeClass:   row.TimeClass,
+				TimeControl: row.TimeControl.String,
+				EndTime:     parseSQLiteTime(row.PlayedAt).Unix(),
+				White:       types.Player{Username: row.WhiteUsername, Rating: int(row.WhiteElo.Int64)},
+				Black:       types.Player{Username: row.BlackUsername, Rating: int(row.BlackElo.Int64)},
+				ECO:         row.Eco.String,
+			}
+			if row.WhiteAccuracy.Valid || row.BlackAccuracy.Valid {
+				game.Accuracies = &types.Accuracies{White: row.WhiteAccuracy.Float64, Black: row.BlackAccuracy.Float64}
+			}
+			games[i] = game
+		}
+		last := rows[len(rows)-1]
+		return games, last.ChessComUsername, &gamesCursor{playedAt: parseSQLiteTime(last.PlayedAt), id: last.ID}, nil
+	})
+}
+
+// Tree keeps the part of the HashMap reached by the games of q.Username that
+// q keeps, counted from the facts of the games saved since the start like
+// the filtered queries, so the games loaded from a snapshot, which belong to
+// no known user, are left out. The tree is already built, replay is not
+// used.
+func (m *Memory) Tree(ctx context.Context, q types.PositionQuery, replay Replayer) (map[string]*types.PositonInfo, error) {
+	tree := Processpipline.CopyHashMap()
+	username := strings.ToLower(q.Username)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	kept := make(map[string]map[string]bool, len(tree))
+	for fen, info := range tree {
+		ids := make(map[string]bool)
+		counted := &types.PositonInfo{Moves: info.Moves}
+		for id := range m.reached[fen] {
+			facts, known := m.games[id]
+			if !known || facts.Username != username || !q.Matches(facts) || ids[id] {
+				continue
+			}
+			ids[id] = true
+			win, loss, draw := outcomeCounts(facts.Outcome)
+			counted.Count++
+			counted.WinCount += win
+			counted.LossCount += loss
+			counted.DrawCount += draw
+			counted.GamesId = Processpipline.AddGameID(counted.GamesId, id)
+			Processpipline.UpdateTermination(counted, facts.Termination, win == 1, loss == 1, draw == 1)
+			if facts.Accuracy > 0 {
+				counted.Accuracy.Add(facts.Color, facts.Accuracy)
+			}
+		}
+		if counted.Count == 0 {
+			delete(tree, fen)
+			continue
+		}
+		tree[fen] = counted
+		kept[fen] = ids
+	}
+
+	// a game is through a move when it reached both its parent and its child
+	for fen, info := range tree {
+		moves := make(map[string]*types.MoveInfo)
+		for san, move := range info.Moves {
+			counted := &types.MoveInfo{Fen: move.Fen}
+			for id := range kept[move.Fen] {
+				if !kept[fen][id] {
+					continue
+				}
+				facts := m.games[id]
+				win, loss, draw := outcomeCounts(facts.Outcome)
+				counted.Count++
+				counted.WinCount += win
+				counted.LossCount += loss
+				counted.DrawCount += draw
+				if facts.Accuracy > 0 {
+					counted.Accuracy.Add(facts.Color, facts.Accuracy)
+				}
+			}
+			if counted.Count > 0 {
+				moves[san] = counted
+			}
+		}
+		info.Moves = moves
+	}
+	return tree, nil
+}
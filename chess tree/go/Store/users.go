@@ -0,0 +1,328 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"chess/Types"
+	"chess/internal/db"
+	"chess/internal/sqlitedb"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ErrNoUserData is returned by Chain when none of its stores keeps users, the
+// in-memory tree aggregates every game without knowing whose it was.
+var ErrNoUserData = errors.New("store: no store keeps per user data")
+
+// UserData is implemented by the stores that keep games per user.
+type UserData interface {
+	ExportUser(ctx context.Context, username string) (*types.UserExport, error)
+	// DeleteUser removes the user, their games and every row derived from
+	// them, and records who asked for it in audit_log.
+	DeleteUser(ctx context.Context, username, actor string) (*types.AuditRecord, error)
+}
+
+// Forgetter is implemented by the stores that keep games without knowing
+// whose they are, the games of a deleted user have to be handed to them,
+// replayed, to be taken out.
+type Forgetter interface {
+	Forget(ctx context.Context, recs []*types.GameRecord) int
+}
+
+func (c Chain) Forget(ctx context.Context, recs []*types.GameRecord) int {
+	forgotten := 0
+	for _, s := range c {
+		if f, ok := s.(Forgetter); ok {
+			forgotten += f.Forget(ctx, recs)
+		}
+	}
+	return forgotten
+}
+
+func (c Chain) ExportUser(ctx context.Context, username string) (*types.UserExport, error) {
+	for _, s := range c {
+		if u, ok := s.(UserData); ok {
+			return u.ExportUser(ctx, username)
+		}
+	}
+	return nil, ErrNoUserData
+}
+
+func (c Chain) DeleteUser(ctx context.Context, username, actor string) (*types.AuditRecord, error) {
+	for _, s := range c {
+		if u, ok := s.(UserData); ok {
+			return u.DeleteUser(ctx, username, actor)
+		}
+	}
+	return nil, ErrNoUserData
+}
+
+func (p *Postgres) ExportUser(ctx context.Context, username string) (*types.UserExport, error) {
+	userID, err := p.userID(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	// one snapshot so the stats match the games
+	tx, err := p.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	q := db.New(tx)
+
+	export := &types.UserExport{
+		Username:  strings.ToLower(username),
+		Games:     []types.ExportedGame{},
+		Positions: []types.ExportedPosition{},
+		Moves:     []types.ExportedMove{},
+	}
+	games, err := q.ListUserGames(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range games {
+		export.Games = append(export.Games, types.ExportedGame{
+			ID:            uuid.UUID(g.ID.Bytes).String(),
+			Link:          g.Link,
+			White:         g.WhiteUsername,
+			Black:         g.BlackUsername,
+			WhiteElo:      int(g.WhiteElo.Int32),
+			BlackElo:      int(g.BlackElo.Int32),
+			Result:        g.Result,
+			TimeClass:     g.TimeClass,
+			TimeControl:   g.TimeControl.String,
+			ECO:           g.Eco.String,
+			Termination:   g.Termination.String,
+			WhiteAccuracy: pgFloatPtr(g.WhiteAccuracy),
+			BlackAccuracy: pgFloatPtr(g.BlackAccuracy),
+			PlayedAt:      g.PlayedAt.Time.UTC(),
+			PGN:           g.Pgn,
+		})
+	}
+
+	positions, err := q.ListUserPositionStats(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range positions {
+		export.Positions = append(export.Positions, types.ExportedPosition{
+			Fen:       r.Fen,
+			Color:     r.Color,
+			TimeClass: r.TimeClass,
+			Games:     int(r.GameCount.Int32),
+			Wins:      int(r.WinCount.Int32),
+			Losses:    int(r.LossCount.Int32),
+			Draws:     int(r.DrawCount.Int32),
+		})
+	}
+
+	edges, err := q.ListUserMoveEdges(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range edges {
+		export.Moves = append(export.Moves, exportedMove(edgeRow{r.Color, r.TimeClass, r.ParentKey, r.MoveSan, r.ChildKey, r.ChildFen,
+			r.WinCount, r.LossCount, r.DrawCount, r.GameCount, 0}))
+	}
+	return export, nil
+}
+
+func (p *Postgres) DeleteUser(ctx context.Context, username, actor string) (*types.AuditRecord, error) {
+	userID, err := p.userID(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	q := db.New(tx)
+
+	games, err := q.CountUserGames(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.DeleteUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	record := deletionRecord(username, actor, int(games))
+	err = q.InsertAuditRecord(ctx, db.InsertAuditRecordParams{
+		Action:    record.Action,
+		Username:  record.Username,
+		Actor:     record.Actor,
+		GameCount: int32(record.Games),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	logAudit(record)
+	return record, nil
+}
+
+func (s *SQLite) ExportUser(ctx context.Context, username string) (*types.UserExport, error) {
+	userID, err := s.userID(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	q := sqlitedb.New(tx)
+
+	export := &types.UserExport{
+		Username:  strings.ToLower(username),
+		Games:     []types.ExportedGame{},
+		Positions: []types.ExportedPosition{},
+		Moves:     []types.ExportedMove{},
+	}
+	games, err := q.ListUserGames(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range games {
+		playedAt, err := time.Parse(time.RFC3339Nano, g.PlayedAt)
+		if err != nil {
+			return nil, fmt.Errorf("store: game %s: %w", g.ID, err)
+		}
+		export.Games = append(export.Games, types.ExportedGame{
+			ID:            g.ID,
+			Link:          g.Link,
+			White:         g.WhiteUsername,
+			Black:         g.BlackUsername,
+			WhiteElo:      int(g.WhiteElo.Int64),
+			BlackElo:      int(g.BlackElo.Int64),
+			Result:        g.Result,
+			TimeClass:     g.TimeClass,
+			TimeControl:   g.TimeControl.String,
+			ECO:           g.Eco.String,
+			Termination:   g.Termination.String,
+			WhiteAccuracy: sqliteFloatPtr(g.WhiteAccuracy),
+			BlackAccuracy: sqliteFloatPtr(g.BlackAccuracy),
+			PlayedAt:      playedAt,
+			PGN:           g.Pgn,
+		})
+	}
+
+	positions, err := q.ListUserPositionStats(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range positions {
+		export.Positions = append(export.Positions, types.ExportedPosition{
+			Fen:       r.Fen,
+			Color:     r.Color,
+			TimeClass: r.TimeClass,
+			Games:     int(r.GameCount),
+			Wins:      int(r.WinCount),
+			Losses:    int(r.LossCount),
+			Draws:     int(r.DrawCount),
+		})
+	}
+
+	edges, err := q.ListUserMoveEdges(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range edges {
+		export.Moves = append(export.Moves, exportedMove(edgeRow{r.Color, r.TimeClass, r.ParentKey, r.MoveSan, r.ChildKey, r.ChildFen,
+			int32(r.WinCount), int32(r.LossCount), int32(r.DrawCount), int32(r.GameCount), 0}))
+	}
+	return export, nil
+}
+
+func (s *SQLite) DeleteUser(ctx context.Context, username, actor string) (*types.AuditRecord, error) {
+	userID, err := s.userID(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	q := sqlitedb.New(tx)
+
+	games, err := q.CountUserGames(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.DeleteUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	record := deletionRecord(username, actor, int(games))
+	err = q.InsertAuditRecord(ctx, sqlitedb.InsertAuditRecordParams{
+		Action:    record.Action,
+		Username:  record.Username,
+		Actor:     record.Actor,
+		GameCount: int64(record.Games),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	logAudit(record)
+	return record, nil
+}
+
+func deletionRecord(username, actor string, games int) *types.AuditRecord {
+	return &types.AuditRecord{
+		Action:   "delete-user",
+		Username: strings.ToLower(username),
+		Actor:    actor,
+		Games:    games,
+		At:       time.Now().UTC(),
+	}
+}
+
+func logAudit(r *types.AuditRecord) {
+	fmt.Println("audit:", r.Action, r.Username, "by", r.Actor, "games:", r.Games)
+}
+
+func exportedMove(r edgeRow) types.ExportedMove {
+	return types.ExportedMove{
+		Color:     r.color,
+		TimeClass: r.timeClass,
+		Edge: types.Edge{
+			Parent:   r.parent,
+			Move:     r.move,
+			Child:    r.child,
+			ChildFen: r.childFen,
+			Games:    int(r.games),
+			Wins:     int(r.win),
+			Losses:   int(r.loss),
+			Draws:    int(r.draw),
+		},
+	}
+}
+
+func pgFloatPtr(v pgtype.Float8) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+func sqliteFloatPtr(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
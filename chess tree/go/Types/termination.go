@@ -0,0 +1,65 @@
+package types
+
+import "fmt"
+
+// Termination is how a game ended, normalized from the PGN Termination tag.
+type Termination int
+
+const (
+	TerminationUnknown Termination = iota
+	TerminationCheckmate
+	TerminationResignation
+	TerminationTime
+	TerminationAbandoned
+	TerminationAgreement
+	TerminationRepetition
+	TerminationStalemate
+	TerminationInsufficientMaterial
+	TerminationTimeoutVsInsufficient
+	TerminationFiftyMove
+)
+
+var terminationNames = [...]string{
+	TerminationUnknown:               "unknown",
+	TerminationCheckmate:             "checkmate",
+	TerminationResignation:           "resignation",
+	TerminationTime:                  "time",
+	TerminationAbandoned:             "abandoned",
+	TerminationAgreement:             "agreement",
+	TerminationRepetition:            "repetition",
+	TerminationStalemate:             "stalemate",
+	TerminationInsufficientMaterial:  "insufficient_material",
+	TerminationTimeoutVsInsufficient: "timeout_vs_insufficient",
+	TerminationFiftyMove:             "fifty_move",
+}
+
+func (t Termination) String() string {
+	if t < 0 || int(t) >= len(terminationNames) {
+		return terminationNames[TerminationUnknown]
+	}
+	return terminationNames[t]
+}
+
+// MarshalText lets terminations be used as JSON object keys.
+func (t Termination) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *Termination) UnmarshalText(text []byte) error {
+	parsed, err := ParseTerminationName(string(text))
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// ParseTerminationName is the inverse of String.
+func ParseTerminationName(name string) (Termination, error) {
+	for i, n := range terminationNames {
+		if n == name {
+			return Termination(i), nil
+		}
+	}
+	return TerminationUnknown, fmt.Errorf("unknown termination %q", name)
+}
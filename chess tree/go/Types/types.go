@@ -1,11 +1,99 @@
 package types
 
+import (
+	"strings"
+	"time"
+)
+
 type PositonInfo struct {
+	Count        int
+	DrawCount    int
+	WinCount     int
+	LossCount    int
+	GamesId      []string
+	Moves        map[string]*MoveInfo
+	Terminations map[Termination]*TerminationCount
+	Accuracy     AccuracyStat
+}
+
+// TerminationCount splits the results reached through a position by how the
+// game ended, from the user's point of view.
+type TerminationCount struct {
+	Win  int `json:"win"`
+	Loss int `json:"loss"`
+	Draw int `json:"draw"`
+}
+
+// MoveInfo is the edge from a position to the one reached by playing San.
+type MoveInfo struct {
+	Fen       string
 	Count     int
 	DrawCount int
 	WinCount  int
 	LossCount int
-	GamesId   []string
+	Accuracy  AccuracyStat
+}
+
+// AccuracyStat sums the user's chess.com accuracy split by the color they
+// played. Only analysed games count, so Games can be lower than the games
+// through a position.
+type AccuracyStat struct {
+	WhiteSum   float64 `json:"whiteSum"`
+	WhiteGames int     `json:"whiteGames"`
+	BlackSum   float64 `json:"blackSum"`
+	BlackGames int     `json:"blackGames"`
+}
+
+func (a *AccuracyStat) Add(color string, accuracy float64) {
+	if color == "black" {
+		a.BlackSum += accuracy
+		a.BlackGames++
+		return
+	}
+	a.WhiteSum += accuracy
+	a.WhiteGames++
+}
+
+// Remove takes back an accuracy Add counted.
+func (a *AccuracyStat) Remove(color string, accuracy float64) {
+	if color == "black" {
+		a.BlackSum -= accuracy
+		a.BlackGames--
+		return
+	}
+	a.WhiteSum -= accuracy
+	a.WhiteGames--
+}
+
+// Merge adds the games of other, counted apart, to a.
+func (a *AccuracyStat) Merge(other AccuracyStat) {
+	a.WhiteSum += other.WhiteSum
+	a.WhiteGames += other.WhiteGames
+	a.BlackSum += other.BlackSum
+	a.BlackGames += other.BlackGames
+}
+
+// Average is the mean accuracy for color, "" meaning both colors. It is 0
+// when no analysed game was played.
+func (a AccuracyStat) Average(color string) float64 {
+	sum, games := a.WhiteSum+a.BlackSum, a.WhiteGames+a.BlackGames
+	switch color {
+	case "white":
+		sum, games = a.WhiteSum, a.WhiteGames
+	case "black":
+		sum, games = a.BlackSum, a.BlackGames
+	}
+	if games == 0 {
+		return 0
+	}
+	return sum / float64(games)
+}
+
+// OpeningStat aggregates the games of one ECO code.
+type OpeningStat struct {
+	Name     string       `json:"name"`
+	Games    int          `json:"games"`
+	Accuracy AccuracyStat `json:"accuracy"`
 }
 
 type Pgn struct {
@@ -53,6 +141,16 @@ type IntermeObj struct {
 	} `json:"data"`
 }
 
+// ChessComArchives and ChessComMonth are the chess.com published-data
+// responses listing a player's monthly archives and the games of one.
+type ChessComArchives struct {
+	Archives []string `json:"archives"`
+}
+
+type ChessComMonth struct {
+	Games []Game `json:"games"`
+}
+
 type Game struct {
 	Accuracies   *Accuracies `json:"accuracies,omitempty"`
 	Black        Player      `json:"black"`
@@ -87,3 +185,390 @@ type Timeline struct {
 type UserGames struct {
 	Games []*Game
 }
+
+// The scores of a diff are null when no game behind them was decided, a
+// position of unfinished games would otherwise look like one always lost.
+
+type DiffNode struct {
+	Fen   string   `json:"fen"`
+	Games int      `json:"games"`
+	Score *float64 `json:"score"`
+}
+
+type DiffMove struct {
+	Fen   string   `json:"fen"`
+	Move  string   `json:"move"`
+	Games int      `json:"games"`
+	Score *float64 `json:"score"`
+}
+
+type SharedNode struct {
+	Fen        string   `json:"fen"`
+	GamesA     int      `json:"gamesA"`
+	GamesB     int      `json:"gamesB"`
+	ScoreA     *float64 `json:"scoreA"`
+	ScoreB     *float64 `json:"scoreB"`
+	ScoreDelta *float64 `json:"scoreDelta"`
+}
+
+type TreeDiff struct {
+	OnlyA      []DiffNode   `json:"onlyA"`
+	OnlyB      []DiffNode   `json:"onlyB"`
+	MovesOnlyA []DiffMove   `json:"movesOnlyA"`
+	MovesOnlyB []DiffMove   `json:"movesOnlyB"`
+	Shared     []SharedNode `json:"shared"`
+}
+
+type BookMove struct {
+	Move   string `json:"move"`
+	Weight uint16 `json:"weight"`
+}
+
+type BookAnnotation struct {
+	Fen        string     `json:"fen"`
+	Move       string     `json:"move"`
+	Games      int        `json:"games"`
+	Score      float64    `json:"score"`
+	InBook     bool       `json:"inBook"`
+	BookWeight uint16     `json:"bookWeight"`
+	BookMoves  []BookMove `json:"bookMoves"`
+}
+
+type TerminationLine struct {
+	Fen   string  `json:"fen"`
+	Games int     `json:"games"`
+	Count int     `json:"count"`
+	Rate  float64 `json:"rate"`
+}
+
+type OpeningAccuracy struct {
+	ECO      string  `json:"eco"`
+	Name     string  `json:"name"`
+	Games    int     `json:"games"`
+	Analysed int     `json:"analysed"`
+	Average  float64 `json:"average"`
+}
+
+// GameRecord is a game once the pipeline replayed it, with everything the
+// stores need to persist it.
+type GameRecord struct {
+	ID            string
+	Username      string
+	Color         string
+	Outcome       string
+	Result        string
+	Termination   Termination
+	TimeClass     string
+	TimeControl   string
+	Rated         bool
+	White         string
+	Black         string
+	WhiteElo      int
+	BlackElo      int
+	ECO           string
+	ECOUrl        string
+	Link          string
+	PGN           string
+	PlayedAt      time.Time
+	WhiteAccuracy *float64
+	BlackAccuracy *float64
+	Plies         []Ply
+}
+
+// Ply is one half move of a GameRecord, Number starting at 1.
+type Ply struct {
+	Number int
+	San    string
+	Parent string
+	Fen    string
+}
+
+type PositionQuery struct {
+	Username  string
+	Fen       string
+	Color     string
+	TimeClass string
+	Filters   Filters
+}
+
+// Filters narrow a query down to some of the user's games beyond the color
+// and time class the stats are precomputed for. The zero value keeps every
+// game and a zero bound is no bound.
+type Filters struct {
+	From        *time.Time // played at or after
+	To          *time.Time // played before
+	Rated       *bool
+	OpponentMin int
+	OpponentMax int
+	RatingMin   int
+	RatingMax   int
+	Opponent    string
+	TimeControl string
+}
+
+func (f Filters) IsZero() bool {
+	return f == Filters{}
+}
+
+// GameFacts are what the filters look at in a game, from the side of the
+// user it was saved for.
+type GameFacts struct {
+	Username       string
+	Color          string
+	TimeClass      string
+	TimeControl    string
+	Rated          bool
+	Opponent       string
+	OpponentRating int
+	PlayerRating   int
+	PlayedAt       time.Time
+	Outcome        string
+	Termination    Termination
+	// Accuracy is the user's chess.com accuracy, 0 when not analysed.
+	Accuracy float64
+}
+
+// Matches reports whether a game is kept by the color, time class and
+// filters of q. Rating bounds are inclusive.
+func (q PositionQuery) Matches(g GameFacts) bool {
+	f := q.Filters
+	switch {
+	case q.Color != "" && g.Color != q.Color,
+		q.TimeClass != "" && g.TimeClass != q.TimeClass,
+		f.From != nil && g.PlayedAt.Before(*f.From),
+		f.To != nil && !g.PlayedAt.Before(*f.To),
+		f.Rated != nil && g.Rated != *f.Rated,
+		f.Opponent != "" && !strings.EqualFold(g.Opponent, f.Opponent),
+		f.TimeControl != "" && g.TimeControl != f.TimeControl:
+		return false
+	}
+	return inBand(g.OpponentRating, f.OpponentMin, f.OpponentMax) &&
+		inBand(g.PlayerRating, f.RatingMin, f.RatingMax)
+}
+
+func inBand(rating, min, max int) bool {
+	return (min == 0 || rating >= min) && (max == 0 || rating <= max)
+}
+
+// PositionStats and MoveStats count the games of a query, TimeClasses
+// splitting them per time class for the stores that keep it.
+type PositionStats struct {
+	Fen         string         `json:"fen"`
+	Games       int            `json:"games"`
+	Wins        int            `json:"wins"`
+	Losses      int            `json:"losses"`
+	Draws       int            `json:"draws"`
+	TimeClasses map[string]int `json:"timeClasses,omitempty"`
+}
+
+type MoveStats struct {
+	Move        string         `json:"move"`
+	Fen         string         `json:"fen"`
+	Games       int            `json:"games"`
+	Wins        int            `json:"wins"`
+	Losses      int            `json:"losses"`
+	Draws       int            `json:"draws"`
+	TimeClasses map[string]int `json:"timeClasses,omitempty"`
+}
+
+// Edge is a move between two positions of the tree. Parent and Child are
+// position keys, ChildFen a full FEN of the child to query it with and Depth
+// the number of plies below the node a subtree was asked for.
+type Edge struct {
+	Parent      string         `json:"parent"`
+	Move        string         `json:"move"`
+	Child       string         `json:"child"`
+	ChildFen    string         `json:"childFen"`
+	Games       int            `json:"games"`
+	Wins        int            `json:"wins"`
+	Losses      int            `json:"losses"`
+	Draws       int            `json:"draws"`
+	TimeClasses map[string]int `json:"timeClasses,omitempty"`
+	Depth       int            `json:"depth,omitempty"`
+}
+
+// RebuildProgress is reported while stats are recomputed from the stored
+// games. Phase is "build", "catch-up" or "swap".
+type RebuildProgress struct {
+	Phase   string `json:"phase"`
+	Done    int    `json:"done"`
+	Total   int    `json:"total"`
+	Skipped int    `json:"skipped"`
+}
+
+// UserExport is everything stored about a user, see the export endpoint.
+type UserExport struct {
+	Username  string             `json:"username"`
+	Games     []ExportedGame     `json:"games"`
+	Positions []ExportedPosition `json:"positions"`
+	Moves     []ExportedMove     `json:"moves"`
+}
+
+type ExportedGame struct {
+	ID            string    `json:"id"`
+	Link          string    `json:"link"`
+	White         string    `json:"white"`
+	Black         string    `json:"black"`
+	WhiteElo      int       `json:"whiteElo,omitempty"`
+	BlackElo      int       `json:"blackElo,omitempty"`
+	Result        string    `json:"result"`
+	TimeClass     string    `json:"timeClass"`
+	TimeControl   string    `json:"timeControl,omitempty"`
+	ECO           string    `json:"eco,omitempty"`
+	Termination   string    `json:"termination,omitempty"`
+	WhiteAccuracy *float64  `json:"whiteAccuracy,omitempty"`
+	BlackAccuracy *float64  `json:"blackAccuracy,omitempty"`
+	PlayedAt      time.Time `json:"playedAt"`
+	PGN           string    `json:"-"`
+}
+
+type ExportedPosition struct {
+	Fen       string `json:"fen"`
+	Color     string `json:"color"`
+	TimeClass string `json:"timeClass"`
+	Games     int    `json:"games"`
+	Wins      int    `json:"wins"`
+	Losses    int    `json:"losses"`
+	Draws     int    `json:"draws"`
+}
+
+type ExportedMove struct {
+	Color     string `json:"color"`
+	TimeClass string `json:"timeClass"`
+	Edge
+}
+
+// AuditRecord is written when a user's data is deleted.
+type AuditRecord struct {
+	Action   string    `json:"action"`
+	Username string    `json:"username"`
+	Actor    string    `json:"actor"`
+	Games    int       `json:"games"`
+	At       time.Time `json:"at"`
+}
+
+// PositionGame is a game of the user that reached a position, Result being
+// from the user's side.
+type PositionGame struct {
+	ID             string    `json:"id"`
+	OpponentName   string    `json:"opponentName"`
+	OpponentRating int       `json:"opponentRating"`
+	PlayerRating   int       `json:"playerRating"`
+	Result         string    `json:"result"`
+	PlayerColor    string    `json:"playerColor"`
+	TimeClass      string    `json:"timeClass"`
+	PlayedAt       time.Time `json:"playedAt"`
+	ChessComUrl    string    `json:"chessComUrl"`
+	Ply            int       `json:"-"`
+	PGN            string    `json:"-"`
+}
+
+// GamesQuery asks for a page of the games that reached a position. Sort is
+// one of GamesByRecent, GamesByRating (the opponent's) or GamesByResult (wins
+// first), Cursor the NextCursor of the previous page.
+type GamesQuery struct {
+	PositionQuery
+	Sort   string
+	Cursor string
+	Limit  int
+}
+
+const (
+	GamesByRecent = "recent"
+	GamesByRating = "rating"
+	GamesByResult = "result"
+)
+
+type GamesPage struct {
+	Games      []PositionGame `json:"games"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+// SearchQuery asks for the games of PositionQuery's user (its Fen unused)
+// that went through a position a search matches, Cursor being the NextCursor
+// of the previous page.
+type SearchQuery struct {
+	PositionQuery
+	Cursor string
+	Limit  int
+}
+
+// SearchMatch is a game with the plies where the search matched, Fen being
+// the position at the first of them.
+type SearchMatch struct {
+	PositionGame
+	Fen   string `json:"fen"`
+	Plies []int  `json:"plies"`
+}
+
+// SearchPage lists the matching games among the Scanned games looked at, a
+// search stopping after a bounded number of games even without a full page.
+// MaxPly is the deepest ply the positions are kept for, MaxPlies of the
+// pipeline, a position reached later in a game is not searched.
+type SearchPage struct {
+	Games      []SearchMatch `json:"games"`
+	Scanned    int           `json:"scanned"`
+	MaxPly     int           `json:"maxPly"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+type OpponentStats struct {
+	Username       string    `json:"username"`
+	GamesPlayed    int       `json:"gamesPlayed"`
+	Wins           int       `json:"wins"`
+	Losses         int       `json:"losses"`
+	Draws          int       `json:"draws"`
+	AvgRating      int       `json:"avgRating"`
+	LastPlayedDate time.Time `json:"lastPlayedDate"`
+}
+
+type TimeClassCount struct {
+	TimeClass string `json:"timeClass"`
+	Count     int    `json:"count"`
+}
+
+// ProcessingLog is one run of the pipeline over a user's games.
+type ProcessingLog struct {
+	ID            int64      `json:"id"`
+	Username      string     `json:"username"`
+	Status        string     `json:"status"`
+	SourceFile    string     `json:"sourceFile,omitempty"`
+	ApiEndpoint   string     `json:"apiEndpoint,omitempty"`
+	GamesFromDate *time.Time `json:"gamesFromDate,omitempty"`
+	GamesToDate   *time.Time `json:"gamesToDate,omitempty"`
+	StartedAt     time.Time  `json:"startedAt"`
+	CompletedAt   *time.Time `json:"completedAt,omitempty"`
+	TotalGames    int        `json:"totalGames"`
+	SuccessCount  int        `json:"successCount"`
+	ErrorCount    int        `json:"errorCount"`
+	ErrorMessage  string     `json:"errorMessage,omitempty"`
+}
+
+// SyncJob is a background sync of a user's chess.com games. Errors lists the
+// games that failed, the whole job failing with Error.
+type SyncJob struct {
+	ID         string     `json:"id"`
+	Username   string     `json:"username"`
+	Status     string     `json:"status"`
+	Archives   int        `json:"archives"`
+	Fetched    int        `json:"fetched"`
+	Processed  int        `json:"processed"`
+	Failed     int        `json:"failed"`
+	Errors     []string   `json:"errors"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// Done reports whether the job has finished, successfully or not.
+func (j *SyncJob) Done() bool {
+	return j.Status == JobCompleted || j.Status == JobFailed
+}
@@ -1,11 +1,15 @@
 package utils
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 	// "encoding/json"
 	// demo "github.com/notnil/chess"
 	"chess/ProcessPipline"
+	"chess/Store"
 	"chess/Types"
 )
 
@@ -121,22 +125,83 @@ func ParsePgnBody(body string) []types.Move {
 	// b, _ := json.MarshalIndent(moves, "", " ")
 	// fmt.Println(string(b))
 	// fmt.Println("result:", Result)
-	fmt.Println("moves array:", moves)
 	return moves
 }
 
-func ParseAllGames(allgames *types.UserGames, username string) {
-	for index, item := range allgames.Games {
-		if index > 30 {
-			return
+// ParseAllGames runs the pipeline over the first limit games, all of them
+// when limit is 0, and saves each one to st. A game failing to save stops
+// the run since the next ones would fail as well. The run is recorded in the
+// processing history when st keeps one.
+func ParseAllGames(ctx context.Context, allgames *types.UserGames, username string, limit int, st store.PositionStore) (err error) {
+	games := allgames.Games
+	if limit > 0 && len(games) > limit {
+		games = games[:limit]
+	}
+	run := types.ProcessingLog{Username: username}
+	for _, item := range games {
+		played := time.Unix(item.EndTime, 0).UTC()
+		if run.GamesFromDate == nil || played.Before(*run.GamesFromDate) {
+			run.GamesFromDate = &played
+		}
+		if run.GamesToDate == nil || played.After(*run.GamesToDate) {
+			run.GamesToDate = &played
 		}
-		yourcolor := "white"
-		if item.Black.Username == username {
-			yourcolor = "black"
+		run.TotalGames++
+	}
+	logger, _ := st.(store.ProcessingLogger)
+	if logger != nil {
+		if run.ID, err = logger.StartProcessing(ctx, run); err != nil {
+			return err
 		}
-		splitted := strings.SplitN(item.PGN, "\n\n", 2)
-		header := ParsePngHeader(splitted[0])
-		moves := ParsePgnBody(splitted[1])
-		Processpipline.ProcessPipeline(item, moves, header, yourcolor)
+		defer func() {
+			run.Status = "completed"
+			if err != nil {
+				run.Status = "failed"
+				run.ErrorMessage = err.Error()
+			}
+			if finishErr := logger.FinishProcessing(context.WithoutCancel(ctx), run); finishErr != nil {
+				fmt.Println("failed to log the processing run:", finishErr)
+			}
+		}()
 	}
+
+	// the run saves through a buffer of its own, see store.Batcher
+	batch := store.Batch(st)
+	if f, ok := batch.(store.Flusher); ok {
+		defer func() {
+			if flushErr := f.Flush(context.WithoutCancel(ctx)); err == nil {
+				err = flushErr
+			}
+		}()
+	}
+
+	for _, item := range games {
+		rec, err := ProcessGame(item, username)
+		if err != nil {
+			fmt.Println("failed to process game", item.UUID, err)
+			run.ErrorCount++
+			continue
+		}
+		if err := batch.SaveGame(ctx, rec); err != nil {
+			run.ErrorCount++
+			return fmt.Errorf("saving game %s: %w", item.UUID, err)
+		}
+		run.SuccessCount++
+	}
+	return nil
+}
+
+// ProcessGame parses the PGN of a game played by username and replays it.
+func ProcessGame(item *types.Game, username string) (*types.GameRecord, error) {
+	yourcolor := "white"
+	if strings.EqualFold(item.Black.Username, username) {
+		yourcolor = "black"
+	}
+	splitted := strings.SplitN(item.PGN, "\n\n", 2)
+	if len(splitted) < 2 {
+		return nil, errors.New("game has no moves")
+	}
+	header := ParsePngHeader(splitted[0])
+	moves := ParsePgnBody(splitted[1])
+	return Processpipline.ProcessPipeline(item, moves, header, yourcolor)
 }
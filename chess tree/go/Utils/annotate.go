@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"strings"
+)
+
+// AnnotatePly adds a {comment} to the movetext of pgn after its ply-th half
+// move and the comments following it, before the first move for ply 0. The
+// movetext is rewritten on one line, the headers are kept as they are.
+func AnnotatePly(pgn string, ply int, comment string) string {
+	headers, body, found := strings.Cut(strings.TrimSpace(pgn), "\n\n")
+	if !found {
+		headers, body = "", headers
+	}
+	note := "{" + strings.ReplaceAll(comment, "}", ")") + "}"
+
+	tokens := movetextTokens(body)
+	annotated := make([]string, 0, len(tokens)+1)
+	moves := 0
+	placed := false
+	for _, token := range tokens {
+		if !placed && moves == ply && !strings.HasPrefix(token, "{") {
+			annotated = append(annotated, note)
+			placed = true
+		}
+		annotated = append(annotated, token)
+		if isSan(token) {
+			moves++
+		}
+	}
+	if !placed && moves == ply {
+		annotated = append(annotated, note)
+	}
+
+	movetext := strings.Join(annotated, " ")
+	if headers == "" {
+		return movetext
+	}
+	return headers + "\n\n" + movetext
+}
+
+// movetextTokens splits a movetext on spaces, a {comment} being one token.
+func movetextTokens(body string) []string {
+	var tokens []string
+	for body = strings.TrimSpace(body); body != ""; body = strings.TrimSpace(body) {
+		end := strings.IndexAny(body, " \t\r\n")
+		if strings.HasPrefix(body, "{") {
+			end = strings.IndexByte(body, '}') + 1
+		}
+		if end <= 0 {
+			end = len(body)
+		}
+		tokens = append(tokens, body[:end])
+		body = body[end:]
+	}
+	return tokens
+}
+
+// isSan reports whether a movetext token is a move rather than a move number,
+// a comment or the result.
+func isSan(token string) bool {
+	switch {
+	case token == "", strings.HasPrefix(token, "{"), strings.HasPrefix(token, "$"):
+		return false
+	case token == "1-0", token == "0-1", token == "1/2-1/2", token == "*":
+		return false
+	}
+	return strings.Trim(token, "0123456789.") != ""
+}
@@ -1,62 +1,46 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	// "github.com/notnil/chess"
 	"chess/Types"
 )
 
-var (
-	name     = "i_use_nvim_btw"
-	gameJSON interface{}
-)
-
-func FetchProcess() (*types.UserGames, error) {
-	fmt.Println("welcome to the test server")
-
-	response, err := http.Get("http://localhost:3000/archives")
-	if err != nil {
-		return nil, errors.New("error hitting endpoint")
-	}
-	defer response.Body.Close()
-
-	data, err := io.ReadAll(response.Body)
-	if err != nil {
-		return nil, errors.New("failed to read the body")
-	}
+// FetchProcess fetches the games username played in the latest archive the
+// game source at sourceURL, the mock chess.com API of the backend, lists.
+func FetchProcess(ctx context.Context, sourceURL, username string) (*types.UserGames, error) {
+	sourceURL = strings.TrimSuffix(sourceURL, "/")
+	fmt.Println("fetching the games of", username, "from", sourceURL)
 
 	igotdata := types.ArchiveResponse{}
-	if err := json.Unmarshal(data, &igotdata); err != nil {
-		return nil, errors.New("failed to parse the JSON")
+	if err := getSource(ctx, sourceURL+"/archives", &igotdata); err != nil {
+		return nil, err
+	}
+	if len(igotdata.Data.Archives) == 0 {
+		return nil, errors.New("the game source lists no archives")
 	}
 
 	url := igotdata.Data.Archives[len(igotdata.Data.Archives)-1]
 	parts := strings.Split(url, "/")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("unexpected archive url %q", url)
+	}
 	timeframe := types.Timeline{
 		Year:  parts[len(parts)-2],
 		Month: parts[len(parts)-1],
 	}
 
-	gameData, err := http.Get("http://localhost:3000/fetchGames/" + timeframe.Year + "/" + timeframe.Month + "/" + "tinku")
-	if err != nil {
-		return nil, errors.New("error during API call")
-	}
-	defer gameData.Body.Close()
-
-	parsedData, err := io.ReadAll(gameData.Body)
-	if err != nil {
-		return nil, errors.New("failed to read game data")
-	}
-
 	intermediate := types.IntermeObj{}
-	if err := json.Unmarshal(parsedData, &intermediate); err != nil {
-		return nil, errors.New("failed to unmarshal game data")
+	if err := getSource(ctx, sourceURL+"/fetchGames/"+timeframe.Year+"/"+timeframe.Month+"/"+username, &intermediate); err != nil {
+		return nil, err
 	}
 
 	uPlayed := types.UserGames{}
@@ -69,3 +53,82 @@ func FetchProcess() (*types.UserGames, error) {
 
 	return &uPlayed, nil
 }
+
+// getSource reads a JSON answer of the game source.
+func getSource(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := ChessComClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error hitting %s: %w", url, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered with status %d", url, response.StatusCode)
+	}
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", url, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse the JSON of %s: %w", url, err)
+	}
+	return nil
+}
+
+// ChessComAPI is the chess.com published-data endpoint the syncs fetch from,
+// ChessComClient the client they fetch with.
+var (
+	ChessComAPI    = "https://api.chess.com/pub/player/"
+	ChessComClient = &http.Client{Timeout: 30 * time.Second}
+)
+
+// FetchArchives lists the urls of the monthly archives of username, oldest
+// first.
+func FetchArchives(ctx context.Context, username string) ([]string, error) {
+	archives := types.ChessComArchives{}
+	if err := getJSON(ctx, ChessComAPI+strings.ToLower(username)+"/games/archives", &archives); err != nil {
+		return nil, err
+	}
+	return archives.Archives, nil
+}
+
+// FetchArchive returns the games of one monthly archive.
+func FetchArchive(ctx context.Context, url string) ([]*types.Game, error) {
+	month := types.ChessComMonth{}
+	if err := getJSON(ctx, url, &month); err != nil {
+		return nil, err
+	}
+	games := make([]*types.Game, len(month.Games))
+	for i := range month.Games {
+		games[i] = &month.Games[i]
+	}
+	return games, nil
+}
+
+func getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	// chess.com rejects requests without a user agent
+	req.Header.Set("User-Agent", "chess-tree")
+	response, err := ChessComClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching %s: %w", url, err)
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("fetching %s: not found", url)
+	case response.StatusCode != http.StatusOK:
+		return fmt.Errorf("fetching %s: status %d", url, response.StatusCode)
+	}
+	if err := json.NewDecoder(response.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding %s: %w", url, err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"sort"
+
+	"chess/ProcessPipline"
+	"chess/Store"
+	"chess/Types"
+	"github.com/gofiber/fiber/v2"
+)
+
+// accuracyHandler compares the user's average accuracy in their games
+// through a position, and after each of its moves, with their overall
+// accuracy which is the one of the start position. The color query
+// parameter picks the color of the average, both when it is empty.
+func accuracyHandler(trees store.Trees) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		q, err := treeQuery(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if trees == nil {
+			return explorerError(c, store.ErrNoUserData)
+		}
+		tree, err := userTree(c, trees, q)
+		if err != nil {
+			return explorerError(c, err)
+		}
+		fen := c.Query("fen", Processpipline.StartFEN)
+		color := c.Query("color")
+		info, exists := Processpipline.KeyInfo(tree, fen)
+		if !exists {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "position not found",
+			})
+		}
+
+		moves := []fiber.Map{}
+		for san, move := range info.Moves {
+			moves = append(moves, fiber.Map{
+				"move":     san,
+				"games":    move.Count,
+				"accuracy": move.Accuracy.Average(color),
+			})
+		}
+		sort.Slice(moves, func(i, j int) bool {
+			return moves[i]["games"].(int) > moves[j]["games"].(int)
+		})
+
+		overall := 0.0
+		if root, exists := Processpipline.KeyInfo(tree, Processpipline.StartFEN); exists {
+			overall = root.Accuracy.Average(color)
+		}
+		return c.Status(200).JSON(fiber.Map{
+			"fen":      fen,
+			"games":    info.Count,
+			"accuracy": info.Accuracy.Average(color),
+			"overall":  overall,
+			"detail":   info.Accuracy,
+			"moves":    moves,
+		})
+	}
+}
+
+// openingAccuracyHandler lists the user's average accuracy per opening,
+// replayed from their stored games of the timeClass query parameter, all of
+// them when it is empty.
+func openingAccuracyHandler(users store.UserData) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if users == nil {
+			return explorerError(c, store.ErrNoUserData)
+		}
+		q := explorerQuery(c, "")
+		export, err := users.ExportUser(c.Context(), q.Username)
+		if err != nil {
+			return userError(c, err)
+		}
+		var games []*types.GameRecord
+		for _, rec := range replayExport(export) {
+			if q.TimeClass == "" || rec.TimeClass == q.TimeClass {
+				games = append(games, rec)
+			}
+		}
+
+		report := Processpipline.AccuracyReport(Processpipline.OpeningsOf(games), c.Query("color"), c.QueryInt("minGames", 1))
+		return c.Status(200).JSON(fiber.Map{
+			"data": report,
+		})
+	}
+}
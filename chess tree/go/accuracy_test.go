@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"chess/Types"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestAccuracyPerUser checks the accuracies, overall included, only average
+// the games of the user asked for.
+func TestAccuracyPerUser(t *testing.T) {
+	lite := analyticsStore(t,
+		playedGame{testUser, testUser, "opponent", "blitz", "1-0", testUser + " won by resignation", "1. e4 e5 2. Nf3 Nc6",
+			&types.Accuracies{White: 80, Black: 50}, "C50"},
+		playedGame{testUser, "opponent", testUser, "blitz", "0-1", testUser + " won by resignation", "1. e4 c5",
+			&types.Accuracies{White: 90, Black: 60}, "B20"},
+		playedGame{"someone", "someone", "opponent", "blitz", "1-0", "someone won by resignation", "1. e4 e5",
+			&types.Accuracies{White: 20, Black: 70}, "C20"},
+	)
+	app := fiber.New()
+	app.Get("/accuracy", accuracyHandler(lite))
+	app.Get("/accuracy/openings", openingAccuracyHandler(lite))
+
+	fen := url.QueryEscape(fenAfter(t, "e4"))
+	for _, tt := range []struct {
+		color        string
+		accuracy, e5 float64
+		c5, overall  float64
+		games        int
+	}{
+		{"", 70, 80, 60, 70, 2},
+		{"white", 80, 80, 0, 80, 2},
+	} {
+		var body struct {
+			Games    int                `json:"games"`
+			Accuracy float64            `json:"accuracy"`
+			Overall  float64            `json:"overall"`
+			Detail   types.AccuracyStat `json:"detail"`
+			Moves    []struct {
+				Move     string  `json:"move"`
+				Accuracy float64 `json:"accuracy"`
+			} `json:"moves"`
+		}
+		status := getJSON(t, app, "/accuracy?username="+testUser+"&color="+tt.color+"&fen="+fen, &body)
+		if status != 200 {
+			t.Fatalf("color %q: status = %d, want 200", tt.color, status)
+		}
+		if body.Games != tt.games || body.Accuracy != tt.accuracy || body.Overall != tt.overall ||
+			body.Detail.WhiteGames != 1 || body.Detail.BlackGames != 1 {
+			t.Errorf("color %q: %d games at %v, overall %v, detail %+v, want %d at %v, overall %v",
+				tt.color, body.Games, body.Accuracy, body.Overall, body.Detail, tt.games, tt.accuracy, tt.overall)
+		}
+		moves := map[string]float64{}
+		for _, m := range body.Moves {
+			moves[m.Move] = m.Accuracy
+		}
+		if len(moves) != 2 || moves["e5"] != tt.e5 || moves["c5"] != tt.c5 {
+			t.Errorf("color %q: moves %v, want e5 at %v and c5 at %v", tt.color, moves, tt.e5, tt.c5)
+		}
+	}
+
+	var openings struct {
+		Data []types.OpeningAccuracy `json:"data"`
+	}
+	if status := getJSON(t, app, "/accuracy/openings?username="+testUser, &openings); status != 200 {
+		t.Fatalf("openings status = %d, want 200", status)
+	}
+	averages := map[string]float64{}
+	for _, o := range openings.Data {
+		averages[o.ECO] = o.Average
+	}
+	if len(averages) != 2 || averages["C50"] != 80 || averages["B20"] != 60 {
+		t.Errorf("openings %+v, want C50 at 80 and B20 at 60 only", openings.Data)
+	}
+
+	if status := getJSON(t, app, "/accuracy?username=nobody", nil); status != 404 {
+		t.Errorf("unknown user: status %d, want 404", status)
+	}
+	if status := getJSON(t, app, "/accuracy/openings?username=nobody", nil); status != 404 {
+		t.Errorf("unknown user openings: status %d, want 404", status)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"chess/Auth"
+	"chess/Store"
+	"github.com/gofiber/fiber/v2"
+)
+
+// The fiber locals holding the account a request is authenticated as and the
+// chess.com user it explores.
+const (
+	accountLocal = "account"
+	userLocal    = "user"
+)
+
+// linkPath is where an account links its chess.com user, the only route
+// open to an account not linked yet.
+const linkPath = "/account"
+
+var errForbidden = errors.New("forbidden")
+
+// access decides who reads and writes what. Without a verifier the server
+// runs open, anyone is everyone.
+type access struct {
+	verifier *auth.Verifier
+	accounts store.Accounts
+	sharing  store.Sharing
+}
+
+// authenticate rejects the requests without a valid token, but for the health
+// check at / and the images under /render/, which show no one's data and are
+// fetched by chat services without a token. The token is a bearer token, or
+// the access_token query parameter for the clients that cannot set headers
+// like EventSource. The tokens name an account of the socket service, the
+// request is made as the chess.com user linked to it.
+func (a *access) authenticate() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if a.verifier == nil || c.Path() == "/" || strings.HasPrefix(c.Path(), "/render/") {
+			return c.Next()
+		}
+		token, found := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !found {
+			token = c.Query("access_token")
+		}
+		if token == "" {
+			c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+			return c.Status(401).JSON(fiber.Map{
+				"error": "a bearer token is required",
+			})
+		}
+		account, err := a.verifier.Account(token)
+		if err != nil {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			return c.Status(401).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		c.Locals(accountLocal, account)
+		if c.Path() == linkPath {
+			return c.Next()
+		}
+
+		if a.accounts == nil {
+			return c.Status(501).JSON(fiber.Map{
+				"error": store.ErrNoUserData.Error(),
+			})
+		}
+		username, err := a.accounts.AccountUser(c.Context(), account)
+		if errors.Is(err, store.ErrNotFound) {
+			return forbidden(c, "link a chess.com user to the account with PUT "+linkPath+" first")
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		c.Locals(userLocal, username)
+		return c.Next()
+	}
+}
+
+// requestUser is the user the request is authenticated as, empty when the
+// server runs open.
+func requestUser(c *fiber.Ctx) string {
+	username, _ := c.Locals(userLocal).(string)
+	return username
+}
+
+// owns reports whether the request may change username's data.
+func (a *access) owns(c *fiber.Ctx, username string) bool {
+	return a.verifier == nil || strings.EqualFold(requestUser(c), username)
+}
+
+// canRead reports whether the request may read username's tree, their own or
+// a shared one.
+func (a *access) canRead(c *fiber.Ctx, username string) (bool, error) {
+	if a.owns(c, username) {
+		return true, nil
+	}
+	if a.sharing == nil {
+		return false, nil
+	}
+	return a.sharing.Shared(c.Context(), username)
+}
+
+// owner lets through the requests of the user named by the username route
+// parameter only.
+func (a *access) owner() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		username := c.Params("username")
+		if !a.owns(c, username) {
+			return forbidden(c, fmt.Sprintf("only %s can do this", username))
+		}
+		return c.Next()
+	}
+}
+
+// reader lets through the requests for the tree of the user named by the
+// username or userId query parameter, the caller's own one by default.
+func (a *access) reader() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		username := c.Query("username", c.Query("userId", requestUser(c)))
+		if username == "" {
+			return c.Next()
+		}
+		allowed, err := a.canRead(c, username)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if !allowed {
+			return forbidden(c, username+" does not share their tree")
+		}
+		return c.Next()
+	}
+}
+
+// open lets the requests through only when the server runs open. The routes
+// over the in-memory tree, the snapshots and the books answer for every user
+// at once, there is no single user to check a token against.
+func (a *access) open() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if a.verifier != nil {
+			return forbidden(c, "this route serves every user's games, it is only available without JWT auth")
+		}
+		return c.Next()
+	}
+}
+
+func forbidden(c *fiber.Ctx, reason string) error {
+	return c.Status(403).JSON(fiber.Map{
+		"error": fmt.Sprintf("%s: %s", errForbidden, reason),
+	})
+}
+
+// sharingHandler sets whether the user's tree can be read by everyone, from a
+// {"shared": true} body.
+func sharingHandler(sharing store.Sharing) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if sharing == nil {
+			return c.Status(501).JSON(fiber.Map{
+				"error": store.ErrNoUserData.Error(),
+			})
+		}
+		var body struct {
+			Shared *bool `json:"shared"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.Shared == nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": `the body must be {"shared": true} or {"shared": false}`,
+			})
+		}
+		username := strings.ToLower(c.Params("username"))
+		err := sharing.SetShared(c.Context(), username, *body.Shared)
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "no games stored for " + username,
+			})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(200).JSON(fiber.Map{
+			"username": username,
+			"shared":   *body.Shared,
+		})
+	}
+}
+
+// accountHandler tells the chess.com user the caller's account is linked to.
+func accountHandler(a *access) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		account, _ := c.Locals(accountLocal).(string)
+		if a.accounts == nil || account == "" {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "the server runs without accounts",
+			})
+		}
+		username, err := a.accounts.AccountUser(c.Context(), account)
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "no chess.com user is linked to the account",
+			})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(200).JSON(fiber.Map{
+			"account":  account,
+			"username": username,
+		})
+	}
+}
+
+// linkAccountHandler links the caller's account to the chess.com user of a
+// {"username": "alice"} body. An account explores one user, and a user is
+// explored by the first account linking them.
+func linkAccountHandler(a *access) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		account, _ := c.Locals(accountLocal).(string)
+		if a.accounts == nil || account == "" {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "the server runs without accounts",
+			})
+		}
+		var body struct {
+			Username string `json:"username"`
+		}
+		if err := c.BodyParser(&body); err != nil || !chessComUsername.MatchString(body.Username) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": `the body must be {"username": "<chess.com username>"}`,
+			})
+		}
+		username := strings.ToLower(body.Username)
+		err := a.accounts.LinkAccount(c.Context(), account, username)
+		if errors.Is(err, store.ErrUserTaken) || errors.Is(err, store.ErrAccountLinked) {
+			return c.Status(409).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(200).JSON(fiber.Map{
+			"account":  account,
+			"username": username,
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"chess/Auth"
+	"chess/Store"
+	"chess/Types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const testSecret = "test-secret"
+
+// testGuard checks the tokens signed by testToken, the accounts of alice,
+// bob and carol being linked to them.
+func testGuard(t *testing.T) *access {
+	t.Helper()
+	verifier, err := auth.New(auth.Config{Secret: testSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts := linkedAccounts{}
+	for _, username := range []string{"alice", "bob", "carol"} {
+		accounts[testAccount(username)] = username
+	}
+	return &access{verifier: verifier, accounts: accounts}
+}
+
+// testAccount is the id of the socket service account of username.
+func testAccount(username string) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(username)).String()
+}
+
+// testToken signs an access token of the socket service, see
+// socket/src/Utils/Authutils.js, for the account of username.
+func testToken(t *testing.T, username string) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":       testAccount(username),
+		"fullname": "Test " + username,
+		"email":    username + "@example.com",
+		"iat":      time.Now().Unix(),
+		"exp":      time.Now().Add(15 * time.Minute).Unix(),
+	}).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// linkedAccounts is an accounts store mapping account ids to users.
+type linkedAccounts map[string]string
+
+func (l linkedAccounts) AccountUser(ctx context.Context, account string) (string, error) {
+	username, ok := l[account]
+	if !ok {
+		return "", store.ErrNotFound
+	}
+	return username, nil
+}
+
+func (l linkedAccounts) LinkAccount(ctx context.Context, account, username string) error {
+	if linked, ok := l[account]; ok && linked != username {
+		return store.ErrAccountLinked
+	}
+	for other, linked := range l {
+		if linked == username && other != account {
+			return store.ErrUserTaken
+		}
+	}
+	l[account] = username
+	return nil
+}
+
+// getAs sends a GET as username, anonymously when it is empty, and returns
+// the status.
+func getAs(t *testing.T, app *fiber.App, target, username string) int {
+	t.Helper()
+	req := httptest.NewRequest("GET", target, nil)
+	if username != "" {
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+testToken(t, username))
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// sharedUsers is a sharing store where the listed users share their tree.
+type sharedUsers map[string]bool
+
+func (s sharedUsers) Shared(ctx context.Context, username string) (bool, error) {
+	return s[username], nil
+}
+
+func (s sharedUsers) SetShared(ctx context.Context, username string, shared bool) error {
+	s[username] = shared
+	return nil
+}
+
+func TestReader(t *testing.T) {
+	guard := testGuard(t)
+	guard.sharing = sharedUsers{"carol": true}
+	app := fiber.New()
+	app.Use(guard.authenticate())
+	app.Get("/tree", guard.reader(), func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	tests := []struct {
+		target, as string
+		want       int
+	}{
+		{"/tree", "alice", 200},
+		{"/tree?username=alice", "alice", 200},
+		{"/tree?userId=bob", "alice", 403},
+		{"/tree?username=carol", "alice", 200},
+		{"/tree", "", 401},
+	}
+	for _, tt := range tests {
+		if got := getAs(t, app, tt.target, tt.as); got != tt.want {
+			t.Errorf("%s as %q: status %d, want %d", tt.target, tt.as, got, tt.want)
+		}
+	}
+}
+
+// TestOpen checks the routes over every user's games are refused to a
+// verified user and served when the server runs open.
+func TestOpen(t *testing.T) {
+	for _, tt := range []struct {
+		guard *access
+		as    string
+		want  int
+	}{
+		{&access{}, "", 200},
+		{testGuard(t), "alice", 403},
+	} {
+		app := fiber.New()
+		app.Use(tt.guard.authenticate())
+		app.Get("/arry", tt.guard.open(), func(c *fiber.Ctx) error {
+			return c.SendStatus(200)
+		})
+		if got := getAs(t, app, "/arry", tt.as); got != tt.want {
+			t.Errorf("as %q: status %d, want %d", tt.as, got, tt.want)
+		}
+	}
+}
+
+// emptyTrees answers every tree with no games.
+type emptyTrees struct{}
+
+func (emptyTrees) Tree(ctx context.Context, q types.PositionQuery, replay store.Replayer) (map[string]*types.PositonInfo, error) {
+	return map[string]*types.PositonInfo{}, nil
+}
+
+func TestDiffAccess(t *testing.T) {
+	guard := testGuard(t)
+	guard.sharing = sharedUsers{"carol": true}
+	app := fiber.New()
+	app.Use(guard.authenticate())
+	app.Get("/diff", diffHandler(guard, emptyTrees{}))
+
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{"/diff", 200},
+		{"/diff?userB=carol", 200},
+		{"/diff?userA=bob", 403},
+		{"/diff?userB=bob", 403},
+		{"/diff?a=old", 403},
+	}
+	for _, tt := range tests {
+		if got := getAs(t, app, tt.target, "alice"); got != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestAuthNeedsUsers(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.JWTSecret = testSecret
+	cfg.Store = "memory"
+	if err := cfg.validate(); err == nil {
+		t.Error("JWT auth was accepted with the memory store")
+	}
+	cfg.Store, cfg.SQLitePath = "sqlite", "test.db"
+	if err := cfg.validate(); err != nil {
+		t.Errorf("JWT auth with sqlite: %v", err)
+	}
+}
+
+// TestAccountLink signs in with the tokens of the socket service, which name
+// an account and no chess.com user: an account reaches the data once it
+// links a user.
+func TestAccountLink(t *testing.T) {
+	guard := testGuard(t)
+	app := fiber.New()
+	app.Use(guard.authenticate())
+	app.Get(linkPath, accountHandler(guard))
+	app.Put(linkPath, linkAccountHandler(guard))
+	app.Get("/tree", guard.reader(), func(c *fiber.Ctx) error {
+		return c.SendString(requestUser(c))
+	})
+
+	send := func(method, target, username, body string) int {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+testToken(t, username))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := getAs(t, app, "/tree", "dave"); got != 403 {
+		t.Errorf("unlinked account: status %d, want 403", got)
+	}
+	if got := getAs(t, app, linkPath, "dave"); got != 404 {
+		t.Errorf("unlinked account info: status %d, want 404", got)
+	}
+	for _, tt := range []struct {
+		body string
+		want int
+	}{
+		{`{"username": "x"}`, 400},
+		{`{"username": "Alice"}`, 409},
+		{`{"username": "Dave_Plays"}`, 200},
+		{`{"username": "dave_plays"}`, 200},
+		{`{"username": "erin"}`, 409},
+	} {
+		if got := send("PUT", linkPath, "dave", tt.body); got != tt.want {
+			t.Errorf("linking %s: status %d, want %d", tt.body, got, tt.want)
+		}
+	}
+	if got := getAs(t, app, "/tree", "dave"); got != 200 {
+		t.Errorf("linked account: status %d, want 200", got)
+	}
+	if guard.accounts.(linkedAccounts)[testAccount("dave")] != "dave_plays" {
+		t.Errorf("accounts = %v, want dave's linked to dave_plays", guard.accounts)
+	}
+
+	// a token signed for the anonymous clients or with another secret
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":       testAccount("alice"),
+		"fullname": "anonymous",
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("client-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/tree", nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+forged)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 401 {
+		t.Errorf("token of another secret: status %d, want 401", resp.StatusCode)
+	}
+}
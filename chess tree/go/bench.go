@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"chess/Store"
+	"chess/Types"
+	"chess/Utils"
+	"github.com/google/uuid"
+)
+
+// benchIngestCommand compares the per game Postgres writer with the bulk one
+// on the games of a chess.com archive file. Every run writes copies of the
+// games under a fresh user so it should point at a scratch database.
+func benchIngestCommand(args []string) error {
+	fs := flag.NewFlagSet("bench-ingest", flag.ContinueOnError)
+	dsn := fs.String("dsn", os.Getenv("DATABASE_URL"), "postgres connection string")
+	username := fs.String("username", "", "the player the archive belongs to")
+	batch := fs.Int("batch", 500, "games per batch for the bulk writer")
+	repeat := fs.Int("repeat", 1, "times the archive is written, to simulate larger imports")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *dsn == "" || *username == "" {
+		return errors.New("usage: bench-ingest -dsn <dsn> -username <player> [-batch n] [-repeat n] <archive.json>")
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var archive struct {
+		Games []types.Game `json:"games"`
+	}
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return err
+	}
+
+	var records []*types.GameRecord
+	for i := range archive.Games {
+		rec, err := utils.ProcessGame(&archive.Games[i], *username)
+		if err != nil {
+			fmt.Println("skipping game", archive.Games[i].UUID, err)
+			continue
+		}
+		records = append(records, rec)
+	}
+
+	ctx := context.Background()
+	pg, err := store.NewPostgres(ctx, *dsn)
+	if err != nil {
+		return err
+	}
+	defer pg.Close()
+
+	run := func(name string, st store.PositionStore) error {
+		games := copyRecords(records, *repeat, fmt.Sprintf("bench-%s-%d", name, time.Now().UnixNano()))
+		start := time.Now()
+		for _, rec := range games {
+			if err := st.SaveGame(ctx, rec); err != nil {
+				return err
+			}
+		}
+		if f, ok := st.(store.Flusher); ok {
+			if err := f.Flush(ctx); err != nil {
+				return err
+			}
+		}
+		elapsed := time.Since(start)
+		fmt.Printf("%-5s %6d games in %10s, %8.1f games/s\n",
+			name, len(games), elapsed.Round(time.Millisecond), float64(len(games))/elapsed.Seconds())
+		return nil
+	}
+
+	if err := run("rows", pg); err != nil {
+		return err
+	}
+	return run("bulk", pg.Bulk(*batch))
+}
+
+// copyRecords duplicates the records under username with new game ids and
+// links, the schema has both unique.
+func copyRecords(records []*types.GameRecord, repeat int, username string) []*types.GameRecord {
+	var games []*types.GameRecord
+	for i := 0; i < repeat; i++ {
+		for _, rec := range records {
+			copied := *rec
+			copied.ID = uuid.NewString()
+			copied.Link = rec.Link + "#" + copied.ID
+			copied.Username = username
+			games = append(games, &copied)
+		}
+	}
+	return games
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"chess/Polyglot"
+	"chess/Store"
+	"chess/Types"
+	"chess/Utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Reference books uploaded to compare the tree against.
+const bookDir = "books"
+
+// bookHandler exports a Polyglot book. With the color query parameter it is
+// the book of the moves the user chose in the games they played with that
+// color, without it the live tree of every user's games, only exported when
+// the server runs open.
+func bookHandler(guard *access, trees store.Trees) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		color := c.Query("color")
+		var tree map[string]*types.PositonInfo
+		var err error
+		switch {
+		case color == "":
+			if guard.verifier != nil {
+				return forbidden(c, "the live tree holds every user's games, pass the color the user played to export their book")
+			}
+			tree, err = loadTree(currentTree)
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+		case color != "white" && color != "black":
+			return c.Status(400).JSON(fiber.Map{
+				"error": "color query param must be white or black",
+			})
+		case trees == nil:
+			return explorerError(c, store.ErrNoUserData)
+		default:
+			q := explorerQuery(c, "")
+			q.Color = color
+			tree, err = trees.Tree(c.Context(), q, utils.ProcessGame)
+			if errors.Is(err, store.ErrNotFound) {
+				tree, err = map[string]*types.PositonInfo{}, nil
+			}
+			if err != nil {
+				return explorerError(c, err)
+			}
+		}
+
+		entries, err := polyglot.Export(tree, polyglot.ExportOptions{
+			Color:    color,
+			MinGames: c.QueryInt("minGames", 1),
+			MaxDepth: c.QueryInt("maxDepth", 0),
+			Weight:   c.Query("weight", polyglot.WeightGames),
+		})
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		var buf bytes.Buffer
+		if err := polyglot.WriteBook(&buf, entries); err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		c.Attachment("book.bin")
+		c.Set(fiber.HeaderContentType, "application/octet-stream")
+		return c.Status(200).Send(buf.Bytes())
+	}
+}
+
+// uploadBookHandler stores the request body as a reference Polyglot book.
+func uploadBookHandler(c *fiber.Ctx) error {
+	name := c.Params("name")
+	path, err := namedFile(bookDir, name, ".bin")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	body := c.Body()
+	if _, err := polyglot.ReadBook(bytes.NewReader(body)); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err := os.MkdirAll(bookDir, 0o755); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err := os.WriteFile(path, body, 0o644); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(201).JSON(fiber.Map{
+		"message": "book saved",
+		"name":    name,
+		"entries": len(body) / 16,
+	})
+}
+
+// annotationsHandler annotates the moves the user chose in the games they
+// played with the color query parameter, out-of-book only keeping the
+// deviations they play most.
+func annotationsHandler(trees store.Trees, outOfBook bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		name := c.Params("name")
+		path, err := namedFile(bookDir, name, ".bin")
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		color := c.Query("color")
+		if color != "white" && color != "black" {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "color query param must be white or black",
+			})
+		}
+		if trees == nil {
+			return explorerError(c, store.ErrNoUserData)
+		}
+		book, err := polyglot.ReadBookFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return c.Status(404).JSON(fiber.Map{
+				"error": fmt.Sprintf("book %q not found", name),
+			})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		q := explorerQuery(c, "")
+		q.Color = color
+		tree, err := trees.Tree(c.Context(), q, utils.ProcessGame)
+		if errors.Is(err, store.ErrNotFound) {
+			tree, err = map[string]*types.PositonInfo{}, nil
+		}
+		if err != nil {
+			return explorerError(c, err)
+		}
+		annotations, err := polyglot.Annotate(tree, book, color)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if outOfBook {
+			annotations = polyglot.OutOfBook(annotations, c.QueryInt("minGames", 1), c.QueryInt("limit", 50))
+		}
+		return c.Status(200).JSON(fiber.Map{
+			"book": name,
+			"data": annotations,
+		})
+	}
+}
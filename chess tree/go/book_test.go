@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"chess/Polyglot"
+	"chess/Store"
+	"chess/Types"
+	"github.com/gofiber/fiber/v2"
+	lib "github.com/notnil/chess"
+)
+
+// colorTrees answers the tree of the games played with q.Color: 1.e4 e5 as
+// white and 1.d4 Nf6 as black.
+type colorTrees struct {
+	t *testing.T
+}
+
+func (c colorTrees) Tree(ctx context.Context, q types.PositionQuery, replay store.Replayer) (map[string]*types.PositonInfo, error) {
+	sans := map[string][]string{"white": {"e4", "e5"}, "black": {"d4", "Nf6"}}[q.Color]
+	tree := map[string]*types.PositonInfo{}
+	for i, san := range sans {
+		parent, fen := fenAfter(c.t, sans[:i]...), fenAfter(c.t, sans[:i+1]...)
+		tree[parent] = &types.PositonInfo{Moves: map[string]*types.MoveInfo{
+			san: {Fen: fen, Count: 1},
+		}}
+	}
+	return tree, nil
+}
+
+// TestBookColor checks the book of a color only holds the user's moves from
+// the games they played with it, not the opponents' replies.
+func TestBookColor(t *testing.T) {
+	guard := testGuard(t)
+	app := fiber.New()
+	app.Use(guard.authenticate())
+	app.Get("/book.bin", guard.reader(), bookHandler(guard, colorTrees{t}))
+
+	for _, tt := range []struct {
+		color, san, fen string
+	}{
+		{"white", "e4", lib.NewGame().FEN()},
+		{"black", "Nf6", fenAfter(t, "d4")},
+	} {
+		req := httptest.NewRequest("GET", "/book.bin?color="+tt.color, nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+testToken(t, "alice"))
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		book, err := polyglot.ReadBook(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		opt, err := lib.FEN(tt.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pos := lib.NewGame(opt).Position()
+		entries := book[polyglot.Key(pos)]
+		if len(book) != 1 || len(entries) != 1 {
+			t.Fatalf("%s book = %+v, want the single move %s", tt.color, book, tt.san)
+		}
+		m, err := polyglot.DecodeMove(pos, entries[0].Move)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if san := (lib.AlgebraicNotation{}).Encode(pos, m); san != tt.san {
+			t.Errorf("%s book move = %s, want %s", tt.color, san, tt.san)
+		}
+	}
+
+	for _, tt := range []struct {
+		target string
+		want   int
+	}{
+		{"/book.bin", 403},
+		{"/book.bin?color=red", 400},
+		{"/book.bin?color=white&username=bob", 403},
+	} {
+		if got := getAs(t, app, tt.target, "alice"); got != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.target, got, tt.want)
+		}
+	}
+}
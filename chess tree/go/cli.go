@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"chess/Polyglot"
+	"chess/ProcessPipline"
+)
+
+// runCommand handles the subcommands with the configuration of the server
+// applied, main only starts the server when the binary is run without
+// arguments.
+func runCommand(cfg config, args []string) error {
+	switch args[0] {
+	case "diff":
+		return diffCommand(args[1:])
+	case "book":
+		return bookCommand(args[1:])
+	case "book-report":
+		return bookReportCommand(args[1:])
+	case "bench-ingest":
+		return benchIngestCommand(args[1:])
+	case "migrate":
+		return migrateCommand(args[1:])
+	case "rebuild":
+		return rebuildCommand(cfg, args[1:])
+	case "export-pgn":
+		return exportPGNCommand(cfg, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// diffCommand compares two snapshot files and prints the diff as JSON.
+func diffCommand(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	minGames := fs.Int("min-games", 1, "ignore positions and moves seen fewer times")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("usage: diff [-min-games n] <a.snapshot> <b.snapshot>")
+	}
+
+	a, err := Processpipline.ReadSnapshotFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := Processpipline.ReadSnapshotFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(Processpipline.Diff(a, b, *minGames))
+}
+
+// bookCommand writes a Polyglot book built from a snapshot file.
+func bookCommand(args []string) error {
+	fs := flag.NewFlagSet("book", flag.ContinueOnError)
+	color := fs.String("color", "", "color the user played in the games of the snapshot, only their moves are exported")
+	minGames := fs.Int("min-games", 1, "drop moves played fewer times")
+	maxDepth := fs.Int("max-depth", 0, "plies from the start to export, 0 for all")
+	weight := fs.String("weight", polyglot.WeightGames, "weigh moves by games or score")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("usage: book [flags] <tree.snapshot> <book.bin>")
+	}
+
+	tree, err := Processpipline.ReadSnapshotFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	entries, err := polyglot.Export(tree, polyglot.ExportOptions{
+		Color:    *color,
+		MinGames: *minGames,
+		MaxDepth: *maxDepth,
+		Weight:   *weight,
+	})
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	if err := polyglot.WriteBook(out, entries); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	fmt.Println("wrote", len(entries), "entries to", fs.Arg(1))
+	return nil
+}
+
+// bookReportCommand prints the moves of a snapshot that leave a reference
+// book, or every annotated move with -all.
+func bookReportCommand(args []string) error {
+	fs := flag.NewFlagSet("book-report", flag.ContinueOnError)
+	color := fs.String("color", "", "color the user played in the games of the snapshot, white or black")
+	minGames := fs.Int("min-games", 1, "ignore moves played fewer times")
+	limit := fs.Int("limit", 50, "number of moves to report, 0 for all")
+	all := fs.Bool("all", false, "print every annotated move, not only the out of book ones")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 || *color == "" {
+		return errors.New("usage: book-report -color white|black [flags] <book.bin> <tree.snapshot>")
+	}
+
+	book, err := polyglot.ReadBookFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	tree, err := Processpipline.ReadSnapshotFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	annotations, err := polyglot.Annotate(tree, book, *color)
+	if err != nil {
+		return err
+	}
+	if !*all {
+		annotations = polyglot.OutOfBook(annotations, *minGames, *limit)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(annotations)
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"chess/Auth"
+	"chess/ProcessPipline"
+	"chess/Utils"
+	"github.com/joho/godotenv"
+)
+
+// defaultEnvFile is read when no config file is given, if it exists.
+const defaultEnvFile = ".env"
+
+// config is the configuration of the server. Every setting comes from, by
+// increasing priority, its default, the dotenv file given by -config or
+// CONFIG_FILE (.env when it exists), the environment and the flags.
+type config struct {
+	Addr            string        // HTTP_ADDR, -addr
+	Store           string        // STORE, -store: memory, postgres or sqlite
+	DatabaseURL     string        // DATABASE_URL, -dsn
+	SQLitePath      string        // SQLITE_PATH, -sqlite-path
+	SnapshotPath    string        // SNAPSHOT_PATH, -snapshot-path
+	BulkBatchSize   int           // BULK_BATCH_SIZE, -bulk-batch-size
+	ChessComAPI     string        // CHESSCOM_API, -chesscom-api
+	ChessComTimeout time.Duration // CHESSCOM_TIMEOUT, -chesscom-timeout
+	Username        string        // DEFAULT_USERNAME, -username
+	GameSourceURL   string        // GAME_SOURCE_URL, -game-source-url
+	SourceMaxGames  int           // SOURCE_MAX_GAMES, -source-max-games
+	MaxPlies        int           // MAX_PLIES, -max-plies
+	SyncWorkers     int           // SYNC_WORKERS, -sync-workers
+	SyncQueueSize   int           // SYNC_QUEUE_SIZE, -sync-queue-size
+	ShutdownTimeout time.Duration // SHUTDOWN_TIMEOUT, -shutdown-timeout
+	CacheSize       int           // CACHE_SIZE, -cache-size
+	CacheTTL        time.Duration // CACHE_TTL, -cache-ttl
+	JWTSecret       string        // JWT_SECRET, -jwt-secret
+	JWTJWKSURL      string        // JWT_JWKS_URL, -jwt-jwks-url
+	JWTIssuer       string        // JWT_ISSUER, -jwt-issuer
+	JWTAudience     string        // JWT_AUDIENCE, -jwt-audience
+	JWTUserClaim    string        // JWT_USER_CLAIM, -jwt-user-claim
+}
+
+func defaultConfig() config {
+	return config{
+		Addr:            ":3030",
+		SQLitePath:      "chess.db",
+		SnapshotPath:    filepath.Join("data", "tree.snapshot"),
+		ChessComAPI:     "https://api.chess.com/pub/player/",
+		ChessComTimeout: 30 * time.Second,
+		Username:        "I_use_NVIM_Btw",
+		GameSourceURL:   "http://localhost:3000",
+		SourceMaxGames:  31,
+		MaxPlies:        31,
+		SyncWorkers:     1,
+		SyncQueueSize:   64,
+		ShutdownTimeout: 30 * time.Second,
+		CacheSize:       4096,
+		CacheTTL:        5 * time.Minute,
+		JWTUserClaim:    "id",
+	}
+}
+
+func (c *config) flags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Addr, "addr", c.Addr, "address the HTTP server listens on")
+	fs.StringVar(&c.Store, "store", c.Store, "storage backend: memory, postgres or sqlite")
+	fs.StringVar(&c.DatabaseURL, "dsn", c.DatabaseURL, "postgres connection string")
+	fs.StringVar(&c.SQLitePath, "sqlite-path", c.SQLitePath, "database file of the sqlite store")
+	fs.StringVar(&c.SnapshotPath, "snapshot-path", c.SnapshotPath, "file the in-memory tree is saved to and loaded from")
+	fs.IntVar(&c.BulkBatchSize, "bulk-batch-size", c.BulkBatchSize, "games per batch of the postgres bulk writer, 0 to write them one by one")
+	fs.StringVar(&c.ChessComAPI, "chesscom-api", c.ChessComAPI, "chess.com published-data endpoint the syncs fetch from")
+	fs.DurationVar(&c.ChessComTimeout, "chesscom-timeout", c.ChessComTimeout, "timeout of a request to chess.com")
+	fs.StringVar(&c.Username, "username", c.Username, "user the explorer answers for when a request names none")
+	fs.StringVar(&c.GameSourceURL, "game-source-url", c.GameSourceURL, "mock chess.com API of the backend /png fetches the default user's latest archive from")
+	fs.IntVar(&c.SourceMaxGames, "source-max-games", c.SourceMaxGames, "games of that archive /png processes, 0 for all of them")
+	fs.IntVar(&c.MaxPlies, "max-plies", c.MaxPlies, "plies of a game the pipeline replays, the positions deeper are neither in the tree nor searched")
+	fs.IntVar(&c.SyncWorkers, "sync-workers", c.SyncWorkers, "sync jobs run at the same time")
+	fs.IntVar(&c.SyncQueueSize, "sync-queue-size", c.SyncQueueSize, "sync jobs waiting for a worker before new ones are refused")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "time given to the requests and sync jobs under way on shutdown")
+	fs.IntVar(&c.CacheSize, "cache-size", c.CacheSize, "position answers kept in memory, 0 to not cache them")
+	fs.DurationVar(&c.CacheTTL, "cache-ttl", c.CacheTTL, "how long a cached position answer is used")
+	fs.StringVar(&c.JWTSecret, "jwt-secret", c.JWTSecret, "secret the HMAC signed tokens are checked with")
+	fs.StringVar(&c.JWTJWKSURL, "jwt-jwks-url", c.JWTJWKSURL, "JWKS url the public keys of the signed tokens are fetched from")
+	fs.StringVar(&c.JWTIssuer, "jwt-issuer", c.JWTIssuer, "issuer the tokens must have, any when empty")
+	fs.StringVar(&c.JWTAudience, "jwt-audience", c.JWTAudience, "audience the tokens must have, any when empty")
+	fs.StringVar(&c.JWTUserClaim, "jwt-user-claim", c.JWTUserClaim, "claim of the tokens holding the id of the socket service account")
+}
+
+// fromEnv reads the settings set in the environment.
+func (c *config) fromEnv() error {
+	var errs []error
+	str := func(name string, v *string) {
+		if raw, ok := os.LookupEnv(name); ok {
+			*v = raw
+		}
+	}
+	num := func(name string, v *int) {
+		if raw, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s must be a number, got %q", name, raw))
+			}
+			*v = n
+		}
+	}
+	duration := func(name string, v *time.Duration) {
+		if raw, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(raw)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s must be a duration (30s, 2m), got %q", name, raw))
+			}
+			*v = d
+		}
+	}
+
+	str("HTTP_ADDR", &c.Addr)
+	str("STORE", &c.Store)
+	str("DATABASE_URL", &c.DatabaseURL)
+	str("SQLITE_PATH", &c.SQLitePath)
+	str("SNAPSHOT_PATH", &c.SnapshotPath)
+	num("BULK_BATCH_SIZE", &c.BulkBatchSize)
+	str("CHESSCOM_API", &c.ChessComAPI)
+	duration("CHESSCOM_TIMEOUT", &c.ChessComTimeout)
+	str("DEFAULT_USERNAME", &c.Username)
+	str("GAME_SOURCE_URL", &c.GameSourceURL)
+	num("SOURCE_MAX_GAMES", &c.SourceMaxGames)
+	num("MAX_PLIES", &c.MaxPlies)
+	num("SYNC_WORKERS", &c.SyncWorkers)
+	num("SYNC_QUEUE_SIZE", &c.SyncQueueSize)
+	duration("SHUTDOWN_TIMEOUT", &c.ShutdownTimeout)
+	num("CACHE_SIZE", &c.CacheSize)
+	duration("CACHE_TTL", &c.CacheTTL)
+	str("JWT_SECRET", &c.JWTSecret)
+	str("JWT_JWKS_URL", &c.JWTJWKSURL)
+	str("JWT_ISSUER", &c.JWTIssuer)
+	str("JWT_AUDIENCE", &c.JWTAudience)
+	str("JWT_USER_CLAIM", &c.JWTUserClaim)
+	return errors.Join(errs...)
+}
+
+// validate checks every setting and reports all the invalid ones at once. It
+// picks the store when none is set, postgres with a DSN and memory otherwise.
+func (c *config) validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		invalid("HTTP_ADDR (-addr) must be host:port or :port, got %q", c.Addr)
+	}
+	if c.Store == "" && c.DatabaseURL != "" {
+		c.Store = "postgres"
+	}
+	switch c.Store {
+	case "", "memory":
+		c.Store = "memory"
+	case "postgres":
+		if c.DatabaseURL == "" {
+			invalid("STORE=postgres needs DATABASE_URL (-dsn)")
+		}
+	case "sqlite":
+		if c.SQLitePath == "" {
+			invalid("STORE=sqlite needs SQLITE_PATH (-sqlite-path)")
+		}
+	default:
+		invalid("STORE (-store) must be memory, postgres or sqlite, got %q", c.Store)
+	}
+	if c.SnapshotPath == "" {
+		invalid("SNAPSHOT_PATH (-snapshot-path) cannot be empty")
+	}
+	if c.BulkBatchSize < 0 {
+		invalid("BULK_BATCH_SIZE (-bulk-batch-size) cannot be negative")
+	}
+	if u, err := url.Parse(c.ChessComAPI); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		invalid("CHESSCOM_API (-chesscom-api) must be an http or https URL, got %q", c.ChessComAPI)
+	} else if !strings.HasSuffix(c.ChessComAPI, "/") {
+		c.ChessComAPI += "/"
+	}
+	if c.ChessComTimeout <= 0 {
+		invalid("CHESSCOM_TIMEOUT (-chesscom-timeout) must be positive")
+	}
+	if !chessComUsername.MatchString(c.Username) {
+		invalid("DEFAULT_USERNAME (-username) is not a chess.com username: %q", c.Username)
+	}
+	if u, err := url.Parse(c.GameSourceURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		invalid("GAME_SOURCE_URL (-game-source-url) must be an http or https URL, got %q", c.GameSourceURL)
+	}
+	if c.SourceMaxGames < 0 {
+		invalid("SOURCE_MAX_GAMES (-source-max-games) cannot be negative")
+	}
+	if c.MaxPlies < 1 {
+		invalid("MAX_PLIES (-max-plies) must be at least 1")
+	}
+	if c.SyncWorkers < 1 {
+		invalid("SYNC_WORKERS (-sync-workers) must be at least 1")
+	}
+	if c.SyncQueueSize < 1 {
+		invalid("SYNC_QUEUE_SIZE (-sync-queue-size) must be at least 1")
+	}
+	if c.ShutdownTimeout <= 0 {
+		invalid("SHUTDOWN_TIMEOUT (-shutdown-timeout) must be positive")
+	}
+	if c.CacheSize < 0 {
+		invalid("CACHE_SIZE (-cache-size) cannot be negative")
+	}
+	if c.CacheSize > 0 && c.CacheTTL <= 0 {
+		invalid("CACHE_TTL (-cache-ttl) must be positive")
+	}
+	if c.JWTSecret != "" && c.JWTJWKSURL != "" {
+		invalid("set JWT_SECRET (-jwt-secret) or JWT_JWKS_URL (-jwt-jwks-url), not both")
+	}
+	if u, err := url.Parse(c.JWTJWKSURL); c.JWTJWKSURL != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+		invalid("JWT_JWKS_URL (-jwt-jwks-url) must be an http or https URL, got %q", c.JWTJWKSURL)
+	}
+	if c.authEnabled() && c.Store == "memory" {
+		invalid("JWT_SECRET and JWT_JWKS_URL need STORE=postgres or STORE=sqlite, the memory store links no accounts to chess.com users")
+	}
+	if c.authEnabled() && c.JWTUserClaim == "" {
+		invalid("JWT_USER_CLAIM (-jwt-user-claim) cannot be empty")
+	}
+	return errors.Join(errs...)
+}
+
+// authEnabled reports whether the requests need a token, the server runs open
+// without a way to check them.
+func (c config) authEnabled() bool {
+	return c.JWTSecret != "" || c.JWTJWKSURL != ""
+}
+
+func (c config) authConfig() auth.Config {
+	return auth.Config{
+		Secret:    c.JWTSecret,
+		JWKSURL:   c.JWTJWKSURL,
+		Issuer:    c.JWTIssuer,
+		Audience:  c.JWTAudience,
+		UserClaim: c.JWTUserClaim,
+	}
+}
+
+// apply hands the settings read outside of main to their packages.
+func (c config) apply() {
+	defaultUsername = c.Username
+	utils.ChessComAPI = c.ChessComAPI
+	utils.ChessComClient.Timeout = c.ChessComTimeout
+	Processpipline.MaxPlies = c.MaxPlies
+}
+
+// loadConfig reads the server configuration, args being its flags.
+func loadConfig(args []string) (config, error) {
+	parsed := flag.NewFlagSet("server", flag.ContinueOnError)
+	file := parsed.String("config", os.Getenv("CONFIG_FILE"), "dotenv file to read the settings from, .env when it exists")
+	scratch := defaultConfig()
+	scratch.flags(parsed)
+	if err := parsed.Parse(args); err != nil {
+		return config{}, err
+	}
+	if parsed.NArg() != 0 {
+		return config{}, fmt.Errorf("unexpected argument %q", parsed.Arg(0))
+	}
+	if err := loadEnvFile(*file); err != nil {
+		return config{}, err
+	}
+
+	cfg := defaultConfig()
+	if err := cfg.fromEnv(); err != nil {
+		return config{}, fmt.Errorf("invalid config: %w", err)
+	}
+	// the flags given win over the environment, they are set again on cfg
+	final := flag.NewFlagSet("server", flag.ContinueOnError)
+	cfg.flags(final)
+	var err error
+	parsed.Visit(func(f *flag.Flag) {
+		if f.Name != "config" && err == nil {
+			err = final.Set(f.Name, f.Value.String())
+		}
+	})
+	if err != nil {
+		return config{}, err
+	}
+	if err := cfg.validate(); err != nil {
+		return config{}, fmt.Errorf("invalid config: %w", err)
+	}
+	return cfg, nil
+}
+
+// loadEnvFile sets the variables of a dotenv file that the environment does
+// not set already. Without a file it reads .env, if there is one.
+func loadEnvFile(path string) error {
+	if path != "" {
+		if err := godotenv.Load(path); err != nil {
+			return fmt.Errorf("reading the config file: %w", err)
+		}
+		return nil
+	}
+	err := godotenv.Load(defaultEnvFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("reading %s: %w", defaultEnvFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+// TestGameSourceConfig reads the settings of the /png game source from the
+// environment and the flags, the flags winning.
+func TestGameSourceConfig(t *testing.T) {
+	t.Setenv("GAME_SOURCE_URL", "http://mock:4000")
+	t.Setenv("SOURCE_MAX_GAMES", "10")
+	t.Setenv("DEFAULT_USERNAME", "tinku")
+	cfg, err := loadConfig([]string{"-config", "", "-source-max-games", "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GameSourceURL != "http://mock:4000" || cfg.SourceMaxGames != 0 || cfg.Username != "tinku" {
+		t.Errorf("source %q, %d games, user %q", cfg.GameSourceURL, cfg.SourceMaxGames, cfg.Username)
+	}
+
+	for _, invalid := range []func(*config){
+		func(c *config) { c.GameSourceURL = "localhost:3000" },
+		func(c *config) { c.SourceMaxGames = -1 },
+	} {
+		cfg := defaultConfig()
+		invalid(&cfg)
+		if err := cfg.validate(); err == nil {
+			t.Errorf("%+v was accepted", cfg)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"chess/Store"
+	"chess/Types"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultSubtreeDepth = 3
+	maxSubtreeDepth     = 10
+)
+
+// edgesQuery reads the position, user and time class of the edge routes, of
+// either color unless playerColor is given like /position/games.
+func edgesQuery(c *fiber.Ctx) (types.PositionQuery, error) {
+	fen := c.Query("fen")
+	if fen == "" {
+		return types.PositionQuery{}, errors.New("fen query param is required")
+	}
+	q := explorerQuery(c, fen)
+	q.Color = c.Query("playerColor")
+	return q, nil
+}
+
+// parentsHandler lists the moves the user's games reached a position with,
+// one per position and move, so the transpositions into it show up.
+func parentsHandler(edges store.Edges) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if edges == nil {
+			return explorerError(c, store.ErrNoUserData)
+		}
+		q, err := edgesQuery(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		parents, err := edges.Parents(c.Context(), q)
+		if errors.Is(err, store.ErrNotFound) {
+			parents, err = nil, nil
+		}
+		if err != nil {
+			return explorerError(c, err)
+		}
+		if parents == nil {
+			parents = []types.Edge{}
+		}
+		return c.Status(200).JSON(fiber.Map{
+			"fen":     q.Fen,
+			"parents": parents,
+		})
+	}
+}
+
+// subtreeHandler lists the moves played below a position down to depth
+// plies, 3 by default, the shallowest first.
+func subtreeHandler(edges store.Edges) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if edges == nil {
+			return explorerError(c, store.ErrNoUserData)
+		}
+		q, err := edgesQuery(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		depth := c.QueryInt("depth", defaultSubtreeDepth)
+		if depth < 1 || depth > maxSubtreeDepth {
+			return c.Status(400).JSON(fiber.Map{
+				"error": fmt.Sprintf("depth must be between 1 and %d", maxSubtreeDepth),
+			})
+		}
+		subtree, err := edges.Subtree(c.Context(), q, depth)
+		if errors.Is(err, store.ErrNotFound) {
+			subtree, err = nil, nil
+		}
+		if err != nil {
+			return explorerError(c, err)
+		}
+		if subtree == nil {
+			subtree = []types.Edge{}
+		}
+		return c.Status(200).JSON(fiber.Map{
+			"fen":   q.Fen,
+			"depth": depth,
+			"edges": subtree,
+		})
+	}
+}
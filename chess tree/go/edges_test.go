@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"chess/Store"
+	"chess/Types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	lib "github.com/notnil/chess"
+)
+
+const testUser = "edges-user"
+
+// testRecord plays sans from the start position into a game of testUser.
+func testRecord(t *testing.T, outcome string, sans ...string) *types.GameRecord {
+	t.Helper()
+	game := lib.NewGame()
+	var plies []types.Ply
+	for i, san := range sans {
+		parent := game.FEN()
+		if err := game.MoveStr(san); err != nil {
+			t.Fatal(err)
+		}
+		plies = append(plies, types.Ply{Number: i + 1, San: san, Parent: parent, Fen: game.FEN()})
+	}
+	id := uuid.NewString()
+	return &types.GameRecord{
+		ID:        id,
+		Username:  testUser,
+		Color:     "white",
+		Outcome:   outcome,
+		Result:    "1-0",
+		TimeClass: "blitz",
+		White:     testUser,
+		Black:     "opponent",
+		Link:      "https://www.chess.com/game/live/" + id,
+		PGN:       "*",
+		PlayedAt:  time.Unix(1_700_000_000, 0).UTC(),
+		Plies:     plies,
+	}
+}
+
+// fenAfter returns the FEN reached by sans.
+func fenAfter(t *testing.T, sans ...string) string {
+	t.Helper()
+	game := lib.NewGame()
+	for _, san := range sans {
+		if err := game.MoveStr(san); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return game.FEN()
+}
+
+// edgesApp serves the edge routes from a SQLite store holding a game of the
+// four knights opening and one transposing into it.
+func edgesApp(t *testing.T) *fiber.App {
+	t.Helper()
+	ctx := context.Background()
+	lite, err := store.NewSQLite(ctx, filepath.Join(t.TempDir(), "edges.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(lite.Close)
+	games := []*types.GameRecord{
+		testRecord(t, "win", "Nf3", "Nf6", "Nc3", "Nc6", "e4"),
+		testRecord(t, "loss", "Nc3", "Nc6", "Nf3", "Nf6", "d4"),
+	}
+	for _, rec := range games {
+		if err := lite.SaveGame(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := fiber.New()
+	app.Get("/position/parents", parentsHandler(lite))
+	app.Get("/position/subtree", subtreeHandler(lite))
+	return app
+}
+
+func getJSON(t *testing.T, app *fiber.App, target string, out any) int {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", target, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestParentsHandler(t *testing.T) {
+	app := edgesApp(t)
+	fen := fenAfter(t, "Nf3", "Nf6", "Nc3", "Nc6")
+
+	var body struct {
+		Parents []types.Edge `json:"parents"`
+	}
+	status := getJSON(t, app, "/position/parents?username="+testUser+"&fen="+url.QueryEscape(fen), &body)
+	if status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	moves := map[string]int{}
+	for _, e := range body.Parents {
+		moves[e.Move] += e.Games
+	}
+	if len(body.Parents) != 2 || moves["Nc6"] != 1 || moves["Nf6"] != 1 {
+		t.Errorf("parents = %+v, want Nc6 and Nf6 once each", body.Parents)
+	}
+
+	body.Parents = nil
+	status = getJSON(t, app, "/position/parents?username=nobody&fen="+url.QueryEscape(fen), &body)
+	if status != 200 || body.Parents == nil || len(body.Parents) != 0 {
+		t.Errorf("unknown user: status %d, parents %+v, want an empty list", status, body.Parents)
+	}
+}
+
+func TestSubtreeHandler(t *testing.T) {
+	app := edgesApp(t)
+	start := url.QueryEscape(lib.NewGame().FEN())
+
+	var body struct {
+		Depth int          `json:"depth"`
+		Edges []types.Edge `json:"edges"`
+	}
+	status := getJSON(t, app, "/position/subtree?username="+testUser+"&depth=2&fen="+start, &body)
+	if status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	// Nf3, Nc3 and the replies Nf6, Nc6
+	if body.Depth != 2 || len(body.Edges) != 4 {
+		t.Fatalf("subtree = %+v, want 4 edges 2 plies deep", body)
+	}
+	for i, e := range body.Edges {
+		if want := 1 + i/2; e.Depth != want {
+			t.Errorf("edge %s at depth %d, want %d", e.Move, e.Depth, want)
+		}
+	}
+
+	status = getJSON(t, app, "/position/subtree?username="+testUser+"&fen="+start, &body)
+	if status != 200 || body.Depth != defaultSubtreeDepth {
+		t.Errorf("default depth: status %d, depth %d", status, body.Depth)
+	}
+
+	for _, target := range []string{
+		"/position/subtree?username=" + testUser,
+		"/position/subtree?username=" + testUser + "&depth=0&fen=" + start,
+		"/position/subtree?username=" + testUser + "&depth=11&fen=" + start,
+	} {
+		if status := getJSON(t, app, target, nil); status != 400 {
+			t.Errorf("%s: status %d, want 400", target, status)
+		}
+	}
+}
+
+func TestEdgesHandlersWithoutStore(t *testing.T) {
+	app := fiber.New()
+	app.Get("/position/parents", parentsHandler(nil))
+	if status := getJSON(t, app, "/position/parents?fen=x", nil); status != 501 {
+		t.Errorf("status = %d, want 501", status)
+	}
+}
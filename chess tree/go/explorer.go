@@ -0,0 +1,452 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"chess/ProcessPipline"
+	"chess/Store"
+	"chess/Types"
+	"chess/Utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultUsername is the user the explorer answers for when the request
+// names none, the one /png syncs. It is set from the config on startup.
+var defaultUsername = "I_use_NVIM_Btw"
+
+const (
+	// recentGamesLimit is how many games /api/position lists under a
+	// position.
+	recentGamesLimit = 10
+	defaultGamesPage = 20
+	maxGamesPage     = 100
+)
+
+// The /api routes are a port of the Node backend's routes/positions.js and
+// answer with the same shapes so the UI can use either backend.
+
+type explorerStats struct {
+	TotalGames int      `json:"totalGames"`
+	Wins       int      `json:"wins"`
+	Losses     int      `json:"losses"`
+	Draws      int      `json:"draws"`
+	WinRate    *float64 `json:"winRate,omitempty"`
+}
+
+type timeClassStats struct {
+	Bullet    int `json:"bullet"`
+	Blitz     int `json:"blitz"`
+	Rapid     int `json:"rapid"`
+	Classical int `json:"classical"`
+	Daily     int `json:"daily"`
+}
+
+type explorerMove struct {
+	Move           string         `json:"move"`
+	Fen            string         `json:"fen"`
+	Stats          explorerStats  `json:"stats"`
+	TimeClassStats timeClassStats `json:"timeClassStats"`
+}
+
+func newTimeClassStats(counts map[string]int) timeClassStats {
+	return timeClassStats{
+		Bullet:    counts["bullet"],
+		Blitz:     counts["blitz"],
+		Rapid:     counts["rapid"],
+		Classical: counts["classical"],
+		Daily:     counts["daily"],
+	}
+}
+
+// explorerQuery reads the user, color and time class of an /api request,
+// userId being accepted as well since the UI sends that. The user defaults to
+// the one the request is authenticated as.
+func explorerQuery(c *fiber.Ctx, fen string) types.PositionQuery {
+	username := requestUser(c)
+	if username == "" {
+		username = defaultUsername
+	}
+	username = c.Query("username", c.Query("userId", username))
+	return types.PositionQuery{
+		Username:  username,
+		Fen:       fen,
+		Color:     c.Query("playerColor", "white"),
+		TimeClass: c.Query("timeClass"),
+	}
+}
+
+// explorerMoves lists the moves out of q's position. The counts are those of
+// q's time class while timeClassStats covers all of them, a move not played
+// in the time class is left out.
+func explorerMoves(c *fiber.Ctx, positionStore store.PositionStore, q types.PositionQuery) ([]explorerMove, error) {
+	all := q
+	all.TimeClass = ""
+	moves, err := positionStore.NextMoves(c.Context(), all)
+	if errors.Is(err, store.ErrNotFound) {
+		return []explorerMove{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	filtered := make(map[string]types.MoveStats)
+	if q.TimeClass != "" {
+		inClass, err := positionStore.NextMoves(c.Context(), q)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return nil, err
+		}
+		for _, move := range inClass {
+			filtered[move.Move] = move
+		}
+	}
+
+	next := make([]explorerMove, 0, len(moves))
+	for _, move := range moves {
+		counted := move
+		if q.TimeClass != "" {
+			var played bool
+			if counted, played = filtered[move.Move]; !played {
+				continue
+			}
+		}
+		next = append(next, explorerMove{
+			Move: move.Move,
+			Fen:  Processpipline.PositionKey(move.Fen),
+			Stats: explorerStats{
+				TotalGames: counted.Games,
+				Wins:       counted.Wins,
+				Losses:     counted.Losses,
+				Draws:      counted.Draws,
+			},
+			TimeClassStats: newTimeClassStats(move.TimeClasses),
+		})
+	}
+	return next, nil
+}
+
+// revalidate has the browser check a position answer again before using it,
+// the ETag making that a 304 while the position is the same.
+func revalidate(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "private, no-cache")
+	return c.Next()
+}
+
+// treeRootHandler answers the starting position. As black the root is the
+// sum of white's first moves, the games the user had black in.
+func treeRootHandler(positionStore store.PositionStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		root := Processpipline.PositionKey(Processpipline.StartFEN)
+		q, err := filteredQuery(c, Processpipline.StartFEN)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		nextMoves, err := explorerMoves(c, positionStore, q)
+		if err != nil {
+			return explorerError(c, err)
+		}
+
+		if q.Color == "black" {
+			var stats explorerStats
+			for _, move := range nextMoves {
+				stats.TotalGames += move.Stats.TotalGames
+				stats.Wins += move.Stats.Wins
+				stats.Losses += move.Stats.Losses
+				stats.Draws += move.Stats.Draws
+			}
+			return c.Status(200).JSON(fiber.Map{
+				"fen":         root,
+				"playerColor": "black",
+				"stats":       stats,
+				"nextMoves":   nextMoves,
+			})
+		}
+
+		q.TimeClass = ""
+		position, err := positionStore.Position(c.Context(), q)
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(200).JSON(fiber.Map{
+				"fen":         root,
+				"playerColor": q.Color,
+				"stats":       explorerStats{},
+				"nextMoves":   []explorerMove{},
+			})
+		}
+		if err != nil {
+			return explorerError(c, err)
+		}
+		return c.Status(200).JSON(fiber.Map{
+			"fen":         root,
+			"playerColor": q.Color,
+			"stats": explorerStats{
+				TotalGames: position.Games,
+				Wins:       position.Wins,
+				Losses:     position.Losses,
+				Draws:      position.Draws,
+			},
+			"timeClassStats": newTimeClassStats(position.TimeClasses),
+			"nextMoves":      nextMoves,
+		})
+	}
+}
+
+// positionHandler answers a position with its moves and the latest games
+// that reached it. moveNumber and moveSequence are those of the latest game
+// and are only known by the stores keeping the games.
+func positionHandler(positionStore store.PositionStore, explorer store.Explorer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		fen := c.Query("fen")
+		if fen == "" {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "fen query param is required",
+			})
+		}
+		return positionResponse(c, positionStore, explorer, fen)
+	}
+}
+
+// lookupHandler answers any position pasted as a FEN or an EPD like
+// positionHandler, once validated and normalized to the key the pipeline
+// stores it under. A FEN refused gets a 400 listing its problems by field.
+func lookupHandler(positionStore store.PositionStore, explorer store.Explorer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		fen := c.Query("fen", c.Query("epd"))
+		if fen == "" {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "fen query param is required",
+			})
+		}
+		key, err := Processpipline.NormalizeFEN(fen)
+		var invalid *Processpipline.FENError
+		if errors.As(err, &invalid) {
+			return c.Status(400).JSON(fiber.Map{
+				"error":    "invalid FEN",
+				"fen":      fen,
+				"problems": invalid.Problems,
+			})
+		}
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return positionResponse(c, positionStore, explorer, key)
+	}
+}
+
+func positionResponse(c *fiber.Ctx, positionStore store.PositionStore, explorer store.Explorer, fen string) error {
+	q, err := filteredQuery(c, fen)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	all := q
+	all.TimeClass = ""
+	position, err := positionStore.Position(c.Context(), all)
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{
+			"error":   "Position not found",
+			"message": "No games found where you played as " + q.Color + " and reached this position",
+			"fen":     Processpipline.PositionKey(fen),
+		})
+	}
+	if err != nil {
+		return explorerError(c, err)
+	}
+	nextMoves, err := explorerMoves(c, positionStore, q)
+	if err != nil {
+		return explorerError(c, err)
+	}
+
+	recentGames := []types.PositionGame{}
+	moveNumber, moveSequence := 0, []string{}
+	if explorer != nil {
+		page, err := explorer.PositionGames(c.Context(), types.GamesQuery{
+			PositionQuery: q,
+			Sort:          types.GamesByRecent,
+			Limit:         recentGamesLimit,
+		})
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return explorerError(c, err)
+		}
+		if err == nil && len(page.Games) > 0 {
+			recentGames = page.Games
+			moveSequence = sanSequence(recentGames[0].PGN, recentGames[0].Ply)
+			moveNumber = (len(moveSequence) + 1) / 2
+		}
+	}
+
+	var winRate float64
+	if position.Games > 0 {
+		winRate = float64(position.Wins) / float64(position.Games) * 100
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"position": fiber.Map{
+			"fen":          Processpipline.PositionKey(fen),
+			"playerColor":  q.Color,
+			"moveNumber":   moveNumber,
+			"moveSequence": moveSequence,
+			"stats": explorerStats{
+				TotalGames: position.Games,
+				Wins:       position.Wins,
+				Losses:     position.Losses,
+				Draws:      position.Draws,
+				WinRate:    &winRate,
+			},
+			"timeClassStats": newTimeClassStats(position.TimeClasses),
+		},
+		"nextMoves":   nextMoves,
+		"recentGames": recentGames,
+	})
+}
+
+// sanSequence returns the first plies moves of a PGN.
+func sanSequence(pgn string, plies int) []string {
+	sequence := []string{}
+	parts := strings.SplitN(pgn, "\n\n", 2)
+	if len(parts) < 2 {
+		return sequence
+	}
+	for _, move := range utils.ParsePgnBody(parts[1]) {
+		if len(sequence) == plies {
+			break
+		}
+		sequence = append(sequence, move.San)
+	}
+	return sequence
+}
+
+// positionGamesHandler pages through the games that reached a position, of
+// either color unless playerColor is given.
+func positionGamesHandler(explorer store.Explorer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if explorer == nil {
+			return explorerError(c, store.ErrNoUserData)
+		}
+		fen := c.Query("fen")
+		if fen == "" {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "fen query param is required",
+			})
+		}
+		filtered, err := filteredQuery(c, fen)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		q := types.GamesQuery{
+			PositionQuery: filtered,
+			Sort:          c.Query("sort", types.GamesByRecent),
+			Cursor:        c.Query("cursor"),
+			Limit:         c.QueryInt("limit", defaultGamesPage),
+		}
+		q.Color = c.Query("playerColor")
+		switch q.Sort {
+		case types.GamesByRecent, types.GamesByRating, types.GamesByResult:
+		default:
+			return c.Status(400).JSON(fiber.Map{
+				"error": "sort must be recent, rating or result",
+			})
+		}
+		if q.Limit < 1 || q.Limit > maxGamesPage {
+			return c.Status(400).JSON(fiber.Map{
+				"error": fmt.Sprintf("limit must be between 1 and %d", maxGamesPage),
+			})
+		}
+
+		page, err := explorer.PositionGames(c.Context(), q)
+		if errors.Is(err, store.ErrNotFound) {
+			page, err = &types.GamesPage{Games: []types.PositionGame{}}, nil
+		}
+		if errors.Is(err, store.ErrInvalidCursor) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err != nil {
+			return explorerError(c, err)
+		}
+		return c.Status(200).JSON(page)
+	}
+}
+
+func opponentsHandler(explorer store.Explorer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if explorer == nil {
+			return explorerError(c, store.ErrNoUserData)
+		}
+		q := explorerQuery(c, "")
+		opponents, err := explorer.Opponents(c.Context(), q.Username, q.TimeClass)
+		if errors.Is(err, store.ErrNotFound) {
+			opponents, err = []types.OpponentStats{}, nil
+		}
+		if err != nil {
+			return explorerError(c, err)
+		}
+		return c.Status(200).JSON(opponents)
+	}
+}
+
+// timeClassesHandler lists the time classes the user has games in, leaving
+// out the games chess.com did not classify.
+func timeClassesHandler(explorer store.Explorer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if explorer == nil {
+			return explorerError(c, store.ErrNoUserData)
+		}
+		counts, err := explorer.TimeClasses(c.Context(), explorerQuery(c, "").Username)
+		if errors.Is(err, store.ErrNotFound) {
+			counts, err = []types.TimeClassCount{}, nil
+		}
+		if err != nil {
+			return explorerError(c, err)
+		}
+		classified := make([]types.TimeClassCount, 0, len(counts))
+		for _, count := range counts {
+			if count.TimeClass != "" && count.TimeClass != "unknown" {
+				classified = append(classified, count)
+			}
+		}
+		return c.Status(200).JSON(classified)
+	}
+}
+
+// processingHistoryHandler lists the latest pipeline runs of one user. An
+// authenticated user only sees theirs whatever username says, the open
+// server answers for username or the default user.
+func processingHistoryHandler(guard *access, explorer store.Explorer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if explorer == nil {
+			return explorerError(c, store.ErrNoUserData)
+		}
+		limit := c.QueryInt("limit", 10)
+		if limit < 1 {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "limit must be positive",
+			})
+		}
+		username := c.Query("username", defaultUsername)
+		if guard.verifier != nil {
+			username = requestUser(c)
+		}
+		history, err := explorer.ProcessingHistory(c.Context(), username, limit)
+		if err != nil {
+			return explorerError(c, err)
+		}
+		return c.Status(200).JSON(history)
+	}
+}
+
+func explorerError(c *fiber.Ctx, err error) error {
+	status := 500
+	if errors.Is(err, store.ErrNoUserData) {
+		status = 501
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
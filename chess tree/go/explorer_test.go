@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"chess/ProcessPipline"
+	"chess/Store"
+	"chess/Types"
+	"github.com/gofiber/fiber/v2"
+)
+
+// historyExplorer records the user the processing history was asked for.
+type historyExplorer struct {
+	store.Explorer
+	username string
+}
+
+func (e *historyExplorer) ProcessingHistory(ctx context.Context, username string, limit int) ([]types.ProcessingLog, error) {
+	e.username = username
+	return []types.ProcessingLog{}, nil
+}
+
+func TestProcessingHistoryHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		guard  *access
+		target string
+		as     string
+		want   string
+	}{
+		{"open without a user", &access{}, "/processing-history", "", defaultUsername},
+		{"open for a user", &access{}, "/processing-history?username=someone", "", "someone"},
+		{"authenticated", testGuard(t), "/processing-history", "alice", "alice"},
+		{"authenticated asking for another user", testGuard(t), "/processing-history?username=bob", "alice", "alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			explorer := &historyExplorer{}
+			app := fiber.New()
+			app.Use(tt.guard.authenticate())
+			app.Get("/processing-history", processingHistoryHandler(tt.guard, explorer))
+			if status := getAs(t, app, tt.target, tt.as); status != 200 {
+				t.Fatalf("status = %d, want 200", status)
+			}
+			if explorer.username != tt.want {
+				t.Errorf("history asked for %q, want %q", explorer.username, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupHandler(t *testing.T) {
+	ctx := context.Background()
+	lite, err := store.NewSQLite(ctx, filepath.Join(t.TempDir(), "lookup.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(lite.Close)
+	for _, rec := range []*types.GameRecord{
+		testRecord(t, "win", "Nf3", "Nf6", "Nc3", "Nc6"),
+		testRecord(t, "loss", "Nc3", "Nc6", "Nf3", "Nf6"),
+	} {
+		if err := lite.SaveGame(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	app := fiber.New()
+	app.Get("/position/lookup", lookupHandler(lite, nil))
+
+	// an EPD of the position both games reach, the clocks left out
+	key := Processpipline.PositionKey(fenAfter(t, "Nf3", "Nf6", "Nc3", "Nc6"))
+	var found struct {
+		Position struct {
+			Fen   string        `json:"fen"`
+			Stats explorerStats `json:"stats"`
+		} `json:"position"`
+	}
+	target := "/position/lookup?username=" + testUser + "&epd=" + url.QueryEscape(key+" bm e4;")
+	if status := getJSON(t, app, target, &found); status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if found.Position.Fen != key || found.Position.Stats.TotalGames != 2 {
+		t.Errorf("position %+v, want %s through both games", found.Position, key)
+	}
+
+	req := httptest.NewRequest("GET", "/position/lookup?fen="+url.QueryEscape("4k3/8/8/8/8/8/8/R3K2R w KK - 0 1"), nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var refused struct {
+		Problems []Processpipline.FENProblem `json:"problems"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&refused); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 400 || len(refused.Problems) != 1 || refused.Problems[0].Field != "castling" {
+		t.Errorf("status %d, problems %+v, want 400 with the repeated castling right", resp.StatusCode, refused.Problems)
+	}
+
+	if status := getJSON(t, app, "/position/lookup", nil); status != 400 {
+		t.Errorf("without a fen: status %d, want 400", status)
+	}
+}
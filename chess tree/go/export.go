@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"chess/ProcessPipline"
+	"chess/Store"
+	"chess/Types"
+	"chess/Utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// pgnExport is what an export writes: the games reaching Fen that pass the
+// filters, with Outcome (win, loss or draw for the user) on top of them.
+type pgnExport struct {
+	types.PositionQuery
+	Outcome  string
+	Annotate bool
+}
+
+func (e pgnExport) validate() error {
+	switch e.Outcome {
+	case "", "win", "loss", "draw":
+	default:
+		return errors.New("result must be win, loss or draw")
+	}
+	if f := e.Filters; f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return errors.New("from must be before to")
+	}
+	return nil
+}
+
+// exportPGN writes the games of e to w one page at a time, newest first, and
+// returns how many it wrote. With Annotate the ply where a game reached the
+// position gets a comment.
+func exportPGN(ctx context.Context, explorer store.Explorer, e pgnExport, w io.Writer) (int, error) {
+	q := types.GamesQuery{PositionQuery: e.PositionQuery, Sort: types.GamesByRecent, Limit: maxGamesPage}
+	written := 0
+	for {
+		page, err := explorer.PositionGames(ctx, q)
+		if errors.Is(err, store.ErrNotFound) {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+		for _, game := range page.Games {
+			if e.Outcome != "" && game.Result != e.Outcome {
+				continue
+			}
+			pgn := strings.TrimSpace(game.PGN)
+			if e.Annotate {
+				pgn = utils.AnnotatePly(pgn, game.Ply, "reached "+Processpipline.PositionKey(e.Fen))
+			}
+			if _, err := io.WriteString(w, pgn+"\n\n"); err != nil {
+				return written, err
+			}
+			written++
+		}
+		if page.NextCursor == "" {
+			return written, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// pgnExportHandler streams the games reaching fen as one PGN file. It takes
+// the filters of /position/games, result to keep only the user's wins, losses
+// or draws, and annotate=true to mark where each game reached the position.
+func pgnExportHandler(explorer store.Explorer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if explorer == nil {
+			return explorerError(c, store.ErrNoUserData)
+		}
+		fen := c.Query("fen")
+		if fen == "" {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "fen query param is required",
+			})
+		}
+		filtered, err := filteredQuery(c, fen)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		filtered.Color = c.Query("playerColor")
+		e := pgnExport{
+			PositionQuery: filtered,
+			Outcome:       c.Query("result"),
+			Annotate:      c.QueryBool("annotate"),
+		}
+		if err := e.validate(); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		c.Attachment("games.pgn")
+		c.Set(fiber.HeaderContentType, "application/x-chess-pgn")
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			// the status is sent by now, a failure can only cut the file short
+			if _, err := exportPGN(context.Background(), explorer, e, w); err != nil {
+				fmt.Println("failed to export the games:", err)
+			}
+			w.Flush()
+		})
+		return nil
+	}
+}
+
+// exportPGNCommand writes the games reaching a position to a PGN file, or to
+// stdout, from the store of the server config.
+func exportPGNCommand(cfg config, args []string) error {
+	fs := flag.NewFlagSet("export-pgn", flag.ContinueOnError)
+	fen := fs.String("fen", Processpipline.StartFEN, "position the games reached")
+	username := fs.String("username", defaultUsername, "chess.com user")
+	color := fs.String("color", "", "only the games played with this color, white or black")
+	timeClass := fs.String("time-class", "", "only the games of this time class")
+	from := fs.String("from", "", "only the games played from this date or RFC 3339 time")
+	to := fs.String("to", "", "only the games played up to this date, or before this RFC 3339 time")
+	rated := fs.String("rated", "", "only the rated games with true, the casual ones with false")
+	opponent := fs.String("opponent", "", "only the games against this opponent")
+	timeControl := fs.String("time-control", "", `only the games of this time control, "180+2" for instance`)
+	opponentMin := fs.Int("opponent-min", 0, "lowest opponent rating")
+	opponentMax := fs.Int("opponent-max", 0, "highest opponent rating")
+	ratingMin := fs.Int("rating-min", 0, "lowest rating of the user")
+	ratingMax := fs.Int("rating-max", 0, "highest rating of the user")
+	result := fs.String("result", "", "only the user's wins, losses or draws: win, loss or draw")
+	annotate := fs.Bool("annotate", false, "comment the ply where each game reached the position")
+	output := fs.String("o", "", "file to write, stdout when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("usage: export-pgn [flags]")
+	}
+
+	e := pgnExport{
+		PositionQuery: types.PositionQuery{
+			Username:  *username,
+			Fen:       *fen,
+			Color:     *color,
+			TimeClass: *timeClass,
+			Filters: types.Filters{
+				OpponentMin: *opponentMin,
+				OpponentMax: *opponentMax,
+				RatingMin:   *ratingMin,
+				RatingMax:   *ratingMax,
+				Opponent:    *opponent,
+				TimeControl: *timeControl,
+			},
+		},
+		Outcome:  *result,
+		Annotate: *annotate,
+	}
+	var err error
+	if e.Filters.From, err = parseFilterTime("from", *from, false); err != nil {
+		return err
+	}
+	if e.Filters.To, err = parseFilterTime("to", *to, true); err != nil {
+		return err
+	}
+	switch *rated {
+	case "":
+	case "true", "false":
+		value := *rated == "true"
+		e.Filters.Rated = &value
+	default:
+		return errors.New("rated must be true or false")
+	}
+	if err := e.validate(); err != nil {
+		return err
+	}
+
+	positionStore, closeStore, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+	explorer, ok := positionStore.(store.Explorer)
+	if !ok {
+		return errors.New("export-pgn needs STORE=postgres or STORE=sqlite")
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		if out, err = os.Create(*output); err != nil {
+			return err
+		}
+	}
+	w := bufio.NewWriter(out)
+	written, err := exportPGN(context.Background(), explorer, e, w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if *output != "" {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			fmt.Println("wrote", written, "games to", *output)
+		}
+	}
+	return err
+}
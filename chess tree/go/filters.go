@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"chess/Types"
+	"github.com/gofiber/fiber/v2"
+)
+
+// filteredQuery is explorerQuery with the filters of the request:
+//   - from and to, a date or an RFC 3339 time, to being inclusive for a date
+//   - rated, true or false
+//   - opponent, a username, and timeControl, as chess.com writes it ("180+2")
+//   - opponentMin, opponentMax, ratingMin and ratingMax, the rating bands of
+//     the opponent and of the user
+func filteredQuery(c *fiber.Ctx, fen string) (types.PositionQuery, error) {
+	q := explorerQuery(c, fen)
+	f := &q.Filters
+	var err error
+	if f.From, err = queryTime(c, "from", false); err != nil {
+		return q, err
+	}
+	if f.To, err = queryTime(c, "to", true); err != nil {
+		return q, err
+	}
+	if rated := c.Query("rated"); rated != "" {
+		value, err := strconv.ParseBool(rated)
+		if err != nil {
+			return q, fmt.Errorf("rated must be true or false")
+		}
+		f.Rated = &value
+	}
+	f.Opponent = c.Query("opponent")
+	f.TimeControl = c.Query("timeControl")
+
+	bands := []struct {
+		name  string
+		value *int
+	}{
+		{"opponentMin", &f.OpponentMin},
+		{"opponentMax", &f.OpponentMax},
+		{"ratingMin", &f.RatingMin},
+		{"ratingMax", &f.RatingMax},
+	}
+	for _, band := range bands {
+		raw := c.Query(band.name)
+		if raw == "" {
+			continue
+		}
+		rating, err := strconv.Atoi(raw)
+		if err != nil || rating < 1 {
+			return q, fmt.Errorf("%s must be a positive rating", band.name)
+		}
+		*band.value = rating
+	}
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return q, fmt.Errorf("from must be before to")
+	}
+	return q, nil
+}
+
+// queryTime reads a date or time parameter. A date given as the end of a
+// range moves to the start of the next day so the whole day is kept.
+func queryTime(c *fiber.Ctx, name string, end bool) (*time.Time, error) {
+	return parseFilterTime(name, c.Query(name), end)
+}
+
+func parseFilterTime(name, raw string, end bool) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a date (2006-01-02) or an RFC 3339 time", name)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
@@ -1,17 +1,92 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
+	"chess/Auth"
 	"chess/ProcessPipline"
+	"chess/Store"
 	"chess/Utils"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/etag"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 )
 
+const snapshotInterval = 5 * time.Minute
+
 func main() {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		// the subcommands replay and fetch games like the server, with the
+		// same MAX_PLIES and CHESSCOM_* settings
+		cfg, err := loadConfig(nil)
+		if err == nil {
+			cfg.apply()
+			err = runCommand(cfg, os.Args[1:])
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	cfg.apply()
+
+	if err := Processpipline.LoadSnapshot(cfg.SnapshotPath); err != nil {
+		fmt.Println("failed to load the snapshot:", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.SnapshotPath), 0o755); err != nil {
+		fmt.Println("failed to create the snapshot directory:", err)
+	}
+
+	positionStore, closeStore, err := openStore(cfg)
+	if err != nil {
+		fmt.Println("failed to open the store:", err)
+		os.Exit(1)
+	}
+	defer closeStore()
+
+	// the position reads go through the cache, committing games of a user
+	// invalidates their answers
+	reads := positionStore
+	var cache *store.Cache
+	if cfg.CacheSize > 0 {
+		cache = store.NewCache(positionStore, cfg.CacheSize, cfg.CacheTTL)
+		reads = cache
+	}
+
+	guard := &access{}
+	guard.sharing, _ = positionStore.(store.Sharing)
+	guard.accounts, _ = positionStore.(store.Accounts)
+	if cfg.authEnabled() {
+		if guard.verifier, err = auth.New(cfg.authConfig()); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	} else {
+		fmt.Println("no JWT_SECRET or JWT_JWKS_URL set, the API is open to everyone")
+	}
+
 	app := fiber.New()
 	app.Use(logger.New())
+	app.Use(guard.authenticate())
 	app.Get("/", func(c *fiber.Ctx) error {
 		fmt.Println("server is up btw")
 		return c.Status(200).JSON(fiber.Map{
@@ -19,17 +94,22 @@ func main() {
 		})
 	})
 
-	app.Get("/png", func(c *fiber.Ctx) error {
+	app.Get("/png", guard.open(), func(c *fiber.Ctx) error {
 		fmt.Println("png route hitted")
 
-		usrGames, err := utils.FetchProcess()
+		usrGames, err := utils.FetchProcess(c.Context(), cfg.GameSourceURL, defaultUsername)
 		if err != nil {
 			return c.Status(400).JSON(fiber.Map{
 				"error": err.Error(),
 			})
 		}
-		username := "I_use_NVIM_Btw"
-		utils.ParseAllGames(usrGames, username)
+		err = utils.ParseAllGames(c.Context(), usrGames, defaultUsername, cfg.SourceMaxGames, positionStore)
+		cache.Invalidate(defaultUsername)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		// Processpipline.ProcessPipeline(png, moves, selectedGame)
 
 		return c.Status(200).JSON(fiber.Map{
@@ -37,7 +117,9 @@ func main() {
 		})
 	})
 
-	app.Get("/arry", func(c *fiber.Ctx) error {
+	app.Get("/arry", guard.open(), func(c *fiber.Ctx) error {
+		Processpipline.Mu.RLock()
+		defer Processpipline.Mu.RUnlock()
 		games := Processpipline.HashMap
 		fmt.Println("games:", games)
 		return c.Status(200).JSON(fiber.Map{
@@ -46,5 +128,131 @@ func main() {
 		})
 	})
 
-	app.Listen(":3030")
+	app.Post("/snapshots/:name", guard.open(), saveSnapshotHandler)
+	trees, _ := positionStore.(store.Trees)
+	users, _ := positionStore.(store.UserData)
+	app.Get("/diff", diffHandler(guard, trees))
+	app.Get("/book.bin", guard.reader(), bookHandler(guard, trees))
+	app.Get("/render/board.svg", boardImageHandler)
+	app.Get("/terminations", guard.reader(), terminationsHandler(trees))
+	app.Get("/terminations/report", guard.reader(), terminationReportHandler(trees))
+	app.Get("/accuracy", guard.reader(), accuracyHandler(trees))
+	app.Get("/accuracy/openings", guard.reader(), openingAccuracyHandler(users))
+	app.Put("/books/:name", guard.open(), uploadBookHandler)
+	app.Get("/books/:name/annotations", guard.reader(), annotationsHandler(trees, false))
+	app.Get("/books/:name/out-of-book", guard.reader(), annotationsHandler(trees, true))
+
+	app.Get(linkPath, accountHandler(guard))
+	app.Put(linkPath, linkAccountHandler(guard))
+
+	app.Get("/users/:username/export", guard.owner(), exportUserHandler(users))
+	app.Delete("/users/:username", guard.owner(), deleteUserHandler(users, cache, cfg.SnapshotPath))
+	app.Put("/users/:username/sharing", guard.owner(), sharingHandler(guard.sharing))
+
+	jobs, _ := positionStore.(store.JobStore)
+	if abandoned, err := jobs.AbandonJobs(context.Background(), "interrupted by a server restart"); err != nil {
+		fmt.Println("failed to clean up the sync jobs:", err)
+	} else if abandoned > 0 {
+		fmt.Println("marked", abandoned, "unfinished sync jobs as failed")
+	}
+	syncs := newSyncer(positionStore, jobs, cache, cfg.SyncWorkers, cfg.SyncQueueSize)
+	app.Post("/users/:username/sync", guard.owner(), syncHandler(syncs))
+	app.Get("/jobs/:id", jobHandler(guard, jobs))
+	app.Get("/jobs/:id/events", jobEventsHandler(guard, syncs, jobs))
+
+	explorer, _ := positionStore.(store.Explorer)
+	api := app.Group("/api", guard.reader())
+	api.Get("/tree/root", revalidate, etag.New(), treeRootHandler(reads))
+	api.Get("/position", revalidate, etag.New(), positionHandler(reads, explorer))
+	api.Get("/position/lookup", revalidate, etag.New(), lookupHandler(reads, explorer))
+	api.Get("/opponents", opponentsHandler(explorer))
+	api.Get("/time-classes", timeClassesHandler(explorer))
+	api.Get("/processing-history", processingHistoryHandler(guard, explorer))
+	app.Get("/position/games", guard.reader(), positionGamesHandler(explorer))
+	app.Get("/position/games.pgn", guard.reader(), pgnExportHandler(explorer))
+	edges, _ := positionStore.(store.Edges)
+	app.Get("/position/parents", guard.reader(), parentsHandler(edges))
+	app.Get("/position/subtree", guard.reader(), subtreeHandler(edges))
+	searcher, _ := positionStore.(store.Searcher)
+	app.Get("/position/search", guard.reader(), searchHandler(searcher))
+
+	stop := make(chan struct{})
+	go saveSnapshots(cfg.SnapshotPath, stop)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+		<-quit
+		fmt.Println("shutting down the server")
+		if err := app.ShutdownWithTimeout(cfg.ShutdownTimeout); err != nil {
+			fmt.Println("failed to shut the server down:", err)
+		}
+	}()
+
+	if err := app.Listen(cfg.Addr); err != nil {
+		fmt.Println("server stopped:", err)
+	}
+
+	syncs.shutdown(cfg.ShutdownTimeout)
+	if f, ok := positionStore.(store.Flusher); ok {
+		if err := f.Flush(context.Background()); err != nil {
+			fmt.Println("failed to flush the store:", err)
+		}
+	}
+	close(stop)
+	if err := Processpipline.SaveSnapshot(cfg.SnapshotPath); err != nil {
+		fmt.Println("failed to save the snapshot:", err)
+	}
+}
+
+// openStore keeps the games in the in-memory tree and in the database picked
+// by cfg.Store, which then answers the position queries:
+//   - "postgres" applies the pending schema migrations first. A BulkBatchSize
+//     switches it to the batched writer for large imports.
+//   - "sqlite" keeps everything in the local file SQLitePath.
+//   - "memory" keeps the in-memory tree only.
+func openStore(cfg config) (store.PositionStore, func(), error) {
+	memory := store.NewMemory()
+	switch cfg.Store {
+	case "memory":
+		return memory, func() {}, nil
+	case "sqlite":
+		lite, err := store.NewSQLite(context.Background(), cfg.SQLitePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return store.Chain{lite, memory}, lite.Close, nil
+	case "postgres":
+	default:
+		return nil, nil, fmt.Errorf("unknown store %q", cfg.Store)
+	}
+
+	if err := migrate(context.Background(), cfg.DatabaseURL); err != nil {
+		return nil, nil, err
+	}
+	pg, err := store.NewPostgres(context.Background(), cfg.DatabaseURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.BulkBatchSize > 0 {
+		return store.Chain{pg.Bulk(cfg.BulkBatchSize), memory}, pg.Close, nil
+	}
+	return store.Chain{pg, memory}, pg.Close, nil
+}
+
+// saveSnapshots writes the tree to path every snapshotInterval until stop is
+// closed, the final save on shutdown is done by main.
+func saveSnapshots(path string, stop <-chan struct{}) {
+	ticker := time.NewTicker(snapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := Processpipline.SaveSnapshot(path); err != nil {
+				fmt.Println("failed to save the snapshot:", err)
+			}
+		case <-stop:
+			return
+		}
+	}
 }
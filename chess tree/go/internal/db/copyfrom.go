@@ -0,0 +1,78 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: copyfrom.go
+
+package db
+
+import (
+	"context"
+)
+
+// iteratorForCopyGamePositions implements pgx.CopyFromSource.
+type iteratorForCopyGamePositions struct {
+	rows                 []CopyGamePositionsParams
+	skippedFirstNextCall bool
+}
+
+func (r *iteratorForCopyGamePositions) Next() bool {
+	if len(r.rows) == 0 {
+		return false
+	}
+	if !r.skippedFirstNextCall {
+		r.skippedFirstNextCall = true
+		return true
+	}
+	r.rows = r.rows[1:]
+	return len(r.rows) > 0
+}
+
+func (r iteratorForCopyGamePositions) Values() ([]interface{}, error) {
+	return []interface{}{
+		r.rows[0].GameID,
+		r.rows[0].Fen,
+		r.rows[0].MoveNumber,
+	}, nil
+}
+
+func (r iteratorForCopyGamePositions) Err() error {
+	return nil
+}
+
+func (q *Queries) CopyGamePositions(ctx context.Context, arg []CopyGamePositionsParams) (int64, error) {
+	return q.db.CopyFrom(ctx, []string{"game_positions"}, []string{"game_id", "fen", "move_number"}, &iteratorForCopyGamePositions{rows: arg})
+}
+
+// iteratorForCopyRebuildGamePositions implements pgx.CopyFromSource.
+type iteratorForCopyRebuildGamePositions struct {
+	rows                 []CopyRebuildGamePositionsParams
+	skippedFirstNextCall bool
+}
+
+func (r *iteratorForCopyRebuildGamePositions) Next() bool {
+	if len(r.rows) == 0 {
+		return false
+	}
+	if !r.skippedFirstNextCall {
+		r.skippedFirstNextCall = true
+		return true
+	}
+	r.rows = r.rows[1:]
+	return len(r.rows) > 0
+}
+
+func (r iteratorForCopyRebuildGamePositions) Values() ([]interface{}, error) {
+	return []interface{}{
+		r.rows[0].GameID,
+		r.rows[0].Fen,
+		r.rows[0].MoveNumber,
+	}, nil
+}
+
+func (r iteratorForCopyRebuildGamePositions) Err() error {
+	return nil
+}
+
+func (q *Queries) CopyRebuildGamePositions(ctx context.Context, arg []CopyRebuildGamePositionsParams) (int64, error) {
+	return q.db.CopyFrom(ctx, []string{"rebuild_game_positions"}, []string{"game_id", "fen", "move_number"}, &iteratorForCopyRebuildGamePositions{rows: arg})
+}
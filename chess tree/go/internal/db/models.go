@@ -0,0 +1,152 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+
+package db
+
+import (
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type AuditLog struct {
+	ID        int64              `json:"id"`
+	Action    string             `json:"action"`
+	Username  string             `json:"username"`
+	Actor     string             `json:"actor"`
+	GameCount int32              `json:"game_count"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type Game struct {
+	ID            pgtype.UUID        `json:"id"`
+	UserID        pgtype.UUID        `json:"user_id"`
+	Link          string             `json:"link"`
+	WhiteUsername string             `json:"white_username"`
+	BlackUsername string             `json:"black_username"`
+	WhiteElo      pgtype.Int4        `json:"white_elo"`
+	BlackElo      pgtype.Int4        `json:"black_elo"`
+	Result        string             `json:"result"`
+	TimeClass     string             `json:"time_class"`
+	TimeControl   pgtype.Text        `json:"time_control"`
+	Pgn           string             `json:"pgn"`
+	PlayedAt      pgtype.Timestamptz `json:"played_at"`
+	Eco           pgtype.Text        `json:"eco"`
+	Termination   pgtype.Text        `json:"termination"`
+	WhiteAccuracy pgtype.Float8      `json:"white_accuracy"`
+	BlackAccuracy pgtype.Float8      `json:"black_accuracy"`
+	CreatedAt     pgtype.Timestamp   `json:"created_at"`
+	Rated         pgtype.Bool        `json:"rated"`
+}
+
+type GamePosition struct {
+	GameID      pgtype.UUID `json:"game_id"`
+	Fen         string      `json:"fen"`
+	MoveNumber  int32       `json:"move_number"`
+	PositionKey pgtype.Text `json:"position_key"`
+}
+
+type MoveEdge struct {
+	UserID    pgtype.UUID      `json:"user_id"`
+	Color     string           `json:"color"`
+	TimeClass string           `json:"time_class"`
+	ParentKey string           `json:"parent_key"`
+	MoveSan   string           `json:"move_san"`
+	ChildKey  string           `json:"child_key"`
+	ChildFen  string           `json:"child_fen"`
+	WinCount  int32            `json:"win_count"`
+	LossCount int32            `json:"loss_count"`
+	DrawCount int32            `json:"draw_count"`
+	GameCount int32            `json:"game_count"`
+	UpdatedAt pgtype.Timestamp `json:"updated_at"`
+}
+
+type PositionStat struct {
+	ID             int64              `json:"id"`
+	Fen            string             `json:"fen"`
+	UserID         pgtype.UUID        `json:"user_id"`
+	Color          string             `json:"color"`
+	TimeClass      string             `json:"time_class"`
+	WinCount       pgtype.Int4        `json:"win_count"`
+	LossCount      pgtype.Int4        `json:"loss_count"`
+	DrawCount      pgtype.Int4        `json:"draw_count"`
+	GameCount      pgtype.Int4        `json:"game_count"`
+	LatestGameID   pgtype.UUID        `json:"latest_game_id"`
+	LatestPlayedAt pgtype.Timestamptz `json:"latest_played_at"`
+	CreatedAt      pgtype.Timestamp   `json:"created_at"`
+	UpdatedAt      pgtype.Timestamp   `json:"updated_at"`
+	PositionKey    pgtype.Text        `json:"position_key"`
+}
+
+type ProcessingLog struct {
+	ID            int64              `json:"id"`
+	Username      string             `json:"username"`
+	Status        string             `json:"status"`
+	SourceFile    pgtype.Text        `json:"source_file"`
+	ApiEndpoint   pgtype.Text        `json:"api_endpoint"`
+	GamesFromDate pgtype.Timestamptz `json:"games_from_date"`
+	GamesToDate   pgtype.Timestamptz `json:"games_to_date"`
+	StartedAt     pgtype.Timestamptz `json:"started_at"`
+	CompletedAt   pgtype.Timestamptz `json:"completed_at"`
+	TotalGames    int32              `json:"total_games"`
+	SuccessCount  int32              `json:"success_count"`
+	ErrorCount    int32              `json:"error_count"`
+	ErrorMessage  pgtype.Text        `json:"error_message"`
+}
+
+type RebuildGamePosition struct {
+	GameID     pgtype.UUID `json:"game_id"`
+	Fen        string      `json:"fen"`
+	MoveNumber int32       `json:"move_number"`
+}
+
+type RebuildMoveEdge struct {
+	UserID    pgtype.UUID `json:"user_id"`
+	Color     string      `json:"color"`
+	TimeClass string      `json:"time_class"`
+	ParentKey string      `json:"parent_key"`
+	MoveSan   string      `json:"move_san"`
+	ChildKey  string      `json:"child_key"`
+	ChildFen  string      `json:"child_fen"`
+	WinCount  int32       `json:"win_count"`
+	LossCount int32       `json:"loss_count"`
+	DrawCount int32       `json:"draw_count"`
+	GameCount int32       `json:"game_count"`
+}
+
+type RebuildPositionStat struct {
+	Fen            string             `json:"fen"`
+	UserID         pgtype.UUID        `json:"user_id"`
+	Color          string             `json:"color"`
+	TimeClass      string             `json:"time_class"`
+	WinCount       int32              `json:"win_count"`
+	LossCount      int32              `json:"loss_count"`
+	DrawCount      int32              `json:"draw_count"`
+	GameCount      int32              `json:"game_count"`
+	LatestGameID   pgtype.UUID        `json:"latest_game_id"`
+	LatestPlayedAt pgtype.Timestamptz `json:"latest_played_at"`
+}
+
+type SyncJob struct {
+	ID             pgtype.UUID        `json:"id"`
+	Username       string             `json:"username"`
+	Status         string             `json:"status"`
+	Archives       int32              `json:"archives"`
+	FetchedGames   int32              `json:"fetched_games"`
+	ProcessedGames int32              `json:"processed_games"`
+	FailedGames    int32              `json:"failed_games"`
+	Errors         []byte             `json:"errors"`
+	ErrorMessage   pgtype.Text        `json:"error_message"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+	StartedAt      pgtype.Timestamptz `json:"started_at"`
+	FinishedAt     pgtype.Timestamptz `json:"finished_at"`
+}
+
+type User struct {
+	ID               pgtype.UUID      `json:"id"`
+	ChessComUsername string           `json:"chess_com_username"`
+	CreatedAt        pgtype.Timestamp `json:"created_at"`
+	UpdatedAt        pgtype.Timestamp `json:"updated_at"`
+	Shared           bool             `json:"shared"`
+	LastArchive      pgtype.Text      `json:"last_archive"`
+	AccountID        pgtype.Text      `json:"account_id"`
+}
@@ -0,0 +1,2038 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: query.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const abandonSyncJobs = `-- name: AbandonSyncJobs :execrows
+UPDATE sync_jobs
+SET status = 'failed',
+    error_message = $1,
+    finished_at = now()
+WHERE status IN ('queued', 'running')
+`
+
+// Jobs left queued or running by a server that stopped without draining.
+func (q *Queries) AbandonSyncJobs(ctx context.Context, errorMessage pgtype.Text) (int64, error) {
+	result, err := q.db.Exec(ctx, abandonSyncJobs, errorMessage)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const applyMoveEdgeDeltas = `-- name: ApplyMoveEdgeDeltas :exec
+INSERT INTO move_edges (
+    user_id, color, time_class, parent_key, move_san, child_key, child_fen,
+    win_count, loss_count, draw_count, game_count
+)
+SELECT unnest($1::uuid[]),
+       unnest($2::text[]),
+       unnest($3::text[]),
+       unnest($4::text[]),
+       unnest($5::text[]),
+       unnest($6::text[]),
+       unnest($7::text[]),
+       unnest($8::int[]),
+       unnest($9::int[]),
+       unnest($10::int[]),
+       unnest($11::int[])
+ON CONFLICT (user_id, color, time_class, parent_key, move_san) DO UPDATE
+SET child_fen = EXCLUDED.child_fen,
+    win_count = move_edges.win_count + EXCLUDED.win_count,
+    loss_count = move_edges.loss_count + EXCLUDED.loss_count,
+    draw_count = move_edges.draw_count + EXCLUDED.draw_count,
+    game_count = move_edges.game_count + EXCLUDED.game_count,
+    updated_at = CURRENT_TIMESTAMP
+`
+
+type ApplyMoveEdgeDeltasParams struct {
+	UserIds     []pgtype.UUID `json:"user_ids"`
+	Colors      []string      `json:"colors"`
+	TimeClasses []string      `json:"time_classes"`
+	ParentKeys  []string      `json:"parent_keys"`
+	MoveSans    []string      `json:"move_sans"`
+	ChildKeys   []string      `json:"child_keys"`
+	ChildFens   []string      `json:"child_fens"`
+	WinCounts   []int32       `json:"win_counts"`
+	LossCounts  []int32       `json:"loss_counts"`
+	DrawCounts  []int32       `json:"draw_counts"`
+	GameCounts  []int32       `json:"game_counts"`
+}
+
+func (q *Queries) ApplyMoveEdgeDeltas(ctx context.Context, arg ApplyMoveEdgeDeltasParams) error {
+	_, err := q.db.Exec(ctx, applyMoveEdgeDeltas,
+		arg.UserIds,
+		arg.Colors,
+		arg.TimeClasses,
+		arg.ParentKeys,
+		arg.MoveSans,
+		arg.ChildKeys,
+		arg.ChildFens,
+		arg.WinCounts,
+		arg.LossCounts,
+		arg.DrawCounts,
+		arg.GameCounts,
+	)
+	return err
+}
+
+const applyPositionStatsDeltas = `-- name: ApplyPositionStatsDeltas :exec
+INSERT INTO position_stats (
+    fen, user_id, color, time_class, win_count, loss_count, draw_count,
+    game_count, latest_game_id, latest_played_at
+)
+SELECT unnest($1::text[]),
+       unnest($2::uuid[]),
+       unnest($3::text[]),
+       unnest($4::text[]),
+       unnest($5::int[]),
+       unnest($6::int[]),
+       unnest($7::int[]),
+       unnest($8::int[]),
+       unnest($9::uuid[]),
+       unnest($10::timestamptz[])
+ON CONFLICT (fen, user_id, color, time_class) DO UPDATE
+SET win_count = position_stats.win_count + EXCLUDED.win_count,
+    loss_count = position_stats.loss_count + EXCLUDED.loss_count,
+    draw_count = position_stats.draw_count + EXCLUDED.draw_count,
+    game_count = position_stats.game_count + EXCLUDED.game_count,
+    latest_game_id = CASE
+        WHEN position_stats.latest_played_at IS NULL
+          OR EXCLUDED.latest_played_at >= position_stats.latest_played_at
+        THEN EXCLUDED.latest_game_id
+        ELSE position_stats.latest_game_id
+    END,
+    latest_played_at = GREATEST(position_stats.latest_played_at, EXCLUDED.latest_played_at),
+    updated_at = CURRENT_TIMESTAMP
+`
+
+type ApplyPositionStatsDeltasParams struct {
+	Fens            []string             `json:"fens"`
+	UserIds         []pgtype.UUID        `json:"user_ids"`
+	Colors          []string             `json:"colors"`
+	TimeClasses     []string             `json:"time_classes"`
+	WinCounts       []int32              `json:"win_counts"`
+	LossCounts      []int32              `json:"loss_counts"`
+	DrawCounts      []int32              `json:"draw_counts"`
+	GameCounts      []int32              `json:"game_counts"`
+	LatestGameIds   []pgtype.UUID        `json:"latest_game_ids"`
+	LatestPlayedAts []pgtype.Timestamptz `json:"latest_played_ats"`
+}
+
+func (q *Queries) ApplyPositionStatsDeltas(ctx context.Context, arg ApplyPositionStatsDeltasParams) error {
+	_, err := q.db.Exec(ctx, applyPositionStatsDeltas,
+		arg.Fens,
+		arg.UserIds,
+		arg.Colors,
+		arg.TimeClasses,
+		arg.WinCounts,
+		arg.LossCounts,
+		arg.DrawCounts,
+		arg.GameCounts,
+		arg.LatestGameIds,
+		arg.LatestPlayedAts,
+	)
+	return err
+}
+
+const applyRebuildMoveEdgeDeltas = `-- name: ApplyRebuildMoveEdgeDeltas :exec
+INSERT INTO rebuild_move_edges (
+    user_id, color, time_class, parent_key, move_san, child_key, child_fen,
+    win_count, loss_count, draw_count, game_count
+)
+SELECT unnest($1::uuid[]),
+       unnest($2::text[]),
+       unnest($3::text[]),
+       unnest($4::text[]),
+       unnest($5::text[]),
+       unnest($6::text[]),
+       unnest($7::text[]),
+       unnest($8::int[]),
+       unnest($9::int[]),
+       unnest($10::int[]),
+       unnest($11::int[])
+ON CONFLICT (user_id, color, time_class, parent_key, move_san) DO UPDATE
+SET child_fen = EXCLUDED.child_fen,
+    win_count = rebuild_move_edges.win_count + EXCLUDED.win_count,
+    loss_count = rebuild_move_edges.loss_count + EXCLUDED.loss_count,
+    draw_count = rebuild_move_edges.draw_count + EXCLUDED.draw_count,
+    game_count = rebuild_move_edges.game_count + EXCLUDED.game_count
+`
+
+type ApplyRebuildMoveEdgeDeltasParams struct {
+	UserIds     []pgtype.UUID `json:"user_ids"`
+	Colors      []string      `json:"colors"`
+	TimeClasses []string      `json:"time_classes"`
+	ParentKeys  []string      `json:"parent_keys"`
+	MoveSans    []string      `json:"move_sans"`
+	ChildKeys   []string      `json:"child_keys"`
+	ChildFens   []string      `json:"child_fens"`
+	WinCounts   []int32       `json:"win_counts"`
+	LossCounts  []int32       `json:"loss_counts"`
+	DrawCounts  []int32       `json:"draw_counts"`
+	GameCounts  []int32       `json:"game_counts"`
+}
+
+func (q *Queries) ApplyRebuildMoveEdgeDeltas(ctx context.Context, arg ApplyRebuildMoveEdgeDeltasParams) error {
+	_, err := q.db.Exec(ctx, applyRebuildMoveEdgeDeltas,
+		arg.UserIds,
+		arg.Colors,
+		arg.TimeClasses,
+		arg.ParentKeys,
+		arg.MoveSans,
+		arg.ChildKeys,
+		arg.ChildFens,
+		arg.WinCounts,
+		arg.LossCounts,
+		arg.DrawCounts,
+		arg.GameCounts,
+	)
+	return err
+}
+
+const applyRebuildPositionStatsDeltas = `-- name: ApplyRebuildPositionStatsDeltas :exec
+INSERT INTO rebuild_position_stats (
+    fen, user_id, color, time_class, win_count, loss_count, draw_count,
+    game_count, latest_game_id, latest_played_at
+)
+SELECT unnest($1::text[]),
+       unnest($2::uuid[]),
+       unnest($3::text[]),
+       unnest($4::text[]),
+       unnest($5::int[]),
+       unnest($6::int[]),
+       unnest($7::int[]),
+       unnest($8::int[]),
+       unnest($9::uuid[]),
+       unnest($10::timestamptz[])
+ON CONFLICT (fen, user_id, color, time_class) DO UPDATE
+SET win_count = rebuild_position_stats.win_count + EXCLUDED.win_count,
+    loss_count = rebuild_position_stats.loss_count + EXCLUDED.loss_count,
+    draw_count = rebuild_position_stats.draw_count + EXCLUDED.draw_count,
+    game_count = rebuild_position_stats.game_count + EXCLUDED.game_count,
+    latest_game_id = CASE
+        WHEN rebuild_position_stats.latest_played_at IS NULL
+          OR EXCLUDED.latest_played_at >= rebuild_position_stats.latest_played_at
+        THEN EXCLUDED.latest_game_id
+        ELSE rebuild_position_stats.latest_game_id
+    END,
+    latest_played_at = GREATEST(rebuild_position_stats.latest_played_at, EXCLUDED.latest_played_at)
+`
+
+type ApplyRebuildPositionStatsDeltasParams struct {
+	Fens            []string             `json:"fens"`
+	UserIds         []pgtype.UUID        `json:"user_ids"`
+	Colors          []string             `json:"colors"`
+	TimeClasses     []string             `json:"time_classes"`
+	WinCounts       []int32              `json:"win_counts"`
+	LossCounts      []int32              `json:"loss_counts"`
+	DrawCounts      []int32              `json:"draw_counts"`
+	GameCounts      []int32              `json:"game_counts"`
+	LatestGameIds   []pgtype.UUID        `json:"latest_game_ids"`
+	LatestPlayedAts []pgtype.Timestamptz `json:"latest_played_ats"`
+}
+
+func (q *Queries) ApplyRebuildPositionStatsDeltas(ctx context.Context, arg ApplyRebuildPositionStatsDeltasParams) error {
+	_, err := q.db.Exec(ctx, applyRebuildPositionStatsDeltas,
+		arg.Fens,
+		arg.UserIds,
+		arg.Colors,
+		arg.TimeClasses,
+		arg.WinCounts,
+		arg.LossCounts,
+		arg.DrawCounts,
+		arg.GameCounts,
+		arg.LatestGameIds,
+		arg.LatestPlayedAts,
+	)
+	return err
+}
+
+const clearRebuildTables = `-- name: ClearRebuildTables :exec
+
+TRUNCATE rebuild_position_stats, rebuild_move_edges, rebuild_game_positions
+`
+
+// Rebuild, see Store/rebuild.go. A null user_id covers every user.
+func (q *Queries) ClearRebuildTables(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, clearRebuildTables)
+	return err
+}
+
+type CopyGamePositionsParams struct {
+	GameID     pgtype.UUID `json:"game_id"`
+	Fen        string      `json:"fen"`
+	MoveNumber int32       `json:"move_number"`
+}
+
+type CopyRebuildGamePositionsParams struct {
+	GameID     pgtype.UUID `json:"game_id"`
+	Fen        string      `json:"fen"`
+	MoveNumber int32       `json:"move_number"`
+}
+
+const countRebuildGames = `-- name: CountRebuildGames :one
+SELECT count(*) FROM games
+WHERE $1::uuid IS NULL OR user_id = $1
+`
+
+func (q *Queries) CountRebuildGames(ctx context.Context, userID pgtype.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, countRebuildGames, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countTimeClasses = `-- name: CountTimeClasses :many
+SELECT time_class, count(*)::int AS games
+FROM games
+WHERE user_id = $1
+GROUP BY time_class
+ORDER BY games DESC, time_class
+`
+
+type CountTimeClassesRow struct {
+	TimeClass string `json:"time_class"`
+	Games     int32  `json:"games"`
+}
+
+func (q *Queries) CountTimeClasses(ctx context.Context, userID pgtype.UUID) ([]CountTimeClassesRow, error) {
+	rows, err := q.db.Query(ctx, countTimeClasses, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountTimeClassesRow
+	for rows.Next() {
+		var i CountTimeClassesRow
+		if err := rows.Scan(&i.TimeClass, &i.Games); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countUserGames = `-- name: CountUserGames :one
+SELECT count(*) FROM games WHERE user_id = $1
+`
+
+func (q *Queries) CountUserGames(ctx context.Context, userID pgtype.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, countUserGames, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createSyncJob = `-- name: CreateSyncJob :exec
+INSERT INTO sync_jobs (id, username, status)
+VALUES ($1, $2, 'queued')
+`
+
+type CreateSyncJobParams struct {
+	ID       pgtype.UUID `json:"id"`
+	Username string      `json:"username"`
+}
+
+func (q *Queries) CreateSyncJob(ctx context.Context, arg CreateSyncJobParams) error {
+	_, err := q.db.Exec(ctx, createSyncJob, arg.ID, arg.Username)
+	return err
+}
+
+const deleteGamePositionsForRebuild = `-- name: DeleteGamePositionsForRebuild :exec
+DELETE FROM game_positions
+WHERE game_id IN (
+    SELECT id FROM games
+    WHERE $1::uuid IS NULL OR user_id = $1
+)
+`
+
+func (q *Queries) DeleteGamePositionsForRebuild(ctx context.Context, userID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteGamePositionsForRebuild, userID)
+	return err
+}
+
+const deleteMoveEdgesForRebuild = `-- name: DeleteMoveEdgesForRebuild :exec
+DELETE FROM move_edges
+WHERE $1::uuid IS NULL OR user_id = $1
+`
+
+func (q *Queries) DeleteMoveEdgesForRebuild(ctx context.Context, userID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteMoveEdgesForRebuild, userID)
+	return err
+}
+
+const deletePositionStatsForRebuild = `-- name: DeletePositionStatsForRebuild :exec
+DELETE FROM position_stats
+WHERE $1::uuid IS NULL OR user_id = $1
+`
+
+func (q *Queries) DeletePositionStatsForRebuild(ctx context.Context, userID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deletePositionStatsForRebuild, userID)
+	return err
+}
+
+const deleteUser = `-- name: DeleteUser :exec
+DELETE FROM users WHERE id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteUser, id)
+	return err
+}
+
+const finishProcessing = `-- name: FinishProcessing :exec
+UPDATE processing_log
+SET status = $2,
+    completed_at = now(),
+    total_games = $3,
+    success_count = $4,
+    error_count = $5,
+    error_message = $6,
+    games_from_date = $7,
+    games_to_date = $8
+WHERE id = $1
+`
+
+type FinishProcessingParams struct {
+	ID            int64              `json:"id"`
+	Status        string             `json:"status"`
+	TotalGames    int32              `json:"total_games"`
+	SuccessCount  int32              `json:"success_count"`
+	ErrorCount    int32              `json:"error_count"`
+	ErrorMessage  pgtype.Text        `json:"error_message"`
+	GamesFromDate pgtype.Timestamptz `json:"games_from_date"`
+	GamesToDate   pgtype.Timestamptz `json:"games_to_date"`
+}
+
+func (q *Queries) FinishProcessing(ctx context.Context, arg FinishProcessingParams) error {
+	_, err := q.db.Exec(ctx, finishProcessing,
+		arg.ID,
+		arg.Status,
+		arg.TotalGames,
+		arg.SuccessCount,
+		arg.ErrorCount,
+		arg.ErrorMessage,
+		arg.GamesFromDate,
+		arg.GamesToDate,
+	)
+	return err
+}
+
+const getAccountUser = `-- name: GetAccountUser :one
+SELECT chess_com_username FROM users
+WHERE account_id = $1
+`
+
+func (q *Queries) GetAccountUser(ctx context.Context, accountID pgtype.Text) (string, error) {
+	row := q.db.QueryRow(ctx, getAccountUser, accountID)
+	var chess_com_username string
+	err := row.Scan(&chess_com_username)
+	return chess_com_username, err
+}
+
+const getChildEdges = `-- name: GetChildEdges :many
+SELECT color, time_class, parent_key, move_san, child_key, child_fen,
+       win_count, loss_count, draw_count, game_count
+FROM move_edges
+WHERE user_id = $1 AND parent_key = $2
+`
+
+type GetChildEdgesParams struct {
+	UserID    pgtype.UUID `json:"user_id"`
+	ParentKey string      `json:"parent_key"`
+}
+
+type GetChildEdgesRow struct {
+	Color     string `json:"color"`
+	TimeClass string `json:"time_class"`
+	ParentKey string `json:"parent_key"`
+	MoveSan   string `json:"move_san"`
+	ChildKey  string `json:"child_key"`
+	ChildFen  string `json:"child_fen"`
+	WinCount  int32  `json:"win_count"`
+	LossCount int32  `json:"loss_count"`
+	DrawCount int32  `json:"draw_count"`
+	GameCount int32  `json:"game_count"`
+}
+
+func (q *Queries) GetChildEdges(ctx context.Context, arg GetChildEdgesParams) ([]GetChildEdgesRow, error) {
+	rows, err := q.db.Query(ctx, getChildEdges, arg.UserID, arg.ParentKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChildEdgesRow
+	for rows.Next() {
+		var i GetChildEdgesRow
+		if err := rows.Scan(
+			&i.Color,
+			&i.TimeClass,
+			&i.ParentKey,
+			&i.MoveSan,
+			&i.ChildKey,
+			&i.ChildFen,
+			&i.WinCount,
+			&i.LossCount,
+			&i.DrawCount,
+			&i.GameCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLastArchive = `-- name: GetLastArchive :one
+SELECT last_archive FROM users
+WHERE chess_com_username = $1
+`
+
+func (q *Queries) GetLastArchive(ctx context.Context, chessComUsername string) (pgtype.Text, error) {
+	row := q.db.QueryRow(ctx, getLastArchive, chessComUsername)
+	var last_archive pgtype.Text
+	err := row.Scan(&last_archive)
+	return last_archive, err
+}
+
+const getParentEdges = `-- name: GetParentEdges :many
+SELECT color, time_class, parent_key, move_san, child_key, child_fen,
+       win_count, loss_count, draw_count, game_count
+FROM move_edges
+WHERE user_id = $1 AND child_key = $2
+`
+
+type GetParentEdgesParams struct {
+	UserID   pgtype.UUID `json:"user_id"`
+	ChildKey string      `json:"child_key"`
+}
+
+type GetParentEdgesRow struct {
+	Color     string `json:"color"`
+	TimeClass string `json:"time_class"`
+	ParentKey string `json:"parent_key"`
+	MoveSan   string `json:"move_san"`
+	ChildKey  string `json:"child_key"`
+	ChildFen  string `json:"child_fen"`
+	WinCount  int32  `json:"win_count"`
+	LossCount int32  `json:"loss_count"`
+	DrawCount int32  `json:"draw_count"`
+	GameCount int32  `json:"game_count"`
+}
+
+func (q *Queries) GetParentEdges(ctx context.Context, arg GetParentEdgesParams) ([]GetParentEdgesRow, error) {
+	rows, err := q.db.Query(ctx, getParentEdges, arg.UserID, arg.ChildKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetParentEdgesRow
+	for rows.Next() {
+		var i GetParentEdgesRow
+		if err := rows.Scan(
+			&i.Color,
+			&i.TimeClass,
+			&i.ParentKey,
+			&i.MoveSan,
+			&i.ChildKey,
+			&i.ChildFen,
+			&i.WinCount,
+			&i.LossCount,
+			&i.DrawCount,
+			&i.GameCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPositionStats = `-- name: GetPositionStats :many
+SELECT fen, color, time_class, win_count, loss_count, draw_count, game_count
+FROM position_stats
+WHERE user_id = $1 AND position_key = $2
+`
+
+type GetPositionStatsParams struct {
+	UserID      pgtype.UUID `json:"user_id"`
+	PositionKey pgtype.Text `json:"position_key"`
+}
+
+type GetPositionStatsRow struct {
+	Fen       string      `json:"fen"`
+	Color     string      `json:"color"`
+	TimeClass string      `json:"time_class"`
+	WinCount  pgtype.Int4 `json:"win_count"`
+	LossCount pgtype.Int4 `json:"loss_count"`
+	DrawCount pgtype.Int4 `json:"draw_count"`
+	GameCount pgtype.Int4 `json:"game_count"`
+}
+
+// Every FEN of the position, whatever its clocks, counts towards it.
+func (q *Queries) GetPositionStats(ctx context.Context, arg GetPositionStatsParams) ([]GetPositionStatsRow, error) {
+	rows, err := q.db.Query(ctx, getPositionStats, arg.UserID, arg.PositionKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPositionStatsRow
+	for rows.Next() {
+		var i GetPositionStatsRow
+		if err := rows.Scan(
+			&i.Fen,
+			&i.Color,
+			&i.TimeClass,
+			&i.WinCount,
+			&i.LossCount,
+			&i.DrawCount,
+			&i.GameCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSubtreeEdges = `-- name: GetSubtreeEdges :many
+WITH RECURSIVE walk AS (
+    SELECT e.color, e.time_class, e.parent_key, e.move_san, e.child_key,
+           e.child_fen, e.win_count, e.loss_count, e.draw_count, e.game_count,
+           1 AS depth
+    FROM move_edges e
+    WHERE e.user_id = $1 AND e.parent_key = $2
+    UNION ALL
+    SELECT e.color, e.time_class, e.parent_key, e.move_san, e.child_key,
+           e.child_fen, e.win_count, e.loss_count, e.draw_count, e.game_count,
+           w.depth + 1
+    FROM walk w
+    JOIN move_edges e
+      ON e.user_id = $1
+     AND e.color = w.color
+     AND e.time_class = w.time_class
+     AND e.parent_key = w.child_key
+    WHERE w.depth < $3::int
+)
+SELECT color, time_class, parent_key, move_san, child_key, child_fen,
+       win_count, loss_count, draw_count, game_count, MIN(depth)::int AS depth
+FROM walk
+GROUP BY color, time_class, parent_key, move_san, child_key, child_fen,
+         win_count, loss_count, draw_count, game_count
+ORDER BY depth, game_count DESC
+`
+
+type GetSubtreeEdgesParams struct {
+	UserID   pgtype.UUID `json:"user_id"`
+	RootKey  string      `json:"root_key"`
+	MaxDepth int32       `json:"max_depth"`
+}
+
+type GetSubtreeEdgesRow struct {
+	Color     string `json:"color"`
+	TimeClass string `json:"time_class"`
+	ParentKey string `json:"parent_key"`
+	MoveSan   string `json:"move_san"`
+	ChildKey  string `json:"child_key"`
+	ChildFen  string `json:"child_fen"`
+	WinCount  int32  `json:"win_count"`
+	LossCount int32  `json:"loss_count"`
+	DrawCount int32  `json:"draw_count"`
+	GameCount int32  `json:"game_count"`
+	Depth     int32  `json:"depth"`
+}
+
+// Walks the edges down from root_key up to max_depth plies. An edge reached
+// along several move orders is returned once, at its shallowest depth.
+func (q *Queries) GetSubtreeEdges(ctx context.Context, arg GetSubtreeEdgesParams) ([]GetSubtreeEdgesRow, error) {
+	rows, err := q.db.Query(ctx, getSubtreeEdges, arg.UserID, arg.RootKey, arg.MaxDepth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetSubtreeEdgesRow
+	for rows.Next() {
+		var i GetSubtreeEdgesRow
+		if err := rows.Scan(
+			&i.Color,
+			&i.TimeClass,
+			&i.ParentKey,
+			&i.MoveSan,
+			&i.ChildKey,
+			&i.ChildFen,
+			&i.WinCount,
+			&i.LossCount,
+			&i.DrawCount,
+			&i.GameCount,
+			&i.Depth,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSyncJob = `-- name: GetSyncJob :one
+SELECT id, username, status, archives, fetched_games, processed_games, failed_games, errors, error_message, created_at, started_at, finished_at FROM sync_jobs
+WHERE id = $1
+`
+
+func (q *Queries) GetSyncJob(ctx context.Context, id pgtype.UUID) (SyncJob, error) {
+	row := q.db.QueryRow(ctx, getSyncJob, id)
+	var i SyncJob
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Status,
+		&i.Archives,
+		&i.FetchedGames,
+		&i.ProcessedGames,
+		&i.FailedGames,
+		&i.Errors,
+		&i.ErrorMessage,
+		&i.CreatedAt,
+		&i.StartedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const getUserByUsername = `-- name: GetUserByUsername :one
+SELECT id, chess_com_username, created_at, updated_at, shared, last_archive, account_id FROM users
+WHERE chess_com_username = $1
+`
+
+func (q *Queries) GetUserByUsername(ctx context.Context, chessComUsername string) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByUsername, chessComUsername)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.ChessComUsername,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Shared,
+		&i.LastArchive,
+		&i.AccountID,
+	)
+	return i, err
+}
+
+const insertAuditRecord = `-- name: InsertAuditRecord :exec
+INSERT INTO audit_log (action, username, actor, game_count)
+VALUES ($1, $2, $3, $4)
+`
+
+type InsertAuditRecordParams struct {
+	Action    string `json:"action"`
+	Username  string `json:"username"`
+	Actor     string `json:"actor"`
+	GameCount int32  `json:"game_count"`
+}
+
+func (q *Queries) InsertAuditRecord(ctx context.Context, arg InsertAuditRecordParams) error {
+	_, err := q.db.Exec(ctx, insertAuditRecord,
+		arg.Action,
+		arg.Username,
+		arg.Actor,
+		arg.GameCount,
+	)
+	return err
+}
+
+const insertGame = `-- name: InsertGame :execrows
+INSERT INTO games (
+    id, user_id, link, white_username, black_username, white_elo, black_elo,
+    result, time_class, time_control, pgn, played_at, eco, termination,
+    white_accuracy, black_accuracy, rated
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
+)
+ON CONFLICT DO NOTHING
+`
+
+type InsertGameParams struct {
+	ID            pgtype.UUID        `json:"id"`
+	UserID        pgtype.UUID        `json:"user_id"`
+	Link          string             `json:"link"`
+	WhiteUsername string             `json:"white_username"`
+	BlackUsername string             `json:"black_username"`
+	WhiteElo      pgtype.Int4        `json:"white_elo"`
+	BlackElo      pgtype.Int4        `json:"black_elo"`
+	Result        string             `json:"result"`
+	TimeClass     string             `json:"time_class"`
+	TimeControl   pgtype.Text        `json:"time_control"`
+	Pgn           string             `json:"pgn"`
+	PlayedAt      pgtype.Timestamptz `json:"played_at"`
+	Eco           pgtype.Text        `json:"eco"`
+	Termination   pgtype.Text        `json:"termination"`
+	WhiteAccuracy pgtype.Float8      `json:"white_accuracy"`
+	BlackAccuracy pgtype.Float8      `json:"black_accuracy"`
+	Rated         pgtype.Bool        `json:"rated"`
+}
+
+func (q *Queries) InsertGame(ctx context.Context, arg InsertGameParams) (int64, error) {
+	result, err := q.db.Exec(ctx, insertGame,
+		arg.ID,
+		arg.UserID,
+		arg.Link,
+		arg.WhiteUsername,
+		arg.BlackUsername,
+		arg.WhiteElo,
+		arg.BlackElo,
+		arg.Result,
+		arg.TimeClass,
+		arg.TimeControl,
+		arg.Pgn,
+		arg.PlayedAt,
+		arg.Eco,
+		arg.Termination,
+		arg.WhiteAccuracy,
+		arg.BlackAccuracy,
+		arg.Rated,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const insertGamePosition = `-- name: InsertGamePosition :exec
+INSERT INTO game_positions (game_id, fen, move_number)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING
+`
+
+type InsertGamePositionParams struct {
+	GameID     pgtype.UUID `json:"game_id"`
+	Fen        string      `json:"fen"`
+	MoveNumber int32       `json:"move_number"`
+}
+
+func (q *Queries) InsertGamePosition(ctx context.Context, arg InsertGamePositionParams) error {
+	_, err := q.db.Exec(ctx, insertGamePosition, arg.GameID, arg.Fen, arg.MoveNumber)
+	return err
+}
+
+const insertGames = `-- name: InsertGames :many
+
+INSERT INTO games (
+    id, user_id, link, white_username, black_username, white_elo, black_elo,
+    result, time_class, time_control, pgn, played_at, eco, termination,
+    white_accuracy, black_accuracy, rated
+)
+SELECT g.id, g.user_id, g.link, g.white_username, g.black_username,
+       NULLIF(g.white_elo, 0), NULLIF(g.black_elo, 0), g.result, g.time_class,
+       NULLIF(g.time_control, ''), g.pgn, g.played_at, NULLIF(g.eco, ''),
+       NULLIF(g.termination, ''), NULLIF(g.white_accuracy, 0), NULLIF(g.black_accuracy, 0),
+       g.rated
+FROM (
+    SELECT unnest($1::uuid[]) AS id,
+           unnest($2::uuid[]) AS user_id,
+           unnest($3::text[]) AS link,
+           unnest($4::text[]) AS white_username,
+           unnest($5::text[]) AS black_username,
+           unnest($6::int[]) AS white_elo,
+           unnest($7::int[]) AS black_elo,
+           unnest($8::text[]) AS result,
+           unnest($9::text[]) AS time_class,
+           unnest($10::text[]) AS time_control,
+           unnest($11::text[]) AS pgn,
+           unnest($12::timestamptz[]) AS played_at,
+           unnest($13::text[]) AS eco,
+           unnest($14::text[]) AS termination,
+           unnest($15::float8[]) AS white_accuracy,
+           unnest($16::float8[]) AS black_accuracy,
+           unnest($17::bool[]) AS rated
+) AS g
+ON CONFLICT DO NOTHING
+RETURNING id
+`
+
+type InsertGamesParams struct {
+	Ids             []pgtype.UUID        `json:"ids"`
+	UserIds         []pgtype.UUID        `json:"user_ids"`
+	Links           []string             `json:"links"`
+	WhiteUsernames  []string             `json:"white_usernames"`
+	BlackUsernames  []string             `json:"black_usernames"`
+	WhiteElos       []int32              `json:"white_elos"`
+	BlackElos       []int32              `json:"black_elos"`
+	Results         []string             `json:"results"`
+	TimeClasses     []string             `json:"time_classes"`
+	TimeControls    []string             `json:"time_controls"`
+	Pgns            []string             `json:"pgns"`
+	PlayedAts       []pgtype.Timestamptz `json:"played_ats"`
+	Ecos            []string             `json:"ecos"`
+	Terminations    []string             `json:"terminations"`
+	WhiteAccuracies []float64            `json:"white_accuracies"`
+	BlackAccuracies []float64            `json:"black_accuracies"`
+	Rated           []bool               `json:"rated"`
+}
+
+// Bulk ingestion, see Store/bulk.go. Every row of a batch is passed as
+// parallel arrays that unnest zips back together, zero values stand for NULL.
+func (q *Queries) InsertGames(ctx context.Context, arg InsertGamesParams) ([]pgtype.UUID, error) {
+	rows, err := q.db.Query(ctx, insertGames,
+		arg.Ids,
+		arg.UserIds,
+		arg.Links,
+		arg.WhiteUsernames,
+		arg.BlackUsernames,
+		arg.WhiteElos,
+		arg.BlackElos,
+		arg.Results,
+		arg.TimeClasses,
+		arg.TimeControls,
+		arg.Pgns,
+		arg.PlayedAts,
+		arg.Ecos,
+		arg.Terminations,
+		arg.WhiteAccuracies,
+		arg.BlackAccuracies,
+		arg.Rated,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []pgtype.UUID
+	for rows.Next() {
+		var id pgtype.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const linkAccount = `-- name: LinkAccount :execrows
+INSERT INTO users (chess_com_username, account_id)
+VALUES ($1, $2)
+ON CONFLICT (chess_com_username) DO UPDATE
+SET account_id = EXCLUDED.account_id, updated_at = CURRENT_TIMESTAMP
+WHERE users.account_id IS NULL OR users.account_id = EXCLUDED.account_id
+`
+
+type LinkAccountParams struct {
+	ChessComUsername string      `json:"chess_com_username"`
+	AccountID        pgtype.Text `json:"account_id"`
+}
+
+func (q *Queries) LinkAccount(ctx context.Context, arg LinkAccountParams) (int64, error) {
+	result, err := q.db.Exec(ctx, linkAccount, arg.ChessComUsername, arg.AccountID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const listFilteredPositionGames = `-- name: ListFilteredPositionGames :many
+SELECT g.id, g.result, g.time_class,
+       (lower(g.white_username) = u.chess_com_username)::bool AS user_is_white,
+       nxt.fen AS next_fen
+FROM game_positions gp
+JOIN games g ON g.id = gp.game_id
+JOIN users u ON u.id = g.user_id
+LEFT JOIN game_positions nxt ON nxt.game_id = gp.game_id AND nxt.move_number = gp.move_number + 1
+WHERE g.user_id = $1
+  AND gp.position_key = $2
+  AND ($3::text IS NULL OR g.time_class = $3)
+  AND ($4::text IS NULL
+       OR (lower(g.white_username) = u.chess_com_username) = ($4 = 'white'))
+  AND ($5::timestamptz IS NULL OR g.played_at >= $5)
+  AND ($6::timestamptz IS NULL OR g.played_at < $6)
+  AND ($7::bool IS NULL OR g.rated = $7)
+  AND ($8::text IS NULL OR g.time_control = $8)
+  AND ($9::text IS NULL OR lower(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_username ELSE g.white_username END) = lower($9))
+  AND ($10::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) >= $10)
+  AND ($11::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) <= $11)
+  AND ($12::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) >= $12)
+  AND ($13::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) <= $13)
+`
+
+type ListFilteredPositionGamesParams struct {
+	UserID      pgtype.UUID        `json:"user_id"`
+	PositionKey pgtype.Text        `json:"position_key"`
+	TimeClass   pgtype.Text        `json:"time_class"`
+	Color       pgtype.Text        `json:"color"`
+	PlayedFrom  pgtype.Timestamptz `json:"played_from"`
+	PlayedTo    pgtype.Timestamptz `json:"played_to"`
+	Rated       pgtype.Bool        `json:"rated"`
+	TimeControl pgtype.Text        `json:"time_control"`
+	Opponent    pgtype.Text        `json:"opponent"`
+	OpponentMin pgtype.Int4        `json:"opponent_min"`
+	OpponentMax pgtype.Int4        `json:"opponent_max"`
+	RatingMin   pgtype.Int4        `json:"rating_min"`
+	RatingMax   pgtype.Int4        `json:"rating_max"`
+}
+
+type ListFilteredPositionGamesRow struct {
+	ID          pgtype.UUID `json:"id"`
+	Result      string      `json:"result"`
+	TimeClass   string      `json:"time_class"`
+	UserIsWhite bool        `json:"user_is_white"`
+	NextFen     pgtype.Text `json:"next_fen"`
+}
+
+// The games reaching a position kept by the filters with the position after
+// the next ply, for the stats the filters make the precomputed ones useless
+// for. The filters are the same as in ListPositionGames.
+func (q *Queries) ListFilteredPositionGames(ctx context.Context, arg ListFilteredPositionGamesParams) ([]ListFilteredPositionGamesRow, error) {
+	rows, err := q.db.Query(ctx, listFilteredPositionGames,
+		arg.UserID,
+		arg.PositionKey,
+		arg.TimeClass,
+		arg.Color,
+		arg.PlayedFrom,
+		arg.PlayedTo,
+		arg.Rated,
+		arg.TimeControl,
+		arg.Opponent,
+		arg.OpponentMin,
+		arg.OpponentMax,
+		arg.RatingMin,
+		arg.RatingMax,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFilteredPositionGamesRow
+	for rows.Next() {
+		var i ListFilteredPositionGamesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Result,
+			&i.TimeClass,
+			&i.UserIsWhite,
+			&i.NextFen,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGamePositions = `-- name: ListGamePositions :many
+SELECT game_id, move_number, fen
+FROM game_positions
+WHERE game_id = ANY($1::uuid[])
+ORDER BY game_id, move_number
+`
+
+type ListGamePositionsRow struct {
+	GameID     pgtype.UUID `json:"game_id"`
+	MoveNumber int32       `json:"move_number"`
+	Fen        string      `json:"fen"`
+}
+
+func (q *Queries) ListGamePositions(ctx context.Context, gameIds []pgtype.UUID) ([]ListGamePositionsRow, error) {
+	rows, err := q.db.Query(ctx, listGamePositions, gameIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListGamePositionsRow
+	for rows.Next() {
+		var i ListGamePositionsRow
+		if err := rows.Scan(&i.GameID, &i.MoveNumber, &i.Fen); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPositionGames = `-- name: ListPositionGames :many
+
+WITH reached AS (
+    SELECT g.id, g.link, g.white_username, g.black_username, g.white_elo,
+           g.black_elo, g.result, g.time_class, g.played_at, g.pgn,
+           (lower(g.white_username) = u.chess_com_username)::bool AS user_is_white,
+           MIN(gp.move_number)::int AS ply
+    FROM game_positions gp
+    JOIN games g ON g.id = gp.game_id
+    JOIN users u ON u.id = g.user_id
+    WHERE g.user_id = $5
+      AND gp.position_key = $6
+      AND ($7::text IS NULL OR g.time_class = $7)
+      AND ($8::text IS NULL
+           OR (lower(g.white_username) = u.chess_com_username) = ($8 = 'white'))
+      AND ($9::timestamptz IS NULL OR g.played_at >= $9)
+      AND ($10::timestamptz IS NULL OR g.played_at < $10)
+      AND ($11::bool IS NULL OR g.rated = $11)
+      AND ($12::text IS NULL OR g.time_control = $12)
+      AND ($13::text IS NULL OR lower(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_username ELSE g.white_username END) = lower($13))
+      AND ($14::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) >= $14)
+      AND ($15::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) <= $15)
+      AND ($16::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) >= $16)
+      AND ($17::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) <= $17)
+    GROUP BY g.id, u.chess_com_username
+), keyed AS (
+    SELECT reached.id, reached.link, reached.white_username, reached.black_username, reached.white_elo, reached.black_elo, reached.result, reached.time_class, reached.played_at, reached.pgn, reached.user_is_white, reached.ply,
+           (CASE $18::text
+                WHEN 'rating' THEN COALESCE(CASE WHEN user_is_white THEN black_elo ELSE white_elo END, 0)
+                WHEN 'result' THEN CASE
+                    WHEN result = '1/2-1/2' THEN 1
+                    WHEN (result = '1-0') = user_is_white THEN 2
+                    ELSE 0 END
+                ELSE 0
+            END)::bigint AS sort_key
+    FROM reached
+)
+SELECT id, link, white_username, black_username, white_elo, black_elo, result, time_class, played_at, pgn, user_is_white, ply, sort_key FROM keyed
+WHERE $1::bigint IS NULL
+   OR sort_key < $1
+   OR (sort_key = $1
+       AND (played_at < $2::timestamptz
+            OR (played_at = $2::timestamptz AND id < $3::uuid)))
+ORDER BY sort_key DESC, played_at DESC, id DESC
+LIMIT $4
+`
+
+type ListPositionGamesParams struct {
+	AfterKey      pgtype.Int8        `json:"after_key"`
+	AfterPlayedAt pgtype.Timestamptz `json:"after_played_at"`
+	AfterID       pgtype.UUID        `json:"after_id"`
+	MaxGames      int32              `json:"max_games"`
+	UserID        pgtype.UUID        `json:"user_id"`
+	PositionKey   pgtype.Text        `json:"position_key"`
+	TimeClass     pgtype.Text        `json:"time_class"`
+	Color         pgtype.Text        `json:"color"`
+	PlayedFrom    pgtype.Timestamptz `json:"played_from"`
+	PlayedTo      pgtype.Timestamptz `json:"played_to"`
+	Rated         pgtype.Bool        `json:"rated"`
+	TimeControl   pgtype.Text        `json:"time_control"`
+	Opponent      pgtype.Text        `json:"opponent"`
+	OpponentMin   pgtype.Int4        `json:"opponent_min"`
+	OpponentMax   pgtype.Int4        `json:"opponent_max"`
+	RatingMin     pgtype.Int4        `json:"rating_min"`
+	RatingMax     pgtype.Int4        `json:"rating_max"`
+	Sort          string             `json:"sort"`
+}
+
+type ListPositionGamesRow struct {
+	ID            pgtype.UUID        `json:"id"`
+	Link          string             `json:"link"`
+	WhiteUsername string             `json:"white_username"`
+	BlackUsername string             `json:"black_username"`
+	WhiteElo      pgtype.Int4        `json:"white_elo"`
+	BlackElo      pgtype.Int4        `json:"black_elo"`
+	Result        string             `json:"result"`
+	TimeClass     string             `json:"time_class"`
+	PlayedAt      pgtype.Timestamptz `json:"played_at"`
+	Pgn           string             `json:"pgn"`
+	UserIsWhite   bool               `json:"user_is_white"`
+	Ply           int32              `json:"ply"`
+	SortKey       int64              `json:"sort_key"`
+}
+
+// Explorer, see Store/explorer.go. The user's color and result are worked
+// out from the usernames since games keeps the PGN's view of the game.
+// One page of the games that reached a position, keyset paginated on
+// (sort_key, played_at, id). sort_key is 0 for the most recent first, the
+// opponent's rating, or the user's result as 2 for a win, 1 for a draw and 0
+// for a loss.
+func (q *Queries) ListPositionGames(ctx context.Context, arg ListPositionGamesParams) ([]ListPositionGamesRow, error) {
+	rows, err := q.db.Query(ctx, listPositionGames,
+		arg.AfterKey,
+		arg.AfterPlayedAt,
+		arg.AfterID,
+		arg.MaxGames,
+		arg.UserID,
+		arg.PositionKey,
+		arg.TimeClass,
+		arg.Color,
+		arg.PlayedFrom,
+		arg.PlayedTo,
+		arg.Rated,
+		arg.TimeControl,
+		arg.Opponent,
+		arg.OpponentMin,
+		arg.OpponentMax,
+		arg.RatingMin,
+		arg.RatingMax,
+		arg.Sort,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPositionGamesRow
+	for rows.Next() {
+		var i ListPositionGamesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Link,
+			&i.WhiteUsername,
+			&i.BlackUsername,
+			&i.WhiteElo,
+			&i.BlackElo,
+			&i.Result,
+			&i.TimeClass,
+			&i.PlayedAt,
+			&i.Pgn,
+			&i.UserIsWhite,
+			&i.Ply,
+			&i.SortKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listProcessingLogs = `-- name: ListProcessingLogs :many
+SELECT id, username, status, source_file, api_endpoint, games_from_date, games_to_date, started_at, completed_at, total_games, success_count, error_count, error_message FROM processing_log
+WHERE $1::text IS NULL OR username = $1
+ORDER BY started_at DESC, id DESC
+LIMIT $2
+`
+
+type ListProcessingLogsParams struct {
+	Username pgtype.Text `json:"username"`
+	MaxRows  int32       `json:"max_rows"`
+}
+
+func (q *Queries) ListProcessingLogs(ctx context.Context, arg ListProcessingLogsParams) ([]ProcessingLog, error) {
+	rows, err := q.db.Query(ctx, listProcessingLogs, arg.Username, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ProcessingLog
+	for rows.Next() {
+		var i ProcessingLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Status,
+			&i.SourceFile,
+			&i.ApiEndpoint,
+			&i.GamesFromDate,
+			&i.GamesToDate,
+			&i.StartedAt,
+			&i.CompletedAt,
+			&i.TotalGames,
+			&i.SuccessCount,
+			&i.ErrorCount,
+			&i.ErrorMessage,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRebuildGames = `-- name: ListRebuildGames :many
+SELECT g.id, g.user_id, u.chess_com_username, g.link, g.white_username, g.black_username,
+       g.white_elo, g.black_elo, g.result, g.time_class, g.time_control, g.pgn,
+       g.played_at, g.eco, g.white_accuracy, g.black_accuracy
+FROM games g
+JOIN users u ON u.id = g.user_id
+WHERE ($1::uuid IS NULL OR g.user_id = $1)
+  AND g.id > $2
+  AND NOT EXISTS (SELECT 1 FROM rebuild_game_positions r WHERE r.game_id = g.id)
+ORDER BY g.id
+LIMIT $3
+`
+
+type ListRebuildGamesParams struct {
+	UserID    pgtype.UUID `json:"user_id"`
+	After     pgtype.UUID `json:"after"`
+	BatchSize int32       `json:"batch_size"`
+}
+
+type ListRebuildGamesRow struct {
+	ID               pgtype.UUID        `json:"id"`
+	UserID           pgtype.UUID        `json:"user_id"`
+	ChessComUsername string             `json:"chess_com_username"`
+	Link             string             `json:"link"`
+	WhiteUsername    string             `json:"white_username"`
+	BlackUsername    string             `json:"black_username"`
+	WhiteElo         pgtype.Int4        `json:"white_elo"`
+	BlackElo         pgtype.Int4        `json:"black_elo"`
+	Result           string             `json:"result"`
+	TimeClass        string             `json:"time_class"`
+	TimeControl      pgtype.Text        `json:"time_control"`
+	Pgn              string             `json:"pgn"`
+	PlayedAt         pgtype.Timestamptz `json:"played_at"`
+	Eco              pgtype.Text        `json:"eco"`
+	WhiteAccuracy    pgtype.Float8      `json:"white_accuracy"`
+	BlackAccuracy    pgtype.Float8      `json:"black_accuracy"`
+}
+
+// Games of the scope after the given id that are not in the shadow tables
+// yet, the catch up pass before the swap picks up the ones stored meanwhile.
+func (q *Queries) ListRebuildGames(ctx context.Context, arg ListRebuildGamesParams) ([]ListRebuildGamesRow, error) {
+	rows, err := q.db.Query(ctx, listRebuildGames, arg.UserID, arg.After, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListRebuildGamesRow
+	for rows.Next() {
+		var i ListRebuildGamesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ChessComUsername,
+			&i.Link,
+			&i.WhiteUsername,
+			&i.BlackUsername,
+			&i.WhiteElo,
+			&i.BlackElo,
+			&i.Result,
+			&i.TimeClass,
+			&i.TimeControl,
+			&i.Pgn,
+			&i.PlayedAt,
+			&i.Eco,
+			&i.WhiteAccuracy,
+			&i.BlackAccuracy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSearchGames = `-- name: ListSearchGames :many
+SELECT g.id, g.link, g.white_username, g.black_username, g.white_elo,
+       g.black_elo, g.result, g.time_class, g.played_at,
+       (lower(g.white_username) = u.chess_com_username)::bool AS user_is_white
+FROM games g
+JOIN users u ON u.id = g.user_id
+WHERE g.user_id = $1
+  AND ($2::timestamptz IS NULL
+       OR (g.played_at, g.id) < ($2::timestamptz, $3::uuid))
+  AND ($4::text IS NULL OR g.time_class = $4)
+  AND ($5::text IS NULL
+       OR (lower(g.white_username) = u.chess_com_username) = ($5 = 'white'))
+  AND ($6::timestamptz IS NULL OR g.played_at >= $6)
+  AND ($7::timestamptz IS NULL OR g.played_at < $7)
+  AND ($8::bool IS NULL OR g.rated = $8)
+  AND ($9::text IS NULL OR g.time_control = $9)
+  AND ($10::text IS NULL OR lower(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_username ELSE g.white_username END) = lower($10))
+  AND ($11::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) >= $11)
+  AND ($12::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) <= $12)
+  AND ($13::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) >= $13)
+  AND ($14::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) <= $14)
+ORDER BY g.played_at DESC, g.id DESC
+LIMIT $15
+`
+
+type ListSearchGamesParams struct {
+	UserID        pgtype.UUID        `json:"user_id"`
+	AfterPlayedAt pgtype.Timestamptz `json:"after_played_at"`
+	AfterID       pgtype.UUID        `json:"after_id"`
+	TimeClass     pgtype.Text        `json:"time_class"`
+	Color         pgtype.Text        `json:"color"`
+	PlayedFrom    pgtype.Timestamptz `json:"played_from"`
+	PlayedTo      pgtype.Timestamptz `json:"played_to"`
+	Rated         pgtype.Bool        `json:"rated"`
+	TimeControl   pgtype.Text        `json:"time_control"`
+	Opponent      pgtype.Text        `json:"opponent"`
+	OpponentMin   pgtype.Int4        `json:"opponent_min"`
+	OpponentMax   pgtype.Int4        `json:"opponent_max"`
+	RatingMin     pgtype.Int4        `json:"rating_min"`
+	RatingMax     pgtype.Int4        `json:"rating_max"`
+	MaxGames      int32              `json:"max_games"`
+}
+
+type ListSearchGamesRow struct {
+	ID            pgtype.UUID        `json:"id"`
+	Link          string             `json:"link"`
+	WhiteUsername string             `json:"white_username"`
+	BlackUsername string             `json:"black_username"`
+	WhiteElo      pgtype.Int4        `json:"white_elo"`
+	BlackElo      pgtype.Int4        `json:"black_elo"`
+	Result        string             `json:"result"`
+	TimeClass     string             `json:"time_class"`
+	PlayedAt      pgtype.Timestamptz `json:"played_at"`
+	UserIsWhite   bool               `json:"user_is_white"`
+}
+
+// One batch of the user's games kept by the filters for a position search,
+// newest first and keyset paginated on (played_at, id). The filters are the
+// same as in ListPositionGames.
+func (q *Queries) ListSearchGames(ctx context.Context, arg ListSearchGamesParams) ([]ListSearchGamesRow, error) {
+	rows, err := q.db.Query(ctx, listSearchGames,
+		arg.UserID,
+		arg.AfterPlayedAt,
+		arg.AfterID,
+		arg.TimeClass,
+		arg.Color,
+		arg.PlayedFrom,
+		arg.PlayedTo,
+		arg.Rated,
+		arg.TimeControl,
+		arg.Opponent,
+		arg.OpponentMin,
+		arg.OpponentMax,
+		arg.RatingMin,
+		arg.RatingMax,
+		arg.MaxGames,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSearchGamesRow
+	for rows.Next() {
+		var i ListSearchGamesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Link,
+			&i.WhiteUsername,
+			&i.BlackUsername,
+			&i.WhiteElo,
+			&i.BlackElo,
+			&i.Result,
+			&i.TimeClass,
+			&i.PlayedAt,
+			&i.UserIsWhite,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTreeGames = `-- name: ListTreeGames :many
+SELECT g.id, g.link, g.white_username, g.black_username, g.white_elo,
+       g.black_elo, g.result, g.time_class, g.time_control, g.pgn, g.played_at,
+       g.eco, g.white_accuracy, g.black_accuracy, u.chess_com_username
+FROM games g
+JOIN users u ON u.id = g.user_id
+WHERE g.user_id = $1
+  AND ($2::timestamptz IS NULL
+       OR (g.played_at, g.id) > ($2::timestamptz, $3::uuid))
+  AND ($4::text IS NULL OR g.time_class = $4)
+  AND ($5::text IS NULL
+       OR (lower(g.white_username) = u.chess_com_username) = ($5 = 'white'))
+  AND ($6::timestamptz IS NULL OR g.played_at >= $6)
+  AND ($7::timestamptz IS NULL OR g.played_at < $7)
+  AND ($8::bool IS NULL OR g.rated = $8)
+  AND ($9::text IS NULL OR g.time_control = $9)
+  AND ($10::text IS NULL OR lower(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_username ELSE g.white_username END) = lower($10))
+  AND ($11::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) >= $11)
+  AND ($12::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) <= $12)
+  AND ($13::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) >= $13)
+  AND ($14::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) <= $14)
+ORDER BY g.played_at, g.id
+LIMIT $15
+`
+
+type ListTreeGamesParams struct {
+	UserID        pgtype.UUID        `json:"user_id"`
+	AfterPlayedAt pgtype.Timestamptz `json:"after_played_at"`
+	AfterID       pgtype.UUID        `json:"after_id"`
+	TimeClass     pgtype.Text        `json:"time_class"`
+	Color         pgtype.Text        `json:"color"`
+	PlayedFrom    pgtype.Timestamptz `json:"played_from"`
+	PlayedTo      pgtype.Timestamptz `json:"played_to"`
+	Rated         pgtype.Bool        `json:"rated"`
+	TimeControl   pgtype.Text        `json:"time_control"`
+	Opponent      pgtype.Text        `json:"opponent"`
+	OpponentMin   pgtype.Int4        `json:"opponent_min"`
+	OpponentMax   pgtype.Int4        `json:"opponent_max"`
+	RatingMin     pgtype.Int4        `json:"rating_min"`
+	RatingMax     pgtype.Int4        `json:"rating_max"`
+	MaxGames      int32              `json:"max_games"`
+}
+
+type ListTreeGamesRow struct {
+	ID               pgtype.UUID        `json:"id"`
+	Link             string             `json:"link"`
+	WhiteUsername    string             `json:"white_username"`
+	BlackUsername    string             `json:"black_username"`
+	WhiteElo         pgtype.Int4        `json:"white_elo"`
+	BlackElo         pgtype.Int4        `json:"black_elo"`
+	Result           string             `json:"result"`
+	TimeClass        string             `json:"time_class"`
+	TimeControl      pgtype.Text        `json:"time_control"`
+	Pgn              string             `json:"pgn"`
+	PlayedAt         pgtype.Timestamptz `json:"played_at"`
+	Eco              pgtype.Text        `json:"eco"`
+	WhiteAccuracy    pgtype.Float8      `json:"white_accuracy"`
+	BlackAccuracy    pgtype.Float8      `json:"black_accuracy"`
+	ChessComUsername string             `json:"chess_com_username"`
+}
+
+// One batch of the user's games kept by the filters, oldest first and keyset
+// paginated on (played_at, id), with what a replay needs to rebuild their
+// tree. The filters are the same as in ListPositionGames.
+func (q *Queries) ListTreeGames(ctx context.Context, arg ListTreeGamesParams) ([]ListTreeGamesRow, error) {
+	rows, err := q.db.Query(ctx, listTreeGames,
+		arg.UserID,
+		arg.AfterPlayedAt,
+		arg.AfterID,
+		arg.TimeClass,
+		arg.Color,
+		arg.PlayedFrom,
+		arg.PlayedTo,
+		arg.Rated,
+		arg.TimeControl,
+		arg.Opponent,
+		arg.OpponentMin,
+		arg.OpponentMax,
+		arg.RatingMin,
+		arg.RatingMax,
+		arg.MaxGames,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTreeGamesRow
+	for rows.Next() {
+		var i ListTreeGamesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Link,
+			&i.WhiteUsername,
+			&i.BlackUsername,
+			&i.WhiteElo,
+			&i.BlackElo,
+			&i.Result,
+			&i.TimeClass,
+			&i.TimeControl,
+			&i.Pgn,
+			&i.PlayedAt,
+			&i.Eco,
+			&i.WhiteAccuracy,
+			&i.BlackAccuracy,
+			&i.ChessComUsername,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserGameResults = `-- name: ListUserGameResults :many
+SELECT g.white_username, g.black_username, g.white_elo, g.black_elo,
+       g.result, g.time_class, g.played_at,
+       (lower(g.white_username) = u.chess_com_username)::bool AS user_is_white
+FROM games g
+JOIN users u ON u.id = g.user_id
+WHERE g.user_id = $1
+  AND ($2::text IS NULL OR g.time_class = $2)
+`
+
+type ListUserGameResultsParams struct {
+	UserID    pgtype.UUID `json:"user_id"`
+	TimeClass pgtype.Text `json:"time_class"`
+}
+
+type ListUserGameResultsRow struct {
+	WhiteUsername string             `json:"white_username"`
+	BlackUsername string             `json:"black_username"`
+	WhiteElo      pgtype.Int4        `json:"white_elo"`
+	BlackElo      pgtype.Int4        `json:"black_elo"`
+	Result        string             `json:"result"`
+	TimeClass     string             `json:"time_class"`
+	PlayedAt      pgtype.Timestamptz `json:"played_at"`
+	UserIsWhite   bool               `json:"user_is_white"`
+}
+
+func (q *Queries) ListUserGameResults(ctx context.Context, arg ListUserGameResultsParams) ([]ListUserGameResultsRow, error) {
+	rows, err := q.db.Query(ctx, listUserGameResults, arg.UserID, arg.TimeClass)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserGameResultsRow
+	for rows.Next() {
+		var i ListUserGameResultsRow
+		if err := rows.Scan(
+			&i.WhiteUsername,
+			&i.BlackUsername,
+			&i.WhiteElo,
+			&i.BlackElo,
+			&i.Result,
+			&i.TimeClass,
+			&i.PlayedAt,
+			&i.UserIsWhite,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserGames = `-- name: ListUserGames :many
+
+SELECT id, link, white_username, black_username, white_elo, black_elo, result,
+       time_class, time_control, pgn, played_at, eco, termination,
+       white_accuracy, black_accuracy
+FROM games
+WHERE user_id = $1
+ORDER BY played_at, id
+`
+
+type ListUserGamesRow struct {
+	ID            pgtype.UUID        `json:"id"`
+	Link          string             `json:"link"`
+	WhiteUsername string             `json:"white_username"`
+	BlackUsername string             `json:"black_username"`
+	WhiteElo      pgtype.Int4        `json:"white_elo"`
+	BlackElo      pgtype.Int4        `json:"black_elo"`
+	Result        string             `json:"result"`
+	TimeClass     string             `json:"time_class"`
+	TimeControl   pgtype.Text        `json:"time_control"`
+	Pgn           string             `json:"pgn"`
+	PlayedAt      pgtype.Timestamptz `json:"played_at"`
+	Eco           pgtype.Text        `json:"eco"`
+	Termination   pgtype.Text        `json:"termination"`
+	WhiteAccuracy pgtype.Float8      `json:"white_accuracy"`
+	BlackAccuracy pgtype.Float8      `json:"black_accuracy"`
+}
+
+// User export and deletion, see Store/users.go.
+func (q *Queries) ListUserGames(ctx context.Context, userID pgtype.UUID) ([]ListUserGamesRow, error) {
+	rows, err := q.db.Query(ctx, listUserGames, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserGamesRow
+	for rows.Next() {
+		var i ListUserGamesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Link,
+			&i.WhiteUsername,
+			&i.BlackUsername,
+			&i.WhiteElo,
+			&i.BlackElo,
+			&i.Result,
+			&i.TimeClass,
+			&i.TimeControl,
+			&i.Pgn,
+			&i.PlayedAt,
+			&i.Eco,
+			&i.Termination,
+			&i.WhiteAccuracy,
+			&i.BlackAccuracy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserMoveEdges = `-- name: ListUserMoveEdges :many
+SELECT color, time_class, parent_key, move_san, child_key, child_fen,
+       win_count, loss_count, draw_count, game_count
+FROM move_edges
+WHERE user_id = $1
+ORDER BY parent_key, move_san, color, time_class
+`
+
+type ListUserMoveEdgesRow struct {
+	Color     string `json:"color"`
+	TimeClass string `json:"time_class"`
+	ParentKey string `json:"parent_key"`
+	MoveSan   string `json:"move_san"`
+	ChildKey  string `json:"child_key"`
+	ChildFen  string `json:"child_fen"`
+	WinCount  int32  `json:"win_count"`
+	LossCount int32  `json:"loss_count"`
+	DrawCount int32  `json:"draw_count"`
+	GameCount int32  `json:"game_count"`
+}
+
+func (q *Queries) ListUserMoveEdges(ctx context.Context, userID pgtype.UUID) ([]ListUserMoveEdgesRow, error) {
+	rows, err := q.db.Query(ctx, listUserMoveEdges, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserMoveEdgesRow
+	for rows.Next() {
+		var i ListUserMoveEdgesRow
+		if err := rows.Scan(
+			&i.Color,
+			&i.TimeClass,
+			&i.ParentKey,
+			&i.MoveSan,
+			&i.ChildKey,
+			&i.ChildFen,
+			&i.WinCount,
+			&i.LossCount,
+			&i.DrawCount,
+			&i.GameCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserPositionStats = `-- name: ListUserPositionStats :many
+SELECT fen, color, time_class, win_count, loss_count, draw_count, game_count
+FROM position_stats
+WHERE user_id = $1
+ORDER BY fen, color, time_class
+`
+
+type ListUserPositionStatsRow struct {
+	Fen       string      `json:"fen"`
+	Color     string      `json:"color"`
+	TimeClass string      `json:"time_class"`
+	WinCount  pgtype.Int4 `json:"win_count"`
+	LossCount pgtype.Int4 `json:"loss_count"`
+	DrawCount pgtype.Int4 `json:"draw_count"`
+	GameCount pgtype.Int4 `json:"game_count"`
+}
+
+func (q *Queries) ListUserPositionStats(ctx context.Context, userID pgtype.UUID) ([]ListUserPositionStatsRow, error) {
+	rows, err := q.db.Query(ctx, listUserPositionStats, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserPositionStatsRow
+	for rows.Next() {
+		var i ListUserPositionStatsRow
+		if err := rows.Scan(
+			&i.Fen,
+			&i.Color,
+			&i.TimeClass,
+			&i.WinCount,
+			&i.LossCount,
+			&i.DrawCount,
+			&i.GameCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setLastArchive = `-- name: SetLastArchive :exec
+INSERT INTO users (chess_com_username, last_archive)
+VALUES ($1, $2)
+ON CONFLICT (chess_com_username) DO UPDATE
+SET last_archive = EXCLUDED.last_archive, updated_at = CURRENT_TIMESTAMP
+`
+
+type SetLastArchiveParams struct {
+	ChessComUsername string      `json:"chess_com_username"`
+	LastArchive      pgtype.Text `json:"last_archive"`
+}
+
+func (q *Queries) SetLastArchive(ctx context.Context, arg SetLastArchiveParams) error {
+	_, err := q.db.Exec(ctx, setLastArchive, arg.ChessComUsername, arg.LastArchive)
+	return err
+}
+
+const setUserShared = `-- name: SetUserShared :execrows
+UPDATE users SET shared = $2, updated_at = CURRENT_TIMESTAMP
+WHERE chess_com_username = $1
+`
+
+type SetUserSharedParams struct {
+	ChessComUsername string `json:"chess_com_username"`
+	Shared           bool   `json:"shared"`
+}
+
+func (q *Queries) SetUserShared(ctx context.Context, arg SetUserSharedParams) (int64, error) {
+	result, err := q.db.Exec(ctx, setUserShared, arg.ChessComUsername, arg.Shared)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const startProcessing = `-- name: StartProcessing :one
+INSERT INTO processing_log (username, status, source_file, api_endpoint, games_from_date, games_to_date)
+VALUES ($1, 'processing', $2, $3, $4, $5)
+RETURNING id
+`
+
+type StartProcessingParams struct {
+	Username      string             `json:"username"`
+	SourceFile    pgtype.Text        `json:"source_file"`
+	ApiEndpoint   pgtype.Text        `json:"api_endpoint"`
+	GamesFromDate pgtype.Timestamptz `json:"games_from_date"`
+	GamesToDate   pgtype.Timestamptz `json:"games_to_date"`
+}
+
+func (q *Queries) StartProcessing(ctx context.Context, arg StartProcessingParams) (int64, error) {
+	row := q.db.QueryRow(ctx, startProcessing,
+		arg.Username,
+		arg.SourceFile,
+		arg.ApiEndpoint,
+		arg.GamesFromDate,
+		arg.GamesToDate,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const swapInGamePositions = `-- name: SwapInGamePositions :exec
+INSERT INTO game_positions (game_id, fen, move_number)
+SELECT game_id, fen, move_number FROM rebuild_game_positions
+`
+
+func (q *Queries) SwapInGamePositions(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, swapInGamePositions)
+	return err
+}
+
+const swapInMoveEdges = `-- name: SwapInMoveEdges :exec
+INSERT INTO move_edges (
+    user_id, color, time_class, parent_key, move_san, child_key, child_fen,
+    win_count, loss_count, draw_count, game_count
+)
+SELECT user_id, color, time_class, parent_key, move_san, child_key, child_fen,
+       win_count, loss_count, draw_count, game_count
+FROM rebuild_move_edges
+`
+
+func (q *Queries) SwapInMoveEdges(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, swapInMoveEdges)
+	return err
+}
+
+const swapInPositionStats = `-- name: SwapInPositionStats :exec
+INSERT INTO position_stats (
+    fen, user_id, color, time_class, win_count, loss_count, draw_count,
+    game_count, latest_game_id, latest_played_at
+)
+SELECT fen, user_id, color, time_class, win_count, loss_count, draw_count,
+       game_count, latest_game_id, latest_played_at
+FROM rebuild_position_stats
+`
+
+func (q *Queries) SwapInPositionStats(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, swapInPositionStats)
+	return err
+}
+
+const updateSyncJob = `-- name: UpdateSyncJob :exec
+UPDATE sync_jobs
+SET status = $2,
+    archives = $3,
+    fetched_games = $4,
+    processed_games = $5,
+    failed_games = $6,
+    errors = $7,
+    error_message = $8,
+    started_at = $9,
+    finished_at = $10
+WHERE id = $1
+`
+
+type UpdateSyncJobParams struct {
+	ID             pgtype.UUID        `json:"id"`
+	Status         string             `json:"status"`
+	Archives       int32              `json:"archives"`
+	FetchedGames   int32              `json:"fetched_games"`
+	ProcessedGames int32              `json:"processed_games"`
+	FailedGames    int32              `json:"failed_games"`
+	Errors         []byte             `json:"errors"`
+	ErrorMessage   pgtype.Text        `json:"error_message"`
+	StartedAt      pgtype.Timestamptz `json:"started_at"`
+	FinishedAt     pgtype.Timestamptz `json:"finished_at"`
+}
+
+func (q *Queries) UpdateSyncJob(ctx context.Context, arg UpdateSyncJobParams) error {
+	_, err := q.db.Exec(ctx, updateSyncJob,
+		arg.ID,
+		arg.Status,
+		arg.Archives,
+		arg.FetchedGames,
+		arg.ProcessedGames,
+		arg.FailedGames,
+		arg.Errors,
+		arg.ErrorMessage,
+		arg.StartedAt,
+		arg.FinishedAt,
+	)
+	return err
+}
+
+const upsertMoveEdge = `-- name: UpsertMoveEdge :exec
+INSERT INTO move_edges (
+    user_id, color, time_class, parent_key, move_san, child_key, child_fen,
+    win_count, loss_count, draw_count, game_count
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 1
+)
+ON CONFLICT (user_id, color, time_class, parent_key, move_san) DO UPDATE
+SET child_fen = EXCLUDED.child_fen,
+    win_count = move_edges.win_count + EXCLUDED.win_count,
+    loss_count = move_edges.loss_count + EXCLUDED.loss_count,
+    draw_count = move_edges.draw_count + EXCLUDED.draw_count,
+    game_count = move_edges.game_count + 1,
+    updated_at = CURRENT_TIMESTAMP
+`
+
+type UpsertMoveEdgeParams struct {
+	UserID    pgtype.UUID `json:"user_id"`
+	Color     string      `json:"color"`
+	TimeClass string      `json:"time_class"`
+	ParentKey string      `json:"parent_key"`
+	MoveSan   string      `json:"move_san"`
+	ChildKey  string      `json:"child_key"`
+	ChildFen  string      `json:"child_fen"`
+	WinCount  int32       `json:"win_count"`
+	LossCount int32       `json:"loss_count"`
+	DrawCount int32       `json:"draw_count"`
+}
+
+func (q *Queries) UpsertMoveEdge(ctx context.Context, arg UpsertMoveEdgeParams) error {
+	_, err := q.db.Exec(ctx, upsertMoveEdge,
+		arg.UserID,
+		arg.Color,
+		arg.TimeClass,
+		arg.ParentKey,
+		arg.MoveSan,
+		arg.ChildKey,
+		arg.ChildFen,
+		arg.WinCount,
+		arg.LossCount,
+		arg.DrawCount,
+	)
+	return err
+}
+
+const upsertPositionStats = `-- name: UpsertPositionStats :exec
+INSERT INTO position_stats (
+    fen, user_id, color, time_class, win_count, loss_count, draw_count,
+    game_count, latest_game_id, latest_played_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, 1, $8, $9
+)
+ON CONFLICT (fen, user_id, color, time_class) DO UPDATE
+SET win_count = position_stats.win_count + EXCLUDED.win_count,
+    loss_count = position_stats.loss_count + EXCLUDED.loss_count,
+    draw_count = position_stats.draw_count + EXCLUDED.draw_count,
+    game_count = position_stats.game_count + 1,
+    latest_game_id = CASE
+        WHEN position_stats.latest_played_at IS NULL
+          OR EXCLUDED.latest_played_at >= position_stats.latest_played_at
+        THEN EXCLUDED.latest_game_id
+        ELSE position_stats.latest_game_id
+    END,
+    latest_played_at = GREATEST(position_stats.latest_played_at, EXCLUDED.latest_played_at),
+    updated_at = CURRENT_TIMESTAMP
+`
+
+type UpsertPositionStatsParams struct {
+	Fen            string             `json:"fen"`
+	UserID         pgtype.UUID        `json:"user_id"`
+	Color          string             `json:"color"`
+	TimeClass      string             `json:"time_class"`
+	WinCount       pgtype.Int4        `json:"win_count"`
+	LossCount      pgtype.Int4        `json:"loss_count"`
+	DrawCount      pgtype.Int4        `json:"draw_count"`
+	LatestGameID   pgtype.UUID        `json:"latest_game_id"`
+	LatestPlayedAt pgtype.Timestamptz `json:"latest_played_at"`
+}
+
+func (q *Queries) UpsertPositionStats(ctx context.Context, arg UpsertPositionStatsParams) error {
+	_, err := q.db.Exec(ctx, upsertPositionStats,
+		arg.Fen,
+		arg.UserID,
+		arg.Color,
+		arg.TimeClass,
+		arg.WinCount,
+		arg.LossCount,
+		arg.DrawCount,
+		arg.LatestGameID,
+		arg.LatestPlayedAt,
+	)
+	return err
+}
+
+const upsertUser = `-- name: UpsertUser :one
+INSERT INTO users (chess_com_username)
+VALUES ($1)
+ON CONFLICT (chess_com_username) DO UPDATE
+SET updated_at = CURRENT_TIMESTAMP
+RETURNING id
+`
+
+func (q *Queries) UpsertUser(ctx context.Context, chessComUsername string) (pgtype.UUID, error) {
+	row := q.db.QueryRow(ctx, upsertUser, chessComUsername)
+	var id pgtype.UUID
+	err := row.Scan(&id)
+	return id, err
+}
@@ -0,0 +1,119 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+
+package sqlitedb
+
+import (
+	"database/sql"
+)
+
+type AuditLog struct {
+	ID        int64  `json:"id"`
+	Action    string `json:"action"`
+	Username  string `json:"username"`
+	Actor     string `json:"actor"`
+	GameCount int64  `json:"game_count"`
+	CreatedAt string `json:"created_at"`
+}
+
+type Game struct {
+	ID            string          `json:"id"`
+	UserID        string          `json:"user_id"`
+	Link          string          `json:"link"`
+	WhiteUsername string          `json:"white_username"`
+	BlackUsername string          `json:"black_username"`
+	WhiteElo      sql.NullInt64   `json:"white_elo"`
+	BlackElo      sql.NullInt64   `json:"black_elo"`
+	Result        string          `json:"result"`
+	TimeClass     string          `json:"time_class"`
+	TimeControl   sql.NullString  `json:"time_control"`
+	Pgn           string          `json:"pgn"`
+	PlayedAt      string          `json:"played_at"`
+	Eco           sql.NullString  `json:"eco"`
+	Termination   sql.NullString  `json:"termination"`
+	WhiteAccuracy sql.NullFloat64 `json:"white_accuracy"`
+	BlackAccuracy sql.NullFloat64 `json:"black_accuracy"`
+	CreatedAt     sql.NullString  `json:"created_at"`
+	Rated         sql.NullInt64   `json:"rated"`
+}
+
+type GamePosition struct {
+	GameID      string         `json:"game_id"`
+	Fen         string         `json:"fen"`
+	MoveNumber  int64          `json:"move_number"`
+	PositionKey sql.NullString `json:"position_key"`
+}
+
+type MoveEdge struct {
+	UserID    string         `json:"user_id"`
+	Color     string         `json:"color"`
+	TimeClass string         `json:"time_class"`
+	ParentKey string         `json:"parent_key"`
+	MoveSan   string         `json:"move_san"`
+	ChildKey  string         `json:"child_key"`
+	ChildFen  string         `json:"child_fen"`
+	WinCount  int64          `json:"win_count"`
+	LossCount int64          `json:"loss_count"`
+	DrawCount int64          `json:"draw_count"`
+	GameCount int64          `json:"game_count"`
+	UpdatedAt sql.NullString `json:"updated_at"`
+}
+
+type PositionStat struct {
+	ID             int64          `json:"id"`
+	Fen            string         `json:"fen"`
+	UserID         string         `json:"user_id"`
+	Color          string         `json:"color"`
+	TimeClass      string         `json:"time_class"`
+	WinCount       int64          `json:"win_count"`
+	LossCount      int64          `json:"loss_count"`
+	DrawCount      int64          `json:"draw_count"`
+	GameCount      int64          `json:"game_count"`
+	LatestGameID   sql.NullString `json:"latest_game_id"`
+	LatestPlayedAt sql.NullString `json:"latest_played_at"`
+	CreatedAt      sql.NullString `json:"created_at"`
+	UpdatedAt      sql.NullString `json:"updated_at"`
+	PositionKey    sql.NullString `json:"position_key"`
+}
+
+type ProcessingLog struct {
+	ID            int64          `json:"id"`
+	Username      string         `json:"username"`
+	Status        string         `json:"status"`
+	SourceFile    sql.NullString `json:"source_file"`
+	ApiEndpoint   sql.NullString `json:"api_endpoint"`
+	GamesFromDate sql.NullString `json:"games_from_date"`
+	GamesToDate   sql.NullString `json:"games_to_date"`
+	StartedAt     string         `json:"started_at"`
+	CompletedAt   sql.NullString `json:"completed_at"`
+	TotalGames    int64          `json:"total_games"`
+	SuccessCount  int64          `json:"success_count"`
+	ErrorCount    int64          `json:"error_count"`
+	ErrorMessage  sql.NullString `json:"error_message"`
+}
+
+type SyncJob struct {
+	ID             string         `json:"id"`
+	Username       string         `json:"username"`
+	Status         string         `json:"status"`
+	Archives       int64          `json:"archives"`
+	FetchedGames   int64          `json:"fetched_games"`
+	ProcessedGames int64          `json:"processed_games"`
+	FailedGames    int64          `json:"failed_games"`
+	Errors         string         `json:"errors"`
+	ErrorMessage   sql.NullString `json:"error_message"`
+	CreatedAt      string         `json:"created_at"`
+	StartedAt      sql.NullString `json:"started_at"`
+	FinishedAt     sql.NullString `json:"finished_at"`
+}
+
+type User struct {
+	ID               string         `json:"id"`
+	ChessComUsername string         `json:"chess_com_username"`
+	CreatedAt        sql.NullString `json:"created_at"`
+	UpdatedAt        sql.NullString `json:"updated_at"`
+	Shared           int64          `json:"shared"`
+	LastArchive      sql.NullString `json:"last_archive"`
+	AccountID        sql.NullString `json:"account_id"`
+}
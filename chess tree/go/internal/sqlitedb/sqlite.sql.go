@@ -0,0 +1,1511 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: sqlite.sql
+
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+const abandonSyncJobs = `-- name: AbandonSyncJobs :execrows
+UPDATE sync_jobs
+SET status = 'failed',
+    error_message = ?,
+    finished_at = ?
+WHERE status IN ('queued', 'running')
+`
+
+type AbandonSyncJobsParams struct {
+	ErrorMessage sql.NullString `json:"error_message"`
+	FinishedAt   sql.NullString `json:"finished_at"`
+}
+
+// Jobs left queued or running by a server that stopped without draining.
+func (q *Queries) AbandonSyncJobs(ctx context.Context, arg AbandonSyncJobsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, abandonSyncJobs, arg.ErrorMessage, arg.FinishedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const countTimeClasses = `-- name: CountTimeClasses :many
+SELECT time_class, CAST(count(*) AS INTEGER) AS games
+FROM games
+WHERE user_id = ?
+GROUP BY time_class
+ORDER BY games DESC, time_class
+`
+
+type CountTimeClassesRow struct {
+	TimeClass string `json:"time_class"`
+	Games     int64  `json:"games"`
+}
+
+func (q *Queries) CountTimeClasses(ctx context.Context, userID string) ([]CountTimeClassesRow, error) {
+	rows, err := q.db.QueryContext(ctx, countTimeClasses, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountTimeClassesRow
+	for rows.Next() {
+		var i CountTimeClassesRow
+		if err := rows.Scan(&i.TimeClass, &i.Games); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countUserGames = `-- name: CountUserGames :one
+SELECT count(*) FROM games WHERE user_id = ?
+`
+
+func (q *Queries) CountUserGames(ctx context.Context, userID string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUserGames, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createSyncJob = `-- name: CreateSyncJob :exec
+INSERT INTO sync_jobs (id, username, status, created_at)
+VALUES (?, ?, 'queued', ?)
+`
+
+type CreateSyncJobParams struct {
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	CreatedAt string `json:"created_at"`
+}
+
+func (q *Queries) CreateSyncJob(ctx context.Context, arg CreateSyncJobParams) error {
+	_, err := q.db.ExecContext(ctx, createSyncJob, arg.ID, arg.Username, arg.CreatedAt)
+	return err
+}
+
+const deleteUser = `-- name: DeleteUser :exec
+DELETE FROM users WHERE id = ?
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id string) error {
+	_, err := q.db.ExecContext(ctx, deleteUser, id)
+	return err
+}
+
+const finishProcessing = `-- name: FinishProcessing :exec
+UPDATE processing_log
+SET status = ?,
+    completed_at = ?,
+    total_games = ?,
+    success_count = ?,
+    error_count = ?,
+    error_message = ?,
+    games_from_date = ?,
+    games_to_date = ?
+WHERE id = ?
+`
+
+type FinishProcessingParams struct {
+	Status        string         `json:"status"`
+	CompletedAt   sql.NullString `json:"completed_at"`
+	TotalGames    int64          `json:"total_games"`
+	SuccessCount  int64          `json:"success_count"`
+	ErrorCount    int64          `json:"error_count"`
+	ErrorMessage  sql.NullString `json:"error_message"`
+	GamesFromDate sql.NullString `json:"games_from_date"`
+	GamesToDate   sql.NullString `json:"games_to_date"`
+	ID            int64          `json:"id"`
+}
+
+func (q *Queries) FinishProcessing(ctx context.Context, arg FinishProcessingParams) error {
+	_, err := q.db.ExecContext(ctx, finishProcessing,
+		arg.Status,
+		arg.CompletedAt,
+		arg.TotalGames,
+		arg.SuccessCount,
+		arg.ErrorCount,
+		arg.ErrorMessage,
+		arg.GamesFromDate,
+		arg.GamesToDate,
+		arg.ID,
+	)
+	return err
+}
+
+const getAccountUser = `-- name: GetAccountUser :one
+SELECT chess_com_username FROM users
+WHERE account_id = ?
+`
+
+func (q *Queries) GetAccountUser(ctx context.Context, accountID sql.NullString) (string, error) {
+	row := q.db.QueryRowContext(ctx, getAccountUser, accountID)
+	var chess_com_username string
+	err := row.Scan(&chess_com_username)
+	return chess_com_username, err
+}
+
+const getChildEdges = `-- name: GetChildEdges :many
+SELECT color, time_class, parent_key, move_san, child_key, child_fen,
+       win_count, loss_count, draw_count, game_count
+FROM move_edges
+WHERE user_id = ? AND parent_key = ?
+`
+
+type GetChildEdgesParams struct {
+	UserID    string `json:"user_id"`
+	ParentKey string `json:"parent_key"`
+}
+
+type GetChildEdgesRow struct {
+	Color     string `json:"color"`
+	TimeClass string `json:"time_class"`
+	ParentKey string `json:"parent_key"`
+	MoveSan   string `json:"move_san"`
+	ChildKey  string `json:"child_key"`
+	ChildFen  string `json:"child_fen"`
+	WinCount  int64  `json:"win_count"`
+	LossCount int64  `json:"loss_count"`
+	DrawCount int64  `json:"draw_count"`
+	GameCount int64  `json:"game_count"`
+}
+
+func (q *Queries) GetChildEdges(ctx context.Context, arg GetChildEdgesParams) ([]GetChildEdgesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChildEdges, arg.UserID, arg.ParentKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChildEdgesRow
+	for rows.Next() {
+		var i GetChildEdgesRow
+		if err := rows.Scan(
+			&i.Color,
+			&i.TimeClass,
+			&i.ParentKey,
+			&i.MoveSan,
+			&i.ChildKey,
+			&i.ChildFen,
+			&i.WinCount,
+			&i.LossCount,
+			&i.DrawCount,
+			&i.GameCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLastArchive = `-- name: GetLastArchive :one
+SELECT last_archive FROM users
+WHERE chess_com_username = ?
+`
+
+func (q *Queries) GetLastArchive(ctx context.Context, chessComUsername string) (sql.NullString, error) {
+	row := q.db.QueryRowContext(ctx, getLastArchive, chessComUsername)
+	var last_archive sql.NullString
+	err := row.Scan(&last_archive)
+	return last_archive, err
+}
+
+const getParentEdges = `-- name: GetParentEdges :many
+SELECT color, time_class, parent_key, move_san, child_key, child_fen,
+       win_count, loss_count, draw_count, game_count
+FROM move_edges
+WHERE user_id = ? AND child_key = ?
+`
+
+type GetParentEdgesParams struct {
+	UserID   string `json:"user_id"`
+	ChildKey string `json:"child_key"`
+}
+
+type GetParentEdgesRow struct {
+	Color     string `json:"color"`
+	TimeClass string `json:"time_class"`
+	ParentKey string `json:"parent_key"`
+	MoveSan   string `json:"move_san"`
+	ChildKey  string `json:"child_key"`
+	ChildFen  string `json:"child_fen"`
+	WinCount  int64  `json:"win_count"`
+	LossCount int64  `json:"loss_count"`
+	DrawCount int64  `json:"draw_count"`
+	GameCount int64  `json:"game_count"`
+}
+
+func (q *Queries) GetParentEdges(ctx context.Context, arg GetParentEdgesParams) ([]GetParentEdgesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getParentEdges, arg.UserID, arg.ChildKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetParentEdgesRow
+	for rows.Next() {
+		var i GetParentEdgesRow
+		if err := rows.Scan(
+			&i.Color,
+			&i.TimeClass,
+			&i.ParentKey,
+			&i.MoveSan,
+			&i.ChildKey,
+			&i.ChildFen,
+			&i.WinCount,
+			&i.LossCount,
+			&i.DrawCount,
+			&i.GameCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPositionStats = `-- name: GetPositionStats :many
+SELECT fen, color, time_class, win_count, loss_count, draw_count, game_count
+FROM position_stats
+WHERE user_id = ? AND position_key = ?
+`
+
+type GetPositionStatsParams struct {
+	UserID      string         `json:"user_id"`
+	PositionKey sql.NullString `json:"position_key"`
+}
+
+type GetPositionStatsRow struct {
+	Fen       string `json:"fen"`
+	Color     string `json:"color"`
+	TimeClass string `json:"time_class"`
+	WinCount  int64  `json:"win_count"`
+	LossCount int64  `json:"loss_count"`
+	DrawCount int64  `json:"draw_count"`
+	GameCount int64  `json:"game_count"`
+}
+
+// Every FEN of the position, whatever its clocks, counts towards it.
+func (q *Queries) GetPositionStats(ctx context.Context, arg GetPositionStatsParams) ([]GetPositionStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPositionStats, arg.UserID, arg.PositionKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPositionStatsRow
+	for rows.Next() {
+		var i GetPositionStatsRow
+		if err := rows.Scan(
+			&i.Fen,
+			&i.Color,
+			&i.TimeClass,
+			&i.WinCount,
+			&i.LossCount,
+			&i.DrawCount,
+			&i.GameCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSyncJob = `-- name: GetSyncJob :one
+SELECT id, username, status, archives, fetched_games, processed_games, failed_games, errors, error_message, created_at, started_at, finished_at FROM sync_jobs
+WHERE id = ?
+`
+
+func (q *Queries) GetSyncJob(ctx context.Context, id string) (SyncJob, error) {
+	row := q.db.QueryRowContext(ctx, getSyncJob, id)
+	var i SyncJob
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Status,
+		&i.Archives,
+		&i.FetchedGames,
+		&i.ProcessedGames,
+		&i.FailedGames,
+		&i.Errors,
+		&i.ErrorMessage,
+		&i.CreatedAt,
+		&i.StartedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const getUserByUsername = `-- name: GetUserByUsername :one
+SELECT id, chess_com_username, created_at, updated_at, shared, last_archive, account_id FROM users
+WHERE chess_com_username = ?
+`
+
+func (q *Queries) GetUserByUsername(ctx context.Context, chessComUsername string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByUsername, chessComUsername)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.ChessComUsername,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Shared,
+		&i.LastArchive,
+		&i.AccountID,
+	)
+	return i, err
+}
+
+const insertAuditRecord = `-- name: InsertAuditRecord :exec
+INSERT INTO audit_log (action, username, actor, game_count)
+VALUES (?, ?, ?, ?)
+`
+
+type InsertAuditRecordParams struct {
+	Action    string `json:"action"`
+	Username  string `json:"username"`
+	Actor     string `json:"actor"`
+	GameCount int64  `json:"game_count"`
+}
+
+func (q *Queries) InsertAuditRecord(ctx context.Context, arg InsertAuditRecordParams) error {
+	_, err := q.db.ExecContext(ctx, insertAuditRecord,
+		arg.Action,
+		arg.Username,
+		arg.Actor,
+		arg.GameCount,
+	)
+	return err
+}
+
+const insertGame = `-- name: InsertGame :execrows
+INSERT INTO games (
+    id, user_id, link, white_username, black_username, white_elo, black_elo,
+    result, time_class, time_control, pgn, played_at, eco, termination,
+    white_accuracy, black_accuracy, rated
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+)
+ON CONFLICT DO NOTHING
+`
+
+type InsertGameParams struct {
+	ID            string          `json:"id"`
+	UserID        string          `json:"user_id"`
+	Link          string          `json:"link"`
+	WhiteUsername string          `json:"white_username"`
+	BlackUsername string          `json:"black_username"`
+	WhiteElo      sql.NullInt64   `json:"white_elo"`
+	BlackElo      sql.NullInt64   `json:"black_elo"`
+	Result        string          `json:"result"`
+	TimeClass     string          `json:"time_class"`
+	TimeControl   sql.NullString  `json:"time_control"`
+	Pgn           string          `json:"pgn"`
+	PlayedAt      string          `json:"played_at"`
+	Eco           sql.NullString  `json:"eco"`
+	Termination   sql.NullString  `json:"termination"`
+	WhiteAccuracy sql.NullFloat64 `json:"white_accuracy"`
+	BlackAccuracy sql.NullFloat64 `json:"black_accuracy"`
+	Rated         sql.NullInt64   `json:"rated"`
+}
+
+func (q *Queries) InsertGame(ctx context.Context, arg InsertGameParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, insertGame,
+		arg.ID,
+		arg.UserID,
+		arg.Link,
+		arg.WhiteUsername,
+		arg.BlackUsername,
+		arg.WhiteElo,
+		arg.BlackElo,
+		arg.Result,
+		arg.TimeClass,
+		arg.TimeControl,
+		arg.Pgn,
+		arg.PlayedAt,
+		arg.Eco,
+		arg.Termination,
+		arg.WhiteAccuracy,
+		arg.BlackAccuracy,
+		arg.Rated,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const insertGamePosition = `-- name: InsertGamePosition :exec
+INSERT INTO game_positions (game_id, fen, move_number)
+VALUES (?, ?, ?)
+ON CONFLICT DO NOTHING
+`
+
+type InsertGamePositionParams struct {
+	GameID     string `json:"game_id"`
+	Fen        string `json:"fen"`
+	MoveNumber int64  `json:"move_number"`
+}
+
+func (q *Queries) InsertGamePosition(ctx context.Context, arg InsertGamePositionParams) error {
+	_, err := q.db.ExecContext(ctx, insertGamePosition, arg.GameID, arg.Fen, arg.MoveNumber)
+	return err
+}
+
+const linkAccount = `-- name: LinkAccount :execrows
+INSERT INTO users (id, chess_com_username, account_id)
+VALUES (?, ?, ?)
+ON CONFLICT (chess_com_username) DO UPDATE
+SET account_id = excluded.account_id, updated_at = CURRENT_TIMESTAMP
+WHERE users.account_id IS NULL OR users.account_id = excluded.account_id
+`
+
+type LinkAccountParams struct {
+	ID               string         `json:"id"`
+	ChessComUsername string         `json:"chess_com_username"`
+	AccountID        sql.NullString `json:"account_id"`
+}
+
+func (q *Queries) LinkAccount(ctx context.Context, arg LinkAccountParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, linkAccount, arg.ID, arg.ChessComUsername, arg.AccountID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listFilteredPositionGames = `-- name: ListFilteredPositionGames :many
+SELECT g.id, g.result, g.time_class,
+       CAST(lower(g.white_username) = u.chess_com_username AS INTEGER) AS user_is_white,
+       nxt.fen AS next_fen
+FROM game_positions gp
+JOIN games g ON g.id = gp.game_id
+JOIN users u ON u.id = g.user_id
+LEFT JOIN game_positions nxt ON nxt.game_id = gp.game_id AND nxt.move_number = gp.move_number + 1
+WHERE g.user_id = ?1
+  AND gp.position_key = ?2
+  AND (?3 IS NULL OR g.time_class = ?3)
+  AND (?4 IS NULL OR (lower(g.white_username) = u.chess_com_username) = (?4 = 'white'))
+  AND (?5 IS NULL OR g.played_at >= ?5)
+  AND (?6 IS NULL OR g.played_at < ?6)
+  AND (?7 IS NULL OR g.rated = ?7)
+  AND (?8 IS NULL OR g.time_control = ?8)
+  AND (?9 IS NULL OR lower(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_username ELSE g.white_username END) = lower(?9))
+  AND (?10 IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) >= ?10)
+  AND (?11 IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) <= ?11)
+  AND (?12 IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) >= ?12)
+  AND (?13 IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) <= ?13)
+`
+
+type ListFilteredPositionGamesParams struct {
+	UserID      string         `json:"user_id"`
+	PositionKey sql.NullString `json:"position_key"`
+	TimeClass   interface{}    `json:"time_class"`
+	Color       interface{}    `json:"color"`
+	PlayedFrom  interface{}    `json:"played_from"`
+	PlayedTo    interface{}    `json:"played_to"`
+	Rated       interface{}    `json:"rated"`
+	TimeControl interface{}    `json:"time_control"`
+	Opponent    interface{}    `json:"opponent"`
+	OpponentMin interface{}    `json:"opponent_min"`
+	OpponentMax interface{}    `json:"opponent_max"`
+	RatingMin   interface{}    `json:"rating_min"`
+	RatingMax   interface{}    `json:"rating_max"`
+}
+
+type ListFilteredPositionGamesRow struct {
+	ID          string         `json:"id"`
+	Result      string         `json:"result"`
+	TimeClass   string         `json:"time_class"`
+	UserIsWhite int64          `json:"user_is_white"`
+	NextFen     sql.NullString `json:"next_fen"`
+}
+
+func (q *Queries) ListFilteredPositionGames(ctx context.Context, arg ListFilteredPositionGamesParams) ([]ListFilteredPositionGamesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listFilteredPositionGames,
+		arg.UserID,
+		arg.PositionKey,
+		arg.TimeClass,
+		arg.Color,
+		arg.PlayedFrom,
+		arg.PlayedTo,
+		arg.Rated,
+		arg.TimeControl,
+		arg.Opponent,
+		arg.OpponentMin,
+		arg.OpponentMax,
+		arg.RatingMin,
+		arg.RatingMax,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFilteredPositionGamesRow
+	for rows.Next() {
+		var i ListFilteredPositionGamesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Result,
+			&i.TimeClass,
+			&i.UserIsWhite,
+			&i.NextFen,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGamePositions = `-- name: ListGamePositions :many
+SELECT game_id, move_number, fen
+FROM game_positions
+WHERE game_id IN (/*SLICE:game_ids*/?)
+ORDER BY game_id, move_number
+`
+
+type ListGamePositionsRow struct {
+	GameID     string `json:"game_id"`
+	MoveNumber int64  `json:"move_number"`
+	Fen        string `json:"fen"`
+}
+
+func (q *Queries) ListGamePositions(ctx context.Context, gameIds []string) ([]ListGamePositionsRow, error) {
+	query := listGamePositions
+	var queryParams []interface{}
+	if len(gameIds) > 0 {
+		for _, v := range gameIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:game_ids*/?", strings.Repeat(",?", len(gameIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:game_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListGamePositionsRow
+	for rows.Next() {
+		var i ListGamePositionsRow
+		if err := rows.Scan(&i.GameID, &i.MoveNumber, &i.Fen); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPositionGames = `-- name: ListPositionGames :many
+SELECT g.id, g.link, g.white_username, g.black_username, g.white_elo,
+       g.black_elo, g.result, g.time_class, g.played_at, g.pgn,
+       CAST(lower(g.white_username) = u.chess_com_username AS INTEGER) AS user_is_white,
+       CAST(MIN(gp.move_number) AS INTEGER) AS ply,
+       CAST(CASE CAST(?1 AS TEXT)
+           WHEN 'rating' THEN COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0)
+           WHEN 'result' THEN CASE
+               WHEN g.result = '1/2-1/2' THEN 1
+               WHEN (g.result = '1-0') = (lower(g.white_username) = u.chess_com_username) THEN 2
+               ELSE 0 END
+           ELSE 0
+       END AS INTEGER) AS sort_key
+FROM game_positions gp
+JOIN games g ON g.id = gp.game_id
+JOIN users u ON u.id = g.user_id
+WHERE g.user_id = ?2
+  AND gp.position_key = ?3
+  AND (?4 IS NULL OR g.time_class = ?4)
+  AND (?5 IS NULL OR (lower(g.white_username) = u.chess_com_username) = (?5 = 'white'))
+  AND (?6 IS NULL OR g.played_at >= ?6)
+  AND (?7 IS NULL OR g.played_at < ?7)
+  AND (?8 IS NULL OR g.rated = ?8)
+  AND (?9 IS NULL OR g.time_control = ?9)
+  AND (?10 IS NULL OR lower(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_username ELSE g.white_username END) = lower(?10))
+  AND (?11 IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) >= ?11)
+  AND (?12 IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) <= ?12)
+  AND (?13 IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) >= ?13)
+  AND (?14 IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) <= ?14)
+  AND (?15 IS NULL
+       OR CAST(CASE CAST(?1 AS TEXT)
+              WHEN 'rating' THEN COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0)
+              WHEN 'result' THEN CASE
+                  WHEN g.result = '1/2-1/2' THEN 1
+                  WHEN (g.result = '1-0') = (lower(g.white_username) = u.chess_com_username) THEN 2
+                  ELSE 0 END
+              ELSE 0
+          END AS INTEGER) < ?15
+       OR (CAST(CASE CAST(?1 AS TEXT)
+               WHEN 'rating' THEN COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0)
+               WHEN 'result' THEN CASE
+                   WHEN g.result = '1/2-1/2' THEN 1
+                   WHEN (g.result = '1-0') = (lower(g.white_username) = u.chess_com_username) THEN 2
+                   ELSE 0 END
+               ELSE 0
+           END AS INTEGER) = ?15
+           AND (g.played_at < ?16
+                OR (g.played_at = ?16 AND g.id < ?17))))
+GROUP BY g.id
+ORDER BY sort_key DESC, g.played_at DESC, g.id DESC
+LIMIT ?18
+`
+
+type ListPositionGamesParams struct {
+	Sort          string         `json:"sort"`
+	UserID        string         `json:"user_id"`
+	PositionKey   sql.NullString `json:"position_key"`
+	TimeClass     interface{}    `json:"time_class"`
+	Color         interface{}    `json:"color"`
+	PlayedFrom    interface{}    `json:"played_from"`
+	PlayedTo      interface{}    `json:"played_to"`
+	Rated         interface{}    `json:"rated"`
+	TimeControl   interface{}    `json:"time_control"`
+	Opponent      interface{}    `json:"opponent"`
+	OpponentMin   interface{}    `json:"opponent_min"`
+	OpponentMax   interface{}    `json:"opponent_max"`
+	RatingMin     interface{}    `json:"rating_min"`
+	RatingMax     interface{}    `json:"rating_max"`
+	AfterKey      interface{}    `json:"after_key"`
+	AfterPlayedAt string         `json:"after_played_at"`
+	AfterID       string         `json:"after_id"`
+	MaxGames      int64          `json:"max_games"`
+}
+
+type ListPositionGamesRow struct {
+	ID            string        `json:"id"`
+	Link          string        `json:"link"`
+	WhiteUsername string        `json:"white_username"`
+	BlackUsername string        `json:"black_username"`
+	WhiteElo      sql.NullInt64 `json:"white_elo"`
+	BlackElo      sql.NullInt64 `json:"black_elo"`
+	Result        string        `json:"result"`
+	TimeClass     string        `json:"time_class"`
+	PlayedAt      string        `json:"played_at"`
+	Pgn           string        `json:"pgn"`
+	UserIsWhite   int64         `json:"user_is_white"`
+	Ply           int64         `json:"ply"`
+	SortKey       int64         `json:"sort_key"`
+}
+
+// See query.sql, the sort key is spelled out in the filter since sqlc cannot
+// resolve the columns of a CTE for SQLite.
+func (q *Queries) ListPositionGames(ctx context.Context, arg ListPositionGamesParams) ([]ListPositionGamesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPositionGames,
+		arg.Sort,
+		arg.UserID,
+		arg.PositionKey,
+		arg.TimeClass,
+		arg.Color,
+		arg.PlayedFrom,
+		arg.PlayedTo,
+		arg.Rated,
+		arg.TimeControl,
+		arg.Opponent,
+		arg.OpponentMin,
+		arg.OpponentMax,
+		arg.RatingMin,
+		arg.RatingMax,
+		arg.AfterKey,
+		arg.AfterPlayedAt,
+		arg.AfterID,
+		arg.MaxGames,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPositionGamesRow
+	for rows.Next() {
+		var i ListPositionGamesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Link,
+			&i.WhiteUsername,
+			&i.BlackUsername,
+			&i.WhiteElo,
+			&i.BlackElo,
+			&i.Result,
+			&i.TimeClass,
+			&i.PlayedAt,
+			&i.Pgn,
+			&i.UserIsWhite,
+			&i.Ply,
+			&i.SortKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listProcessingLogs = `-- name: ListProcessingLogs :many
+SELECT id, username, status, source_file, api_endpoint, games_from_date, games_to_date, started_at, completed_at, total_games, success_count, error_count, error_message FROM processing_log
+WHERE ?1 IS NULL OR username = ?1
+ORDER BY started_at DESC, id DESC
+LIMIT ?2
+`
+
+type ListProcessingLogsParams struct {
+	Username interface{} `json:"username"`
+	MaxRows  int64       `json:"max_rows"`
+}
+
+func (q *Queries) ListProcessingLogs(ctx context.Context, arg ListProcessingLogsParams) ([]ProcessingLog, error) {
+	rows, err := q.db.QueryContext(ctx, listProcessingLogs, arg.Username, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ProcessingLog
+	for rows.Next() {
+		var i ProcessingLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Status,
+			&i.SourceFile,
+			&i.ApiEndpoint,
+			&i.GamesFromDate,
+			&i.GamesToDate,
+			&i.StartedAt,
+			&i.CompletedAt,
+			&i.TotalGames,
+			&i.SuccessCount,
+			&i.ErrorCount,
+			&i.ErrorMessage,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSearchGames = `-- name: ListSearchGames :many
+SELECT g.id, g.link, g.white_username, g.black_username, g.white_elo,
+       g.black_elo, g.result, g.time_class, g.played_at,
+       CAST(lower(g.white_username) = u.chess_com_username AS INTEGER) AS user_is_white
+FROM games g
+JOIN users u ON u.id = g.user_id
+WHERE g.user_id = ?1
+  AND (?2 IS NULL
+       OR g.played_at < ?2
+       OR (g.played_at = ?2 AND g.id < CAST(?3 AS TEXT)))
+  AND (?4 IS NULL OR g.time_class = ?4)
+  AND (?5 IS NULL OR (lower(g.white_username) = u.chess_com_username) = (?5 = 'white'))
+  AND (?6 IS NULL OR g.played_at >= ?6)
+  AND (?7 IS NULL OR g.played_at < ?7)
+  AND (?8 IS NULL OR g.rated = ?8)
+  AND (?9 IS NULL OR g.time_control = ?9)
+  AND (?10 IS NULL OR lower(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_username ELSE g.white_username END) = lower(?10))
+  AND (?11 IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) >= ?11)
+  AND (?12 IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) <= ?12)
+  AND (?13 IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) >= ?13)
+  AND (?14 IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) <= ?14)
+ORDER BY g.played_at DESC, g.id DESC
+LIMIT ?15
+`
+
+type ListSearchGamesParams struct {
+	UserID        string      `json:"user_id"`
+	AfterPlayedAt interface{} `json:"after_played_at"`
+	AfterID       string      `json:"after_id"`
+	TimeClass     interface{} `json:"time_class"`
+	Color         interface{} `json:"color"`
+	PlayedFrom    interface{} `json:"played_from"`
+	PlayedTo      interface{} `json:"played_to"`
+	Rated         interface{} `json:"rated"`
+	TimeControl   interface{} `json:"time_control"`
+	Opponent      interface{} `json:"opponent"`
+	OpponentMin   interface{} `json:"opponent_min"`
+	OpponentMax   interface{} `json:"opponent_max"`
+	RatingMin     interface{} `json:"rating_min"`
+	RatingMax     interface{} `json:"rating_max"`
+	MaxGames      int64       `json:"max_games"`
+}
+
+type ListSearchGamesRow struct {
+	ID            string        `json:"id"`
+	Link          string        `json:"link"`
+	WhiteUsername string        `json:"white_username"`
+	BlackUsername string        `json:"black_username"`
+	WhiteElo      sql.NullInt64 `json:"white_elo"`
+	BlackElo      sql.NullInt64 `json:"black_elo"`
+	Result        string        `json:"result"`
+	TimeClass     string        `json:"time_class"`
+	PlayedAt      string        `json:"played_at"`
+	UserIsWhite   int64         `json:"user_is_white"`
+}
+
+func (q *Queries) ListSearchGames(ctx context.Context, arg ListSearchGamesParams) ([]ListSearchGamesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listSearchGames,
+		arg.UserID,
+		arg.AfterPlayedAt,
+		arg.AfterID,
+		arg.TimeClass,
+		arg.Color,
+		arg.PlayedFrom,
+		arg.PlayedTo,
+		arg.Rated,
+		arg.TimeControl,
+		arg.Opponent,
+		arg.OpponentMin,
+		arg.OpponentMax,
+		arg.RatingMin,
+		arg.RatingMax,
+		arg.MaxGames,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSearchGamesRow
+	for rows.Next() {
+		var i ListSearchGamesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Link,
+			&i.WhiteUsername,
+			&i.BlackUsername,
+			&i.WhiteElo,
+			&i.BlackElo,
+			&i.Result,
+			&i.TimeClass,
+			&i.PlayedAt,
+			&i.UserIsWhite,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTreeGames = `-- name: ListTreeGames :many
+SELECT g.id, g.link, g.white_username, g.black_username, g.white_elo,
+       g.black_elo, g.result, g.time_class, g.time_control, g.pgn, g.played_at,
+       g.eco, g.white_accuracy, g.black_accuracy, u.chess_com_username
+FROM games g
+JOIN users u ON u.id = g.user_id
+WHERE g.user_id = ?1
+  AND (?2 IS NULL
+       OR g.played_at > ?2
+       OR (g.played_at = ?2 AND g.id > CAST(?3 AS TEXT)))
+  AND (?4 IS NULL OR g.time_class = ?4)
+  AND (?5 IS NULL OR (lower(g.white_username) = u.chess_com_username) = (?5 = 'white'))
+  AND (?6 IS NULL OR g.played_at >= ?6)
+  AND (?7 IS NULL OR g.played_at < ?7)
+  AND (?8 IS NULL OR g.rated = ?8)
+  AND (?9 IS NULL OR g.time_control = ?9)
+  AND (?10 IS NULL OR lower(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_username ELSE g.white_username END) = lower(?10))
+  AND (?11 IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) >= ?11)
+  AND (?12 IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) <= ?12)
+  AND (?13 IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) >= ?13)
+  AND (?14 IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) <= ?14)
+ORDER BY g.played_at, g.id
+LIMIT ?15
+`
+
+type ListTreeGamesParams struct {
+	UserID        string      `json:"user_id"`
+	AfterPlayedAt interface{} `json:"after_played_at"`
+	AfterID       string      `json:"after_id"`
+	TimeClass     interface{} `json:"time_class"`
+	Color         interface{} `json:"color"`
+	PlayedFrom    interface{} `json:"played_from"`
+	PlayedTo      interface{} `json:"played_to"`
+	Rated         interface{} `json:"rated"`
+	TimeControl   interface{} `json:"time_control"`
+	Opponent      interface{} `json:"opponent"`
+	OpponentMin   interface{} `json:"opponent_min"`
+	OpponentMax   interface{} `json:"opponent_max"`
+	RatingMin     interface{} `json:"rating_min"`
+	RatingMax     interface{} `json:"rating_max"`
+	MaxGames      int64       `json:"max_games"`
+}
+
+type ListTreeGamesRow struct {
+	ID               string          `json:"id"`
+	Link             string          `json:"link"`
+	WhiteUsername    string          `json:"white_username"`
+	BlackUsername    string          `json:"black_username"`
+	WhiteElo         sql.NullInt64   `json:"white_elo"`
+	BlackElo         sql.NullInt64   `json:"black_elo"`
+	Result           string          `json:"result"`
+	TimeClass        string          `json:"time_class"`
+	TimeControl      sql.NullString  `json:"time_control"`
+	Pgn              string          `json:"pgn"`
+	PlayedAt         string          `json:"played_at"`
+	Eco              sql.NullString  `json:"eco"`
+	WhiteAccuracy    sql.NullFloat64 `json:"white_accuracy"`
+	BlackAccuracy    sql.NullFloat64 `json:"black_accuracy"`
+	ChessComUsername string          `json:"chess_com_username"`
+}
+
+func (q *Queries) ListTreeGames(ctx context.Context, arg ListTreeGamesParams) ([]ListTreeGamesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTreeGames,
+		arg.UserID,
+		arg.AfterPlayedAt,
+		arg.AfterID,
+		arg.TimeClass,
+		arg.Color,
+		arg.PlayedFrom,
+		arg.PlayedTo,
+		arg.Rated,
+		arg.TimeControl,
+		arg.Opponent,
+		arg.OpponentMin,
+		arg.OpponentMax,
+		arg.RatingMin,
+		arg.RatingMax,
+		arg.MaxGames,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTreeGamesRow
+	for rows.Next() {
+		var i ListTreeGamesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Link,
+			&i.WhiteUsername,
+			&i.BlackUsername,
+			&i.WhiteElo,
+			&i.BlackElo,
+			&i.Result,
+			&i.TimeClass,
+			&i.TimeControl,
+			&i.Pgn,
+			&i.PlayedAt,
+			&i.Eco,
+			&i.WhiteAccuracy,
+			&i.BlackAccuracy,
+			&i.ChessComUsername,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserGameResults = `-- name: ListUserGameResults :many
+SELECT g.white_username, g.black_username, g.white_elo, g.black_elo,
+       g.result, g.time_class, g.played_at,
+       CAST(lower(g.white_username) = u.chess_com_username AS INTEGER) AS user_is_white
+FROM games g
+JOIN users u ON u.id = g.user_id
+WHERE g.user_id = ?1
+  AND (?2 IS NULL OR g.time_class = ?2)
+`
+
+type ListUserGameResultsParams struct {
+	UserID    string      `json:"user_id"`
+	TimeClass interface{} `json:"time_class"`
+}
+
+type ListUserGameResultsRow struct {
+	WhiteUsername string        `json:"white_username"`
+	BlackUsername string        `json:"black_username"`
+	WhiteElo      sql.NullInt64 `json:"white_elo"`
+	BlackElo      sql.NullInt64 `json:"black_elo"`
+	Result        string        `json:"result"`
+	TimeClass     string        `json:"time_class"`
+	PlayedAt      string        `json:"played_at"`
+	UserIsWhite   int64         `json:"user_is_white"`
+}
+
+func (q *Queries) ListUserGameResults(ctx context.Context, arg ListUserGameResultsParams) ([]ListUserGameResultsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUserGameResults, arg.UserID, arg.TimeClass)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserGameResultsRow
+	for rows.Next() {
+		var i ListUserGameResultsRow
+		if err := rows.Scan(
+			&i.WhiteUsername,
+			&i.BlackUsername,
+			&i.WhiteElo,
+			&i.BlackElo,
+			&i.Result,
+			&i.TimeClass,
+			&i.PlayedAt,
+			&i.UserIsWhite,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserGames = `-- name: ListUserGames :many
+
+SELECT id, link, white_username, black_username, white_elo, black_elo, result,
+       time_class, time_control, pgn, played_at, eco, termination,
+       white_accuracy, black_accuracy
+FROM games
+WHERE user_id = ?
+ORDER BY played_at, id
+`
+
+type ListUserGamesRow struct {
+	ID            string          `json:"id"`
+	Link          string          `json:"link"`
+	WhiteUsername string          `json:"white_username"`
+	BlackUsername string          `json:"black_username"`
+	WhiteElo      sql.NullInt64   `json:"white_elo"`
+	BlackElo      sql.NullInt64   `json:"black_elo"`
+	Result        string          `json:"result"`
+	TimeClass     string          `json:"time_class"`
+	TimeControl   sql.NullString  `json:"time_control"`
+	Pgn           string          `json:"pgn"`
+	PlayedAt      string          `json:"played_at"`
+	Eco           sql.NullString  `json:"eco"`
+	Termination   sql.NullString  `json:"termination"`
+	WhiteAccuracy sql.NullFloat64 `json:"white_accuracy"`
+	BlackAccuracy sql.NullFloat64 `json:"black_accuracy"`
+}
+
+// The subtree walk is in Store/sqlite.go, sqlc cannot parse recursive CTEs
+// for SQLite.
+func (q *Queries) ListUserGames(ctx context.Context, userID string) ([]ListUserGamesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUserGames, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserGamesRow
+	for rows.Next() {
+		var i ListUserGamesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Link,
+			&i.WhiteUsername,
+			&i.BlackUsername,
+			&i.WhiteElo,
+			&i.BlackElo,
+			&i.Result,
+			&i.TimeClass,
+			&i.TimeControl,
+			&i.Pgn,
+			&i.PlayedAt,
+			&i.Eco,
+			&i.Termination,
+			&i.WhiteAccuracy,
+			&i.BlackAccuracy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserMoveEdges = `-- name: ListUserMoveEdges :many
+SELECT color, time_class, parent_key, move_san, child_key, child_fen,
+       win_count, loss_count, draw_count, game_count
+FROM move_edges
+WHERE user_id = ?
+ORDER BY parent_key, move_san, color, time_class
+`
+
+type ListUserMoveEdgesRow struct {
+	Color     string `json:"color"`
+	TimeClass string `json:"time_class"`
+	ParentKey string `json:"parent_key"`
+	MoveSan   string `json:"move_san"`
+	ChildKey  string `json:"child_key"`
+	ChildFen  string `json:"child_fen"`
+	WinCount  int64  `json:"win_count"`
+	LossCount int64  `json:"loss_count"`
+	DrawCount int64  `json:"draw_count"`
+	GameCount int64  `json:"game_count"`
+}
+
+func (q *Queries) ListUserMoveEdges(ctx context.Context, userID string) ([]ListUserMoveEdgesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUserMoveEdges, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserMoveEdgesRow
+	for rows.Next() {
+		var i ListUserMoveEdgesRow
+		if err := rows.Scan(
+			&i.Color,
+			&i.TimeClass,
+			&i.ParentKey,
+			&i.MoveSan,
+			&i.ChildKey,
+			&i.ChildFen,
+			&i.WinCount,
+			&i.LossCount,
+			&i.DrawCount,
+			&i.GameCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserPositionStats = `-- name: ListUserPositionStats :many
+SELECT fen, color, time_class, win_count, loss_count, draw_count, game_count
+FROM position_stats
+WHERE user_id = ?
+ORDER BY fen, color, time_class
+`
+
+type ListUserPositionStatsRow struct {
+	Fen       string `json:"fen"`
+	Color     string `json:"color"`
+	TimeClass string `json:"time_class"`
+	WinCount  int64  `json:"win_count"`
+	LossCount int64  `json:"loss_count"`
+	DrawCount int64  `json:"draw_count"`
+	GameCount int64  `json:"game_count"`
+}
+
+func (q *Queries) ListUserPositionStats(ctx context.Context, userID string) ([]ListUserPositionStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUserPositionStats, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserPositionStatsRow
+	for rows.Next() {
+		var i ListUserPositionStatsRow
+		if err := rows.Scan(
+			&i.Fen,
+			&i.Color,
+			&i.TimeClass,
+			&i.WinCount,
+			&i.LossCount,
+			&i.DrawCount,
+			&i.GameCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setLastArchive = `-- name: SetLastArchive :exec
+INSERT INTO users (id, chess_com_username, last_archive)
+VALUES (?, ?, ?)
+ON CONFLICT (chess_com_username) DO UPDATE
+SET last_archive = excluded.last_archive, updated_at = CURRENT_TIMESTAMP
+`
+
+type SetLastArchiveParams struct {
+	ID               string         `json:"id"`
+	ChessComUsername string         `json:"chess_com_username"`
+	LastArchive      sql.NullString `json:"last_archive"`
+}
+
+func (q *Queries) SetLastArchive(ctx context.Context, arg SetLastArchiveParams) error {
+	_, err := q.db.ExecContext(ctx, setLastArchive, arg.ID, arg.ChessComUsername, arg.LastArchive)
+	return err
+}
+
+const setUserShared = `-- name: SetUserShared :execrows
+UPDATE users SET shared = ?, updated_at = CURRENT_TIMESTAMP
+WHERE chess_com_username = ?
+`
+
+type SetUserSharedParams struct {
+	Shared           int64  `json:"shared"`
+	ChessComUsername string `json:"chess_com_username"`
+}
+
+func (q *Queries) SetUserShared(ctx context.Context, arg SetUserSharedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setUserShared, arg.Shared, arg.ChessComUsername)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const startProcessing = `-- name: StartProcessing :one
+INSERT INTO processing_log (username, status, source_file, api_endpoint, games_from_date, games_to_date, started_at)
+VALUES (?, 'processing', ?, ?, ?, ?, ?)
+RETURNING id
+`
+
+type StartProcessingParams struct {
+	Username      string         `json:"username"`
+	SourceFile    sql.NullString `json:"source_file"`
+	ApiEndpoint   sql.NullString `json:"api_endpoint"`
+	GamesFromDate sql.NullString `json:"games_from_date"`
+	GamesToDate   sql.NullString `json:"games_to_date"`
+	StartedAt     string         `json:"started_at"`
+}
+
+func (q *Queries) StartProcessing(ctx context.Context, arg StartProcessingParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, startProcessing,
+		arg.Username,
+		arg.SourceFile,
+		arg.ApiEndpoint,
+		arg.GamesFromDate,
+		arg.GamesToDate,
+		arg.StartedAt,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const updateSyncJob = `-- name: UpdateSyncJob :exec
+UPDATE sync_jobs
+SET status = ?,
+    archives = ?,
+    fetched_games = ?,
+    processed_games = ?,
+    failed_games = ?,
+    errors = ?,
+    error_message = ?,
+    started_at = ?,
+    finished_at = ?
+WHERE id = ?
+`
+
+type UpdateSyncJobParams struct {
+	Status         string         `json:"status"`
+	Archives       int64          `json:"archives"`
+	FetchedGames   int64          `json:"fetched_games"`
+	ProcessedGames int64          `json:"processed_games"`
+	FailedGames    int64          `json:"failed_games"`
+	Errors         string         `json:"errors"`
+	ErrorMessage   sql.NullString `json:"error_message"`
+	StartedAt      sql.NullString `json:"started_at"`
+	FinishedAt     sql.NullString `json:"finished_at"`
+	ID             string         `json:"id"`
+}
+
+func (q *Queries) UpdateSyncJob(ctx context.Context, arg UpdateSyncJobParams) error {
+	_, err := q.db.ExecContext(ctx, updateSyncJob,
+		arg.Status,
+		arg.Archives,
+		arg.FetchedGames,
+		arg.ProcessedGames,
+		arg.FailedGames,
+		arg.Errors,
+		arg.ErrorMessage,
+		arg.StartedAt,
+		arg.FinishedAt,
+		arg.ID,
+	)
+	return err
+}
+
+const upsertMoveEdge = `-- name: UpsertMoveEdge :exec
+INSERT INTO move_edges (
+    user_id, color, time_class, parent_key, move_san, child_key, child_fen,
+    win_count, loss_count, draw_count, game_count
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1
+)
+ON CONFLICT (user_id, color, time_class, parent_key, move_san) DO UPDATE
+SET child_fen = excluded.child_fen,
+    win_count = move_edges.win_count + excluded.win_count,
+    loss_count = move_edges.loss_count + excluded.loss_count,
+    draw_count = move_edges.draw_count + excluded.draw_count,
+    game_count = move_edges.game_count + 1,
+    updated_at = CURRENT_TIMESTAMP
+`
+
+type UpsertMoveEdgeParams struct {
+	UserID    string `json:"user_id"`
+	Color     string `json:"color"`
+	TimeClass string `json:"time_class"`
+	ParentKey string `json:"parent_key"`
+	MoveSan   string `json:"move_san"`
+	ChildKey  string `json:"child_key"`
+	ChildFen  string `json:"child_fen"`
+	WinCount  int64  `json:"win_count"`
+	LossCount int64  `json:"loss_count"`
+	DrawCount int64  `json:"draw_count"`
+}
+
+func (q *Queries) UpsertMoveEdge(ctx context.Context, arg UpsertMoveEdgeParams) error {
+	_, err := q.db.ExecContext(ctx, upsertMoveEdge,
+		arg.UserID,
+		arg.Color,
+		arg.TimeClass,
+		arg.ParentKey,
+		arg.MoveSan,
+		arg.ChildKey,
+		arg.ChildFen,
+		arg.WinCount,
+		arg.LossCount,
+		arg.DrawCount,
+	)
+	return err
+}
+
+const upsertPositionStats = `-- name: UpsertPositionStats :exec
+INSERT INTO position_stats (
+    fen, user_id, color, time_class, win_count, loss_count, draw_count,
+    game_count, latest_game_id, latest_played_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, 1, ?, ?
+)
+ON CONFLICT (fen, user_id, color, time_class) DO UPDATE
+SET win_count = position_stats.win_count + excluded.win_count,
+    loss_count = position_stats.loss_count + excluded.loss_count,
+    draw_count = position_stats.draw_count + excluded.draw_count,
+    game_count = position_stats.game_count + 1,
+    latest_game_id = CASE
+        WHEN position_stats.latest_played_at IS NULL
+          OR excluded.latest_played_at >= position_stats.latest_played_at
+        THEN excluded.latest_game_id
+        ELSE position_stats.latest_game_id
+    END,
+    latest_played_at = MAX(COALESCE(position_stats.latest_played_at, ''), excluded.latest_played_at),
+    updated_at = CURRENT_TIMESTAMP
+`
+
+type UpsertPositionStatsParams struct {
+	Fen            string         `json:"fen"`
+	UserID         string         `json:"user_id"`
+	Color          string         `json:"color"`
+	TimeClass      string         `json:"time_class"`
+	WinCount       int64          `json:"win_count"`
+	LossCount      int64          `json:"loss_count"`
+	DrawCount      int64          `json:"draw_count"`
+	LatestGameID   sql.NullString `json:"latest_game_id"`
+	LatestPlayedAt sql.NullString `json:"latest_played_at"`
+}
+
+func (q *Queries) UpsertPositionStats(ctx context.Context, arg UpsertPositionStatsParams) error {
+	_, err := q.db.ExecContext(ctx, upsertPositionStats,
+		arg.Fen,
+		arg.UserID,
+		arg.Color,
+		arg.TimeClass,
+		arg.WinCount,
+		arg.LossCount,
+		arg.DrawCount,
+		arg.LatestGameID,
+		arg.LatestPlayedAt,
+	)
+	return err
+}
+
+const upsertUser = `-- name: UpsertUser :one
+INSERT INTO users (id, chess_com_username)
+VALUES (?, ?)
+ON CONFLICT (chess_com_username) DO UPDATE
+SET updated_at = CURRENT_TIMESTAMP
+RETURNING id
+`
+
+type UpsertUserParams struct {
+	ID               string `json:"id"`
+	ChessComUsername string `json:"chess_com_username"`
+}
+
+func (q *Queries) UpsertUser(ctx context.Context, arg UpsertUserParams) (string, error) {
+	row := q.db.QueryRowContext(ctx, upsertUser, arg.ID, arg.ChessComUsername)
+	var id string
+	err := row.Scan(&id)
+	return id, err
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"chess/Migrations"
+	"github.com/jackc/pgx/v5"
+)
+
+// migrate brings the database at dsn up to the latest schema, the server calls
+// it before opening the pool.
+func migrate(ctx context.Context, dsn string) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	applied, err := migrations.Up(ctx, conn)
+	for _, m := range applied {
+		fmt.Printf("applied migration %04d_%s\n", m.Version, m.Name)
+	}
+	return err
+}
+
+// migrateCommand applies, reverts or lists the schema migrations.
+func migrateCommand(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dsn := fs.String("dsn", os.Getenv("DATABASE_URL"), "Postgres connection string")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	usage := errors.New("usage: migrate [-dsn url] up | down [n] | status")
+	if fs.NArg() < 1 || *dsn == "" {
+		return usage
+	}
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, *dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	switch fs.Arg(0) {
+	case "up":
+		applied, err := migrations.Up(ctx, conn)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "down":
+		steps := 1
+		if fs.NArg() > 1 {
+			steps, err = strconv.Atoi(fs.Arg(1))
+			if err != nil || steps < 1 {
+				return usage
+			}
+		}
+		reverted, err := migrations.Down(ctx, conn, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrations.List(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, state)
+		}
+		return nil
+	default:
+		return usage
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"chess/Store"
+	"chess/Types"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// progressEvery is how many games a job processes between two progress
+	// events, on top of the one after each archive.
+	progressEvery = 100
+	// feedRetention is how long the events of a finished job stay around for
+	// the clients reconnecting late.
+	feedRetention = 10 * time.Minute
+	keepAlive     = 15 * time.Second
+)
+
+// Progress events of a sync job.
+const (
+	eventArchive  = "archive"
+	eventProgress = "progress"
+	eventError    = "error"
+	eventDone     = "done"
+)
+
+// jobEvent is one server-sent event, ID counts the events of the job from 1
+// so a client can resume with Last-Event-ID.
+type jobEvent struct {
+	ID   int
+	Type string
+	Data any
+}
+
+type archiveEvent struct {
+	URL      string `json:"url"`
+	Archive  int    `json:"archive"`
+	Archives int    `json:"archives"`
+	Games    int    `json:"games"`
+}
+
+type progressEvent struct {
+	Fetched   int `json:"fetched"`
+	Processed int `json:"processed"`
+	Failed    int `json:"failed"`
+}
+
+type errorEvent struct {
+	Message string `json:"message"`
+}
+
+// jobFeed keeps the events of a job, every event published wakes up the
+// streams waiting on changed.
+type jobFeed struct {
+	mu      sync.Mutex
+	events  []jobEvent
+	done    bool
+	changed chan struct{}
+}
+
+func newJobFeed() *jobFeed {
+	return &jobFeed{changed: make(chan struct{})}
+}
+
+func (f *jobFeed) publish(eventType string, data any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.done {
+		return
+	}
+	f.events = append(f.events, jobEvent{ID: len(f.events) + 1, Type: eventType, Data: data})
+	f.done = eventType == eventDone
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// since returns the events after the one with id lastID, whether the feed is
+// over, and a channel closed on the next event.
+func (f *jobFeed) since(lastID int) ([]jobEvent, bool, <-chan struct{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if lastID < 0 || lastID > len(f.events) {
+		lastID = 0
+	}
+	events := append([]jobEvent(nil), f.events[lastID:]...)
+	return events, f.done, f.changed
+}
+
+// feed returns the events of a job this server runs or ran lately.
+func (s *syncer) feed(id string) *jobFeed {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.feeds[id]
+}
+
+func (s *syncer) publish(job *types.SyncJob, eventType string, data any) {
+	if feed := s.feed(job.ID); feed != nil {
+		feed.publish(eventType, data)
+	}
+}
+
+func (s *syncer) publishProgress(job *types.SyncJob) {
+	s.publish(job, eventProgress, progressEvent{
+		Fetched:   job.Fetched,
+		Processed: job.Processed,
+		Failed:    job.Failed,
+	})
+}
+
+// publishDone ends the feed of a job with its final state and forgets the
+// feed after feedRetention.
+func (s *syncer) publishDone(job *types.SyncJob) {
+	snapshot := *job
+	snapshot.Errors = append([]string{}, job.Errors...)
+	s.publish(job, eventDone, snapshot)
+	time.AfterFunc(feedRetention, func() {
+		s.mu.Lock()
+		delete(s.feeds, job.ID)
+		s.mu.Unlock()
+	})
+}
+
+// jobEventsHandler streams the progress of a sync job as server-sent events:
+// "archive" once an archive is fetched, "progress" with the game counts,
+// "error" for every error the job records and "done" with the final job. A
+// client reconnecting with Last-Event-ID, or the lastEventId query parameter,
+// gets the events it missed. A job this server does not follow, finished
+// before a restart for instance, streams its "done" event only.
+func jobEventsHandler(a *access, s *syncer, jobs store.JobStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		lastID := 0
+		if last := c.Get("Last-Event-ID", c.Query("lastEventId")); last != "" {
+			n, err := strconv.Atoi(last)
+			if err != nil || n < 0 {
+				return c.Status(400).JSON(fiber.Map{
+					"error": "Last-Event-ID must be an event id",
+				})
+			}
+			lastID = n
+		}
+
+		job, err := ownJob(c, a, jobs)
+		if job == nil {
+			return err
+		}
+		feed := s.feed(job.ID)
+		if feed == nil {
+			feed = newJobFeed()
+			feed.publish(eventDone, job)
+		}
+
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		c.Set("X-Accel-Buffering", "no")
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			streamJobEvents(w, feed, lastID)
+		})
+		return nil
+	}
+}
+
+// streamJobEvents writes the events of feed after lastID until the job is
+// done or the client goes away.
+func streamJobEvents(w *bufio.Writer, feed *jobFeed, lastID int) {
+	fmt.Fprintf(w, "retry: %d\n\n", (3 * time.Second).Milliseconds())
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		events, done, changed := feed.since(lastID)
+		for _, event := range events {
+			data, err := json.Marshal(event.Data)
+			if err != nil {
+				fmt.Println("failed to encode a job event:", err)
+				return
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			lastID = event.ID
+		}
+		if err := w.Flush(); err != nil || done {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-ticker.C:
+			// a comment line, for the proxies closing idle connections and
+			// to notice the client is gone
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+	}
+}
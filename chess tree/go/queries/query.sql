@@ -0,0 +1,579 @@
+-- name: UpsertUser :one
+INSERT INTO users (chess_com_username)
+VALUES ($1)
+ON CONFLICT (chess_com_username) DO UPDATE
+SET updated_at = CURRENT_TIMESTAMP
+RETURNING id;
+
+-- name: GetUserByUsername :one
+SELECT * FROM users
+WHERE chess_com_username = $1;
+
+-- name: InsertGame :execrows
+INSERT INTO games (
+    id, user_id, link, white_username, black_username, white_elo, black_elo,
+    result, time_class, time_control, pgn, played_at, eco, termination,
+    white_accuracy, black_accuracy, rated
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UpsertPositionStats :exec
+INSERT INTO position_stats (
+    fen, user_id, color, time_class, win_count, loss_count, draw_count,
+    game_count, latest_game_id, latest_played_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, 1, $8, $9
+)
+ON CONFLICT (fen, user_id, color, time_class) DO UPDATE
+SET win_count = position_stats.win_count + EXCLUDED.win_count,
+    loss_count = position_stats.loss_count + EXCLUDED.loss_count,
+    draw_count = position_stats.draw_count + EXCLUDED.draw_count,
+    game_count = position_stats.game_count + 1,
+    latest_game_id = CASE
+        WHEN position_stats.latest_played_at IS NULL
+          OR EXCLUDED.latest_played_at >= position_stats.latest_played_at
+        THEN EXCLUDED.latest_game_id
+        ELSE position_stats.latest_game_id
+    END,
+    latest_played_at = GREATEST(position_stats.latest_played_at, EXCLUDED.latest_played_at),
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: InsertGamePosition :exec
+INSERT INTO game_positions (game_id, fen, move_number)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: UpsertMoveEdge :exec
+INSERT INTO move_edges (
+    user_id, color, time_class, parent_key, move_san, child_key, child_fen,
+    win_count, loss_count, draw_count, game_count
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 1
+)
+ON CONFLICT (user_id, color, time_class, parent_key, move_san) DO UPDATE
+SET child_fen = EXCLUDED.child_fen,
+    win_count = move_edges.win_count + EXCLUDED.win_count,
+    loss_count = move_edges.loss_count + EXCLUDED.loss_count,
+    draw_count = move_edges.draw_count + EXCLUDED.draw_count,
+    game_count = move_edges.game_count + 1,
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: GetPositionStats :many
+-- Every FEN of the position, whatever its clocks, counts towards it.
+SELECT fen, color, time_class, win_count, loss_count, draw_count, game_count
+FROM position_stats
+WHERE user_id = $1 AND position_key = $2;
+
+-- name: GetChildEdges :many
+SELECT color, time_class, parent_key, move_san, child_key, child_fen,
+       win_count, loss_count, draw_count, game_count
+FROM move_edges
+WHERE user_id = $1 AND parent_key = $2;
+
+-- name: GetParentEdges :many
+SELECT color, time_class, parent_key, move_san, child_key, child_fen,
+       win_count, loss_count, draw_count, game_count
+FROM move_edges
+WHERE user_id = $1 AND child_key = $2;
+
+-- name: GetSubtreeEdges :many
+-- Walks the edges down from root_key up to max_depth plies. An edge reached
+-- along several move orders is returned once, at its shallowest depth.
+WITH RECURSIVE walk AS (
+    SELECT e.color, e.time_class, e.parent_key, e.move_san, e.child_key,
+           e.child_fen, e.win_count, e.loss_count, e.draw_count, e.game_count,
+           1 AS depth
+    FROM move_edges e
+    WHERE e.user_id = @user_id AND e.parent_key = @root_key
+    UNION ALL
+    SELECT e.color, e.time_class, e.parent_key, e.move_san, e.child_key,
+           e.child_fen, e.win_count, e.loss_count, e.draw_count, e.game_count,
+           w.depth + 1
+    FROM walk w
+    JOIN move_edges e
+      ON e.user_id = @user_id
+     AND e.color = w.color
+     AND e.time_class = w.time_class
+     AND e.parent_key = w.child_key
+    WHERE w.depth < @max_depth::int
+)
+SELECT color, time_class, parent_key, move_san, child_key, child_fen,
+       win_count, loss_count, draw_count, game_count, MIN(depth)::int AS depth
+FROM walk
+GROUP BY color, time_class, parent_key, move_san, child_key, child_fen,
+         win_count, loss_count, draw_count, game_count
+ORDER BY depth, game_count DESC;
+
+-- Bulk ingestion, see Store/bulk.go. Every row of a batch is passed as
+-- parallel arrays that unnest zips back together, zero values stand for NULL.
+
+-- name: InsertGames :many
+INSERT INTO games (
+    id, user_id, link, white_username, black_username, white_elo, black_elo,
+    result, time_class, time_control, pgn, played_at, eco, termination,
+    white_accuracy, black_accuracy, rated
+)
+SELECT g.id, g.user_id, g.link, g.white_username, g.black_username,
+       NULLIF(g.white_elo, 0), NULLIF(g.black_elo, 0), g.result, g.time_class,
+       NULLIF(g.time_control, ''), g.pgn, g.played_at, NULLIF(g.eco, ''),
+       NULLIF(g.termination, ''), NULLIF(g.white_accuracy, 0), NULLIF(g.black_accuracy, 0),
+       g.rated
+FROM (
+    SELECT unnest(@ids::uuid[]) AS id,
+           unnest(@user_ids::uuid[]) AS user_id,
+           unnest(@links::text[]) AS link,
+           unnest(@white_usernames::text[]) AS white_username,
+           unnest(@black_usernames::text[]) AS black_username,
+           unnest(@white_elos::int[]) AS white_elo,
+           unnest(@black_elos::int[]) AS black_elo,
+           unnest(@results::text[]) AS result,
+           unnest(@time_classes::text[]) AS time_class,
+           unnest(@time_controls::text[]) AS time_control,
+           unnest(@pgns::text[]) AS pgn,
+           unnest(@played_ats::timestamptz[]) AS played_at,
+           unnest(@ecos::text[]) AS eco,
+           unnest(@terminations::text[]) AS termination,
+           unnest(@white_accuracies::float8[]) AS white_accuracy,
+           unnest(@black_accuracies::float8[]) AS black_accuracy,
+           unnest(@rated::bool[]) AS rated
+) AS g
+ON CONFLICT DO NOTHING
+RETURNING id;
+
+-- name: CopyGamePositions :copyfrom
+INSERT INTO game_positions (game_id, fen, move_number)
+VALUES ($1, $2, $3);
+
+-- name: ApplyPositionStatsDeltas :exec
+INSERT INTO position_stats (
+    fen, user_id, color, time_class, win_count, loss_count, draw_count,
+    game_count, latest_game_id, latest_played_at
+)
+SELECT unnest(@fens::text[]),
+       unnest(@user_ids::uuid[]),
+       unnest(@colors::text[]),
+       unnest(@time_classes::text[]),
+       unnest(@win_counts::int[]),
+       unnest(@loss_counts::int[]),
+       unnest(@draw_counts::int[]),
+       unnest(@game_counts::int[]),
+       unnest(@latest_game_ids::uuid[]),
+       unnest(@latest_played_ats::timestamptz[])
+ON CONFLICT (fen, user_id, color, time_class) DO UPDATE
+SET win_count = position_stats.win_count + EXCLUDED.win_count,
+    loss_count = position_stats.loss_count + EXCLUDED.loss_count,
+    draw_count = position_stats.draw_count + EXCLUDED.draw_count,
+    game_count = position_stats.game_count + EXCLUDED.game_count,
+    latest_game_id = CASE
+        WHEN position_stats.latest_played_at IS NULL
+          OR EXCLUDED.latest_played_at >= position_stats.latest_played_at
+        THEN EXCLUDED.latest_game_id
+        ELSE position_stats.latest_game_id
+    END,
+    latest_played_at = GREATEST(position_stats.latest_played_at, EXCLUDED.latest_played_at),
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: ApplyMoveEdgeDeltas :exec
+INSERT INTO move_edges (
+    user_id, color, time_class, parent_key, move_san, child_key, child_fen,
+    win_count, loss_count, draw_count, game_count
+)
+SELECT unnest(@user_ids::uuid[]),
+       unnest(@colors::text[]),
+       unnest(@time_classes::text[]),
+       unnest(@parent_keys::text[]),
+       unnest(@move_sans::text[]),
+       unnest(@child_keys::text[]),
+       unnest(@child_fens::text[]),
+       unnest(@win_counts::int[]),
+       unnest(@loss_counts::int[]),
+       unnest(@draw_counts::int[]),
+       unnest(@game_counts::int[])
+ON CONFLICT (user_id, color, time_class, parent_key, move_san) DO UPDATE
+SET child_fen = EXCLUDED.child_fen,
+    win_count = move_edges.win_count + EXCLUDED.win_count,
+    loss_count = move_edges.loss_count + EXCLUDED.loss_count,
+    draw_count = move_edges.draw_count + EXCLUDED.draw_count,
+    game_count = move_edges.game_count + EXCLUDED.game_count,
+    updated_at = CURRENT_TIMESTAMP;
+
+-- Rebuild, see Store/rebuild.go. A null user_id covers every user.
+
+-- name: ClearRebuildTables :exec
+TRUNCATE rebuild_position_stats, rebuild_move_edges, rebuild_game_positions;
+
+-- name: CountRebuildGames :one
+SELECT count(*) FROM games
+WHERE sqlc.narg(user_id)::uuid IS NULL OR user_id = sqlc.narg(user_id);
+
+-- name: ListRebuildGames :many
+-- Games of the scope after the given id that are not in the shadow tables
+-- yet, the catch up pass before the swap picks up the ones stored meanwhile.
+SELECT g.id, g.user_id, u.chess_com_username, g.link, g.white_username, g.black_username,
+       g.white_elo, g.black_elo, g.result, g.time_class, g.time_control, g.pgn,
+       g.played_at, g.eco, g.white_accuracy, g.black_accuracy
+FROM games g
+JOIN users u ON u.id = g.user_id
+WHERE (sqlc.narg(user_id)::uuid IS NULL OR g.user_id = sqlc.narg(user_id))
+  AND g.id > @after
+  AND NOT EXISTS (SELECT 1 FROM rebuild_game_positions r WHERE r.game_id = g.id)
+ORDER BY g.id
+LIMIT @batch_size;
+
+-- name: CopyRebuildGamePositions :copyfrom
+INSERT INTO rebuild_game_positions (game_id, fen, move_number)
+VALUES ($1, $2, $3);
+
+-- name: ApplyRebuildPositionStatsDeltas :exec
+INSERT INTO rebuild_position_stats (
+    fen, user_id, color, time_class, win_count, loss_count, draw_count,
+    game_count, latest_game_id, latest_played_at
+)
+SELECT unnest(@fens::text[]),
+       unnest(@user_ids::uuid[]),
+       unnest(@colors::text[]),
+       unnest(@time_classes::text[]),
+       unnest(@win_counts::int[]),
+       unnest(@loss_counts::int[]),
+       unnest(@draw_counts::int[]),
+       unnest(@game_counts::int[]),
+       unnest(@latest_game_ids::uuid[]),
+       unnest(@latest_played_ats::timestamptz[])
+ON CONFLICT (fen, user_id, color, time_class) DO UPDATE
+SET win_count = rebuild_position_stats.win_count + EXCLUDED.win_count,
+    loss_count = rebuild_position_stats.loss_count + EXCLUDED.loss_count,
+    draw_count = rebuild_position_stats.draw_count + EXCLUDED.draw_count,
+    game_count = rebuild_position_stats.game_count + EXCLUDED.game_count,
+    latest_game_id = CASE
+        WHEN rebuild_position_stats.latest_played_at IS NULL
+          OR EXCLUDED.latest_played_at >= rebuild_position_stats.latest_played_at
+        THEN EXCLUDED.latest_game_id
+        ELSE rebuild_position_stats.latest_game_id
+    END,
+    latest_played_at = GREATEST(rebuild_position_stats.latest_played_at, EXCLUDED.latest_played_at);
+
+-- name: ApplyRebuildMoveEdgeDeltas :exec
+INSERT INTO rebuild_move_edges (
+    user_id, color, time_class, parent_key, move_san, child_key, child_fen,
+    win_count, loss_count, draw_count, game_count
+)
+SELECT unnest(@user_ids::uuid[]),
+       unnest(@colors::text[]),
+       unnest(@time_classes::text[]),
+       unnest(@parent_keys::text[]),
+       unnest(@move_sans::text[]),
+       unnest(@child_keys::text[]),
+       unnest(@child_fens::text[]),
+       unnest(@win_counts::int[]),
+       unnest(@loss_counts::int[]),
+       unnest(@draw_counts::int[]),
+       unnest(@game_counts::int[])
+ON CONFLICT (user_id, color, time_class, parent_key, move_san) DO UPDATE
+SET child_fen = EXCLUDED.child_fen,
+    win_count = rebuild_move_edges.win_count + EXCLUDED.win_count,
+    loss_count = rebuild_move_edges.loss_count + EXCLUDED.loss_count,
+    draw_count = rebuild_move_edges.draw_count + EXCLUDED.draw_count,
+    game_count = rebuild_move_edges.game_count + EXCLUDED.game_count;
+
+-- name: DeletePositionStatsForRebuild :exec
+DELETE FROM position_stats
+WHERE sqlc.narg(user_id)::uuid IS NULL OR user_id = sqlc.narg(user_id);
+
+-- name: DeleteMoveEdgesForRebuild :exec
+DELETE FROM move_edges
+WHERE sqlc.narg(user_id)::uuid IS NULL OR user_id = sqlc.narg(user_id);
+
+-- name: DeleteGamePositionsForRebuild :exec
+DELETE FROM game_positions
+WHERE game_id IN (
+    SELECT id FROM games
+    WHERE sqlc.narg(user_id)::uuid IS NULL OR user_id = sqlc.narg(user_id)
+);
+
+-- name: SwapInPositionStats :exec
+INSERT INTO position_stats (
+    fen, user_id, color, time_class, win_count, loss_count, draw_count,
+    game_count, latest_game_id, latest_played_at
+)
+SELECT fen, user_id, color, time_class, win_count, loss_count, draw_count,
+       game_count, latest_game_id, latest_played_at
+FROM rebuild_position_stats;
+
+-- name: SwapInMoveEdges :exec
+INSERT INTO move_edges (
+    user_id, color, time_class, parent_key, move_san, child_key, child_fen,
+    win_count, loss_count, draw_count, game_count
+)
+SELECT user_id, color, time_class, parent_key, move_san, child_key, child_fen,
+       win_count, loss_count, draw_count, game_count
+FROM rebuild_move_edges;
+
+-- name: SwapInGamePositions :exec
+INSERT INTO game_positions (game_id, fen, move_number)
+SELECT game_id, fen, move_number FROM rebuild_game_positions;
+
+-- User export and deletion, see Store/users.go.
+
+-- name: ListUserGames :many
+SELECT id, link, white_username, black_username, white_elo, black_elo, result,
+       time_class, time_control, pgn, played_at, eco, termination,
+       white_accuracy, black_accuracy
+FROM games
+WHERE user_id = $1
+ORDER BY played_at, id;
+
+-- name: ListUserPositionStats :many
+SELECT fen, color, time_class, win_count, loss_count, draw_count, game_count
+FROM position_stats
+WHERE user_id = $1
+ORDER BY fen, color, time_class;
+
+-- name: ListUserMoveEdges :many
+SELECT color, time_class, parent_key, move_san, child_key, child_fen,
+       win_count, loss_count, draw_count, game_count
+FROM move_edges
+WHERE user_id = $1
+ORDER BY parent_key, move_san, color, time_class;
+
+-- name: CountUserGames :one
+SELECT count(*) FROM games WHERE user_id = $1;
+
+-- name: DeleteUser :exec
+DELETE FROM users WHERE id = $1;
+
+-- name: SetUserShared :execrows
+UPDATE users SET shared = $2, updated_at = CURRENT_TIMESTAMP
+WHERE chess_com_username = $1;
+
+-- name: GetLastArchive :one
+SELECT last_archive FROM users
+WHERE chess_com_username = $1;
+
+-- name: SetLastArchive :exec
+INSERT INTO users (chess_com_username, last_archive)
+VALUES ($1, $2)
+ON CONFLICT (chess_com_username) DO UPDATE
+SET last_archive = EXCLUDED.last_archive, updated_at = CURRENT_TIMESTAMP;
+
+-- name: GetAccountUser :one
+SELECT chess_com_username FROM users
+WHERE account_id = $1;
+
+-- name: LinkAccount :execrows
+INSERT INTO users (chess_com_username, account_id)
+VALUES ($1, $2)
+ON CONFLICT (chess_com_username) DO UPDATE
+SET account_id = EXCLUDED.account_id, updated_at = CURRENT_TIMESTAMP
+WHERE users.account_id IS NULL OR users.account_id = EXCLUDED.account_id;
+
+-- name: InsertAuditRecord :exec
+INSERT INTO audit_log (action, username, actor, game_count)
+VALUES ($1, $2, $3, $4);
+
+-- Explorer, see Store/explorer.go. The user's color and result are worked
+-- out from the usernames since games keeps the PGN's view of the game.
+
+-- name: ListPositionGames :many
+-- One page of the games that reached a position, keyset paginated on
+-- (sort_key, played_at, id). sort_key is 0 for the most recent first, the
+-- opponent's rating, or the user's result as 2 for a win, 1 for a draw and 0
+-- for a loss.
+WITH reached AS (
+    SELECT g.id, g.link, g.white_username, g.black_username, g.white_elo,
+           g.black_elo, g.result, g.time_class, g.played_at, g.pgn,
+           (lower(g.white_username) = u.chess_com_username)::bool AS user_is_white,
+           MIN(gp.move_number)::int AS ply
+    FROM game_positions gp
+    JOIN games g ON g.id = gp.game_id
+    JOIN users u ON u.id = g.user_id
+    WHERE g.user_id = @user_id
+      AND gp.position_key = @position_key
+      AND (sqlc.narg(time_class)::text IS NULL OR g.time_class = sqlc.narg(time_class))
+      AND (sqlc.narg(color)::text IS NULL
+           OR (lower(g.white_username) = u.chess_com_username) = (sqlc.narg(color) = 'white'))
+      AND (sqlc.narg(played_from)::timestamptz IS NULL OR g.played_at >= sqlc.narg(played_from))
+      AND (sqlc.narg(played_to)::timestamptz IS NULL OR g.played_at < sqlc.narg(played_to))
+      AND (sqlc.narg(rated)::bool IS NULL OR g.rated = sqlc.narg(rated))
+      AND (sqlc.narg(time_control)::text IS NULL OR g.time_control = sqlc.narg(time_control))
+      AND (sqlc.narg(opponent)::text IS NULL OR lower(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_username ELSE g.white_username END) = lower(sqlc.narg(opponent)))
+      AND (sqlc.narg(opponent_min)::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) >= sqlc.narg(opponent_min))
+      AND (sqlc.narg(opponent_max)::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) <= sqlc.narg(opponent_max))
+      AND (sqlc.narg(rating_min)::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) >= sqlc.narg(rating_min))
+      AND (sqlc.narg(rating_max)::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) <= sqlc.narg(rating_max))
+    GROUP BY g.id, u.chess_com_username
+), keyed AS (
+    SELECT reached.*,
+           (CASE @sort::text
+                WHEN 'rating' THEN COALESCE(CASE WHEN user_is_white THEN black_elo ELSE white_elo END, 0)
+                WHEN 'result' THEN CASE
+                    WHEN result = '1/2-1/2' THEN 1
+                    WHEN (result = '1-0') = user_is_white THEN 2
+                    ELSE 0 END
+                ELSE 0
+            END)::bigint AS sort_key
+    FROM reached
+)
+SELECT * FROM keyed
+WHERE sqlc.narg(after_key)::bigint IS NULL
+   OR sort_key < sqlc.narg(after_key)
+   OR (sort_key = sqlc.narg(after_key)
+       AND (played_at < @after_played_at::timestamptz
+            OR (played_at = @after_played_at::timestamptz AND id < @after_id::uuid)))
+ORDER BY sort_key DESC, played_at DESC, id DESC
+LIMIT @max_games;
+
+-- name: ListFilteredPositionGames :many
+-- The games reaching a position kept by the filters with the position after
+-- the next ply, for the stats the filters make the precomputed ones useless
+-- for. The filters are the same as in ListPositionGames.
+SELECT g.id, g.result, g.time_class,
+       (lower(g.white_username) = u.chess_com_username)::bool AS user_is_white,
+       nxt.fen AS next_fen
+FROM game_positions gp
+JOIN games g ON g.id = gp.game_id
+JOIN users u ON u.id = g.user_id
+LEFT JOIN game_positions nxt ON nxt.game_id = gp.game_id AND nxt.move_number = gp.move_number + 1
+WHERE g.user_id = @user_id
+  AND gp.position_key = @position_key
+  AND (sqlc.narg(time_class)::text IS NULL OR g.time_class = sqlc.narg(time_class))
+  AND (sqlc.narg(color)::text IS NULL
+       OR (lower(g.white_username) = u.chess_com_username) = (sqlc.narg(color) = 'white'))
+  AND (sqlc.narg(played_from)::timestamptz IS NULL OR g.played_at >= sqlc.narg(played_from))
+  AND (sqlc.narg(played_to)::timestamptz IS NULL OR g.played_at < sqlc.narg(played_to))
+  AND (sqlc.narg(rated)::bool IS NULL OR g.rated = sqlc.narg(rated))
+  AND (sqlc.narg(time_control)::text IS NULL OR g.time_control = sqlc.narg(time_control))
+  AND (sqlc.narg(opponent)::text IS NULL OR lower(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_username ELSE g.white_username END) = lower(sqlc.narg(opponent)))
+  AND (sqlc.narg(opponent_min)::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) >= sqlc.narg(opponent_min))
+  AND (sqlc.narg(opponent_max)::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) <= sqlc.narg(opponent_max))
+  AND (sqlc.narg(rating_min)::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) >= sqlc.narg(rating_min))
+  AND (sqlc.narg(rating_max)::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) <= sqlc.narg(rating_max));
+
+-- name: ListSearchGames :many
+-- One batch of the user's games kept by the filters for a position search,
+-- newest first and keyset paginated on (played_at, id). The filters are the
+-- same as in ListPositionGames.
+SELECT g.id, g.link, g.white_username, g.black_username, g.white_elo,
+       g.black_elo, g.result, g.time_class, g.played_at,
+       (lower(g.white_username) = u.chess_com_username)::bool AS user_is_white
+FROM games g
+JOIN users u ON u.id = g.user_id
+WHERE g.user_id = @user_id
+  AND (sqlc.narg(after_played_at)::timestamptz IS NULL
+       OR (g.played_at, g.id) < (sqlc.narg(after_played_at)::timestamptz, @after_id::uuid))
+  AND (sqlc.narg(time_class)::text IS NULL OR g.time_class = sqlc.narg(time_class))
+  AND (sqlc.narg(color)::text IS NULL
+       OR (lower(g.white_username) = u.chess_com_username) = (sqlc.narg(color) = 'white'))
+  AND (sqlc.narg(played_from)::timestamptz IS NULL OR g.played_at >= sqlc.narg(played_from))
+  AND (sqlc.narg(played_to)::timestamptz IS NULL OR g.played_at < sqlc.narg(played_to))
+  AND (sqlc.narg(rated)::bool IS NULL OR g.rated = sqlc.narg(rated))
+  AND (sqlc.narg(time_control)::text IS NULL OR g.time_control = sqlc.narg(time_control))
+  AND (sqlc.narg(opponent)::text IS NULL OR lower(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_username ELSE g.white_username END) = lower(sqlc.narg(opponent)))
+  AND (sqlc.narg(opponent_min)::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) >= sqlc.narg(opponent_min))
+  AND (sqlc.narg(opponent_max)::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) <= sqlc.narg(opponent_max))
+  AND (sqlc.narg(rating_min)::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) >= sqlc.narg(rating_min))
+  AND (sqlc.narg(rating_max)::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) <= sqlc.narg(rating_max))
+ORDER BY g.played_at DESC, g.id DESC
+LIMIT @max_games;
+
+-- name: ListTreeGames :many
+-- One batch of the user's games kept by the filters, oldest first and keyset
+-- paginated on (played_at, id), with what a replay needs to rebuild their
+-- tree. The filters are the same as in ListPositionGames.
+SELECT g.id, g.link, g.white_username, g.black_username, g.white_elo,
+       g.black_elo, g.result, g.time_class, g.time_control, g.pgn, g.played_at,
+       g.eco, g.white_accuracy, g.black_accuracy, u.chess_com_username
+FROM games g
+JOIN users u ON u.id = g.user_id
+WHERE g.user_id = @user_id
+  AND (sqlc.narg(after_played_at)::timestamptz IS NULL
+       OR (g.played_at, g.id) > (sqlc.narg(after_played_at)::timestamptz, @after_id::uuid))
+  AND (sqlc.narg(time_class)::text IS NULL OR g.time_class = sqlc.narg(time_class))
+  AND (sqlc.narg(color)::text IS NULL
+       OR (lower(g.white_username) = u.chess_com_username) = (sqlc.narg(color) = 'white'))
+  AND (sqlc.narg(played_from)::timestamptz IS NULL OR g.played_at >= sqlc.narg(played_from))
+  AND (sqlc.narg(played_to)::timestamptz IS NULL OR g.played_at < sqlc.narg(played_to))
+  AND (sqlc.narg(rated)::bool IS NULL OR g.rated = sqlc.narg(rated))
+  AND (sqlc.narg(time_control)::text IS NULL OR g.time_control = sqlc.narg(time_control))
+  AND (sqlc.narg(opponent)::text IS NULL OR lower(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_username ELSE g.white_username END) = lower(sqlc.narg(opponent)))
+  AND (sqlc.narg(opponent_min)::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) >= sqlc.narg(opponent_min))
+  AND (sqlc.narg(opponent_max)::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) <= sqlc.narg(opponent_max))
+  AND (sqlc.narg(rating_min)::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) >= sqlc.narg(rating_min))
+  AND (sqlc.narg(rating_max)::int IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) <= sqlc.narg(rating_max))
+ORDER BY g.played_at, g.id
+LIMIT @max_games;
+
+-- name: ListGamePositions :many
+SELECT game_id, move_number, fen
+FROM game_positions
+WHERE game_id = ANY(@game_ids::uuid[])
+ORDER BY game_id, move_number;
+
+-- name: ListUserGameResults :many
+SELECT g.white_username, g.black_username, g.white_elo, g.black_elo,
+       g.result, g.time_class, g.played_at,
+       (lower(g.white_username) = u.chess_com_username)::bool AS user_is_white
+FROM games g
+JOIN users u ON u.id = g.user_id
+WHERE g.user_id = @user_id
+  AND (sqlc.narg(time_class)::text IS NULL OR g.time_class = sqlc.narg(time_class));
+
+-- name: CountTimeClasses :many
+SELECT time_class, count(*)::int AS games
+FROM games
+WHERE user_id = $1
+GROUP BY time_class
+ORDER BY games DESC, time_class;
+
+-- name: StartProcessing :one
+INSERT INTO processing_log (username, status, source_file, api_endpoint, games_from_date, games_to_date)
+VALUES ($1, 'processing', $2, $3, $4, $5)
+RETURNING id;
+
+-- name: FinishProcessing :exec
+UPDATE processing_log
+SET status = $2,
+    completed_at = now(),
+    total_games = $3,
+    success_count = $4,
+    error_count = $5,
+    error_message = $6,
+    games_from_date = $7,
+    games_to_date = $8
+WHERE id = $1;
+
+-- name: ListProcessingLogs :many
+SELECT * FROM processing_log
+WHERE sqlc.narg(username)::text IS NULL OR username = sqlc.narg(username)
+ORDER BY started_at DESC, id DESC
+LIMIT @max_rows;
+
+-- name: CreateSyncJob :exec
+INSERT INTO sync_jobs (id, username, status)
+VALUES ($1, $2, 'queued');
+
+-- name: UpdateSyncJob :exec
+UPDATE sync_jobs
+SET status = $2,
+    archives = $3,
+    fetched_games = $4,
+    processed_games = $5,
+    failed_games = $6,
+    errors = $7,
+    error_message = $8,
+    started_at = $9,
+    finished_at = $10
+WHERE id = $1;
+
+-- name: GetSyncJob :one
+SELECT * FROM sync_jobs
+WHERE id = $1;
+
+-- name: AbandonSyncJobs :execrows
+-- Jobs left queued or running by a server that stopped without draining.
+UPDATE sync_jobs
+SET status = 'failed',
+    error_message = $1,
+    finished_at = now()
+WHERE status IN ('queued', 'running');
@@ -0,0 +1,336 @@
+-- name: UpsertUser :one
+INSERT INTO users (id, chess_com_username)
+VALUES (?, ?)
+ON CONFLICT (chess_com_username) DO UPDATE
+SET updated_at = CURRENT_TIMESTAMP
+RETURNING id;
+
+-- name: GetUserByUsername :one
+SELECT * FROM users
+WHERE chess_com_username = ?;
+
+-- name: InsertGame :execrows
+INSERT INTO games (
+    id, user_id, link, white_username, black_username, white_elo, black_elo,
+    result, time_class, time_control, pgn, played_at, eco, termination,
+    white_accuracy, black_accuracy, rated
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UpsertPositionStats :exec
+INSERT INTO position_stats (
+    fen, user_id, color, time_class, win_count, loss_count, draw_count,
+    game_count, latest_game_id, latest_played_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, 1, ?, ?
+)
+ON CONFLICT (fen, user_id, color, time_class) DO UPDATE
+SET win_count = position_stats.win_count + excluded.win_count,
+    loss_count = position_stats.loss_count + excluded.loss_count,
+    draw_count = position_stats.draw_count + excluded.draw_count,
+    game_count = position_stats.game_count + 1,
+    latest_game_id = CASE
+        WHEN position_stats.latest_played_at IS NULL
+          OR excluded.latest_played_at >= position_stats.latest_played_at
+        THEN excluded.latest_game_id
+        ELSE position_stats.latest_game_id
+    END,
+    latest_played_at = MAX(COALESCE(position_stats.latest_played_at, ''), excluded.latest_played_at),
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: InsertGamePosition :exec
+INSERT INTO game_positions (game_id, fen, move_number)
+VALUES (?, ?, ?)
+ON CONFLICT DO NOTHING;
+
+-- name: UpsertMoveEdge :exec
+INSERT INTO move_edges (
+    user_id, color, time_class, parent_key, move_san, child_key, child_fen,
+    win_count, loss_count, draw_count, game_count
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1
+)
+ON CONFLICT (user_id, color, time_class, parent_key, move_san) DO UPDATE
+SET child_fen = excluded.child_fen,
+    win_count = move_edges.win_count + excluded.win_count,
+    loss_count = move_edges.loss_count + excluded.loss_count,
+    draw_count = move_edges.draw_count + excluded.draw_count,
+    game_count = move_edges.game_count + 1,
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: GetPositionStats :many
+-- Every FEN of the position, whatever its clocks, counts towards it.
+SELECT fen, color, time_class, win_count, loss_count, draw_count, game_count
+FROM position_stats
+WHERE user_id = ? AND position_key = ?;
+
+-- name: GetChildEdges :many
+SELECT color, time_class, parent_key, move_san, child_key, child_fen,
+       win_count, loss_count, draw_count, game_count
+FROM move_edges
+WHERE user_id = ? AND parent_key = ?;
+
+-- name: GetParentEdges :many
+SELECT color, time_class, parent_key, move_san, child_key, child_fen,
+       win_count, loss_count, draw_count, game_count
+FROM move_edges
+WHERE user_id = ? AND child_key = ?;
+
+-- The subtree walk is in Store/sqlite.go, sqlc cannot parse recursive CTEs
+-- for SQLite.
+
+-- name: ListUserGames :many
+SELECT id, link, white_username, black_username, white_elo, black_elo, result,
+       time_class, time_control, pgn, played_at, eco, termination,
+       white_accuracy, black_accuracy
+FROM games
+WHERE user_id = ?
+ORDER BY played_at, id;
+
+-- name: ListUserPositionStats :many
+SELECT fen, color, time_class, win_count, loss_count, draw_count, game_count
+FROM position_stats
+WHERE user_id = ?
+ORDER BY fen, color, time_class;
+
+-- name: ListUserMoveEdges :many
+SELECT color, time_class, parent_key, move_san, child_key, child_fen,
+       win_count, loss_count, draw_count, game_count
+FROM move_edges
+WHERE user_id = ?
+ORDER BY parent_key, move_san, color, time_class;
+
+-- name: CountUserGames :one
+SELECT count(*) FROM games WHERE user_id = ?;
+
+-- name: DeleteUser :exec
+DELETE FROM users WHERE id = ?;
+
+-- name: SetUserShared :execrows
+UPDATE users SET shared = ?, updated_at = CURRENT_TIMESTAMP
+WHERE chess_com_username = ?;
+
+-- name: GetLastArchive :one
+SELECT last_archive FROM users
+WHERE chess_com_username = ?;
+
+-- name: SetLastArchive :exec
+INSERT INTO users (id, chess_com_username, last_archive)
+VALUES (?, ?, ?)
+ON CONFLICT (chess_com_username) DO UPDATE
+SET last_archive = excluded.last_archive, updated_at = CURRENT_TIMESTAMP;
+
+-- name: GetAccountUser :one
+SELECT chess_com_username FROM users
+WHERE account_id = ?;
+
+-- name: LinkAccount :execrows
+INSERT INTO users (id, chess_com_username, account_id)
+VALUES (?, ?, ?)
+ON CONFLICT (chess_com_username) DO UPDATE
+SET account_id = excluded.account_id, updated_at = CURRENT_TIMESTAMP
+WHERE users.account_id IS NULL OR users.account_id = excluded.account_id;
+
+-- name: InsertAuditRecord :exec
+INSERT INTO audit_log (action, username, actor, game_count)
+VALUES (?, ?, ?, ?);
+
+-- name: ListPositionGames :many
+-- See query.sql, the sort key is spelled out in the filter since sqlc cannot
+-- resolve the columns of a CTE for SQLite.
+SELECT g.id, g.link, g.white_username, g.black_username, g.white_elo,
+       g.black_elo, g.result, g.time_class, g.played_at, g.pgn,
+       CAST(lower(g.white_username) = u.chess_com_username AS INTEGER) AS user_is_white,
+       CAST(MIN(gp.move_number) AS INTEGER) AS ply,
+       CAST(CASE CAST(@sort AS TEXT)
+           WHEN 'rating' THEN COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0)
+           WHEN 'result' THEN CASE
+               WHEN g.result = '1/2-1/2' THEN 1
+               WHEN (g.result = '1-0') = (lower(g.white_username) = u.chess_com_username) THEN 2
+               ELSE 0 END
+           ELSE 0
+       END AS INTEGER) AS sort_key
+FROM game_positions gp
+JOIN games g ON g.id = gp.game_id
+JOIN users u ON u.id = g.user_id
+WHERE g.user_id = @user_id
+  AND gp.position_key = @position_key
+  AND (sqlc.narg(time_class) IS NULL OR g.time_class = sqlc.narg(time_class))
+  AND (sqlc.narg(color) IS NULL OR (lower(g.white_username) = u.chess_com_username) = (sqlc.narg(color) = 'white'))
+  AND (sqlc.narg(played_from) IS NULL OR g.played_at >= sqlc.narg(played_from))
+  AND (sqlc.narg(played_to) IS NULL OR g.played_at < sqlc.narg(played_to))
+  AND (sqlc.narg(rated) IS NULL OR g.rated = sqlc.narg(rated))
+  AND (sqlc.narg(time_control) IS NULL OR g.time_control = sqlc.narg(time_control))
+  AND (sqlc.narg(opponent) IS NULL OR lower(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_username ELSE g.white_username END) = lower(sqlc.narg(opponent)))
+  AND (sqlc.narg(opponent_min) IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) >= sqlc.narg(opponent_min))
+  AND (sqlc.narg(opponent_max) IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) <= sqlc.narg(opponent_max))
+  AND (sqlc.narg(rating_min) IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) >= sqlc.narg(rating_min))
+  AND (sqlc.narg(rating_max) IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) <= sqlc.narg(rating_max))
+  AND (sqlc.narg(after_key) IS NULL
+       OR CAST(CASE CAST(@sort AS TEXT)
+              WHEN 'rating' THEN COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0)
+              WHEN 'result' THEN CASE
+                  WHEN g.result = '1/2-1/2' THEN 1
+                  WHEN (g.result = '1-0') = (lower(g.white_username) = u.chess_com_username) THEN 2
+                  ELSE 0 END
+              ELSE 0
+          END AS INTEGER) < sqlc.narg(after_key)
+       OR (CAST(CASE CAST(@sort AS TEXT)
+               WHEN 'rating' THEN COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0)
+               WHEN 'result' THEN CASE
+                   WHEN g.result = '1/2-1/2' THEN 1
+                   WHEN (g.result = '1-0') = (lower(g.white_username) = u.chess_com_username) THEN 2
+                   ELSE 0 END
+               ELSE 0
+           END AS INTEGER) = sqlc.narg(after_key)
+           AND (g.played_at < @after_played_at
+                OR (g.played_at = @after_played_at AND g.id < @after_id))))
+GROUP BY g.id
+ORDER BY sort_key DESC, g.played_at DESC, g.id DESC
+LIMIT @max_games;
+
+-- name: ListFilteredPositionGames :many
+SELECT g.id, g.result, g.time_class,
+       CAST(lower(g.white_username) = u.chess_com_username AS INTEGER) AS user_is_white,
+       nxt.fen AS next_fen
+FROM game_positions gp
+JOIN games g ON g.id = gp.game_id
+JOIN users u ON u.id = g.user_id
+LEFT JOIN game_positions nxt ON nxt.game_id = gp.game_id AND nxt.move_number = gp.move_number + 1
+WHERE g.user_id = @user_id
+  AND gp.position_key = @position_key
+  AND (sqlc.narg(time_class) IS NULL OR g.time_class = sqlc.narg(time_class))
+  AND (sqlc.narg(color) IS NULL OR (lower(g.white_username) = u.chess_com_username) = (sqlc.narg(color) = 'white'))
+  AND (sqlc.narg(played_from) IS NULL OR g.played_at >= sqlc.narg(played_from))
+  AND (sqlc.narg(played_to) IS NULL OR g.played_at < sqlc.narg(played_to))
+  AND (sqlc.narg(rated) IS NULL OR g.rated = sqlc.narg(rated))
+  AND (sqlc.narg(time_control) IS NULL OR g.time_control = sqlc.narg(time_control))
+  AND (sqlc.narg(opponent) IS NULL OR lower(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_username ELSE g.white_username END) = lower(sqlc.narg(opponent)))
+  AND (sqlc.narg(opponent_min) IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) >= sqlc.narg(opponent_min))
+  AND (sqlc.narg(opponent_max) IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) <= sqlc.narg(opponent_max))
+  AND (sqlc.narg(rating_min) IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) >= sqlc.narg(rating_min))
+  AND (sqlc.narg(rating_max) IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) <= sqlc.narg(rating_max));
+
+-- name: ListSearchGames :many
+SELECT g.id, g.link, g.white_username, g.black_username, g.white_elo,
+       g.black_elo, g.result, g.time_class, g.played_at,
+       CAST(lower(g.white_username) = u.chess_com_username AS INTEGER) AS user_is_white
+FROM games g
+JOIN users u ON u.id = g.user_id
+WHERE g.user_id = @user_id
+  AND (sqlc.narg(after_played_at) IS NULL
+       OR g.played_at < sqlc.narg(after_played_at)
+       OR (g.played_at = sqlc.narg(after_played_at) AND g.id < CAST(@after_id AS TEXT)))
+  AND (sqlc.narg(time_class) IS NULL OR g.time_class = sqlc.narg(time_class))
+  AND (sqlc.narg(color) IS NULL OR (lower(g.white_username) = u.chess_com_username) = (sqlc.narg(color) = 'white'))
+  AND (sqlc.narg(played_from) IS NULL OR g.played_at >= sqlc.narg(played_from))
+  AND (sqlc.narg(played_to) IS NULL OR g.played_at < sqlc.narg(played_to))
+  AND (sqlc.narg(rated) IS NULL OR g.rated = sqlc.narg(rated))
+  AND (sqlc.narg(time_control) IS NULL OR g.time_control = sqlc.narg(time_control))
+  AND (sqlc.narg(opponent) IS NULL OR lower(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_username ELSE g.white_username END) = lower(sqlc.narg(opponent)))
+  AND (sqlc.narg(opponent_min) IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) >= sqlc.narg(opponent_min))
+  AND (sqlc.narg(opponent_max) IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) <= sqlc.narg(opponent_max))
+  AND (sqlc.narg(rating_min) IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) >= sqlc.narg(rating_min))
+  AND (sqlc.narg(rating_max) IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) <= sqlc.narg(rating_max))
+ORDER BY g.played_at DESC, g.id DESC
+LIMIT @max_games;
+
+-- name: ListTreeGames :many
+SELECT g.id, g.link, g.white_username, g.black_username, g.white_elo,
+       g.black_elo, g.result, g.time_class, g.time_control, g.pgn, g.played_at,
+       g.eco, g.white_accuracy, g.black_accuracy, u.chess_com_username
+FROM games g
+JOIN users u ON u.id = g.user_id
+WHERE g.user_id = @user_id
+  AND (sqlc.narg(after_played_at) IS NULL
+       OR g.played_at > sqlc.narg(after_played_at)
+       OR (g.played_at = sqlc.narg(after_played_at) AND g.id > CAST(@after_id AS TEXT)))
+  AND (sqlc.narg(time_class) IS NULL OR g.time_class = sqlc.narg(time_class))
+  AND (sqlc.narg(color) IS NULL OR (lower(g.white_username) = u.chess_com_username) = (sqlc.narg(color) = 'white'))
+  AND (sqlc.narg(played_from) IS NULL OR g.played_at >= sqlc.narg(played_from))
+  AND (sqlc.narg(played_to) IS NULL OR g.played_at < sqlc.narg(played_to))
+  AND (sqlc.narg(rated) IS NULL OR g.rated = sqlc.narg(rated))
+  AND (sqlc.narg(time_control) IS NULL OR g.time_control = sqlc.narg(time_control))
+  AND (sqlc.narg(opponent) IS NULL OR lower(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_username ELSE g.white_username END) = lower(sqlc.narg(opponent)))
+  AND (sqlc.narg(opponent_min) IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) >= sqlc.narg(opponent_min))
+  AND (sqlc.narg(opponent_max) IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.black_elo ELSE g.white_elo END, 0) <= sqlc.narg(opponent_max))
+  AND (sqlc.narg(rating_min) IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) >= sqlc.narg(rating_min))
+  AND (sqlc.narg(rating_max) IS NULL OR COALESCE(CASE WHEN lower(g.white_username) = u.chess_com_username THEN g.white_elo ELSE g.black_elo END, 0) <= sqlc.narg(rating_max))
+ORDER BY g.played_at, g.id
+LIMIT @max_games;
+
+-- name: ListGamePositions :many
+SELECT game_id, move_number, fen
+FROM game_positions
+WHERE game_id IN (sqlc.slice(game_ids))
+ORDER BY game_id, move_number;
+
+-- name: ListUserGameResults :many
+SELECT g.white_username, g.black_username, g.white_elo, g.black_elo,
+       g.result, g.time_class, g.played_at,
+       CAST(lower(g.white_username) = u.chess_com_username AS INTEGER) AS user_is_white
+FROM games g
+JOIN users u ON u.id = g.user_id
+WHERE g.user_id = @user_id
+  AND (sqlc.narg(time_class) IS NULL OR g.time_class = sqlc.narg(time_class));
+
+-- name: CountTimeClasses :many
+SELECT time_class, CAST(count(*) AS INTEGER) AS games
+FROM games
+WHERE user_id = ?
+GROUP BY time_class
+ORDER BY games DESC, time_class;
+
+-- name: StartProcessing :one
+INSERT INTO processing_log (username, status, source_file, api_endpoint, games_from_date, games_to_date, started_at)
+VALUES (?, 'processing', ?, ?, ?, ?, ?)
+RETURNING id;
+
+-- name: FinishProcessing :exec
+UPDATE processing_log
+SET status = ?,
+    completed_at = ?,
+    total_games = ?,
+    success_count = ?,
+    error_count = ?,
+    error_message = ?,
+    games_from_date = ?,
+    games_to_date = ?
+WHERE id = ?;
+
+-- name: ListProcessingLogs :many
+SELECT * FROM processing_log
+WHERE sqlc.narg(username) IS NULL OR username = sqlc.narg(username)
+ORDER BY started_at DESC, id DESC
+LIMIT @max_rows;
+
+-- name: CreateSyncJob :exec
+INSERT INTO sync_jobs (id, username, status, created_at)
+VALUES (?, ?, 'queued', ?);
+
+-- name: UpdateSyncJob :exec
+UPDATE sync_jobs
+SET status = ?,
+    archives = ?,
+    fetched_games = ?,
+    processed_games = ?,
+    failed_games = ?,
+    errors = ?,
+    error_message = ?,
+    started_at = ?,
+    finished_at = ?
+WHERE id = ?;
+
+-- name: GetSyncJob :one
+SELECT * FROM sync_jobs
+WHERE id = ?;
+
+-- name: AbandonSyncJobs :execrows
+-- Jobs left queued or running by a server that stopped without draining.
+UPDATE sync_jobs
+SET status = 'failed',
+    error_message = ?,
+    finished_at = ?
+WHERE status IN ('queued', 'running');
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"chess/Store"
+	"chess/Types"
+	"chess/Utils"
+)
+
+// rebuildCommand recomputes the position stats, edges and game positions
+// from the stored games, printing the progress as it goes.
+func rebuildCommand(cfg config, args []string) error {
+	fs := flag.NewFlagSet("rebuild", flag.ContinueOnError)
+	dsn := fs.String("dsn", cfg.DatabaseURL, "postgres connection string")
+	username := fs.String("username", "", "only rebuild this user, every user when empty")
+	batch := fs.Int("batch", 500, "games replayed per batch")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dsn == "" && cfg.Store == "sqlite" {
+		return errors.New("rebuild does not support STORE=sqlite, it only rebuilds the postgres tables")
+	}
+	if fs.NArg() != 0 || *dsn == "" {
+		return errors.New("usage: rebuild -dsn <dsn> [-username player] [-batch n]")
+	}
+
+	ctx := context.Background()
+	if err := migrate(ctx, *dsn); err != nil {
+		return err
+	}
+	pg, err := store.NewPostgres(ctx, *dsn)
+	if err != nil {
+		return err
+	}
+	defer pg.Close()
+
+	return pg.Rebuild(ctx, *username, *batch, utils.ProcessGame, func(p types.RebuildProgress) {
+		fmt.Printf("rebuild %s: %d/%d games, %d skipped\n", p.Phase, p.Done, p.Total, p.Skipped)
+	})
+}
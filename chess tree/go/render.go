@@ -0,0 +1,48 @@
+package main
+
+import (
+	"chess/Render"
+	"github.com/gofiber/fiber/v2"
+)
+
+// boardImageHandler draws a position as an SVG board for the reports, chat
+// embeds and notifications, from the query parameters:
+//   - fen, the position, only its piece placement is read
+//   - orientation, white (the default) or black at the bottom
+//   - lastMove, the move to highlight in UCI ("e2e4")
+//   - arrows, comma separated moves like "e2e4,g1f3:red", a lone square
+//     being circled, in green unless :red, :blue or :yellow is given
+//   - coordinates, false to leave out the file and rank labels
+//   - size, the width and height in pixels, 360 by default
+//
+// The image only depends on the query so it can be cached for long.
+func boardImageHandler(c *fiber.Ctx) error {
+	fen := c.Query("fen")
+	if fen == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "fen query param is required",
+		})
+	}
+	arrows, err := render.ParseArrows(c.Query("arrows"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	svg, err := render.Board(render.Options{
+		FEN:         fen,
+		Orientation: c.Query("orientation"),
+		LastMove:    c.Query("lastMove"),
+		Arrows:      arrows,
+		Coordinates: c.QueryBool("coordinates", true),
+		Size:        c.QueryInt("size", render.DefaultSize),
+	})
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	c.Set(fiber.HeaderContentType, "image/svg+xml")
+	c.Set(fiber.HeaderCacheControl, "public, max-age=86400")
+	return c.Status(200).Send(svg)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestBoardImageHandler(t *testing.T) {
+	app := fiber.New()
+	app.Get("/render/board.svg", boardImageHandler)
+	start := url.QueryEscape("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"fen=" + start + "&arrows=e2e4,d5:blue&lastMove=e2e4&orientation=black", 200},
+		{"", 400},
+		{"fen=not-a-fen", 400},
+		{"fen=" + start + "&arrows=e2e9", 400},
+		{"fen=" + start + "&arrows=e2e4:purple", 400},
+		{"fen=" + start + "&orientation=left", 400},
+		{"fen=" + start + "&lastMove=e2", 400},
+		{"fen=" + start + "&size=10", 400},
+	}
+	for _, tt := range tests {
+		resp, err := app.Test(httptest.NewRequest("GET", "/render/board.svg?"+tt.query, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%q: status %d, want %d", tt.query, resp.StatusCode, tt.want)
+		}
+		if tt.want == 200 && resp.Header.Get("Content-Type") != "image/svg+xml" {
+			t.Errorf("content type %q, want image/svg+xml", resp.Header.Get("Content-Type"))
+		}
+	}
+}
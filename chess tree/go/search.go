@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"chess/ProcessPipline"
+	"chess/Search"
+	"chess/Store"
+	"chess/Types"
+	"github.com/gofiber/fiber/v2"
+)
+
+// searchHandler finds the games of the user that went through a position
+// matching the q query parameter, see the search package for the language,
+// newest games first. It takes the filters of the explorer, and playerColor
+// for the color the user played. A page looks at a bounded number of games,
+// so it can come back short, or empty, with a nextCursor to carry on. Only the
+// first MAX_PLIES plies of a game are stored, the page says how deep in
+// maxPly: a position reached later is not searched, and a query whose move
+// or ply bounds are beyond them is answered 400.
+func searchHandler(searcher store.Searcher) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if searcher == nil {
+			return explorerError(c, store.ErrNoUserData)
+		}
+		query, err := search.Parse(c.Query("q"))
+		if err != nil {
+			var syntax *search.SyntaxError
+			errors.As(err, &syntax)
+			return c.Status(400).JSON(fiber.Map{
+				"error":  err.Error(),
+				"offset": syntax.Offset,
+			})
+		}
+		if !query.Reachable(Processpipline.MaxPlies) {
+			return c.Status(400).JSON(fiber.Map{
+				"error":  fmt.Sprintf("no position within the first %d plies, the ones stored (MAX_PLIES), can match %q", Processpipline.MaxPlies, query),
+				"maxPly": Processpipline.MaxPlies,
+			})
+		}
+		filtered, err := filteredQuery(c, "")
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		q := types.SearchQuery{
+			PositionQuery: filtered,
+			Cursor:        c.Query("cursor"),
+			Limit:         c.QueryInt("limit", defaultGamesPage),
+		}
+		q.Color = c.Query("playerColor")
+		if q.Limit < 1 || q.Limit > maxGamesPage {
+			return c.Status(400).JSON(fiber.Map{
+				"error": fmt.Sprintf("limit must be between 1 and %d", maxGamesPage),
+			})
+		}
+
+		page, err := searcher.SearchPositions(c.Context(), q, query.Match)
+		if errors.Is(err, store.ErrNotFound) {
+			page, err = &types.SearchPage{Games: []types.SearchMatch{}}, nil
+		}
+		if errors.Is(err, store.ErrInvalidCursor) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err != nil {
+			return explorerError(c, err)
+		}
+		return c.Status(200).JSON(page)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"chess/Store"
+	"chess/Types"
+	"github.com/gofiber/fiber/v2"
+)
+
+// countedSearches answers every search with no game and counts them.
+type countedSearches struct {
+	n *int
+}
+
+func (s countedSearches) SearchPositions(ctx context.Context, q types.SearchQuery, match store.MatchFunc) (*types.SearchPage, error) {
+	*s.n++
+	return &types.SearchPage{Games: []types.SearchMatch{}}, nil
+}
+
+// TestSearchBeyondStoredPlies checks a query no stored position can match is
+// answered 400 without searching.
+func TestSearchBeyondStoredPlies(t *testing.T) {
+	var searches int
+	app := fiber.New()
+	app.Get("/search", searchHandler(countedSearches{&searches}))
+
+	for _, tt := range []struct {
+		query string
+		want  int
+	}{
+		{"queens-off and move < 15", 200},
+		{"queens-off and move > 40", 400},
+		{"queens-off or", 400},
+	} {
+		target := "/search?username=" + testUser + "&q=" + url.QueryEscape(tt.query)
+		if status := getJSON(t, app, target, nil); status != tt.want {
+			t.Errorf("%q: status %d, want %d", tt.query, status, tt.want)
+		}
+	}
+	if searches != 1 {
+		t.Errorf("%d searches run, want the reachable query's only", searches)
+	}
+}
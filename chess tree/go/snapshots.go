@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"chess/ProcessPipline"
+	"chess/Store"
+	"chess/Types"
+	"chess/Utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Named snapshots live in snapshotDir so two periods or two users can be
+// compared later, "current" always refers to the live tree.
+const (
+	snapshotDir = "snapshots"
+	currentTree = "current"
+)
+
+func snapshotFile(name string) (string, error) {
+	return namedFile(snapshotDir, name, ".snapshot")
+}
+
+// namedFile resolves a user supplied name inside dir, rejecting anything that
+// could escape it.
+func namedFile(dir, name, ext string) (string, error) {
+	if name == "" || filepath.Base(name) != name || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid name %q", name)
+	}
+	return filepath.Join(dir, name+ext), nil
+}
+
+// loadTree returns a copy of the live tree for "current" and the named
+// snapshot otherwise.
+func loadTree(name string) (map[string]*types.PositonInfo, error) {
+	if name == currentTree {
+		return Processpipline.CopyHashMap(), nil
+	}
+
+	path, err := snapshotFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return Processpipline.ReadSnapshotFile(path)
+}
+
+func saveSnapshotHandler(c *fiber.Ctx) error {
+	name := c.Params("name")
+	if name == currentTree {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "snapshot name is reserved",
+		})
+	}
+	path, err := snapshotFile(name)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err := os.MkdirAll(snapshotDir, 0o755); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err := Processpipline.SaveSnapshot(path); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(201).JSON(fiber.Map{
+		"message": "snapshot saved",
+		"name":    name,
+	})
+}
+
+// diffHandler compares two trees. With a and b it compares the named
+// snapshots, b being the live tree by default. Without them, when the store
+// can build the tree of a user's games, it compares the games of userA between fromA and toA to the
+// games of userB between fromB and toB, userB defaulting to userA. Both sides
+// take the filters of the explorer, and playerColor for the color the user
+// played. The snapshots hold every user's games and are only compared when
+// the server runs open, the users of a period diff must be readable by the
+// caller.
+func diffHandler(guard *access, trees store.Trees) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		minGames := c.QueryInt("minGames", 1)
+		if c.Query("a") == "" && trees != nil {
+			return periodDiff(c, guard, trees, minGames)
+		}
+		if guard.verifier != nil {
+			return forbidden(c, "snapshots hold every user's games, they are only compared without JWT auth")
+		}
+		a, b := c.Query("a"), c.Query("b", currentTree)
+		if a == "" {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "a query param is required",
+			})
+		}
+
+		snapshots := make([]map[string]*types.PositonInfo, 2)
+		for i, name := range []string{a, b} {
+			tree, err := loadTree(name)
+			if errors.Is(err, os.ErrNotExist) {
+				return c.Status(404).JSON(fiber.Map{
+					"error": fmt.Sprintf("snapshot %q not found", name),
+				})
+			}
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			snapshots[i] = tree
+		}
+
+		return c.Status(200).JSON(fiber.Map{
+			"a":    a,
+			"b":    b,
+			"data": Processpipline.Diff(snapshots[0], snapshots[1], minGames),
+		})
+	}
+}
+
+// periodDiff is the diff of two sides replayed from the stored games.
+func periodDiff(c *fiber.Ctx, guard *access, trees store.Trees, minGames int) error {
+	filtered, err := filteredQuery(c, "")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	filtered.Color = c.Query("playerColor")
+
+	sides := make([]types.PositionQuery, 2)
+	for i, side := range []string{"A", "B"} {
+		q := filtered
+		q.Username = c.Query("userA", filtered.Username)
+		if side == "B" {
+			q.Username = c.Query("userB", sides[0].Username)
+		}
+		from, err := queryTime(c, "from"+side, false)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		to, err := queryTime(c, "to"+side, true)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		f := &q.Filters
+		if from != nil {
+			f.From = from
+		}
+		if to != nil {
+			f.To = to
+		}
+		if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": fmt.Sprintf("from%s must be before to%s", side, side),
+			})
+		}
+		sides[i] = q
+	}
+
+	for _, q := range sides {
+		allowed, err := guard.canRead(c, q.Username)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if !allowed {
+			return forbidden(c, q.Username+" does not share their tree")
+		}
+	}
+
+	diffTrees := make([]map[string]*types.PositonInfo, 2)
+	for i, q := range sides {
+		tree, err := trees.Tree(c.Context(), q, utils.ProcessGame)
+		if errors.Is(err, store.ErrNotFound) {
+			tree, err = map[string]*types.PositonInfo{}, nil
+		}
+		if err != nil {
+			return explorerError(c, err)
+		}
+		diffTrees[i] = tree
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"a":    diffSide(sides[0]),
+		"b":    diffSide(sides[1]),
+		"data": Processpipline.Diff(diffTrees[0], diffTrees[1], minGames),
+	})
+}
+
+// diffSide describes a side of a period diff in the answer.
+func diffSide(q types.PositionQuery) fiber.Map {
+	return fiber.Map{
+		"username": q.Username,
+		"from":     q.Filters.From,
+		"to":       q.Filters.To,
+	}
+}
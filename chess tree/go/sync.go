@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"chess/Store"
+	"chess/Types"
+	"chess/Utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxJobErrors bounds the game errors a job keeps, a broken archive
+	// would otherwise store one per game.
+	maxJobErrors = 50
+)
+
+var (
+	errSyncQueueFull    = errors.New("the sync queue is full, try again later")
+	errSyncShuttingDown = errors.New("the server is shutting down, try again later")
+	errSyncInterrupted  = errors.New("interrupted by the server shutting down")
+	chessComUsername    = regexp.MustCompile(`^[A-Za-z0-9_-]{3,25}$`)
+)
+
+// syncer runs the sync jobs in the background, one at a time per worker and
+// at most one per user. The jobs run under ctx, cancelled when a shutdown
+// runs out of time.
+type syncer struct {
+	store   store.PositionStore
+	jobs    store.JobStore
+	cache   *store.Cache
+	queue   chan *types.SyncJob
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+
+	mu      sync.Mutex
+	active  map[string]string
+	feeds   map[string]*jobFeed
+	closing bool
+}
+
+func newSyncer(positionStore store.PositionStore, jobs store.JobStore, cache *store.Cache, workers, queueSize int) *syncer {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &syncer{
+		store:  positionStore,
+		jobs:   jobs,
+		cache:  cache,
+		queue:  make(chan *types.SyncJob, queueSize),
+		ctx:    ctx,
+		cancel: cancel,
+		active: make(map[string]string),
+		feeds:  make(map[string]*jobFeed),
+	}
+	s.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+	return s
+}
+
+// shutdown refuses new jobs and waits for the running and queued ones to
+// finish. Past timeout the jobs left are cancelled and fail.
+func (s *syncer) shutdown(timeout time.Duration) {
+	s.mu.Lock()
+	s.closing = true
+	close(s.queue)
+	s.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return
+	case <-time.After(timeout):
+	}
+	fmt.Println("sync jobs still running after", timeout, "cancelling them")
+	s.cancel()
+	<-drained
+}
+
+// enqueue queues a sync of username, or returns the job already queued or
+// running for them with queued false.
+func (s *syncer) enqueue(ctx context.Context, username string) (job *types.SyncJob, queued bool, err error) {
+	username = strings.ToLower(username)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing {
+		return nil, false, errSyncShuttingDown
+	}
+	if id, busy := s.active[username]; busy {
+		job, err := s.jobs.Job(ctx, id)
+		return job, false, err
+	}
+
+	job = &types.SyncJob{
+		ID:        uuid.NewString(),
+		Username:  username,
+		Status:    types.JobQueued,
+		Errors:    []string{},
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.jobs.CreateJob(ctx, job); err != nil {
+		return nil, false, err
+	}
+	// the worker owns job once queued, the caller gets a copy
+	snapshot := *job
+	snapshot.Errors = []string{}
+	s.feeds[job.ID] = newJobFeed()
+	select {
+	case s.queue <- job:
+	default:
+		// no one follows the job yet, it only fails in the store as
+		// publishing would lock s.mu again
+		delete(s.feeds, job.ID)
+		s.end(job, errSyncQueueFull)
+		return nil, false, errSyncQueueFull
+	}
+	s.active[username] = job.ID
+	return &snapshot, true, nil
+}
+
+func (s *syncer) work() {
+	defer s.workers.Done()
+	for job := range s.queue {
+		started := time.Now().UTC()
+		job.Status = types.JobRunning
+		job.StartedAt = &started
+		s.save(job)
+
+		err := s.sync(s.ctx, job)
+		s.cache.Invalidate(job.Username)
+		if err != nil && s.ctx.Err() != nil {
+			err = errSyncInterrupted
+		}
+
+		s.mu.Lock()
+		delete(s.active, job.Username)
+		s.mu.Unlock()
+		s.finish(job, err)
+	}
+}
+
+// sync fetches the archives of the job's user from the last one synced on and
+// saves their games. A game failing to process is counted and skipped, a game
+// failing to save fails the job like in utils.ParseAllGames.
+func (s *syncer) sync(ctx context.Context, job *types.SyncJob) (err error) {
+	archives, err := utils.FetchArchives(ctx, job.Username)
+	if err != nil {
+		return err
+	}
+	tracker, _ := s.store.(store.Archives)
+	if tracker != nil {
+		last, err := tracker.LastArchive(ctx, job.Username)
+		if err != nil {
+			return err
+		}
+		archives = archivesSince(archives, last)
+	}
+	job.Archives = len(archives)
+	s.save(job)
+
+	run := types.ProcessingLog{
+		Username:    job.Username,
+		ApiEndpoint: utils.ChessComAPI + job.Username + "/games/archives",
+	}
+	logger, _ := s.store.(store.ProcessingLogger)
+	if logger != nil {
+		if run.ID, err = logger.StartProcessing(ctx, run); err != nil {
+			return err
+		}
+		defer func() {
+			run.Status = "completed"
+			if err != nil {
+				run.Status = "failed"
+				run.ErrorMessage = err.Error()
+			}
+			run.TotalGames, run.SuccessCount, run.ErrorCount = job.Fetched, job.Processed, job.Failed
+			if logErr := logger.FinishProcessing(context.WithoutCancel(ctx), run); logErr != nil {
+				fmt.Println("failed to log the sync:", logErr)
+			}
+		}()
+	}
+
+	// the last archive is recorded once its games are written, an archive
+	// that failed to download leaves the ones after it to the next sync
+	var synced string
+	failed := false
+	if tracker != nil {
+		defer func() {
+			if err == nil && synced != "" {
+				err = tracker.SetLastArchive(context.WithoutCancel(ctx), job.Username, synced)
+			}
+		}()
+	}
+
+	// the job saves through a buffer of its own, written when it ends even
+	// when it is interrupted so the games saved so far are kept
+	st := store.Batch(s.store)
+	if f, ok := st.(store.Flusher); ok {
+		defer func() {
+			if flushErr := f.Flush(context.WithoutCancel(ctx)); err == nil {
+				err = flushErr
+			}
+		}()
+	}
+
+	for i, url := range archives {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		games, err := utils.FetchArchive(ctx, url)
+		if err != nil {
+			failed = true
+			s.addError(job, err.Error())
+			s.save(job)
+			continue
+		}
+		job.Fetched += len(games)
+		s.publish(job, eventArchive, archiveEvent{URL: url, Archive: i + 1, Archives: len(archives), Games: len(games)})
+		for _, game := range games {
+			played := time.Unix(game.EndTime, 0).UTC()
+			if run.GamesFromDate == nil || played.Before(*run.GamesFromDate) {
+				run.GamesFromDate = &played
+			}
+			if run.GamesToDate == nil || played.After(*run.GamesToDate) {
+				run.GamesToDate = &played
+			}
+
+			rec, err := utils.ProcessGame(game, job.Username)
+			if err != nil {
+				job.Failed++
+				s.addError(job, fmt.Sprintf("game %s: %v", game.UUID, err))
+				continue
+			}
+			if err := st.SaveGame(ctx, rec); err != nil {
+				job.Failed++
+				return fmt.Errorf("saving game %s: %w", game.UUID, err)
+			}
+			job.Processed++
+			if job.Processed%progressEvery == 0 {
+				s.publishProgress(job)
+			}
+		}
+		if !failed {
+			synced = url
+		}
+		s.save(job)
+		s.cache.Invalidate(job.Username)
+		s.publishProgress(job)
+	}
+
+	return nil
+}
+
+// archivesSince keeps the archives from the month of last on, that month
+// being fetched again as it may have been synced before it ended.
+func archivesSince(archives []string, last string) []string {
+	if last == "" {
+		return archives
+	}
+	month := archiveMonth(last)
+	since := []string{}
+	for _, archive := range archives {
+		if archiveMonth(archive) >= month {
+			since = append(since, archive)
+		}
+	}
+	return since
+}
+
+// archiveMonth is the YYYY/MM an archive url ends with.
+func archiveMonth(url string) string {
+	parts := strings.Split(strings.TrimSuffix(url, "/"), "/")
+	if len(parts) < 2 {
+		return url
+	}
+	return parts[len(parts)-2] + "/" + parts[len(parts)-1]
+}
+
+// finish ends a job and its feed.
+func (s *syncer) finish(job *types.SyncJob, err error) {
+	s.end(job, err)
+	s.publishDone(job)
+}
+
+// end records a job as completed, or failed with err.
+func (s *syncer) end(job *types.SyncJob, err error) {
+	finished := time.Now().UTC()
+	job.FinishedAt = &finished
+	job.Status = types.JobCompleted
+	if err != nil {
+		job.Status = types.JobFailed
+		job.Error = err.Error()
+	}
+	s.save(job)
+}
+
+// save records the progress of a job, a failure is only logged since the
+// sync itself can go on.
+func (s *syncer) save(job *types.SyncJob) {
+	if err := s.jobs.UpdateJob(context.Background(), job); err != nil {
+		fmt.Println("failed to save sync job", job.ID, err)
+	}
+}
+
+// addError records an error of a job and publishes it, past maxJobErrors it
+// is only counted.
+func (s *syncer) addError(job *types.SyncJob, message string) {
+	if len(job.Errors) < maxJobErrors {
+		job.Errors = append(job.Errors, message)
+		s.publish(job, eventError, errorEvent{Message: message})
+	}
+}
+
+// syncHandler queues a sync of the user's games, answering 202 with the new
+// job or 200 with the one already under way.
+func syncHandler(s *syncer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		username := c.Params("username")
+		if !chessComUsername.MatchString(username) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "invalid chess.com username",
+			})
+		}
+		job, queued, err := s.enqueue(c.Context(), username)
+		if errors.Is(err, errSyncQueueFull) || errors.Is(err, errSyncShuttingDown) {
+			return c.Status(503).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		status := 202
+		if !queued {
+			status = 200
+		}
+		c.Location("/jobs/" + job.ID)
+		return c.Status(status).JSON(fiber.Map{
+			"jobId": job.ID,
+			"job":   job,
+		})
+	}
+}
+
+// jobView is a job with how long it ran, or has been running for.
+type jobView struct {
+	*types.SyncJob
+	DurationMs int64 `json:"durationMs"`
+}
+
+func jobHandler(a *access, jobs store.JobStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		job, err := ownJob(c, a, jobs)
+		if job == nil {
+			return err
+		}
+		view := jobView{SyncJob: job}
+		if job.StartedAt != nil {
+			end := time.Now()
+			if job.FinishedAt != nil {
+				end = *job.FinishedAt
+			}
+			view.DurationMs = end.Sub(*job.StartedAt).Milliseconds()
+		}
+		return c.Status(200).JSON(view)
+	}
+}
+
+// ownJob returns the job of the id route parameter when it is the caller's,
+// or answers the request and returns nil.
+func ownJob(c *fiber.Ctx, a *access, jobs store.JobStore) (*types.SyncJob, error) {
+	job, err := jobs.Job(c.Context(), c.Params("id"))
+	if errors.Is(err, store.ErrJobNotFound) {
+		return nil, c.Status(404).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return nil, c.Status(500).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if !a.owns(c, job.Username) {
+		return nil, forbidden(c, "the job is not yours")
+	}
+	return job, nil
+}
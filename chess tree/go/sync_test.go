@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"chess/Store"
+)
+
+func TestArchivesSince(t *testing.T) {
+	const base = "https://api.chess.com/pub/player/alice/games/"
+	archives := []string{base + "2025/11", base + "2025/12", base + "2026/01", base + "2026/02"}
+	tests := []struct {
+		last string
+		want []string
+	}{
+		{"", archives},
+		{base + "2025/12", archives[1:]},
+		// another API base url still names the same months
+		{"http://localhost:8765/alice/games/2026/02", archives[3:]},
+		{base + "2026/03", []string{}},
+	}
+	for _, tt := range tests {
+		if got := archivesSince(archives, tt.last); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("archivesSince(%q) = %v, want %v", tt.last, got, tt.want)
+		}
+	}
+}
+
+func TestEnqueueQueueFull(t *testing.T) {
+	jobs := store.NewMemory()
+	// no worker takes the queued job so the queue stays full
+	s := newSyncer(jobs, jobs, nil, 0, 1)
+	if _, queued, err := s.enqueue(context.Background(), "alice"); err != nil || !queued {
+		t.Fatalf("first job: queued %v, err %v", queued, err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := s.enqueue(context.Background(), "bob")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errSyncQueueFull) {
+			t.Fatalf("err = %v, want errSyncQueueFull", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("enqueue on a full queue did not return")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, busy := s.active["bob"]; busy || len(s.feeds) != 1 {
+		t.Errorf("active %v, %d feeds, want bob neither active nor followed", s.active, len(s.feeds))
+	}
+}
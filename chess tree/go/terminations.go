@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+
+	"chess/ProcessPipline"
+	"chess/Store"
+	"chess/Types"
+	"chess/Utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// treeQuery is the query of the analytics over the user's tree: the filters,
+// the time class and the color of playerColor, both colors when it is empty.
+func treeQuery(c *fiber.Ctx) (types.PositionQuery, error) {
+	q, err := filteredQuery(c, "")
+	q.Color = c.Query("playerColor")
+	return q, err
+}
+
+// userTree replays the games of q into a tree, empty when there are none.
+func userTree(c *fiber.Ctx, trees store.Trees, q types.PositionQuery) (map[string]*types.PositonInfo, error) {
+	tree, err := trees.Tree(c.Context(), q, utils.ProcessGame)
+	if errors.Is(err, store.ErrNotFound) {
+		return map[string]*types.PositonInfo{}, nil
+	}
+	return tree, err
+}
+
+// terminationsHandler returns how the user's games through a position ended,
+// from any move order reaching it.
+func terminationsHandler(trees store.Trees) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if trees == nil {
+			return explorerError(c, store.ErrNoUserData)
+		}
+		q, err := treeQuery(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		tree, err := userTree(c, trees, q)
+		if err != nil {
+			return explorerError(c, err)
+		}
+		fen := c.Query("fen", Processpipline.StartFEN)
+		info, exists := Processpipline.KeyInfo(tree, fen)
+		if !exists {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "position not found",
+			})
+		}
+		return c.Status(200).JSON(fiber.Map{
+			"fen":          fen,
+			"timeClass":    c.Query("timeClass"),
+			"games":        info.Count,
+			"terminations": info.Terminations,
+		})
+	}
+}
+
+// terminationReportHandler ranks the lines of the user's games by a
+// termination and result, by default the ones where they lose on time.
+func terminationReportHandler(trees store.Trees) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		termination, err := types.ParseTerminationName(c.Query("termination", "time"))
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		result := c.Query("result", "loss")
+		switch result {
+		case "win", "loss", "draw":
+		default:
+			return c.Status(400).JSON(fiber.Map{
+				"error": "result must be win, loss or draw",
+			})
+		}
+		q, err := treeQuery(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if trees == nil {
+			return explorerError(c, store.ErrNoUserData)
+		}
+		tree, err := userTree(c, trees, q)
+		if err != nil {
+			return explorerError(c, err)
+		}
+
+		lines := Processpipline.TerminationReport(tree, termination, result,
+			c.QueryInt("minGames", 5), c.QueryInt("limit", 20))
+		return c.Status(200).JSON(fiber.Map{
+			"termination": termination,
+			"result":      result,
+			"timeClass":   c.Query("timeClass"),
+			"data":        lines,
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"chess/Store"
+	"chess/Types"
+	"chess/Utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// playedGame is a chess.com game of white against black, replayed for
+// username like a synced one.
+type playedGame struct {
+	username, white, black string
+	timeClass, result      string
+	termination, movetext  string
+	accuracies             *types.Accuracies
+	eco                    string
+}
+
+// analyticsStore is a SQLite store holding the games, for the routes that
+// replay the user's games into a tree.
+func analyticsStore(t *testing.T, games ...playedGame) *store.SQLite {
+	t.Helper()
+	ctx := context.Background()
+	lite, err := store.NewSQLite(ctx, filepath.Join(t.TempDir(), "analytics.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(lite.Close)
+	for i, g := range games {
+		pgn := fmt.Sprintf("[White %q]\n[Black %q]\n[Result %q]\n[Termination %q]\n[ECO %q]\n\n%s %s",
+			g.white, g.black, g.result, g.termination, g.eco, g.movetext, g.result)
+		rec, err := utils.ProcessGame(&types.Game{
+			UUID:       uuid.NewString(),
+			URL:        fmt.Sprintf("https://www.chess.com/game/live/%d", i),
+			PGN:        pgn,
+			TimeClass:  g.timeClass,
+			EndTime:    int64(1_700_000_000 + i),
+			White:      types.Player{Username: g.white},
+			Black:      types.Player{Username: g.black},
+			Accuracies: g.accuracies,
+		}, g.username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := lite.SaveGame(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return lite
+}
+
+func TestTerminationsHandler(t *testing.T) {
+	lite := analyticsStore(t,
+		playedGame{testUser, testUser, "opponent", "bullet", "0-1", "opponent won on time", "1. e4 e5 2. Nf3 Nc6", nil, ""},
+		playedGame{testUser, testUser, "opponent", "bullet", "1-0", testUser + " won by checkmate", "1. Nf3 e5 2. e4 Nc6", nil, ""},
+		playedGame{testUser, testUser, "opponent", "blitz", "0-1", "opponent won on time", "1. e4 e5 2. Nf3 Nc6", nil, ""},
+		playedGame{"someone", "someone", "opponent", "bullet", "0-1", "opponent won by resignation", "1. e4 e5 2. Nf3 Nc6", nil, ""},
+	)
+	app := fiber.New()
+	app.Get("/terminations", terminationsHandler(lite))
+	app.Get("/terminations/report", terminationReportHandler(lite))
+
+	// the second game transposes, the clocks of its FEN differ
+	fen := url.QueryEscape(fenAfter(t, "e4", "e5", "Nf3", "Nc6"))
+	var body struct {
+		Games        int                                           `json:"games"`
+		Terminations map[types.Termination]*types.TerminationCount `json:"terminations"`
+	}
+	status := getJSON(t, app, "/terminations?username="+testUser+"&timeClass=bullet&fen="+fen, &body)
+	if status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	onTime, mated := body.Terminations[types.TerminationTime], body.Terminations[types.TerminationCheckmate]
+	if body.Games != 2 || len(body.Terminations) != 2 || onTime == nil || onTime.Loss != 1 || mated == nil || mated.Win != 1 {
+		t.Errorf("bullet terminations = %d games %+v, want a loss on time and a win by checkmate", body.Games, body.Terminations)
+	}
+
+	var report struct {
+		Data []types.TerminationLine `json:"data"`
+	}
+	status = getJSON(t, app, "/terminations/report?username="+testUser+"&timeClass=bullet&minGames=1", &report)
+	if status != 200 {
+		t.Fatalf("report status = %d, want 200", status)
+	}
+	for _, line := range report.Data {
+		if line.Count != 1 {
+			t.Errorf("line %s lost %d bullet games on time, want 1", line.Fen, line.Count)
+		}
+	}
+	if len(report.Data) == 0 {
+		t.Error("the report has no line lost on time")
+	}
+
+	for target, want := range map[string]int{
+		"/terminations?username=nobody":                           404,
+		"/terminations?username=" + testUser + "&rated=maybe":     400,
+		"/terminations/report?username=" + testUser + "&result=x": 400,
+	} {
+		if status := getJSON(t, app, target, nil); status != want {
+			t.Errorf("%s: status %d, want %d", target, status, want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"chess/ProcessPipline"
+	"chess/Store"
+	"chess/Types"
+	"chess/Utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// exportUserHandler sends everything stored about a user as a zip archive
+// holding games.pgn and stats.json.
+func exportUserHandler(users store.UserData) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if users == nil {
+			return c.Status(501).JSON(fiber.Map{
+				"error": store.ErrNoUserData.Error(),
+			})
+		}
+		export, err := users.ExportUser(c.Context(), c.Params("username"))
+		if err != nil {
+			return userError(c, err)
+		}
+
+		var buf bytes.Buffer
+		archive := zip.NewWriter(&buf)
+		pgn, err := archive.Create("games.pgn")
+		if err != nil {
+			return userError(c, err)
+		}
+		for _, game := range export.Games {
+			if _, err := pgn.Write([]byte(strings.TrimSpace(game.PGN) + "\n\n")); err != nil {
+				return userError(c, err)
+			}
+		}
+		stats, err := archive.Create("stats.json")
+		if err != nil {
+			return userError(c, err)
+		}
+		enc := json.NewEncoder(stats)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(export); err != nil {
+			return userError(c, err)
+		}
+		if err := archive.Close(); err != nil {
+			return userError(c, err)
+		}
+
+		c.Attachment(export.Username + ".zip")
+		c.Set(fiber.HeaderContentType, "application/zip")
+		return c.Status(200).Send(buf.Bytes())
+	}
+}
+
+// deleteUserHandler deletes a user with their games and stats. The in-memory
+// tree is an aggregate of every user, the games are replayed before the
+// delete to take them out of it and the snapshot at snapshotPath is written
+// again so a restart does not bring them back. With the memory store alone
+// there are no users to delete and it answers 501.
+func deleteUserHandler(users store.UserData, cache *store.Cache, snapshotPath string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if users == nil {
+			return c.Status(501).JSON(fiber.Map{
+				"error": store.ErrNoUserData.Error(),
+			})
+		}
+		actor := requestUser(c)
+		if actor == "" {
+			actor = c.IP()
+		}
+		username := c.Params("username")
+		forgetter, _ := users.(store.Forgetter)
+		var games []*types.GameRecord
+		if forgetter != nil {
+			export, err := users.ExportUser(c.Context(), username)
+			if err != nil {
+				return userError(c, err)
+			}
+			games = replayExport(export)
+		}
+
+		record, err := users.DeleteUser(c.Context(), username, actor)
+		cache.Invalidate(username)
+		if err != nil {
+			return userError(c, err)
+		}
+		if forgetter != nil && forgetter.Forget(c.Context(), games) > 0 {
+			if err := Processpipline.SaveSnapshot(snapshotPath); err != nil {
+				fmt.Println("failed to save the snapshot:", err)
+			}
+		}
+		return c.Status(200).JSON(fiber.Map{
+			"message": "user deleted",
+			"audit":   record,
+		})
+	}
+}
+
+// replayExport turns the exported games back into the records the tree was
+// built from, the games that fail to replay being skipped.
+func replayExport(export *types.UserExport) []*types.GameRecord {
+	recs := make([]*types.GameRecord, 0, len(export.Games))
+	for _, g := range export.Games {
+		game := &types.Game{
+			UUID:        g.ID,
+			URL:         g.Link,
+			PGN:         g.PGN,
+			TimeClass:   g.TimeClass,
+			TimeControl: g.TimeControl,
+			EndTime:     g.PlayedAt.Unix(),
+			White:       types.Player{Username: g.White, Rating: g.WhiteElo},
+			Black:       types.Player{Username: g.Black, Rating: g.BlackElo},
+			ECO:         g.ECO,
+		}
+		if g.WhiteAccuracy != nil || g.BlackAccuracy != nil {
+			game.Accuracies = &types.Accuracies{}
+			if g.WhiteAccuracy != nil {
+				game.Accuracies.White = *g.WhiteAccuracy
+			}
+			if g.BlackAccuracy != nil {
+				game.Accuracies.Black = *g.BlackAccuracy
+			}
+		}
+		rec, err := utils.ProcessGame(game, export.Username)
+		if err != nil {
+			fmt.Println("replay: skipping game", g.Link, err)
+			continue
+		}
+		rec.ID = g.ID
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func userError(c *fiber.Ctx, err error) error {
+	status := 500
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		status = 404
+	case errors.Is(err, store.ErrNoUserData):
+		status = 501
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}